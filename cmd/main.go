@@ -1,6 +1,9 @@
 package main
 
 import (
+	"context"
+	"os"
+
 	"github.com/tuongaz/go-saas/core"
 	"github.com/tuongaz/go-saas/pkg/log"
 )
@@ -11,6 +14,13 @@ func main() {
 		log.Panic("failed to start new app", err)
 	}
 
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if err := app.Migrate(context.Background(), os.Args[2:]); err != nil {
+			log.Panic("failed to migrate", err)
+		}
+		return
+	}
+
 	if err := app.Start(); err != nil {
 		log.Panic("failed to run app", err)
 	}
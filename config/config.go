@@ -19,9 +19,41 @@ type Config struct {
 	ServerPort      string `mapstructure:"GOS_SERVER_PORT" validate:"required,port"`
 	EncryptionKey   string `mapstructure:"GOS_ENCRYPTION_KEY"`
 
-	// Datasource, credentials
+	// Datasource, credentials. SQLite databases are opened with sqlite:<file> or sqlite::memory:
+	// once the application imports the store/sqlite package
 	PostgresDataSource string `mapstructure:"GOS_POSTGRES_DATASOURCE"`
 
+	// PostgresReplicaDataSources are the read replicas, comma separated, reads are routed to them
+	PostgresReplicaDataSources []string `mapstructure:"GOS_POSTGRES_REPLICA_DATASOURCES"`
+
+	// AutoMigrate applies pending migrations when the app starts
+	AutoMigrate bool `mapstructure:"GOS_AUTO_MIGRATE"`
+
+	// Database connection pool, zero keeps the database/sql default. In-memory SQLite
+	// databases are dropped with their last connection, keep the lifetimes at zero for them.
+	DBMaxOpenConns           int `mapstructure:"GOS_DB_MAX_OPEN_CONNS"`
+	DBMaxIdleConns           int `mapstructure:"GOS_DB_MAX_IDLE_CONNS"`
+	DBConnMaxLifetimeSeconds int `mapstructure:"GOS_DB_CONN_MAX_LIFETIME_SECONDS"`
+	DBConnMaxIdleTimeSeconds int `mapstructure:"GOS_DB_CONN_MAX_IDLE_TIME_SECONDS"`
+
+	// DBStatementTimeoutSeconds aborts the Postgres statements running longer, including the
+	// migrations applied at startup, zero disables it
+	DBStatementTimeoutSeconds int `mapstructure:"GOS_DB_STATEMENT_TIMEOUT_SECONDS"`
+
+	// DBConnectRetries is how many times the initial connection is retried when the database
+	// cannot be reached, the delay doubles after each attempt
+	DBConnectRetries           int `mapstructure:"GOS_DB_CONNECT_RETRIES"`
+	DBConnectRetryDelaySeconds int `mapstructure:"GOS_DB_CONNECT_RETRY_DELAY_SECONDS"`
+
+	// Change feed
+	ChangeFeedEnabled bool `mapstructure:"GOS_CHANGE_FEED_ENABLED"`
+	// ChangeFeedTables lists the tables published to the change feed, none when empty
+	ChangeFeedTables         []string `mapstructure:"GOS_CHANGE_FEED_TABLES"`
+	ChangeFeedIncludePayload bool     `mapstructure:"GOS_CHANGE_FEED_INCLUDE_PAYLOAD"`
+	// ChangeFeedExcludedFields are removed from the published payloads, e.g. secrets
+	ChangeFeedExcludedFields []string `mapstructure:"GOS_CHANGE_FEED_EXCLUDED_FIELDS"`
+	ChangeFeedRetentionHours int      `mapstructure:"GOS_CHANGE_FEED_RETENTION_HOURS"`
+
 	// CORS
 	CORSAllowedOrigins   []string `mapstructure:"GOS_CORS_ALLOWED_ORIGINS"`
 	CORSAllowedHeaders   []string `mapstructure:"GOS_CORS_ALLOWED_HEADERS"`
@@ -57,13 +89,29 @@ func New() (*Config, error) {
 	SetDefault("GOS_ENCRYPTION_KEY", defaultEncryptionKey)
 
 	SetDefault("GOS_POSTGRES_DATASOURCE", "")
+	SetDefault("GOS_POSTGRES_REPLICA_DATASOURCES", []string{})
+	SetDefault("GOS_AUTO_MIGRATE", true)
+	SetDefault("GOS_DB_MAX_OPEN_CONNS", 25)
+	SetDefault("GOS_DB_MAX_IDLE_CONNS", 10)
+	SetDefault("GOS_DB_CONN_MAX_LIFETIME_SECONDS", 0)
+	SetDefault("GOS_DB_CONN_MAX_IDLE_TIME_SECONDS", 0)
+	SetDefault("GOS_DB_STATEMENT_TIMEOUT_SECONDS", 0)
+	SetDefault("GOS_DB_CONNECT_RETRIES", 5)
+	SetDefault("GOS_DB_CONNECT_RETRY_DELAY_SECONDS", 1)
 	SetDefault("GOS_EMAIL_FROM", "")
 
+	// Change feed, only the listed tables are published, none by default
+	SetDefault("GOS_CHANGE_FEED_ENABLED", false)
+	SetDefault("GOS_CHANGE_FEED_TABLES", []string{})
+	SetDefault("GOS_CHANGE_FEED_INCLUDE_PAYLOAD", false)
+	SetDefault("GOS_CHANGE_FEED_EXCLUDED_FIELDS", []string{"password", "refresh_token", "access_token", "reset_password_code"})
+	SetDefault("GOS_CHANGE_FEED_RETENTION_HOURS", 24)
+
 	// CORS
 	SetDefault("GOS_CORS_ALLOWED_ORIGINS", []string{"https://*", "http://*"})
 	SetDefault("GOS_CORS_ALLOWED_HEADERS", []string{"*"})
 	SetDefault("GOS_CORS_ALLOWED_METHODS", []string{"GET", "POST", "PUT", "DELETE", "OPTIONS", "PATCH"})
-	SetDefault("GOS_CORS_EXPOSED_HEADERS", []string{"Link"})
+	SetDefault("GOS_CORS_EXPOSED_HEADERS", []string{"Link", "ETag"})
 	SetDefault("GOS_CORS_ALLOW_CREDENTIALS", false)
 	SetDefault("GOS_CORS_MAX_AGE", 300)
 	SetDefault("GOS_JWT_SIGNING_SECRET", "")
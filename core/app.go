@@ -15,10 +15,13 @@ import (
 	"github.com/tuongaz/go-saas/core/auth"
 	"github.com/tuongaz/go-saas/pkg/encrypt"
 	"github.com/tuongaz/go-saas/pkg/hooks"
+	"github.com/tuongaz/go-saas/pkg/httputil"
 	"github.com/tuongaz/go-saas/pkg/log"
 	"github.com/tuongaz/go-saas/server"
 	"github.com/tuongaz/go-saas/service/emailer"
 	"github.com/tuongaz/go-saas/store"
+	"github.com/tuongaz/go-saas/store/changes"
+	"github.com/tuongaz/go-saas/store/migrate"
 )
 
 type Router chi.Router
@@ -36,6 +39,10 @@ type AppInterface interface {
 
 	Config() *config.Config
 
+	// Changes returns the change feed, which delivers the committed record changes of every replica.
+	// It is only started when GOS_CHANGE_FEED_ENABLED is set.
+	Changes() changes.Interface
+
 	// OnBeforeBootstrap returns the hook that is triggered before the app is bootstrapped.
 	OnBeforeBootstrap() *hooks.Hook[*OnBeforeBootstrapEvent]
 
@@ -58,6 +65,10 @@ type AppInterface interface {
 	OnAfterRecordUpdated() *hooks.Hook[*OnAfterRecordUpdatedEvent]
 	OnBeforeRecordDeleted() *hooks.Hook[*OnBeforeRecordDeletedEvent]
 	OnAfterRecordDeleted() *hooks.Hook[*OnAfterRecordDeletedEvent]
+	OnBeforeRecordRestored() *hooks.Hook[*OnBeforeRecordRestoredEvent]
+	OnAfterRecordRestored() *hooks.Hook[*OnAfterRecordRestoredEvent]
+	OnBeforeRecordPurged() *hooks.Hook[*OnBeforeRecordPurgedEvent]
+	OnAfterRecordPurged() *hooks.Hook[*OnAfterRecordPurgedEvent]
 
 	// Collection event hooks
 	OnBeforeOrganisationCreated() *hooks.Hook[*OnBeforeOrganisationCreatedEvent]
@@ -79,6 +90,9 @@ type AppInterface interface {
 
 	PublicRoute(pattern string, fn func(r Router))
 	PrivateRoute(pattern string, fn func(r Router))
+
+	// RegisterCollection exposes a collection through a generated REST API
+	RegisterCollection(table string, rules CollectionRules)
 }
 
 type App struct {
@@ -93,6 +107,7 @@ type App struct {
 	emailer           emailer.Interface
 	server            *server.Server
 	encryptor         encrypt.Interface
+	changes           *changes.Feed
 
 	// Database event hooks
 	onBeforeRecordCreated *hooks.Hook[*OnBeforeRecordCreatedEvent]
@@ -102,6 +117,11 @@ type App struct {
 	onBeforeRecordDeleted *hooks.Hook[*OnBeforeRecordDeletedEvent]
 	onAfterRecordDeleted  *hooks.Hook[*OnAfterRecordDeletedEvent]
 
+	onBeforeRecordRestored *hooks.Hook[*OnBeforeRecordRestoredEvent]
+	onAfterRecordRestored  *hooks.Hook[*OnAfterRecordRestoredEvent]
+	onBeforeRecordPurged   *hooks.Hook[*OnBeforeRecordPurgedEvent]
+	onAfterRecordPurged    *hooks.Hook[*OnAfterRecordPurgedEvent]
+
 	onBeforeOrganisationCreated *hooks.Hook[*OnBeforeOrganisationCreatedEvent]
 	onAfterOrganisationCreated  *hooks.Hook[*OnAfterOrganisationCreatedEvent]
 	onBeforeOrganisationUpdated *hooks.Hook[*OnBeforeOrganisationUpdatedEvent]
@@ -151,6 +171,10 @@ func New(opts ...func(cfg *config.Config)) (*App, error) {
 		onAfterRecordUpdated:        &hooks.Hook[*OnAfterRecordUpdatedEvent]{},
 		onBeforeRecordDeleted:       &hooks.Hook[*OnBeforeRecordDeletedEvent]{},
 		onAfterRecordDeleted:        &hooks.Hook[*OnAfterRecordDeletedEvent]{},
+		onBeforeRecordRestored:      &hooks.Hook[*OnBeforeRecordRestoredEvent]{},
+		onAfterRecordRestored:       &hooks.Hook[*OnAfterRecordRestoredEvent]{},
+		onBeforeRecordPurged:        &hooks.Hook[*OnBeforeRecordPurgedEvent]{},
+		onAfterRecordPurged:         &hooks.Hook[*OnAfterRecordPurgedEvent]{},
 		onBeforeOrganisationCreated: &hooks.Hook[*OnBeforeOrganisationCreatedEvent]{},
 		onAfterOrganisationCreated:  &hooks.Hook[*OnAfterOrganisationCreatedEvent]{},
 		onBeforeOrganisationUpdated: &hooks.Hook[*OnBeforeOrganisationUpdatedEvent]{},
@@ -164,6 +188,7 @@ func New(opts ...func(cfg *config.Config)) (*App, error) {
 		onBeforeAccountDeleted:      &hooks.Hook[*OnBeforeAccountDeletedEvent]{},
 		onAfterAccountDeleted:       &hooks.Hook[*OnAfterAccountDeletedEvent]{},
 		encryptor:                   encryptor,
+		changes:                     changes.New(changes.WithRetention(time.Duration(cfg.ChangeFeedRetentionHours) * time.Hour)),
 		emailer:                     emailService,
 		serverMiddlewares:           []func(http.Handler) http.Handler{},
 	}, nil
@@ -189,6 +214,10 @@ func (a *App) Config() *config.Config {
 	return a.cfg
 }
 
+func (a *App) Changes() changes.Interface {
+	return a.changes
+}
+
 func (a *App) Encryptor() encrypt.Interface {
 	return a.encryptor
 }
@@ -234,6 +263,9 @@ func (a *App) Start() error {
 		w.Write([]byte(fmt.Sprintf(`{"healthy": true, "version": "%s"}`, os.Getenv("COMMIT_HASH"))))
 	})
 
+	// readiness checks the database, e.g. for load balancers to hold traffic while it is unreachable
+	a.server.Router().Get("/ready", a.readyHandler)
+
 	if err := a.OnBeforeServe().Trigger(
 		ctx,
 		&OnBeforeServeEvent{
@@ -280,6 +312,75 @@ func (a *App) Start() error {
 	return nil
 }
 
+// readyCheckTimeout bounds the database ping of the readiness endpoint
+const readyCheckTimeout = 2 * time.Second
+
+// databaseHealth is implemented by the stores backed by a database
+type databaseHealth interface {
+	Ping(ctx context.Context) error
+	Stats() store.PoolStats
+}
+
+// readyHandler reports whether the database can be reached, and the saturation of its connection pool
+func (a *App) readyHandler(w http.ResponseWriter, r *http.Request) {
+	db, ok := a.store.(databaseHealth)
+	if !ok {
+		httputil.New(w).JSON(map[string]any{"ready": true})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), readyCheckTimeout)
+	defer cancel()
+
+	stats := db.Stats()
+	body := map[string]any{
+		"ready": true,
+		"database": map[string]any{
+			"max_open_connections": stats.MaxOpenConnections,
+			"open_connections":     stats.OpenConnections,
+			"in_use":               stats.InUse,
+			"idle":                 stats.Idle,
+			"wait_count":           stats.WaitCount,
+			"wait_duration_ms":     stats.WaitDuration.Milliseconds(),
+			"saturation":           stats.Saturation,
+		},
+	}
+	if err := db.Ping(ctx); err != nil {
+		log.Default().ErrorContext(ctx, "database is not ready", log.ErrorAttr(err))
+		body["ready"] = false
+		httputil.New(w).JSON(body, http.StatusServiceUnavailable)
+		return
+	}
+
+	httputil.New(w).JSON(body)
+}
+
+// storeOptions returns the options of the store configured by the config
+func (a *App) storeOptions() []store.Option {
+	cfg := a.Config()
+	return []store.Option{
+		store.WithPool(store.PoolConfig{
+			MaxOpenConns:    cfg.DBMaxOpenConns,
+			MaxIdleConns:    cfg.DBMaxIdleConns,
+			ConnMaxLifetime: time.Duration(cfg.DBConnMaxLifetimeSeconds) * time.Second,
+			ConnMaxIdleTime: time.Duration(cfg.DBConnMaxIdleTimeSeconds) * time.Second,
+		}),
+		store.WithConnectRetry(cfg.DBConnectRetries, time.Duration(cfg.DBConnectRetryDelaySeconds)*time.Second),
+	}
+}
+
+// Migrate runs a migrate command (up, down, status or redo) against the registered migrations.
+// The statement timeout does not apply, migrations may run long statements.
+func (a *App) Migrate(ctx context.Context, args []string) error {
+	st, err := store.New(a.Config().PostgresDataSource, a.storeOptions()...)
+	if err != nil {
+		return fmt.Errorf("new store: %w", err)
+	}
+	defer st.Close()
+
+	return migrate.RunCommand(ctx, migrate.New(st.DB(), migrate.Sources()...), args, os.Stdout)
+}
+
 func (a *App) Shutdown() error {
 	log.Default().Warn("shutting down app")
 
@@ -305,15 +406,32 @@ func (a *App) bootstrap(ctx context.Context) error {
 	}
 
 	log.Info("bootstrapping database")
-	st, err := store.New(a.Config().PostgresDataSource)
+	opts := append(a.storeOptions(),
+		store.WithReplicas(a.Config().PostgresReplicaDataSources...),
+		store.WithStatementTimeout(time.Duration(a.Config().DBStatementTimeoutSeconds)*time.Second),
+	)
+	st, err := store.New(a.Config().PostgresDataSource, opts...)
 	if err != nil {
 		return fmt.Errorf("new store: %w", err)
 	}
 	a.store = st
 
+	if a.Config().AutoMigrate {
+		if _, err := migrate.New(st.DB(), migrate.Sources()...).Up(ctx); err != nil {
+			return fmt.Errorf("migrate database: %w", err)
+		}
+	}
+
 	// Register database event handler
 	st.AddEventHandler(NewAppDatabaseEventHandler(a))
 
+	if a.Config().ChangeFeedEnabled {
+		if err := a.changes.Start(ctx, st.DB(), a.Config().PostgresDataSource); err != nil {
+			return fmt.Errorf("start change feed: %w", err)
+		}
+		a.registerChangeFeedHooks(st)
+	}
+
 	if err := a.OnDatabaseReady().Trigger(ctx, &OnDatabaseReadyEvent{
 		App: a,
 	}); err != nil {
@@ -328,6 +446,7 @@ func (a *App) bootstrap(ctx context.Context) error {
 	a.auth = authSrv
 
 	a.OnTerminate().Add(func(ctx context.Context, e *OnTerminateEvent) error {
+		a.changes.Stop()
 		a.store.Close()
 
 		return nil
@@ -374,6 +493,22 @@ func (a *App) OnAfterRecordDeleted() *hooks.Hook[*OnAfterRecordDeletedEvent] {
 	return a.onAfterRecordDeleted
 }
 
+func (a *App) OnBeforeRecordRestored() *hooks.Hook[*OnBeforeRecordRestoredEvent] {
+	return a.onBeforeRecordRestored
+}
+
+func (a *App) OnAfterRecordRestored() *hooks.Hook[*OnAfterRecordRestoredEvent] {
+	return a.onAfterRecordRestored
+}
+
+func (a *App) OnBeforeRecordPurged() *hooks.Hook[*OnBeforeRecordPurgedEvent] {
+	return a.onBeforeRecordPurged
+}
+
+func (a *App) OnAfterRecordPurged() *hooks.Hook[*OnAfterRecordPurgedEvent] {
+	return a.onAfterRecordPurged
+}
+
 func (a *App) OnAfterOrganisationCreated() *hooks.Hook[*OnAfterOrganisationCreatedEvent] {
 	return a.onAfterOrganisationCreated
 }
@@ -0,0 +1,65 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/tuongaz/go-saas/store"
+	_ "github.com/tuongaz/go-saas/store/sqlite"
+)
+
+// unreachableStore is a store whose database cannot be reached
+type unreachableStore struct {
+	*store.MemoryStore
+}
+
+func (unreachableStore) Ping(ctx context.Context) error {
+	return errors.New("connection refused")
+}
+
+func (unreachableStore) Stats() store.PoolStats {
+	return store.PoolStats{MaxOpenConnections: 4, OpenConnections: 4, InUse: 4, Saturation: 1}
+}
+
+func TestApp_ReadyHandler(t *testing.T) {
+	ready := func(st store.Interface) (int, map[string]any) {
+		w := httptest.NewRecorder()
+		(&App{store: st}).readyHandler(w, httptest.NewRequest(http.MethodGet, "/ready", nil))
+
+		var body map[string]any
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+		return w.Code, body
+	}
+
+	t.Run("reports the connection pool", func(t *testing.T) {
+		st, err := store.New("sqlite:"+filepath.Join(t.TempDir(), "app.db"), store.WithPool(store.PoolConfig{MaxOpenConns: 5}))
+		assert.NoError(t, err)
+		defer st.Close()
+
+		code, body := ready(st)
+		assert.Equal(t, http.StatusOK, code)
+		assert.Equal(t, true, body["ready"])
+		assert.Equal(t, float64(5), body["database"].(map[string]any)["max_open_connections"])
+		assert.Equal(t, float64(0), body["database"].(map[string]any)["saturation"])
+	})
+
+	t.Run("unavailable when the database cannot be reached", func(t *testing.T) {
+		code, body := ready(unreachableStore{store.NewMemory()})
+		assert.Equal(t, http.StatusServiceUnavailable, code)
+		assert.Equal(t, false, body["ready"])
+		assert.Equal(t, float64(1), body["database"].(map[string]any)["saturation"])
+	})
+
+	t.Run("ready without a database", func(t *testing.T) {
+		code, body := ready(store.NewMemory())
+		assert.Equal(t, http.StatusOK, code)
+		assert.Equal(t, map[string]any{"ready": true}, body)
+	})
+}
@@ -59,6 +59,9 @@ func (s *service) SetupAPI(router *chi.Mux) {
 func (s *service) MeHandler(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 	out, err := s.store.GetAccount(ctx, AccountID(ctx))
+	if err == nil {
+		httputil.SetETag(w, out.Version)
+	}
 	httputil.HandleResponse(ctx, w, out, err)
 }
 
@@ -267,6 +270,9 @@ func (s *service) GetOrganisationHandler(w http.ResponseWriter, r *http.Request)
 	}
 
 	organisation, err := s.store.GetOrganisation(ctx, organisationID)
+	if err == nil {
+		httputil.SetETag(w, organisation.Version)
+	}
 	httputil.HandleResponse(ctx, w, organisation, err)
 }
 
@@ -290,7 +296,17 @@ func (s *service) UpdateOrganisationHandler(w http.ResponseWriter, r *http.Reque
 	// Set the ID from the URL path parameter
 	input.ID = organisationID
 
+	// Reject the update if the organisation changed since the client read it
+	input.Version, err = httputil.IfMatchVersion(r)
+	if err != nil {
+		httputil.HandleResponse(ctx, w, nil, err)
+		return
+	}
+
 	organisation, err := s.store.UpdateOrganisation(ctx, *input)
+	if err == nil {
+		httputil.SetETag(w, organisation.Version)
+	}
 	httputil.HandleResponse(ctx, w, organisation, err)
 }
 
@@ -440,6 +456,12 @@ func (s *service) UpdateAccountHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	ifMatch, err := httputil.IfMatchVersion(r)
+	if err != nil {
+		httputil.HandleResponse(ctx, w, nil, err)
+		return
+	}
+
 	accountID := AccountID(ctx)
 	account, err := s.store.GetAccount(ctx, accountID)
 	if err != nil {
@@ -452,7 +474,16 @@ func (s *service) UpdateAccountHandler(w http.ResponseWriter, r *http.Request) {
 	account.CommunicationEmail = input.CommunicationEmail
 	account.Avatar = input.Avatar
 
+	// Reject the update if the account changed since the client read it,
+	// otherwise since it was read above
+	if ifMatch != nil {
+		account.Version = *ifMatch
+	}
+
 	updatedAccount, err := s.store.UpdateAccount(ctx, accountID, account)
+	if err == nil {
+		httputil.SetETag(w, updatedAccount.Version)
+	}
 	httputil.HandleResponse(ctx, w, updatedAccount, err)
 }
 
@@ -9,6 +9,28 @@ type OnAccountCreatedEvent struct {
 	OrganisationID string
 }
 
+// OnLoginEvent is fired after an account logged in
+type OnLoginEvent struct {
+	AccountID      string
+	OrganisationID string
+	Provider       string
+	Device         string
+}
+
+// OnPasswordChangedEvent is fired after the password of an account was changed or reset
+type OnPasswordChangedEvent struct {
+	AccountID string
+	Reset     bool
+}
+
 func (s *service) OnAccountCreated() *hooks.Hook[*OnAccountCreatedEvent] {
 	return s.onAccountCreated
 }
+
+func (s *service) OnLogin() *hooks.Hook[*OnLoginEvent] {
+	return s.onLogin
+}
+
+func (s *service) OnPasswordChanged() *hooks.Hook[*OnPasswordChangedEvent] {
+	return s.onPasswordChanged
+}
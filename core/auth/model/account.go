@@ -5,21 +5,25 @@ import (
 )
 
 type Account struct {
-	ID                 string    `json:"id"`
-	Name               string    `json:"name"`
-	FirstName          string    `json:"first_name"`
-	LastName           string    `json:"last_name"`
-	Avatar             string    `json:"avatar"`
-	CommunicationEmail string    `json:"communication_email"`
-	CreatedAt          time.Time `json:"created_at"`
-	UpdatedAt          time.Time `json:"updated_at"`
+	ID                 string    `json:"id" db:"id"`
+	Name               string    `json:"name" db:"name"`
+	FirstName          string    `json:"first_name" db:"first_name"`
+	LastName           string    `json:"last_name" db:"last_name"`
+	Avatar             string    `json:"avatar" db:"avatar"`
+	CommunicationEmail string    `json:"communication_email" db:"communication_email"`
+	Version            int64     `json:"version" db:"version"`
+	CreatedAt          time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt          time.Time `json:"updated_at" db:"updated_at"`
 }
 
 type AccountRole struct {
-	ID             string    `json:"id"`
-	AccountID      string    `json:"account_id"`
-	OrganisationID string    `json:"organisation_id"`
-	Role           string    `json:"role"`
-	CreatedAt      time.Time `json:"created_at"`
-	UpdatedAt      time.Time `json:"updated_at"`
+	ID             string    `json:"id" db:"id"`
+	AccountID      string    `json:"account_id" db:"account_id"`
+	OrganisationID string    `json:"organisation_id" db:"organisation_id"`
+	Role           string    `json:"role" db:"role"`
+	CreatedAt      time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at" db:"updated_at"`
+
+	// Account is set when the account relation is expanded
+	Account *Account `json:"account,omitempty" db:"-" relation:"account"`
 }
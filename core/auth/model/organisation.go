@@ -14,6 +14,7 @@ type Organisation struct {
 	Metadata    *json.RawMessage `json:"metadata,omitempty" db:"metadata"`
 	OwnerID     string           `json:"owner_id" db:"owner_id"`
 	IsArchived  bool             `json:"is_archived" db:"is_archived"`
+	Version     int64            `json:"version" db:"version"`
 	CreatedAt   time.Time        `json:"created_at" db:"created_at"`
 	UpdatedAt   time.Time        `json:"updated_at" db:"updated_at"`
 }
@@ -55,6 +55,15 @@ func (s *service) oauth2Authenticate(
 		return nil, err
 	}
 
+	if err := s.OnLogin().Trigger(ctx, &OnLoginEvent{
+		AccountID:      ownerAcc.ID,
+		OrganisationID: org.ID,
+		Provider:       user.Provider,
+		Device:         DeviceFromCtx(ctx),
+	}); err != nil {
+		return nil, fmt.Errorf("trigger on login: %w", err)
+	}
+
 	return s.getAuthenticatedInfo(ctx, accountRole, user.UserID, DeviceFromCtx(ctx))
 }
 
@@ -4,7 +4,9 @@ import (
 	"context"
 
 	"github.com/tuongaz/go-saas/core/auth/model"
+	"github.com/tuongaz/go-saas/pkg/apierror"
 	"github.com/tuongaz/go-saas/pkg/log"
+	coreStore "github.com/tuongaz/go-saas/store"
 )
 
 type PrincipalKey string
@@ -29,9 +31,25 @@ func PrincipalFromCtx(ctx context.Context) model.Principal {
 	return p
 }
 
+// LookupPrincipal returns the principal in context and whether there is one
+func LookupPrincipal(ctx context.Context) (model.Principal, bool) {
+	p, ok := ctx.Value(principalKey).(model.Principal)
+	return p, ok
+}
+
 func AccountID(ctx context.Context) string {
 	return PrincipalFromCtx(ctx).AccountID
 }
 func OrganisationID(ctx context.Context) string {
 	return PrincipalFromCtx(ctx).OrganisationID
 }
+
+// TenantStore returns a view of the store scoped to the organisation of the principal in context
+func TenantStore(ctx context.Context, st coreStore.Interface) (*coreStore.TenantStore, error) {
+	organisationID := OrganisationID(ctx)
+	if organisationID == "" {
+		return nil, apierror.NewUnauthorizedErr("no organisation in context", nil)
+	}
+
+	return st.ForOrganisation(organisationID), nil
+}
@@ -30,6 +30,8 @@ type Interface interface {
 	CreateAccessToken(ctx context.Context, accountRoleID, providerUserID, device string) (*model.AccessToken, error)
 	RefreshToken(ctx context.Context, refreshToken string) (*model.AuthenticatedInfo, error)
 	OnAccountCreated() *hooks.Hook[*OnAccountCreatedEvent]
+	OnLogin() *hooks.Hook[*OnLoginEvent]
+	OnPasswordChanged() *hooks.Hook[*OnPasswordChangedEvent]
 
 	// API Setup
 	SetupAPI(router *chi.Mux)
@@ -73,6 +75,9 @@ type service struct {
 	jwtIssuer        string
 	providers        map[string]config.OAuth2ProviderConfig
 	onAccountCreated *hooks.Hook[*OnAccountCreatedEvent]
+
+	onLogin           *hooks.Hook[*OnLoginEvent]
+	onPasswordChanged *hooks.Hook[*OnPasswordChangedEvent]
 }
 
 func New(cfg *config.Config, emailer emailer.Interface, st coreStore.Interface) (*service, error) {
@@ -91,6 +96,9 @@ func New(cfg *config.Config, emailer emailer.Interface, st coreStore.Interface)
 		providers:        cfg.Oauth2AuthProviders,
 		onAccountCreated: &hooks.Hook[*OnAccountCreatedEvent]{},
 		store:            authStore,
+
+		onLogin:           &hooks.Hook[*OnLoginEvent]{},
+		onPasswordChanged: &hooks.Hook[*OnPasswordChangedEvent]{},
 	}
 
 	return authSrv, nil
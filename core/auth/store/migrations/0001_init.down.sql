@@ -0,0 +1,7 @@
+DROP TABLE IF EXISTS access_token;
+DROP TABLE IF EXISTS login_provider;
+DROP TABLE IF EXISTS organisation_account_role;
+DROP TABLE IF EXISTS organisation;
+DROP TABLE IF EXISTS account;
+DROP TABLE IF EXISTS login_credentials_user_reset_password;
+DROP TABLE IF EXISTS login_credentials_user;
@@ -0,0 +1,5 @@
+ALTER TABLE organisation
+    DROP COLUMN IF EXISTS version;
+
+ALTER TABLE account
+    DROP COLUMN IF EXISTS version;
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS organisation_search_idx;
+
+DROP INDEX IF EXISTS account_search_idx;
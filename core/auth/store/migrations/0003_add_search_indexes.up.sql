@@ -0,0 +1,12 @@
+-- The expressions match the search indexes registered in store.go, so that WithSearch uses them
+CREATE INDEX IF NOT EXISTS organisation_search_idx ON organisation USING GIN ((
+    setweight(to_tsvector('english', coalesce(name, '')), 'A') ||
+    setweight(to_tsvector('english', coalesce(description, '')), 'B')
+));
+
+CREATE INDEX IF NOT EXISTS account_search_idx ON account USING GIN ((
+    setweight(to_tsvector('english', coalesce(name, '')), 'A') ||
+    setweight(to_tsvector('english', coalesce(first_name, '')), 'B') ||
+    setweight(to_tsvector('english', coalesce(last_name, '')), 'B') ||
+    setweight(to_tsvector('english', coalesce(communication_email, '')), 'C')
+));
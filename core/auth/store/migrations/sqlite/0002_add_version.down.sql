@@ -0,0 +1,5 @@
+ALTER TABLE organisation
+    DROP COLUMN version;
+
+ALTER TABLE account
+    DROP COLUMN version;
@@ -0,0 +1,5 @@
+ALTER TABLE organisation
+    ADD COLUMN version BIGINT NOT NULL DEFAULT 1;
+
+ALTER TABLE account
+    ADD COLUMN version BIGINT NOT NULL DEFAULT 1;
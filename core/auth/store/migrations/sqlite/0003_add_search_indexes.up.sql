@@ -0,0 +1 @@
+-- SQLite has no full-text search indexes, WithSearch is only supported on Postgres
@@ -19,6 +19,10 @@ type UpdateOrganisationInput struct {
 	Avatar      *string          `json:"avatar,omitempty"`
 	Metadata    *json.RawMessage `json:"metadata,omitempty"`
 	IsArchived  *bool            `json:"is_archived,omitempty"`
+
+	// Version is the expected version of the organisation, usually from the If-Match header.
+	// When set, the update fails with a ConflictErr if the organisation was modified since.
+	Version *int64 `json:"-"`
 }
 
 // AddOrganisationMemberInput defines the input for adding a member to an Organisation
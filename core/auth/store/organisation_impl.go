@@ -5,12 +5,19 @@ import (
 	"fmt"
 
 	"github.com/tuongaz/go-saas/core/auth/model"
-	"github.com/tuongaz/go-saas/pkg/timer"
 	"github.com/tuongaz/go-saas/pkg/uid"
 	"github.com/tuongaz/go-saas/store"
 	"github.com/tuongaz/go-saas/store/types"
 )
 
+func (s *Store) organisations() *store.Repo[model.Organisation] {
+	return store.NewRepo[model.Organisation](s.store.Collection(TableOrganisation))
+}
+
+func (s *Store) accountRoles() *store.Repo[model.AccountRole] {
+	return store.NewRepo[model.AccountRole](s.store.Collection(TableOrganisationAccountRole))
+}
+
 // ListOrganisationsByAccountID returns all organisations that the account is a member of
 func (s *Store) ListOrganisationsByAccountID(ctx context.Context, accountID string) ([]model.Organisation, error) {
 	query := `
@@ -29,19 +36,8 @@ func (s *Store) ListOrganisationsByAccountID(ctx context.Context, accountID stri
 
 // CreateOrganisation creates a new organisation and adds the owner as a member
 func (s *Store) CreateOrganisation(ctx context.Context, input CreateOrganisationInput) (*model.Organisation, error) {
-	tx, err := s.store.Tx(ctx)
-	if err != nil {
-		return nil, fmt.Errorf("begin tx: %w", err)
-	}
-	defer func() {
-		if err != nil {
-			_ = tx.Rollback()
-		}
-	}()
-
 	// Create the organisation
 	organisationID := uid.ID()
-	now := timer.Now()
 
 	// Create organisation record with required fields
 	orgData := types.Record{
@@ -51,8 +47,6 @@ func (s *Store) CreateOrganisation(ctx context.Context, input CreateOrganisation
 		"avatar":      "",
 		"description": "",
 		"metadata":    "{}",
-		"created_at":  now,
-		"updated_at":  now,
 	}
 
 	// Add optional fields only if they're provided (non-nil pointers)
@@ -68,30 +62,30 @@ func (s *Store) CreateOrganisation(ctx context.Context, input CreateOrganisation
 		orgData["metadata"] = *input.Metadata
 	}
 
-	orgRecord, err := s.store.Collection(TableOrganisation).CreateRecord(ctx, orgData)
-	if err != nil {
-		return nil, fmt.Errorf("create organisation: %w", err)
-	}
+	organisation := &model.Organisation{}
+	err := s.store.WithTx(ctx, nil, func(tx *store.StoreTx) error {
+		orgRecord, err := tx.Collection(TableOrganisation).CreateRecord(ctx, orgData)
+		if err != nil {
+			return fmt.Errorf("create organisation: %w", err)
+		}
 
-	// Add the owner as a member with owner role
-	_, err = s.store.Collection(tableOrganisationAccountRole).CreateRecord(ctx, types.Record{
-		"id":              uid.ID(),
-		"organisation_id": organisationID,
-		"account_id":      input.OwnerID,
-		"role":            string(model.RoleOwner),
-		"created_at":      now,
-		"updated_at":      now,
-	})
-	if err != nil {
-		return nil, fmt.Errorf("add owner to organisation: %w", err)
-	}
+		if err := orgRecord.ScanStruct(organisation); err != nil {
+			return err
+		}
 
-	if err := tx.Commit(); err != nil {
-		return nil, fmt.Errorf("commit tx: %w", err)
-	}
+		// Add the owner as a member with owner role
+		_, err = tx.Collection(TableOrganisationAccountRole).CreateRecord(ctx, types.Record{
+			"organisation_id": organisationID,
+			"account_id":      input.OwnerID,
+			"role":            string(model.RoleOwner),
+		})
+		if err != nil {
+			return fmt.Errorf("add owner to organisation: %w", err)
+		}
 
-	organisation := &model.Organisation{}
-	if err := orgRecord.Decode(organisation); err != nil {
+		return nil
+	})
+	if err != nil {
 		return nil, err
 	}
 
@@ -100,16 +94,11 @@ func (s *Store) CreateOrganisation(ctx context.Context, input CreateOrganisation
 
 // GetOrganisation returns an organisation by ID
 func (s *Store) GetOrganisation(ctx context.Context, organisationID string) (*model.Organisation, error) {
-	record, err := s.store.Collection(TableOrganisation).FindOne(ctx, store.Filter{"id": organisationID})
+	organisation, err := s.organisations().Get(ctx, organisationID)
 	if err != nil {
 		return nil, fmt.Errorf("get organisation: %w", err)
 	}
 
-	organisation := &model.Organisation{}
-	if err := record.Decode(organisation); err != nil {
-		return nil, err
-	}
-
 	return organisation, nil
 }
 
@@ -122,9 +111,7 @@ func (s *Store) UpdateOrganisation(ctx context.Context, input UpdateOrganisation
 	}
 
 	// Create update record with only the fields that are provided
-	updateRecord := types.Record{
-		"updated_at": timer.Now(),
-	}
+	updateRecord := types.Record{}
 
 	// Only update fields that are provided (non-nil pointers)
 	if input.Name != nil {
@@ -148,14 +135,14 @@ func (s *Store) UpdateOrganisation(ctx context.Context, input UpdateOrganisation
 		updateRecord["is_archived"] = *input.IsArchived
 	}
 
-	record, err := s.store.Collection(TableOrganisation).UpdateRecord(ctx, input.ID, updateRecord)
-	if err != nil {
-		return nil, fmt.Errorf("update organisation: %w", err)
+	opts := []store.UpdateOption{store.WithVersionIncrement()}
+	if input.Version != nil {
+		opts = append(opts, store.WithVersion(*input.Version))
 	}
 
-	updatedOrganisation := &model.Organisation{}
-	if err := record.Decode(updatedOrganisation); err != nil {
-		return nil, err
+	updatedOrganisation, err := s.organisations().Patch(ctx, input.ID, updateRecord, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("update organisation: %w", err)
 	}
 
 	return updatedOrganisation, nil
@@ -163,38 +150,25 @@ func (s *Store) UpdateOrganisation(ctx context.Context, input UpdateOrganisation
 
 // DeleteOrganisation deletes an organisation and all its members
 func (s *Store) DeleteOrganisation(ctx context.Context, organisationID string) error {
-	tx, err := s.store.Tx(ctx)
-	if err != nil {
-		return fmt.Errorf("begin tx: %w", err)
-	}
-	defer func() {
-		if err != nil {
-			_ = tx.Rollback()
+	return s.store.WithTx(ctx, nil, func(tx *store.StoreTx) error {
+		// Delete all members
+		if err := tx.Exec(ctx, "DELETE FROM organisation_account_role WHERE organisation_id = $1", organisationID); err != nil {
+			return fmt.Errorf("delete organisation members: %w", err)
 		}
-	}()
-
-	// Delete all members
-	err = tx.Exec(ctx, "DELETE FROM organisation_account_role WHERE organisation_id = $1", organisationID)
-	if err != nil {
-		return fmt.Errorf("delete organisation members: %w", err)
-	}
-
-	// Delete the organisation
-	if err := s.store.Collection(TableOrganisation).DeleteRecord(ctx, organisationID); err != nil {
-		return fmt.Errorf("delete organisation: %w", err)
-	}
 
-	if err := tx.Commit(); err != nil {
-		return fmt.Errorf("commit tx: %w", err)
-	}
+		// Delete the organisation
+		if err := tx.Collection(TableOrganisation).DeleteRecord(ctx, organisationID); err != nil {
+			return fmt.Errorf("delete organisation: %w", err)
+		}
 
-	return nil
+		return nil
+	})
 }
 
 // AddOrganisationMember adds a member to an organisation
 func (s *Store) AddOrganisationMember(ctx context.Context, input AddOrganisationMemberInput) (*model.AccountRole, error) {
 	// Check if the member already exists
-	exists, err := s.store.Collection(tableOrganisationAccountRole).Exists(ctx, store.Filter{
+	exists, err := s.accountRoles().Exists(ctx, store.Filter{
 		"organisation_id": input.OrganisationID,
 		"account_id":      input.AccountID,
 	})
@@ -207,49 +181,36 @@ func (s *Store) AddOrganisationMember(ctx context.Context, input AddOrganisation
 	}
 
 	// Add the member
-	now := timer.Now()
-	record, err := s.store.Collection(tableOrganisationAccountRole).CreateRecord(ctx, types.Record{
-		"id":              uid.ID(),
-		"organisation_id": input.OrganisationID,
-		"account_id":      input.AccountID,
-		"role":            input.Role,
-		"created_at":      now,
-		"updated_at":      now,
+	accountRole, err := s.accountRoles().Create(ctx, model.AccountRole{
+		OrganisationID: input.OrganisationID,
+		AccountID:      input.AccountID,
+		Role:           input.Role,
 	})
 	if err != nil {
 		return nil, fmt.Errorf("add member to organisation: %w", err)
 	}
 
-	accountRole := &model.AccountRole{}
-	if err := record.Decode(accountRole); err != nil {
-		return nil, err
-	}
-
 	return accountRole, nil
 }
 
-// ListOrganisationMembers returns all members of an organisation
+// ListOrganisationMembers returns all members of an organisation, with their account
 func (s *Store) ListOrganisationMembers(ctx context.Context, organisationID string) ([]model.AccountRole, error) {
-	records, err := s.store.Collection(tableOrganisationAccountRole).Find(
+	members, _, err := s.accountRoles().Find(
 		ctx,
 		store.WithFilter(store.Filter{"organisation_id": organisationID}),
+		store.WithExpand("account"),
 	)
 	if err != nil {
 		return nil, fmt.Errorf("list organisation members: %w", err)
 	}
 
-	var members []model.AccountRole
-	if err := records.Decode(&members); err != nil {
-		return nil, err
-	}
-
 	return members, nil
 }
 
 // RemoveOrganisationMember removes a member from an organisation
 func (s *Store) RemoveOrganisationMember(ctx context.Context, organisationID, accountID string) error {
 	// Check if the member exists
-	record, err := s.store.Collection(tableOrganisationAccountRole).FindOne(ctx, store.Filter{
+	accountRole, err := s.accountRoles().FindOne(ctx, store.Filter{
 		"organisation_id": organisationID,
 		"account_id":      accountID,
 	})
@@ -258,17 +219,12 @@ func (s *Store) RemoveOrganisationMember(ctx context.Context, organisationID, ac
 	}
 
 	// Check if the member is the owner
-	accountRole := &model.AccountRole{}
-	if err := record.Decode(accountRole); err != nil {
-		return err
-	}
-
 	if model.Role(accountRole.Role) == model.RoleOwner {
 		return fmt.Errorf("cannot remove the owner from the organisation")
 	}
 
 	// Remove the member
-	if err := s.store.Collection(tableOrganisationAccountRole).DeleteRecord(ctx, accountRole.ID); err != nil {
+	if err := s.accountRoles().Delete(ctx, accountRole.ID); err != nil {
 		return fmt.Errorf("remove member from organisation: %w", err)
 	}
 
@@ -278,7 +234,7 @@ func (s *Store) RemoveOrganisationMember(ctx context.Context, organisationID, ac
 // UpdateOrganisationMemberRole updates a member's role in an organisation
 func (s *Store) UpdateOrganisationMemberRole(ctx context.Context, input UpdateOrganisationMemberRoleInput) (*model.AccountRole, error) {
 	// Check if the member exists
-	record, err := s.store.Collection(tableOrganisationAccountRole).FindOne(ctx, store.Filter{
+	accountRole, err := s.accountRoles().FindOne(ctx, store.Filter{
 		"organisation_id": input.OrganisationID,
 		"account_id":      input.AccountID,
 	})
@@ -287,27 +243,17 @@ func (s *Store) UpdateOrganisationMemberRole(ctx context.Context, input UpdateOr
 	}
 
 	// Check if the member is the owner
-	accountRole := &model.AccountRole{}
-	if err := record.Decode(accountRole); err != nil {
-		return nil, err
-	}
-
 	if model.Role(accountRole.Role) == model.RoleOwner {
 		return nil, fmt.Errorf("cannot change the role of the owner")
 	}
 
 	// Update the member's role
-	record, err = s.store.Collection(tableOrganisationAccountRole).UpdateRecord(ctx, accountRole.ID, types.Record{
-		"role":       input.Role,
-		"updated_at": timer.Now(),
+	accountRole, err = s.accountRoles().Patch(ctx, accountRole.ID, types.Record{
+		"role": input.Role,
 	})
 	if err != nil {
 		return nil, fmt.Errorf("update member role: %w", err)
 	}
 
-	if err := record.Decode(accountRole); err != nil {
-		return nil, err
-	}
-
 	return accountRole, nil
 }
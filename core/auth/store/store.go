@@ -2,7 +2,7 @@ package store
 
 import (
 	"context"
-	_ "embed"
+	"embed"
 	"fmt"
 
 	"github.com/google/uuid"
@@ -11,20 +11,25 @@ import (
 	"github.com/tuongaz/go-saas/pkg/timer"
 	"github.com/tuongaz/go-saas/pkg/uid"
 	"github.com/tuongaz/go-saas/store"
+	"github.com/tuongaz/go-saas/store/migrate"
 	"github.com/tuongaz/go-saas/store/types"
 )
 
-//go:embed postgres.sql
-var postgresSchema string
+//go:embed migrations/*.sql migrations/sqlite/*.sql
+var migrations embed.FS
+
+func init() {
+	migrate.Register("auth", migrations, "migrations")
+}
 
 const (
-	TableAccount      = "account"
-	TableOrganisation = "organisation"
+	TableAccount                 = "account"
+	TableOrganisation            = "organisation"
+	TableOrganisationAccountRole = "organisation_account_role"
 
 	tableLoginCredentialsUser              = "login_credentials_user"
 	tableLoginCredentialsUserResetPassword = "login_credentials_user_reset_password"
 	tableAccessToken                       = "access_token"
-	tableOrganisationAccountRole           = "organisation_account_role"
 	tableLoginProvider                     = "login_provider"
 )
 
@@ -107,22 +112,47 @@ type Interface interface {
 	UpdateOrganisationMemberRole(ctx context.Context, input UpdateOrganisationMemberRoleInput) (*model.AccountRole, error)
 }
 
-func New(store store.Interface) (*Store, error) {
-	if err := store.Exec(context.Background(), postgresSchema); err != nil {
-		return nil, fmt.Errorf("failed to create schema: %w", err)
-	}
-
-	s := &Store{
-		store: store,
-	}
+func New(st store.Interface) (*Store, error) {
+	st.EnableAutoFields(store.StandardAutoFields,
+		TableAccount,
+		TableOrganisation,
+		tableLoginCredentialsUser,
+		tableLoginCredentialsUserResetPassword,
+		tableAccessToken,
+		TableOrganisationAccountRole,
+		tableLoginProvider,
+	)
 
-	// Run migrations
-	db := store.DB()
-	if err := RunMigrations(context.Background(), db); err != nil {
-		return nil, fmt.Errorf("failed to run migrations: %w", err)
-	}
+	// The tenant keys scope the expansions of the collections scoped to an organisation,
+	// e.g. the organisations of an account are limited to the organisation of the collection.
+	// Accounts are not owned by an organisation, so their relations are unscoped.
+	st.RegisterRelations(TableOrganisation,
+		store.Relation{Name: "members", Kind: store.HasMany, Table: TableOrganisationAccountRole, ForeignKey: "organisation_id", TenantKey: "organisation_id"},
+		store.Relation{Name: "owner", Kind: store.BelongsTo, Table: TableAccount, ForeignKey: "owner_id", Unscoped: true},
+	)
+	st.RegisterRelations(TableOrganisationAccountRole,
+		store.Relation{Name: "account", Kind: store.BelongsTo, Table: TableAccount, ForeignKey: "account_id", Unscoped: true},
+		store.Relation{Name: "organisation", Kind: store.BelongsTo, Table: TableOrganisation, ForeignKey: "organisation_id", TenantKey: "id"},
+	)
+	st.RegisterRelations(TableAccount,
+		store.Relation{Name: "organisations", Kind: store.ManyToMany, Table: TableOrganisation, Through: TableOrganisationAccountRole, ForeignKey: "account_id", OtherKey: "organisation_id", TenantKey: "organisation_id"},
+	)
 
-	return s, nil
+	// The GIN indexes of the search indexes are created by the 0003 migration
+	st.RegisterSearch(TableOrganisation, store.SearchIndex{Columns: []store.SearchColumn{
+		{Name: "name", Weight: store.SearchWeightA},
+		{Name: "description", Weight: store.SearchWeightB},
+	}})
+	st.RegisterSearch(TableAccount, store.SearchIndex{Columns: []store.SearchColumn{
+		{Name: "name", Weight: store.SearchWeightA},
+		{Name: "first_name", Weight: store.SearchWeightB},
+		{Name: "last_name", Weight: store.SearchWeightB},
+		{Name: "communication_email", Weight: store.SearchWeightC},
+	}})
+
+	return &Store{
+		store: st,
+	}, nil
 }
 
 type Store struct {
@@ -131,13 +161,10 @@ type Store struct {
 
 func (s *Store) CreateAccessToken(ctx context.Context, input CreateAccessTokenInput) (*model.AccessToken, error) {
 	record, err := s.store.Collection(tableAccessToken).CreateRecord(ctx, types.Record{
-		"id":               uid.ID(),
 		"refresh_token":    input.RefreshToken,
 		"account_role_id":  input.AccountRoleID,
 		"device":           input.Device,
 		"provider_user_id": input.ProviderUserID,
-		"created_at":       timer.Now(),
-		"updated_at":       timer.Now(),
 	})
 	if err != nil {
 		return nil, fmt.Errorf("create access token: %w", err)
@@ -155,7 +182,7 @@ func (s *Store) UpdateRefreshToken(ctx context.Context, id string, refreshToken
 	_, err := s.store.Collection(tableAccessToken).UpdateRecord(
 		ctx,
 		id,
-		types.Record{"refresh_token": refreshToken, "updated_at": timer.Now()},
+		types.Record{"refresh_token": refreshToken},
 	)
 	if err != nil {
 		return fmt.Errorf("update refresh token: %w", err)
@@ -230,16 +257,6 @@ func (s *Store) CreateOwnerAccount(ctx context.Context, input CreateOwnerAccount
 	mAccountRole *model.AccountRole,
 	err error,
 ) {
-	tx, err := s.store.Tx(ctx)
-	if err != nil {
-		return nil, nil, nil, nil, fmt.Errorf("begin tx: %w", err)
-	}
-	defer func() {
-		if err != nil {
-			_ = tx.Rollback()
-		}
-	}()
-
 	var userRecord *types.Record
 	if input.Provider == model.AuthProviderUsernamePassword {
 		found, err := s.LoginCredentialsUserEmailExists(ctx, input.Email)
@@ -258,8 +275,6 @@ func (s *Store) CreateOwnerAccount(ctx context.Context, input CreateOwnerAccount
 			"password":                              input.Password,
 			"reset_password_code":                   "",
 			"reset_password_code_expired_timestamp": nil,
-			"created_at":                            timer.Now(),
-			"updated_at":                            timer.Now(),
 		}
 		input.ProviderUserID = userRecord.Get("id").(string)
 	}
@@ -271,8 +286,6 @@ func (s *Store) CreateOwnerAccount(ctx context.Context, input CreateOwnerAccount
 		"last_name":           input.LastName,
 		"avatar":              input.Avatar,
 		"communication_email": input.Email,
-		"created_at":          timer.Now(),
-		"updated_at":          timer.Now(),
 	}
 
 	orgRecord := types.Record{
@@ -282,21 +295,15 @@ func (s *Store) CreateOwnerAccount(ctx context.Context, input CreateOwnerAccount
 		"avatar":      "",
 		"metadata":    "{}",
 		"owner_id":    accountRecord.Get("id"),
-		"created_at":  timer.Now(),
-		"updated_at":  timer.Now(),
 	}
 
 	accRoleRecord := types.Record{
-		"id":              uid.ID(),
 		"organisation_id": orgRecord.Get("id"),
 		"account_id":      accountRecord.Get("id"),
 		"role":            "OWNER",
-		"created_at":      timer.Now(),
-		"updated_at":      timer.Now(),
 	}
 
 	loginProviderRecord := types.Record{
-		"id":               uid.ID(),
 		"name":             input.Name,
 		"provider":         input.Provider,
 		"provider_user_id": input.ProviderUserID,
@@ -304,18 +311,35 @@ func (s *Store) CreateOwnerAccount(ctx context.Context, input CreateOwnerAccount
 		"avatar":           input.Avatar,
 		"account_id":       accountRecord.Get("id"),
 		"last_login":       timer.Now(),
-		"created_at":       timer.Now(),
-		"updated_at":       timer.Now(),
 	}
 
-	if userRecord != nil {
-		if _, err := tx.Collection(tableLoginCredentialsUser).CreateRecord(ctx, *userRecord); err != nil {
-			return nil, nil, nil, nil, fmt.Errorf("create user: %w", err)
+	err = s.store.WithTx(ctx, nil, func(tx *store.StoreTx) error {
+		if userRecord != nil {
+			if _, err := tx.Collection(tableLoginCredentialsUser).CreateRecord(ctx, *userRecord); err != nil {
+				return fmt.Errorf("create user: %w", err)
+			}
+		}
+
+		if _, err := tx.Collection(TableAccount).CreateRecord(ctx, accountRecord); err != nil {
+			return fmt.Errorf("create account: %w", err)
+		}
+
+		if _, err := tx.Collection(TableOrganisation).CreateRecord(ctx, orgRecord); err != nil {
+			return fmt.Errorf("create organisation: %w", err)
+		}
+
+		if _, err := tx.Collection(TableOrganisationAccountRole).CreateRecord(ctx, accRoleRecord); err != nil {
+			return fmt.Errorf("create account role: %w", err)
+		}
+
+		if _, err := tx.Collection(tableLoginProvider).CreateRecord(ctx, loginProviderRecord); err != nil {
+			return fmt.Errorf("create auth provider: %w", err)
 		}
-	}
 
-	if _, err := tx.Collection(TableAccount).CreateRecord(ctx, accountRecord); err != nil {
-		return nil, nil, nil, nil, fmt.Errorf("create account: %w", err)
+		return nil
+	})
+	if err != nil {
+		return nil, nil, nil, nil, err
 	}
 
 	mAccount = &model.Account{}
@@ -323,37 +347,21 @@ func (s *Store) CreateOwnerAccount(ctx context.Context, input CreateOwnerAccount
 		return nil, nil, nil, nil, err
 	}
 
-	if _, err := tx.Collection(TableOrganisation).CreateRecord(ctx, orgRecord); err != nil {
-		return nil, nil, nil, nil, fmt.Errorf("create organisation: %w", err)
-	}
-
 	mOrg = &model.Organisation{}
 	if err := orgRecord.Decode(mOrg); err != nil {
 		return nil, nil, nil, nil, err
 	}
 
-	if _, err := tx.Collection(tableOrganisationAccountRole).CreateRecord(ctx, accRoleRecord); err != nil {
-		return nil, nil, nil, nil, fmt.Errorf("create account role: %w", err)
-	}
-
 	mAccountRole = &model.AccountRole{}
 	if err := accRoleRecord.Decode(mAccountRole); err != nil {
 		return nil, nil, nil, nil, err
 	}
 
-	if _, err := tx.Collection(tableLoginProvider).CreateRecord(ctx, loginProviderRecord); err != nil {
-		return nil, nil, nil, nil, fmt.Errorf("create auth provider: %w", err)
-	}
-
 	mLoginProvider = &model.LoginProvider{}
 	if err := loginProviderRecord.Decode(mLoginProvider); err != nil {
 		return nil, nil, nil, nil, err
 	}
 
-	if err := tx.Commit(); err != nil {
-		return nil, nil, nil, nil, fmt.Errorf("commit tx: %w", err)
-	}
-
 	return mAccount, mOrg, mLoginProvider, mAccountRole, nil
 }
 
@@ -386,7 +394,7 @@ func (s *Store) GetAccountByLoginProvider(ctx context.Context, provider string,
 }
 
 func (s *Store) GetAccountRoleByID(ctx context.Context, accountRoleID string) (*model.AccountRole, error) {
-	record, err := s.store.Collection(tableOrganisationAccountRole).GetRecord(ctx, accountRoleID)
+	record, err := s.store.Collection(TableOrganisationAccountRole).GetRecord(ctx, accountRoleID)
 	if err != nil {
 		return nil, fmt.Errorf("get account role: %w", err)
 	}
@@ -400,7 +408,7 @@ func (s *Store) GetAccountRoleByID(ctx context.Context, accountRoleID string) (*
 }
 
 func (s *Store) GetAccountRoleByOrgAndAccountID(ctx context.Context, organisationID, accountID string) (*model.AccountRole, error) {
-	record, err := s.store.Collection(tableOrganisationAccountRole).FindOne(ctx, store.Filter{
+	record, err := s.store.Collection(TableOrganisationAccountRole).FindOne(ctx, store.Filter{
 		"organisation_id": organisationID,
 		"account_id":      accountID,
 	})
@@ -417,7 +425,7 @@ func (s *Store) GetAccountRoleByOrgAndAccountID(ctx context.Context, organisatio
 }
 
 func (s *Store) GetOrganisationByAccountIDAndRole(ctx context.Context, accountID, role string) (*model.Organisation, error) {
-	record, err := s.store.Collection(tableOrganisationAccountRole).FindOne(ctx, store.Filter{
+	record, err := s.store.Collection(TableOrganisationAccountRole).FindOne(ctx, store.Filter{
 		"account_id": accountID,
 		"role":       role,
 	})
@@ -432,11 +440,8 @@ func (s *Store) GetOrganisationByAccountIDAndRole(ctx context.Context, accountID
 
 func (s *Store) CreateResetPasswordRequest(ctx context.Context, userID, code string) (*model.ResetPasswordRequest, error) {
 	record, err := s.store.Collection(tableLoginCredentialsUserResetPassword).CreateRecord(ctx, types.Record{
-		"id":         uid.ID(),
-		"user_id":    userID,
-		"code":       code,
-		"created_at": timer.Now(),
-		"updated_at": timer.Now(),
+		"user_id": userID,
+		"code":    code,
 	})
 	if err != nil {
 		return nil, fmt.Errorf("create reset password request: %w", err)
@@ -452,8 +457,7 @@ func (s *Store) CreateResetPasswordRequest(ctx context.Context, userID, code str
 
 func (s *Store) UpdateResetPasswordReceipt(ctx context.Context, id string, receipt string) error {
 	_, err := s.store.Collection(tableLoginCredentialsUserResetPassword).UpdateRecord(ctx, id, types.Record{
-		"receipt":    receipt,
-		"updated_at": timer.Now(),
+		"receipt": receipt,
 	})
 	if err != nil {
 		return fmt.Errorf("update receipt: %w", err)
@@ -478,8 +482,7 @@ func (s *Store) GetResetPasswordRequest(ctx context.Context, code string) (*mode
 
 func (s *Store) UpdateLoginCredentialsUserPassword(ctx context.Context, userID, password string) error {
 	_, err := s.store.Collection(tableLoginCredentialsUser).UpdateRecord(ctx, userID, types.Record{
-		"password":   password,
-		"updated_at": timer.Now(),
+		"password": password,
 	})
 	if err != nil {
 		return fmt.Errorf("update login credentials user password: %w", err)
@@ -513,15 +516,21 @@ func (s *Store) GetLoginProviderByAccountID(ctx context.Context, accountID, prov
 	return loginProvider, nil
 }
 
+// UpdateAccount updates the account.
+// When account.Version is set, the update fails with a ConflictErr if the account was modified since.
 func (s *Store) UpdateAccount(ctx context.Context, accountID string, account *model.Account) (*model.Account, error) {
+	opts := []store.UpdateOption{store.WithVersionIncrement()}
+	if account.Version > 0 {
+		opts = append(opts, store.WithVersion(account.Version))
+	}
+
 	record, err := s.store.Collection(TableAccount).UpdateRecord(ctx, accountID, types.Record{
 		"name":                account.Name,
 		"first_name":          account.FirstName,
 		"last_name":           account.LastName,
 		"avatar":              account.Avatar,
 		"communication_email": account.CommunicationEmail,
-		"updated_at":          timer.Now(),
-	})
+	}, opts...)
 	if err != nil {
 		return nil, fmt.Errorf("update account: %w", err)
 	}
@@ -120,6 +120,18 @@ func (s *service) resetPasswordConfirm(ctx context.Context, input *ResetPassword
 		return fmt.Errorf("auth: reset password confirm - DeleteResetPasswordRequest: %w", err)
 	}
 
+	acc, err := s.store.GetAccountByLoginProvider(ctx, model2.AuthProviderUsernamePassword, req.UserID)
+	if err != nil {
+		return fmt.Errorf("auth: reset password confirm - GetAccountByLoginProvider: %w", err)
+	}
+
+	if err := s.OnPasswordChanged().Trigger(ctx, &OnPasswordChangedEvent{
+		AccountID: acc.ID,
+		Reset:     true,
+	}); err != nil {
+		return fmt.Errorf("auth: reset password confirm - trigger on password changed: %w", err)
+	}
+
 	return nil
 }
 
@@ -216,6 +228,15 @@ func (s *service) loginUsernamePasswordAccount(
 		return nil, fmt.Errorf("get account role: %w", err)
 	}
 
+	if err := s.OnLogin().Trigger(ctx, &OnLoginEvent{
+		AccountID:      acc.ID,
+		OrganisationID: org.ID,
+		Provider:       model2.AuthProviderUsernamePassword,
+		Device:         DeviceFromCtx(ctx),
+	}); err != nil {
+		return nil, fmt.Errorf("trigger on login: %w", err)
+	}
+
 	return s.getAuthenticatedInfo(ctx, accountRole, user.ID, DeviceFromCtx(ctx))
 }
 
@@ -266,6 +287,12 @@ func (s *service) changePassword(ctx context.Context, accountID string, input *C
 		return fmt.Errorf("auth: change password - update password: %w", err)
 	}
 
+	if err := s.OnPasswordChanged().Trigger(ctx, &OnPasswordChangedEvent{
+		AccountID: accountID,
+	}); err != nil {
+		return fmt.Errorf("auth: change password - trigger on password changed: %w", err)
+	}
+
 	return nil
 }
 
@@ -0,0 +1,51 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+
+	authStore "github.com/tuongaz/go-saas/core/auth/store"
+	"github.com/tuongaz/go-saas/pkg/log"
+	"github.com/tuongaz/go-saas/store"
+	"github.com/tuongaz/go-saas/store/changes"
+)
+
+// registerChangeFeedHooks publishes the record changes of the configured tables to the change
+// feed, in the transaction writing them so that a change is published if and only if it commits
+func (a *App) registerChangeFeedHooks(st *store.Store) {
+	tables := a.Config().ChangeFeedTables
+	if len(tables) == 0 {
+		log.Default().Warn("change feed is enabled without tables, set GOS_CHANGE_FEED_TABLES to publish changes")
+		return
+	}
+
+	excluded := a.Config().ChangeFeedExcludedFields
+
+	st.AddTxEventHandler(func(ctx context.Context, tx *store.StoreTx, rc store.RecordChange) error {
+		change := changes.Change{
+			Table:          rc.Table,
+			RecordID:       rc.Record.String("id"),
+			Action:         rc.Action,
+			OrganisationID: rc.Record.String(store.TenantColumn),
+		}
+		if rc.Table == authStore.TableOrganisation {
+			change.OrganisationID = change.RecordID
+		}
+		if a.Config().ChangeFeedIncludePayload {
+			record := maps.Clone(rc.Record)
+			for _, field := range excluded {
+				delete(record, field)
+			}
+
+			payload, err := json.Marshal(record)
+			if err != nil {
+				return fmt.Errorf("encode change payload: %w", err)
+			}
+			change.Payload = payload
+		}
+
+		return a.changes.PublishTx(ctx, tx, change)
+	}, tables...)
+}
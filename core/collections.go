@@ -0,0 +1,158 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	"github.com/tuongaz/go-saas/core/auth/model"
+	"github.com/tuongaz/go-saas/store"
+	"github.com/tuongaz/go-saas/store/filterql"
+	"github.com/tuongaz/go-saas/store/types"
+)
+
+// systemFields are managed by the API and cannot be written by clients
+var systemFields = []string{"id", store.TenantColumn, "version", "created_at", "updated_at", "deleted_at"}
+
+// AccessRule decides if the principal may perform an operation on a collection.
+// The record is nil for list, the input for create and the stored record otherwise.
+// Updates are checked against the stored record and again against the updated record.
+type AccessRule func(ctx context.Context, principal model.Principal, record types.Record) bool
+
+// AllowAuthenticated allows every authenticated principal
+func AllowAuthenticated(ctx context.Context, principal model.Principal, record types.Record) bool {
+	return true
+}
+
+// AllowOwners allows the owners of the organisation
+func AllowOwners(ctx context.Context, principal model.Principal, record types.Record) bool {
+	return principal.Role.IsOwner()
+}
+
+// DenyAll disables an operation
+func DenyAll(ctx context.Context, principal model.Principal, record types.Record) bool {
+	return false
+}
+
+// CollectionRules configures the REST API of a collection registered with RegisterCollection
+type CollectionRules struct {
+	// Path is where the routes are mounted, it defaults to /{table}
+	Path string
+
+	// Fields are the columns exposed by the API, only these can be selected, filtered and sorted
+	Fields []string
+
+	// Writable are the columns clients can set on create and update.
+	// It defaults to Fields without the system fields (id, organisation_id, version and timestamps).
+	Writable []string
+
+	// Global exposes the records of every organisation. Collections are scoped to the organisation
+	// of the principal by default, their table must have an organisation_id column.
+	Global bool
+
+	// DefaultSort is used when the request does not specify a sort
+	DefaultSort []store.SortOption
+
+	// DefaultLimit and MaxLimit bound the page size of the list endpoint, they default to 20 and 100
+	DefaultLimit int
+	MaxLimit     int
+
+	// Access rules of the operations, nil denies the operation.
+	// Use AllowAuthenticated to allow every authenticated principal.
+	List   AccessRule
+	View   AccessRule
+	Create AccessRule
+	Update AccessRule
+	Delete AccessRule
+}
+
+// collectionAPI serves the REST API of a registered collection
+type collectionAPI struct {
+	app      *App
+	table    string
+	rules    CollectionRules
+	fields   map[string]bool
+	writable map[string]bool
+
+	// filterFields is the allowlist of the filter and sort params
+	filterFields filterql.Fields
+}
+
+// RegisterCollection exposes a collection through list, get, create, update and delete endpoints
+// mounted on a private route. Writes go through the collection, so the record hooks fire as usual,
+// records are validated against the schema registered for the table, if any, and the ids and
+// timestamps are set by the auto fields enabled for the table, see store.EnableAutoFields.
+func (a *App) RegisterCollection(table string, rules CollectionRules) {
+	api, err := newCollectionAPI(a, table, rules)
+	if err != nil {
+		panic(fmt.Sprintf("register collection %s: %s", table, err))
+	}
+
+	a.OnBeforeServe().Add(func(ctx context.Context, e *OnBeforeServeEvent) error {
+		if schema := a.Store().Schema(table); schema != nil {
+			// Filter values are converted to the types of the schema fields
+			api.filterFields = filterql.SchemaFields(schema, api.rules.Fields...)
+		}
+		a.PrivateRoute(api.rules.Path, api.routes)
+		return nil
+	})
+}
+
+func newCollectionAPI(a *App, table string, rules CollectionRules) (*collectionAPI, error) {
+	if !store.ValidTableName(table) {
+		return nil, fmt.Errorf("invalid table name")
+	}
+	if len(rules.Fields) == 0 {
+		return nil, fmt.Errorf("no fields")
+	}
+	for _, field := range append(slices.Clone(rules.Fields), rules.Writable...) {
+		if !store.ValidIdentifierName(field) {
+			return nil, fmt.Errorf("invalid field name %s", field)
+		}
+	}
+
+	if rules.Path == "" {
+		rules.Path = "/" + table
+	}
+	if rules.Writable == nil {
+		for _, field := range rules.Fields {
+			if !slices.Contains(systemFields, field) {
+				rules.Writable = append(rules.Writable, field)
+			}
+		}
+	}
+	if rules.DefaultLimit <= 0 {
+		rules.DefaultLimit = 20
+	}
+	if rules.MaxLimit <= 0 {
+		rules.MaxLimit = 100
+	}
+	for _, rule := range []*AccessRule{&rules.List, &rules.View, &rules.Create, &rules.Update, &rules.Delete} {
+		if *rule == nil {
+			*rule = DenyAll
+		}
+	}
+
+	api := &collectionAPI{
+		app:          a,
+		table:        table,
+		rules:        rules,
+		fields:       toSet(rules.Fields),
+		writable:     toSet(rules.Writable),
+		filterFields: filterql.NewFields(rules.Fields...),
+	}
+	if !rules.Global {
+		// The organisation is set from the principal
+		delete(api.writable, store.TenantColumn)
+	}
+
+	return api, nil
+}
+
+func toSet(values []string) map[string]bool {
+	set := make(map[string]bool, len(values))
+	for _, v := range values {
+		set[v] = true
+	}
+	return set
+}
@@ -0,0 +1,375 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/tuongaz/go-saas/core/auth"
+	"github.com/tuongaz/go-saas/pkg/apierror"
+	"github.com/tuongaz/go-saas/pkg/httputil"
+	"github.com/tuongaz/go-saas/pkg/timer"
+	"github.com/tuongaz/go-saas/store"
+	"github.com/tuongaz/go-saas/store/filterql"
+	"github.com/tuongaz/go-saas/store/types"
+)
+
+// reservedParams are the list query params that are not field filters
+var reservedParams = map[string]bool{"filter": true, "fields": true, "sort": true, "limit": true, "offset": true, "cursor": true}
+
+// CollectionListResponse is a page of records of a registered collection
+type CollectionListResponse struct {
+	Records []types.Record `json:"records"`
+	Meta    store.Metadata `json:"meta"`
+}
+
+func (c *collectionAPI) routes(r Router) {
+	r.Get("/", c.ListHandler)
+	r.Post("/", c.CreateHandler)
+	r.Get("/{id}", c.GetHandler)
+	r.Put("/{id}", c.UpdateHandler)
+	r.Patch("/{id}", c.UpdateHandler)
+	r.Delete("/{id}", c.DeleteHandler)
+}
+
+// collection returns the collection, scoped to the organisation of the principal unless it is global
+func (c *collectionAPI) collection(ctx context.Context) (store.CollectionInterface, error) {
+	if c.rules.Global {
+		return c.app.Store().Collection(c.table), nil
+	}
+
+	st, err := auth.TenantStore(ctx, c.app.Store())
+	if err != nil {
+		return nil, err
+	}
+	return st.Collection(c.table), nil
+}
+
+func (c *collectionAPI) authorize(ctx context.Context, rule AccessRule, record types.Record) error {
+	if !rule(ctx, auth.PrincipalFromCtx(ctx), record) {
+		return apierror.NewForbiddenError("you do not have access to this resource", nil)
+	}
+	return nil
+}
+
+// ListHandler lists the records matching the query params.
+// The filter and sort params use the filterql syntax, e.g. ?filter=status='active' && seats>5&sort=-created_at.
+// Other params than filter, fields, sort, limit, offset and cursor match the field of the same name,
+// repeating a param matches any of the values. Pagination uses the cursor unless an offset is given.
+func (c *collectionAPI) ListHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	if err := c.authorize(ctx, c.rules.List, nil); err != nil {
+		httputil.HandleResponse(ctx, w, nil, err)
+		return
+	}
+
+	opts, err := c.parseFindOptions(r)
+	if err != nil {
+		httputil.HandleResponse(ctx, w, nil, err)
+		return
+	}
+
+	coll, err := c.collection(ctx)
+	if err != nil {
+		httputil.HandleResponse(ctx, w, nil, err)
+		return
+	}
+
+	list, err := coll.Find(ctx, opts...)
+	if err != nil {
+		httputil.HandleResponse(ctx, w, nil, err)
+		return
+	}
+
+	httputil.HandleResponse(ctx, w, CollectionListResponse{Records: list.Records, Meta: list.Meta}, nil)
+}
+
+// GetHandler returns a record
+func (c *collectionAPI) GetHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	record, err := c.getRecord(ctx, chi.URLParam(r, "id"), c.rules.View)
+	if err != nil {
+		httputil.HandleResponse(ctx, w, nil, err)
+		return
+	}
+
+	c.respondRecord(ctx, w, record, http.StatusOK)
+}
+
+// CreateHandler creates a record from the writable fields of the request body
+func (c *collectionAPI) CreateHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	input, err := c.parseInput(r)
+	if err != nil {
+		httputil.HandleResponse(ctx, w, nil, err)
+		return
+	}
+
+	if err := c.authorize(ctx, c.rules.Create, input); err != nil {
+		httputil.HandleResponse(ctx, w, nil, err)
+		return
+	}
+
+	coll, err := c.collection(ctx)
+	if err != nil {
+		httputil.HandleResponse(ctx, w, nil, err)
+		return
+	}
+
+	record, err := coll.CreateRecord(ctx, input)
+	if err != nil {
+		httputil.HandleResponse(ctx, w, nil, err)
+		return
+	}
+
+	c.respondRecord(ctx, w, *record, http.StatusCreated)
+}
+
+// UpdateHandler updates the writable fields given in the request body.
+// An If-Match header rejects the update when the record version changed.
+func (c *collectionAPI) UpdateHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	id := chi.URLParam(r, "id")
+
+	input, err := c.parseInput(r)
+	if err != nil {
+		httputil.HandleResponse(ctx, w, nil, err)
+		return
+	}
+	if len(input) == 0 {
+		httputil.HandleResponse(ctx, w, nil, apierror.NewValidationError("no fields to update", nil))
+		return
+	}
+
+	ifMatch, err := httputil.IfMatchVersion(r)
+	if err != nil {
+		httputil.HandleResponse(ctx, w, nil, err)
+		return
+	}
+
+	existing, err := c.getRecord(ctx, id, c.rules.Update)
+	if err != nil {
+		httputil.HandleResponse(ctx, w, nil, err)
+		return
+	}
+
+	var opts []store.UpdateOption
+	if _, ok := existing["version"]; ok {
+		opts = append(opts, store.WithVersionIncrement())
+		if ifMatch != nil {
+			opts = append(opts, store.WithVersion(*ifMatch))
+		}
+	}
+	if c.fields["updated_at"] {
+		input["updated_at"] = timer.Now()
+	}
+
+	// The rule must also allow the record as it will be after the update
+	updated := maps.Clone(existing)
+	maps.Copy(updated, input)
+	if err := c.authorize(ctx, c.rules.Update, updated); err != nil {
+		httputil.HandleResponse(ctx, w, nil, err)
+		return
+	}
+
+	coll, err := c.collection(ctx)
+	if err != nil {
+		httputil.HandleResponse(ctx, w, nil, err)
+		return
+	}
+
+	record, err := coll.UpdateRecord(ctx, id, input, opts...)
+	if err != nil {
+		httputil.HandleResponse(ctx, w, nil, err)
+		return
+	}
+
+	c.respondRecord(ctx, w, *record, http.StatusOK)
+}
+
+// DeleteHandler deletes a record
+func (c *collectionAPI) DeleteHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	id := chi.URLParam(r, "id")
+
+	if _, err := c.getRecord(ctx, id, c.rules.Delete); err != nil {
+		httputil.HandleResponse(ctx, w, nil, err)
+		return
+	}
+
+	coll, err := c.collection(ctx)
+	if err != nil {
+		httputil.HandleResponse(ctx, w, nil, err)
+		return
+	}
+
+	if err := coll.DeleteRecord(ctx, id); err != nil {
+		httputil.HandleResponse(ctx, w, nil, err)
+		return
+	}
+
+	httputil.New(w).NoContent()
+}
+
+// getRecord loads a record and checks the access rule of the operation against it
+func (c *collectionAPI) getRecord(ctx context.Context, id string, rule AccessRule) (types.Record, error) {
+	coll, err := c.collection(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	record, err := coll.GetRecord(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := c.authorize(ctx, rule, *record); err != nil {
+		return nil, err
+	}
+
+	return *record, nil
+}
+
+// respondRecord writes the exposed fields of a record, with its version as ETag
+func (c *collectionAPI) respondRecord(ctx context.Context, w http.ResponseWriter, record types.Record, status int) {
+	if _, ok := record["version"]; ok {
+		httputil.SetETag(w, record.Int64("version"))
+	}
+
+	httputil.HandleResponse(ctx, w, c.project(record), nil, status)
+}
+
+// project keeps the exposed fields of a record
+func (c *collectionAPI) project(record types.Record) types.Record {
+	out := make(types.Record, len(c.fields))
+	for field := range c.fields {
+		if v, ok := record[field]; ok {
+			out[field] = v
+		}
+	}
+	return out
+}
+
+// parseInput decodes the request body, rejecting the fields that are not writable
+func (c *collectionAPI) parseInput(r *http.Request) (types.Record, error) {
+	body, err := httputil.ParseRequestBody[types.Record](r)
+	if err != nil {
+		return nil, err
+	}
+	if body == nil || *body == nil {
+		return types.Record{}, nil
+	}
+
+	for field := range *body {
+		if !c.writable[field] {
+			return nil, apierror.NewValidationError(fmt.Sprintf("field %s is not writable", field), nil)
+		}
+	}
+
+	return *body, nil
+}
+
+// parseFindOptions converts the list query params into find options
+func (c *collectionAPI) parseFindOptions(r *http.Request) ([]store.FindOption, error) {
+	query := r.URL.Query()
+
+	fields := c.rules.Fields
+	if v := query.Get("fields"); v != "" {
+		fields = strings.Split(v, ",")
+		for _, field := range fields {
+			if !c.fields[field] {
+				return nil, apierror.NewValidationError(fmt.Sprintf("unknown field %s", field), nil)
+			}
+		}
+	}
+
+	sort := c.rules.DefaultSort
+	if v := query.Get("sort"); v != "" {
+		var err error
+		if sort, err = filterql.ParseSort(v, c.filterFields); err != nil {
+			return nil, err
+		}
+	}
+
+	limit := c.rules.DefaultLimit
+	if v := query.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			return nil, apierror.NewValidationError("invalid limit", err)
+		}
+		limit = min(n, c.rules.MaxLimit)
+	}
+
+	var conditions []store.FilterExpression
+	if v := query.Get("filter"); v != "" {
+		expr, err := filterql.ParseFilter(v, c.filterFields)
+		if err != nil {
+			return nil, err
+		}
+		conditions = append(conditions, expr)
+	}
+	for _, param := range slices.Sorted(maps.Keys(query)) {
+		if reservedParams[param] {
+			continue
+		}
+		values := query[param]
+		if !c.fields[param] {
+			return nil, apierror.NewValidationError(fmt.Sprintf("cannot filter by %s", param), nil)
+		}
+		if len(values) == 1 {
+			conditions = append(conditions, store.NewCondition(param, store.FilterOpEqual, values[0]))
+			continue
+		}
+		conditions = append(conditions, store.NewCondition(param, store.FilterOpIn, toAnySlice(values)))
+	}
+
+	opts := []store.FindOption{}
+	if len(conditions) > 0 {
+		opts = append(opts, store.WithAndGroup(conditions...))
+	}
+	if len(sort) > 0 {
+		opts = append(opts, store.WithSort(sort...))
+	}
+
+	if v := query.Get("offset"); v != "" {
+		offset, err := strconv.Atoi(v)
+		if err != nil || offset < 0 {
+			return nil, apierror.NewValidationError("invalid offset", err)
+		}
+		opts = append(opts, store.WithPagination(limit, offset))
+	} else {
+		// Keyset pagination needs the sort fields and the id tiebreaker in the selection
+		fields = withFields(fields, "id")
+		for _, s := range sort {
+			fields = withFields(fields, s.Field)
+		}
+		opts = append(opts, store.WithCursor(query.Get("cursor"), limit))
+	}
+
+	return append(opts, store.WithFields(fields...)), nil
+}
+
+// withFields appends field to fields when it is missing
+func withFields(fields []string, field string) []string {
+	if slices.Contains(fields, field) {
+		return fields
+	}
+	return append(slices.Clip(fields), field)
+}
+
+func toAnySlice(values []string) []any {
+	out := make([]any, len(values))
+	for i, v := range values {
+		out[i] = v
+	}
+	return out
+}
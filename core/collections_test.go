@@ -0,0 +1,236 @@
+package core
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/tuongaz/go-saas/core/auth"
+	"github.com/tuongaz/go-saas/core/auth/model"
+	"github.com/tuongaz/go-saas/pkg/apierror"
+	"github.com/tuongaz/go-saas/store"
+	"github.com/tuongaz/go-saas/store/types"
+	mocks "github.com/tuongaz/go-saas/testutils/mocks/store"
+)
+
+func newTestCollectionAPI(t *testing.T, st store.Interface, rules CollectionRules) *collectionAPI {
+	api, err := newCollectionAPI(&App{store: st}, "project", rules)
+	assert.NoError(t, err)
+	return api
+}
+
+func findOptions(opts []store.FindOption) store.FindOptions {
+	var o store.FindOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+func TestNewCollectionAPI(t *testing.T) {
+	api := newTestCollectionAPI(t, nil, CollectionRules{
+		Fields: []string{"id", "organisation_id", "name", "status", "created_at"},
+		List:   AllowAuthenticated,
+	})
+
+	assert.Equal(t, "/project", api.rules.Path)
+	assert.Equal(t, []string{"name", "status"}, api.rules.Writable)
+	assert.Equal(t, 20, api.rules.DefaultLimit)
+	assert.Equal(t, 100, api.rules.MaxLimit)
+
+	// Operations without a rule are denied
+	principal := model.Principal{AccountID: "acc1", OrganisationID: "org1"}
+	assert.True(t, api.rules.List(context.Background(), principal, nil))
+	assert.False(t, api.rules.Delete(context.Background(), principal, types.Record{}))
+
+	_, err := newCollectionAPI(&App{}, "project", CollectionRules{})
+	assert.Error(t, err)
+
+	_, err = newCollectionAPI(&App{}, "project", CollectionRules{Fields: []string{"name; drop"}})
+	assert.Error(t, err)
+}
+
+func TestParseFindOptions(t *testing.T) {
+	api := newTestCollectionAPI(t, nil, CollectionRules{
+		Fields:   []string{"id", "name", "status", "created_at"},
+		MaxLimit: 50,
+	})
+
+	t.Run("filters sort fields and cursor", func(t *testing.T) {
+		r := httptest.NewRequest(http.MethodGet, "/project?fields=name&sort=-created_at,name&status=active&status=draft&name=x&limit=500&cursor=abc", nil)
+		opts, err := api.parseFindOptions(r)
+		assert.NoError(t, err)
+
+		o := findOptions(opts)
+		assert.Equal(t, []string{"name", "id", "created_at"}, o.Fields)
+		assert.Equal(t, []store.SortOption{
+			{Field: "created_at", Direction: store.SortDesc},
+			{Field: "name", Direction: store.SortAsc},
+		}, o.Sort)
+		assert.Equal(t, &store.Cursor{After: "abc", Limit: 50}, o.Cursor)
+		assert.Equal(t, store.NewAndGroup(
+			store.NewCondition("name", store.FilterOpEqual, "x"),
+			store.NewCondition("status", store.FilterOpIn, []any{"active", "draft"}),
+		), o.AdvancedFilter.Expression)
+	})
+
+	t.Run("filter param", func(t *testing.T) {
+		r := httptest.NewRequest(http.MethodGet, "/project?"+url.Values{
+			"filter": {"status='active' || name~'acme'"},
+			"id":     {"p1"},
+		}.Encode(), nil)
+		opts, err := api.parseFindOptions(r)
+		assert.NoError(t, err)
+
+		o := findOptions(opts)
+		assert.Equal(t, store.NewAndGroup(
+			store.NewOrGroup(
+				store.NewCondition("status", store.FilterOpEqual, "active"),
+				store.NewCondition("name", store.FilterOpILike, "%acme%"),
+			),
+			store.NewCondition("id", store.FilterOpEqual, "p1"),
+		), o.AdvancedFilter.Expression)
+	})
+
+	t.Run("offset pagination", func(t *testing.T) {
+		r := httptest.NewRequest(http.MethodGet, "/project?offset=40", nil)
+		opts, err := api.parseFindOptions(r)
+		assert.NoError(t, err)
+
+		o := findOptions(opts)
+		assert.Equal(t, &store.Pagination{Limit: 20, Offset: 40}, o.Pagination)
+		assert.Nil(t, o.Cursor)
+		assert.Equal(t, []string{"id", "name", "status", "created_at"}, o.Fields)
+	})
+
+	for _, query := range []string{"secret=1", "sort=secret", "fields=secret", "limit=0", "offset=-1", "filter=secret%3D1"} {
+		t.Run("rejects "+query, func(t *testing.T) {
+			_, err := api.parseFindOptions(httptest.NewRequest(http.MethodGet, "/project?"+query, nil))
+			var apiErr *apierror.APIError
+			assert.ErrorAs(t, err, &apiErr)
+			assert.Equal(t, http.StatusBadRequest, apiErr.Code)
+		})
+	}
+}
+
+func TestCollectionCreateHandler(t *testing.T) {
+	st := mocks.NewMockInterface(t)
+	coll := mocks.NewMockCollectionInterface(t)
+
+	api := newTestCollectionAPI(t, st, CollectionRules{
+		Fields: []string{"id", "name", "created_at", "updated_at"},
+		Global: true,
+		Create: func(ctx context.Context, principal model.Principal, record types.Record) bool {
+			return record.String("name") != "forbidden"
+		},
+	})
+
+	ctx := auth.PrincipalToCtx(context.Background(), model.Principal{AccountID: "acc1", OrganisationID: "org1"})
+
+	t.Run("creates the record", func(t *testing.T) {
+		st.EXPECT().Collection("project").Return(coll).Once()
+		coll.EXPECT().CreateRecord(mock.Anything, mock.MatchedBy(func(r types.Record) bool {
+			// The auto fields of the collection set the id and timestamps
+			return r.String("name") == "one" && r["id"] == nil && r["created_at"] == nil && r["updated_at"] == nil
+		})).Return(&types.Record{"id": "p1", "name": "one", "secret": "x"}, nil).Once()
+
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodPost, "/project", strings.NewReader(`{"name":"one"}`)).WithContext(ctx)
+		api.CreateHandler(w, r)
+
+		assert.Equal(t, http.StatusCreated, w.Code)
+		assert.JSONEq(t, `{"id":"p1","name":"one"}`, w.Body.String())
+	})
+
+	t.Run("rejects fields that are not writable", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodPost, "/project", strings.NewReader(`{"name":"one","id":"p1"}`)).WithContext(ctx)
+		api.CreateHandler(w, r)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("checks the access rule", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodPost, "/project", strings.NewReader(`{"name":"forbidden"}`)).WithContext(ctx)
+		api.CreateHandler(w, r)
+
+		assert.Equal(t, http.StatusForbidden, w.Code)
+	})
+}
+
+func TestCollectionAPI_MemoryStore(t *testing.T) {
+	st := store.NewMemory()
+	st.EnableAutoFields(store.StandardAutoFields, "project")
+	api := newTestCollectionAPI(t, st, CollectionRules{
+		Fields: []string{"id", "organisation_id", "name", "created_at", "updated_at"},
+		List:   AllowAuthenticated,
+		Create: AllowAuthenticated,
+	})
+
+	org1 := auth.PrincipalToCtx(context.Background(), model.Principal{AccountID: "acc1", OrganisationID: "org1"})
+	org2 := auth.PrincipalToCtx(context.Background(), model.Principal{AccountID: "acc2", OrganisationID: "org2"})
+
+	for _, name := range []string{"beta", "alpha"} {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodPost, "/project", strings.NewReader(`{"name":"`+name+`"}`)).WithContext(org1)
+		api.CreateHandler(w, r)
+		assert.Equal(t, http.StatusCreated, w.Code)
+		assert.Regexp(t, `"created_at":"\d{4}-`, w.Body.String())
+	}
+
+	w := httptest.NewRecorder()
+	api.ListHandler(w, httptest.NewRequest(http.MethodGet, "/project?fields=name&sort=name", nil).WithContext(org1))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"records":[{"id":`)
+	assert.Regexp(t, `"name":"alpha".*"name":"beta"`, w.Body.String())
+
+	w = httptest.NewRecorder()
+	api.ListHandler(w, httptest.NewRequest(http.MethodGet, "/project", nil).WithContext(org2))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"total":0`)
+}
+
+func TestCollectionUpdateHandler(t *testing.T) {
+	ctx := context.Background()
+	st := store.NewMemory()
+	ownerOnly := func(ctx context.Context, principal model.Principal, record types.Record) bool {
+		return record.String("owner_id") == principal.AccountID
+	}
+	api := newTestCollectionAPI(t, st, CollectionRules{
+		Fields:   []string{"id", "organisation_id", "name", "owner_id"},
+		Writable: []string{"name", "owner_id"},
+		Update:   ownerOnly,
+	})
+
+	_, err := st.ForOrganisation("org1").Collection("project").CreateRecord(ctx, types.Record{"id": "p1", "name": "one", "owner_id": "acc1"})
+	assert.NoError(t, err)
+
+	update := func(principal model.Principal, body string) int {
+		rctx := chi.NewRouteContext()
+		rctx.URLParams.Add("id", "p1")
+		ctx := context.WithValue(auth.PrincipalToCtx(ctx, principal), chi.RouteCtxKey, rctx)
+
+		w := httptest.NewRecorder()
+		api.UpdateHandler(w, httptest.NewRequest(http.MethodPatch, "/project/p1", strings.NewReader(body)).WithContext(ctx))
+		return w.Code
+	}
+
+	owner := model.Principal{AccountID: "acc1", OrganisationID: "org1"}
+	assert.Equal(t, http.StatusOK, update(owner, `{"name":"renamed"}`))
+	assert.Equal(t, http.StatusForbidden, update(owner, `{"owner_id":"acc2"}`), "the updated record must pass the rule")
+	assert.Equal(t, http.StatusForbidden, update(model.Principal{AccountID: "acc2", OrganisationID: "org1"}, `{"name":"stolen"}`))
+	assert.Equal(t, http.StatusNotFound, update(model.Principal{AccountID: "acc1", OrganisationID: "org2"}, `{"name":"other org"}`))
+
+	record, err := st.Collection("project").GetRecord(ctx, "p1")
+	assert.NoError(t, err)
+	assert.Equal(t, "renamed", record.Get("name"))
+	assert.Equal(t, "acc1", record.Get("owner_id"))
+}
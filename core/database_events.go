@@ -47,6 +47,26 @@ type OnAfterRecordDeletedEvent struct {
 	DatabaseEvent
 }
 
+// OnBeforeRecordRestoredEvent is fired before a soft deleted record is restored
+type OnBeforeRecordRestoredEvent struct {
+	DatabaseEvent
+}
+
+// OnAfterRecordRestoredEvent is fired after a soft deleted record is restored
+type OnAfterRecordRestoredEvent struct {
+	DatabaseEvent
+}
+
+// OnBeforeRecordPurgedEvent is fired before a soft deleted record is permanently deleted
+type OnBeforeRecordPurgedEvent struct {
+	DatabaseEvent
+}
+
+// OnAfterRecordPurgedEvent is fired after a soft deleted record is permanently deleted
+type OnAfterRecordPurgedEvent struct {
+	DatabaseEvent
+}
+
 type OnBeforeOrganisationCreatedEvent struct {
 	Organisation model.Organisation
 }
@@ -114,6 +134,12 @@ type AppDatabaseEventHandler struct {
 	app *App
 }
 
+var (
+	_ events.Handler        = (*AppDatabaseEventHandler)(nil)
+	_ events.RestoreHandler = (*AppDatabaseEventHandler)(nil)
+	_ events.PurgeHandler   = (*AppDatabaseEventHandler)(nil)
+)
+
 // NewAppDatabaseEventHandler creates a new app database event handler
 func NewAppDatabaseEventHandler(app *App) *AppDatabaseEventHandler {
 	return &AppDatabaseEventHandler{
@@ -125,7 +151,7 @@ func NewAppDatabaseEventHandler(app *App) *AppDatabaseEventHandler {
 func (h *AppDatabaseEventHandler) OnBeforeCreate(ctx context.Context, event *events.OnBeforeRecordCreatedEvent) error {
 	if event.Table == authStore.TableOrganisation {
 		org := model.Organisation{}
-		if err := event.Record.Decode(&org); err != nil {
+		if err := event.Record.ScanStruct(&org); err != nil {
 			return err
 		}
 
@@ -138,7 +164,7 @@ func (h *AppDatabaseEventHandler) OnBeforeCreate(ctx context.Context, event *eve
 
 	if event.Table == authStore.TableAccount {
 		acc := model.Account{}
-		if err := event.Record.Decode(&acc); err != nil {
+		if err := event.Record.ScanStruct(&acc); err != nil {
 			return err
 		}
 
@@ -162,7 +188,7 @@ func (h *AppDatabaseEventHandler) OnBeforeCreate(ctx context.Context, event *eve
 func (h *AppDatabaseEventHandler) OnAfterCreate(ctx context.Context, event *events.OnAfterRecordCreatedEvent) error {
 	if event.Table == authStore.TableOrganisation {
 		org := model.Organisation{}
-		if err := event.Record.Decode(&org); err != nil {
+		if err := event.Record.ScanStruct(&org); err != nil {
 			return err
 		}
 
@@ -175,7 +201,7 @@ func (h *AppDatabaseEventHandler) OnAfterCreate(ctx context.Context, event *even
 
 	if event.Table == authStore.TableAccount {
 		acc := model.Account{}
-		if err := event.Record.Decode(&acc); err != nil {
+		if err := event.Record.ScanStruct(&acc); err != nil {
 			return err
 		}
 
@@ -199,12 +225,12 @@ func (h *AppDatabaseEventHandler) OnAfterCreate(ctx context.Context, event *even
 func (h *AppDatabaseEventHandler) OnBeforeUpdate(ctx context.Context, event *events.OnBeforeRecordUpdatedEvent) error {
 	if event.Table == authStore.TableOrganisation {
 		org := model.Organisation{}
-		if err := event.Record.Decode(&org); err != nil {
+		if err := event.Record.ScanStruct(&org); err != nil {
 			return err
 		}
 
 		oldOrg := model.Organisation{}
-		if err := types.Record(event.OldRecord).Decode(&oldOrg); err != nil {
+		if err := types.Record(event.OldRecord).ScanStruct(&oldOrg); err != nil {
 			return err
 		}
 
@@ -218,12 +244,12 @@ func (h *AppDatabaseEventHandler) OnBeforeUpdate(ctx context.Context, event *eve
 
 	if event.Table == authStore.TableAccount {
 		acc := model.Account{}
-		if err := event.Record.Decode(&acc); err != nil {
+		if err := event.Record.ScanStruct(&acc); err != nil {
 			return err
 		}
 
 		oldAcc := model.Account{}
-		if err := types.Record(event.OldRecord).Decode(&oldAcc); err != nil {
+		if err := types.Record(event.OldRecord).ScanStruct(&oldAcc); err != nil {
 			return err
 		}
 
@@ -249,12 +275,12 @@ func (h *AppDatabaseEventHandler) OnBeforeUpdate(ctx context.Context, event *eve
 func (h *AppDatabaseEventHandler) OnAfterUpdate(ctx context.Context, event *events.OnAfterRecordUpdatedEvent) error {
 	if event.Table == authStore.TableOrganisation {
 		org := model.Organisation{}
-		if err := event.Record.Decode(&org); err != nil {
+		if err := event.Record.ScanStruct(&org); err != nil {
 			return err
 		}
 
 		oldOrg := model.Organisation{}
-		if err := types.Record(event.OldRecord).Decode(&oldOrg); err != nil {
+		if err := types.Record(event.OldRecord).ScanStruct(&oldOrg); err != nil {
 			return err
 		}
 
@@ -268,12 +294,12 @@ func (h *AppDatabaseEventHandler) OnAfterUpdate(ctx context.Context, event *even
 
 	if event.Table == authStore.TableAccount {
 		acc := model.Account{}
-		if err := event.Record.Decode(&acc); err != nil {
+		if err := event.Record.ScanStruct(&acc); err != nil {
 			return err
 		}
 
 		oldAcc := model.Account{}
-		if err := types.Record(event.OldRecord).Decode(&oldAcc); err != nil {
+		if err := types.Record(event.OldRecord).ScanStruct(&oldAcc); err != nil {
 			return err
 		}
 
@@ -299,7 +325,7 @@ func (h *AppDatabaseEventHandler) OnAfterUpdate(ctx context.Context, event *even
 func (h *AppDatabaseEventHandler) OnBeforeDelete(ctx context.Context, event *events.OnBeforeRecordDeletedEvent) error {
 	if event.Table == authStore.TableOrganisation {
 		org := model.Organisation{}
-		if err := event.Record.Decode(&org); err != nil {
+		if err := event.Record.ScanStruct(&org); err != nil {
 			return err
 		}
 
@@ -312,7 +338,7 @@ func (h *AppDatabaseEventHandler) OnBeforeDelete(ctx context.Context, event *eve
 
 	if event.Table == authStore.TableAccount {
 		acc := model.Account{}
-		if err := event.Record.Decode(&acc); err != nil {
+		if err := event.Record.ScanStruct(&acc); err != nil {
 			return err
 		}
 
@@ -336,7 +362,7 @@ func (h *AppDatabaseEventHandler) OnBeforeDelete(ctx context.Context, event *eve
 func (h *AppDatabaseEventHandler) OnAfterDelete(ctx context.Context, event *events.OnAfterRecordDeletedEvent) error {
 	if event.Table == authStore.TableOrganisation {
 		org := model.Organisation{}
-		if err := event.Record.Decode(&org); err != nil {
+		if err := event.Record.ScanStruct(&org); err != nil {
 			return err
 		}
 
@@ -349,7 +375,7 @@ func (h *AppDatabaseEventHandler) OnAfterDelete(ctx context.Context, event *even
 
 	if event.Table == authStore.TableAccount {
 		acc := model.Account{}
-		if err := event.Record.Decode(&acc); err != nil {
+		if err := event.Record.ScanStruct(&acc); err != nil {
 			return err
 		}
 
@@ -368,3 +394,47 @@ func (h *AppDatabaseEventHandler) OnAfterDelete(ctx context.Context, event *even
 	}
 	return h.app.onAfterRecordDeleted.Trigger(ctx, coreEvent)
 }
+
+// OnBeforeRestore is called before a soft deleted record is restored
+func (h *AppDatabaseEventHandler) OnBeforeRestore(ctx context.Context, event *events.OnBeforeRecordRestoredEvent) error {
+	coreEvent := &OnBeforeRecordRestoredEvent{
+		DatabaseEvent: DatabaseEvent{
+			Table:  event.Table,
+			Record: event.Record,
+		},
+	}
+	return h.app.onBeforeRecordRestored.Trigger(ctx, coreEvent)
+}
+
+// OnAfterRestore is called after a soft deleted record is restored
+func (h *AppDatabaseEventHandler) OnAfterRestore(ctx context.Context, event *events.OnAfterRecordRestoredEvent) error {
+	coreEvent := &OnAfterRecordRestoredEvent{
+		DatabaseEvent: DatabaseEvent{
+			Table:  event.Table,
+			Record: event.Record,
+		},
+	}
+	return h.app.onAfterRecordRestored.Trigger(ctx, coreEvent)
+}
+
+// OnBeforePurge is called before a soft deleted record is permanently deleted
+func (h *AppDatabaseEventHandler) OnBeforePurge(ctx context.Context, event *events.OnBeforeRecordPurgedEvent) error {
+	coreEvent := &OnBeforeRecordPurgedEvent{
+		DatabaseEvent: DatabaseEvent{
+			Table:  event.Table,
+			Record: event.Record,
+		},
+	}
+	return h.app.onBeforeRecordPurged.Trigger(ctx, coreEvent)
+}
+
+// OnAfterPurge is called after a soft deleted record is permanently deleted
+func (h *AppDatabaseEventHandler) OnAfterPurge(ctx context.Context, event *events.OnAfterRecordPurgedEvent) error {
+	coreEvent := &OnAfterRecordPurgedEvent{
+		DatabaseEvent: DatabaseEvent{
+			Table:  event.Table,
+			Record: event.Record,
+		},
+	}
+	return h.app.onAfterRecordPurged.Trigger(ctx, coreEvent)
+}
@@ -0,0 +1,41 @@
+package httputil
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/tuongaz/go-saas/pkg/apierror"
+)
+
+// ETag formats a record version as a strong entity tag
+func ETag(version int64) string {
+	return strconv.Quote(strconv.FormatInt(version, 10))
+}
+
+// SetETag sets the ETag header of the response to the record version
+func SetETag(w http.ResponseWriter, version int64) {
+	w.Header().Set("ETag", ETag(version))
+}
+
+// IfMatchVersion returns the record version of the If-Match request header.
+// It returns nil when the header is absent or "*".
+func IfMatchVersion(r *http.Request) (*int64, error) {
+	header := strings.TrimSpace(r.Header.Get("If-Match"))
+	if header == "" || header == "*" {
+		return nil, nil
+	}
+
+	tag, err := strconv.Unquote(strings.TrimPrefix(header, "W/"))
+	if err != nil {
+		return nil, apierror.NewValidationError("Invalid If-Match header", fmt.Errorf("unquote If-Match header: %w", err))
+	}
+
+	version, err := strconv.ParseInt(tag, 10, 64)
+	if err != nil {
+		return nil, apierror.NewValidationError("Invalid If-Match header", fmt.Errorf("parse If-Match version: %w", err))
+	}
+
+	return &version, nil
+}
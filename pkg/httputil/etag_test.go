@@ -0,0 +1,50 @@
+package httputil
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestIfMatchVersion(t *testing.T) {
+	tests := []struct {
+		header  string
+		want    int64
+		wantNil bool
+		wantErr bool
+	}{
+		{header: "", wantNil: true},
+		{header: "*", wantNil: true},
+		{header: ETag(3), want: 3},
+		{header: `W/"7"`, want: 7},
+		{header: "3", wantErr: true},
+		{header: `"abc"`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest("PUT", "/", nil)
+		if tt.header != "" {
+			req.Header.Set("If-Match", tt.header)
+		}
+
+		got, err := IfMatchVersion(req)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("IfMatchVersion(%q) should have returned an error", tt.header)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("IfMatchVersion(%q) returned an error: %v", tt.header, err)
+			continue
+		}
+		if tt.wantNil {
+			if got != nil {
+				t.Errorf("IfMatchVersion(%q) = %v, want nil", tt.header, *got)
+			}
+			continue
+		}
+		if got == nil || *got != tt.want {
+			t.Errorf("IfMatchVersion(%q) = %v, want %v", tt.header, got, tt.want)
+		}
+	}
+}
@@ -2,6 +2,7 @@ package httputil
 
 import (
 	"context"
+	"database/sql"
 	"encoding/json"
 	"errors"
 	"net/http"
@@ -16,11 +17,31 @@ type Response struct {
 }
 
 func (r *Response) Error(ctx context.Context, err error) {
-	if store.IsNotFoundError(err) {
+	if store.IsNotFoundError(err) || errors.Is(err, sql.ErrNoRows) {
 		r.JSON(map[string]string{"message": "not found"}, http.StatusNotFound)
 		return
 	}
 
+	if store.IsConflictError(err) {
+		r.JSON(map[string]string{"message": "the resource was modified, reload it and try again"}, http.StatusConflict)
+		return
+	}
+
+	if store.IsTenantError(err) {
+		r.JSON(map[string]string{"message": "forbidden"}, http.StatusForbidden)
+		return
+	}
+
+	var validationErr *store.ValidationErr
+	if errors.As(err, &validationErr) {
+		r.JSON(apierror.APIError{
+			Code:    http.StatusBadRequest,
+			Message: "invalid " + validationErr.Table + " record",
+			Data:    map[string]any{"fields": validationErr.Fields},
+		}, http.StatusBadRequest)
+		return
+	}
+
 	log.Default().ErrorContext(ctx, "internal server error", log.ErrorAttr(err))
 	r.JSON(map[string]string{"message": "internal server error"}, http.StatusInternalServerError)
 }
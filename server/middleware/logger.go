@@ -0,0 +1,41 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+// redactedQueryParams are the query parameters holding credentials, e.g. the access token
+// of the realtime connections, which browsers cannot send in a header
+var redactedQueryParams = []string{"access_token"}
+
+// Logger logs the requests like the chi logger, without the credentials of the query string
+func Logger(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		middleware.Logger(http.HandlerFunc(func(w http.ResponseWriter, logged *http.Request) {
+			// The handlers get the request as sent, with the log entry of the logger
+			next.ServeHTTP(w, r.WithContext(logged.Context()))
+		})).ServeHTTP(w, redactQuery(r))
+	})
+}
+
+// redactQuery returns a copy of the request with the credentials of the query string redacted
+func redactQuery(r *http.Request) *http.Request {
+	query := r.URL.Query()
+	redacted := false
+	for _, param := range redactedQueryParams {
+		if query.Has(param) {
+			query.Set(param, "REDACTED")
+			redacted = true
+		}
+	}
+	if !redacted {
+		return r
+	}
+
+	r = r.Clone(r.Context())
+	r.URL.RawQuery = query.Encode()
+	r.RequestURI = r.URL.RequestURI()
+	return r
+}
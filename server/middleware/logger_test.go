@@ -0,0 +1,31 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRedactQuery(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/realtime/sse?subscribe=project&access_token=secret", nil)
+	redacted := redactQuery(r)
+	assert.Equal(t, "/realtime/sse?access_token=REDACTED&subscribe=project", redacted.RequestURI)
+	assert.Equal(t, "secret", r.URL.Query().Get("access_token"))
+
+	r = httptest.NewRequest(http.MethodGet, "/health?verbose=1", nil)
+	assert.Same(t, r, redactQuery(r))
+}
+
+func TestLogger(t *testing.T) {
+	var got *http.Request
+	handler := Logger(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r
+	}))
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/realtime/sse?access_token=secret", nil))
+	if assert.NotNil(t, got) {
+		assert.Equal(t, "secret", got.URL.Query().Get("access_token"))
+	}
+}
@@ -24,7 +24,7 @@ type Server struct {
 
 func New(cfg *config.Config, middlewares ...func(http.Handler) http.Handler) *Server {
 	r := chi.NewRouter()
-	r.Use(middleware.Logger)
+	r.Use(serverMiddleware.Logger)
 	r.Use(middleware.Recoverer)
 	r.Use(cors.Handler(cors.Options{
 		AllowedOrigins:   cfg.CORSAllowedOrigins,
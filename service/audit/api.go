@@ -0,0 +1,77 @@
+package audit
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/tuongaz/go-saas/core/auth"
+	"github.com/tuongaz/go-saas/pkg/apierror"
+	"github.com/tuongaz/go-saas/pkg/httputil"
+	"github.com/tuongaz/go-saas/store"
+)
+
+// ListResponse is a page of audit log entries
+type ListResponse struct {
+	Entries []Entry        `json:"entries"`
+	Meta    store.Metadata `json:"meta"`
+}
+
+// ListHandler lists the audit log of the organisation of the principal, newest first.
+// Only owners can read the audit log.
+func (s *Service) ListHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	principal := auth.PrincipalFromCtx(ctx)
+	if !principal.Role.IsOwner() {
+		httputil.HandleResponse(ctx, w, nil, apierror.NewForbiddenError("only owners can read the audit log", nil))
+		return
+	}
+
+	filter, err := parseListFilter(r)
+	if err != nil {
+		httputil.HandleResponse(ctx, w, nil, err)
+		return
+	}
+
+	entries, meta, err := s.List(ctx, principal.OrganisationID, filter)
+	if err != nil {
+		httputil.HandleResponse(ctx, w, nil, err)
+		return
+	}
+
+	httputil.HandleResponse(ctx, w, ListResponse{Entries: entries, Meta: meta}, nil)
+}
+
+func parseListFilter(r *http.Request) (ListFilter, error) {
+	query := r.URL.Query()
+	filter := ListFilter{
+		Table:          query.Get("table"),
+		RecordID:       query.Get("record_id"),
+		ActorAccountID: query.Get("actor_account_id"),
+		Action:         query.Get("action"),
+		Cursor:         query.Get("cursor"),
+	}
+
+	if v := query.Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil {
+			return filter, apierror.NewValidationError("invalid limit", err)
+		}
+		filter.Limit = limit
+	}
+
+	for name, dst := range map[string]**time.Time{"from": &filter.From, "to": &filter.To} {
+		v := query.Get(name)
+		if v == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			return filter, apierror.NewValidationError("invalid "+name+", expected an RFC3339 time", err)
+		}
+		*dst = &t
+	}
+
+	return filter, nil
+}
@@ -0,0 +1,346 @@
+package audit
+
+import (
+	"context"
+	"embed"
+	"fmt"
+	"time"
+
+	"github.com/tuongaz/go-saas/core"
+	"github.com/tuongaz/go-saas/core/auth"
+	authStore "github.com/tuongaz/go-saas/core/auth/store"
+	"github.com/tuongaz/go-saas/pkg/log"
+	"github.com/tuongaz/go-saas/pkg/timer"
+	"github.com/tuongaz/go-saas/pkg/uid"
+	"github.com/tuongaz/go-saas/service/scheduler"
+	"github.com/tuongaz/go-saas/store"
+	"github.com/tuongaz/go-saas/store/migrate"
+	"github.com/tuongaz/go-saas/store/types"
+)
+
+//go:embed migrations/*.sql
+var migrations embed.FS
+
+func init() {
+	migrate.Register("audit", migrations, "migrations")
+}
+
+const (
+	tableAuditLog = "audit_log"
+
+	ActionCreate         = "create"
+	ActionUpdate         = "update"
+	ActionDelete         = "delete"
+	ActionRestore        = "restore"
+	ActionPurge          = "purge"
+	ActionLogin          = "login"
+	ActionPasswordChange = "password_change"
+	ActionRoleChange     = "role_change"
+)
+
+var _ Interface = &Service{}
+
+// Interface is the audit log.
+// Changes of the configured collections and auth actions are recorded automatically,
+// Record can be used to add application specific entries.
+type Interface interface {
+	Record(ctx context.Context, entry Entry) error
+	List(ctx context.Context, organisationID string, filter ListFilter) ([]Entry, store.Metadata, error)
+	PurgeBefore(ctx context.Context, t time.Time) (int64, error)
+}
+
+// Entry is an audit log entry
+type Entry struct {
+	ID             string            `json:"id" db:"id"`
+	OrganisationID string            `json:"organisation_id" db:"organisation_id"`
+	ActorAccountID string            `json:"actor_account_id" db:"actor_account_id"`
+	Table          string            `json:"table" db:"table_name"`
+	RecordID       string            `json:"record_id" db:"record_id"`
+	Action         string            `json:"action" db:"action"`
+	Changes        map[string]Change `json:"changes" db:"changes"`
+	CreatedAt      time.Time         `json:"created_at" db:"created_at"`
+}
+
+// ListFilter filters the entries returned by List, empty fields are ignored
+type ListFilter struct {
+	Table          string
+	RecordID       string
+	ActorAccountID string
+	Action         string
+	From           *time.Time
+	To             *time.Time
+	Cursor         string
+	Limit          int
+}
+
+type Service struct {
+	app      core.AppInterface
+	cfg      *Config
+	tables   map[string]bool
+	redacted map[string]bool
+}
+
+func MustRegister(app core.AppInterface, sched scheduler.Interface) *Service {
+	cfg, err := newConfig()
+	if err != nil {
+		panic(fmt.Errorf("new audit config: %w", err))
+	}
+
+	s := &Service{
+		app:      app,
+		cfg:      cfg,
+		tables:   toSet(cfg.Tables),
+		redacted: toSet(cfg.RedactedFields),
+	}
+
+	s.registerRecordHooks()
+
+	app.OnAfterBootstrap().Add(func(ctx context.Context, e *core.OnAfterBootstrapEvent) error {
+		s.registerAuthHooks(app.Auth())
+
+		if cfg.RetentionDays > 0 {
+			if _, err := sched.RunEvery(cfg.retentionInterval(), s.purgeExpired, "audit-retention"); err != nil {
+				return fmt.Errorf("schedule audit retention: %w", err)
+			}
+		}
+
+		return nil
+	})
+
+	app.OnBeforeServe().Add(func(ctx context.Context, e *core.OnBeforeServeEvent) error {
+		e.App.PrivateRoute("/audit-logs", func(r core.Router) {
+			r.Get("/", s.ListHandler)
+		})
+
+		return nil
+	})
+
+	return s
+}
+
+func toSet(values []string) map[string]bool {
+	set := make(map[string]bool, len(values))
+	for _, v := range values {
+		set[v] = true
+	}
+	return set
+}
+
+// Record writes an entry to the audit log.
+// The actor and organisation default to the principal in context.
+func (s *Service) Record(ctx context.Context, entry Entry) error {
+	if principal, ok := auth.LookupPrincipal(ctx); ok {
+		if entry.ActorAccountID == "" {
+			entry.ActorAccountID = principal.AccountID
+		}
+		if entry.OrganisationID == "" {
+			entry.OrganisationID = principal.OrganisationID
+		}
+	}
+
+	entry.ID = uid.ID()
+	entry.CreatedAt = timer.Now()
+	if entry.Changes == nil {
+		entry.Changes = map[string]Change{}
+	}
+
+	if _, err := s.entries(s.app.Store()).Create(ctx, entry); err != nil {
+		return fmt.Errorf("create audit log entry: %w", err)
+	}
+
+	return nil
+}
+
+// List returns the entries of an organisation, newest first
+func (s *Service) List(ctx context.Context, organisationID string, filter ListFilter) ([]Entry, store.Metadata, error) {
+	conditions := []store.FilterExpression{}
+	for _, c := range []struct{ field, value string }{
+		{"table_name", filter.Table},
+		{"record_id", filter.RecordID},
+		{"actor_account_id", filter.ActorAccountID},
+		{"action", filter.Action},
+	} {
+		if c.value != "" {
+			conditions = append(conditions, store.NewCondition(c.field, store.FilterOpEqual, c.value))
+		}
+	}
+	if filter.From != nil {
+		conditions = append(conditions, store.NewCondition("created_at", store.FilterOpGreaterEqual, *filter.From))
+	}
+	if filter.To != nil {
+		conditions = append(conditions, store.NewCondition("created_at", store.FilterOpLess, *filter.To))
+	}
+
+	limit := filter.Limit
+	if limit <= 0 || limit > 100 {
+		limit = 50
+	}
+
+	// Both keys descend like the index on (organisation_id, created_at DESC, id DESC)
+	opts := []store.FindOption{
+		store.WithSort(
+			store.SortOption{Field: "created_at", Direction: store.SortDesc},
+			store.SortOption{Field: "id", Direction: store.SortDesc},
+		),
+		store.WithCursor(filter.Cursor, limit),
+		store.WithoutTotal(),
+	}
+	if len(conditions) > 0 {
+		opts = append(opts, store.WithAndGroup(conditions...))
+	}
+
+	return s.entries(s.app.Store().ForOrganisation(organisationID)).Find(ctx, opts...)
+}
+
+// PurgeBefore deletes the entries created before t and returns the number of deleted entries
+func (s *Service) PurgeBefore(ctx context.Context, t time.Time) (int64, error) {
+	result, err := s.app.Store().DB().ExecContext(ctx, "DELETE FROM "+tableAuditLog+" WHERE created_at < $1", t)
+	if err != nil {
+		return 0, fmt.Errorf("purge audit log: %w", err)
+	}
+
+	return result.RowsAffected()
+}
+
+func (s *Service) purgeExpired() {
+	ctx := context.Background()
+	purged, err := s.PurgeBefore(ctx, timer.Now().Add(-s.cfg.retention()))
+	if err != nil {
+		log.Default().ErrorContext(ctx, "failed to purge expired audit log entries", log.ErrorAttr(err))
+		return
+	}
+
+	log.Info("purged expired audit log entries", "count", purged)
+}
+
+// collectionProvider is implemented by store.Interface and store.TenantStore
+type collectionProvider interface {
+	Collection(table string) store.CollectionInterface
+}
+
+func (s *Service) entries(st collectionProvider) *store.Repo[Entry] {
+	return store.NewRepo[Entry](st.Collection(tableAuditLog))
+}
+
+// record writes an entry from an event handler.
+// The change is already done, so a failure is logged rather than returned.
+func (s *Service) record(ctx context.Context, entry Entry) error {
+	if err := s.Record(ctx, entry); err != nil {
+		log.Default().ErrorContext(ctx, "failed to record audit log entry", log.ErrorAttr(err))
+	}
+
+	return nil
+}
+
+func (s *Service) audited(table string) bool {
+	return table != tableAuditLog && s.tables[table]
+}
+
+// recordEntry builds the entry of a record change
+func (s *Service) recordEntry(table, action string, record, oldRecord types.Record) Entry {
+	current := record
+	if current == nil {
+		current = oldRecord
+	}
+
+	entry := Entry{
+		Table:          table,
+		RecordID:       current.String("id"),
+		Action:         action,
+		OrganisationID: current.String(store.TenantColumn),
+		Changes:        diff(oldRecord, record, s.redacted),
+	}
+	if table == authStore.TableOrganisation {
+		entry.OrganisationID = entry.RecordID
+	}
+
+	return entry
+}
+
+func (s *Service) registerRecordHooks() {
+	s.app.OnAfterRecordCreated().Add(func(ctx context.Context, e *core.OnAfterRecordCreatedEvent) error {
+		if !s.audited(e.Table) {
+			return nil
+		}
+		return s.record(ctx, s.recordEntry(e.Table, ActionCreate, e.Record, nil))
+	})
+
+	s.app.OnAfterRecordUpdated().Add(func(ctx context.Context, e *core.OnAfterRecordUpdatedEvent) error {
+		if e.Table == authStore.TableOrganisationAccountRole {
+			s.recordRoleChange(ctx, e.Record, e.OldRecord)
+		}
+		if !s.audited(e.Table) {
+			return nil
+		}
+		return s.record(ctx, s.recordEntry(e.Table, ActionUpdate, e.Record, e.OldRecord))
+	})
+
+	s.app.OnAfterRecordDeleted().Add(func(ctx context.Context, e *core.OnAfterRecordDeletedEvent) error {
+		if !s.audited(e.Table) {
+			return nil
+		}
+		return s.record(ctx, s.recordEntry(e.Table, ActionDelete, nil, e.Record))
+	})
+
+	s.app.OnAfterRecordRestored().Add(func(ctx context.Context, e *core.OnAfterRecordRestoredEvent) error {
+		if !s.audited(e.Table) {
+			return nil
+		}
+		return s.record(ctx, s.recordEntry(e.Table, ActionRestore, e.Record, nil))
+	})
+
+	s.app.OnAfterRecordPurged().Add(func(ctx context.Context, e *core.OnAfterRecordPurgedEvent) error {
+		if !s.audited(e.Table) {
+			return nil
+		}
+		return s.record(ctx, s.recordEntry(e.Table, ActionPurge, nil, e.Record))
+	})
+}
+
+func (s *Service) registerAuthHooks(authSrv auth.Interface) {
+	authSrv.OnLogin().Add(func(ctx context.Context, e *auth.OnLoginEvent) error {
+		return s.record(ctx, Entry{
+			OrganisationID: e.OrganisationID,
+			ActorAccountID: e.AccountID,
+			Table:          authStore.TableAccount,
+			RecordID:       e.AccountID,
+			Action:         ActionLogin,
+			Changes: map[string]Change{
+				"provider": {New: e.Provider},
+				"device":   {New: e.Device},
+			},
+		})
+	})
+
+	authSrv.OnPasswordChanged().Add(func(ctx context.Context, e *auth.OnPasswordChangedEvent) error {
+		return s.record(ctx, Entry{
+			ActorAccountID: e.AccountID,
+			Table:          authStore.TableAccount,
+			RecordID:       e.AccountID,
+			Action:         ActionPasswordChange,
+			Changes: map[string]Change{
+				"reset": {New: e.Reset},
+			},
+		})
+	})
+}
+
+// recordRoleChange records the change of the role of an organisation member. It is recorded
+// from the writes of the member roles so that every role change is audited, whichever code
+// path made it.
+func (s *Service) recordRoleChange(ctx context.Context, record, oldRecord types.Record) {
+	oldRole, newRole := oldRecord.String("role"), record.String("role")
+	if oldRole == newRole {
+		return
+	}
+
+	_ = s.record(ctx, Entry{
+		OrganisationID: record.String(store.TenantColumn),
+		Table:          authStore.TableAccount,
+		RecordID:       record.String("account_id"),
+		Action:         ActionRoleChange,
+		Changes: map[string]Change{
+			"role": {Old: oldRole, New: newRole},
+		},
+	})
+}
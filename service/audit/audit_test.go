@@ -0,0 +1,55 @@
+package audit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/tuongaz/go-saas/core"
+	"github.com/tuongaz/go-saas/pkg/uid"
+	"github.com/tuongaz/go-saas/store"
+	"github.com/tuongaz/go-saas/store/types"
+	mocktimer "github.com/tuongaz/go-saas/testutils/mocks/timer"
+	mockuid "github.com/tuongaz/go-saas/testutils/mocks/uid"
+)
+
+// fakeApp is an application whose store is st
+type fakeApp struct {
+	core.AppInterface
+	st store.Interface
+}
+
+func (a fakeApp) Store() store.Interface {
+	return a.st
+}
+
+func TestService_RoleChange(t *testing.T) {
+	ctx := context.Background()
+	mocktimer.MockTimer(time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC))
+	t.Cleanup(mocktimer.ResetTimer)
+	previous := uid.Default
+	t.Cleanup(func() { uid.SetDefaultUID(previous) })
+
+	s := &Service{app: fakeApp{st: store.NewMemory()}}
+
+	member := types.Record{"organisation_id": "org1", "account_id": "acc1", "role": "member"}
+	admin := types.Record{"organisation_id": "org1", "account_id": "acc1", "role": "admin"}
+	mockuid.MockUID("e1")
+	s.recordRoleChange(ctx, admin, member)
+	mockuid.MockUID("e2")
+	s.recordRoleChange(ctx, member, member)
+	s.recordRoleChange(ctx, member, admin)
+
+	entries, _, err := s.List(ctx, "org1", ListFilter{Action: ActionRoleChange})
+	assert.NoError(t, err)
+	if assert.Len(t, entries, 2) {
+		// The entries created at the same time are listed by descending id
+		assert.Equal(t, "e2", entries[0].ID)
+		assert.Equal(t, "e1", entries[1].ID)
+		assert.Equal(t, "acc1", entries[1].RecordID)
+		assert.Equal(t, map[string]Change{"role": {Old: "member", New: "admin"}}, entries[1].Changes)
+		assert.Equal(t, map[string]Change{"role": {Old: "admin", New: "member"}}, entries[0].Changes)
+	}
+}
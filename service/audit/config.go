@@ -0,0 +1,44 @@
+package audit
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+type Config struct {
+	// Tables are the collections whose record changes are audited
+	Tables                 []string `mapstructure:"GOS_AUDIT_TABLES"`
+	RedactedFields         []string `mapstructure:"GOS_AUDIT_REDACTED_FIELDS"`
+	RetentionDays          int      `mapstructure:"GOS_AUDIT_RETENTION_DAYS"`
+	RetentionIntervalHours int      `mapstructure:"GOS_AUDIT_RETENTION_INTERVAL_HOURS"`
+}
+
+func SetDefault(key string, value any) {
+	viper.SetDefault(key, value)
+}
+
+func newConfig() (*Config, error) {
+	viper.AutomaticEnv()
+
+	SetDefault("GOS_AUDIT_TABLES", []string{"organisation", "account", "organisation_account_role"})
+	SetDefault("GOS_AUDIT_REDACTED_FIELDS", []string{"password", "token", "refresh_token"})
+	SetDefault("GOS_AUDIT_RETENTION_DAYS", 365)
+	SetDefault("GOS_AUDIT_RETENTION_INTERVAL_HOURS", 24)
+
+	var cfg Config
+	if err := viper.Unmarshal(&cfg); err != nil {
+		return nil, fmt.Errorf("unable to unmarshal config: %w", err)
+	}
+
+	return &cfg, nil
+}
+
+func (c *Config) retention() time.Duration {
+	return time.Duration(c.RetentionDays) * 24 * time.Hour
+}
+
+func (c *Config) retentionInterval() time.Duration {
+	return time.Duration(c.RetentionIntervalHours) * time.Hour
+}
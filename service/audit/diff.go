@@ -0,0 +1,62 @@
+package audit
+
+import (
+	"reflect"
+
+	"github.com/tuongaz/go-saas/store/types"
+)
+
+const redactedValue = "[redacted]"
+
+// ignoredFields change on every update and are left out of the diff
+var ignoredFields = map[string]bool{
+	"updated_at": true,
+	"version":    true,
+}
+
+// Change is the old and new value of a field
+type Change struct {
+	Old any `json:"old"`
+	New any `json:"new"`
+}
+
+// diff returns the fields that differ between the old and new record.
+// Creates have no old record and deletes no new record, so all their fields are included.
+// Values of redacted fields are masked.
+func diff(oldRecord, newRecord types.Record, redacted map[string]bool) map[string]Change {
+	keys := make([]string, 0, len(oldRecord)+len(newRecord))
+	for k := range oldRecord {
+		keys = append(keys, k)
+	}
+	for k := range newRecord {
+		if _, ok := oldRecord[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+
+	changes := map[string]Change{}
+	for _, k := range keys {
+		if ignoredFields[k] {
+			continue
+		}
+
+		oldValue, newValue := oldRecord[k], newRecord[k]
+		if oldRecord != nil && newRecord != nil && reflect.DeepEqual(oldValue, newValue) {
+			continue
+		}
+
+		if redacted[k] {
+			oldValue, newValue = maskValue(oldValue), maskValue(newValue)
+		}
+		changes[k] = Change{Old: oldValue, New: newValue}
+	}
+
+	return changes
+}
+
+func maskValue(v any) any {
+	if v == nil {
+		return nil
+	}
+	return redactedValue
+}
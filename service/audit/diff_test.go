@@ -0,0 +1,56 @@
+package audit
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tuongaz/go-saas/store/types"
+)
+
+func TestDiff(t *testing.T) {
+	redacted := map[string]bool{"password": true}
+
+	t.Run("update", func(t *testing.T) {
+		changes := diff(
+			types.Record{"id": "1", "name": "old", "password": "a", "updated_at": "t1", "avatar": nil},
+			types.Record{"id": "1", "name": "new", "password": "b", "updated_at": "t2", "avatar": nil},
+			redacted,
+		)
+		assert.Equal(t, map[string]Change{
+			"name":     {Old: "old", New: "new"},
+			"password": {Old: redactedValue, New: redactedValue},
+		}, changes)
+	})
+
+	t.Run("create", func(t *testing.T) {
+		changes := diff(nil, types.Record{"id": "1", "password": "a"}, redacted)
+		assert.Equal(t, map[string]Change{
+			"id":       {New: "1"},
+			"password": {New: redactedValue},
+		}, changes)
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		changes := diff(types.Record{"id": "1"}, nil, redacted)
+		assert.Equal(t, map[string]Change{"id": {Old: "1"}}, changes)
+	})
+}
+
+func TestParseListFilter(t *testing.T) {
+	r := httptest.NewRequest("GET", "/audit-logs?table=account&action=update&from=2025-01-01T00:00:00Z&limit=10&cursor=abc", nil)
+	filter, err := parseListFilter(r)
+	assert.NoError(t, err)
+	assert.Equal(t, "account", filter.Table)
+	assert.Equal(t, "update", filter.Action)
+	assert.Equal(t, 10, filter.Limit)
+	assert.Equal(t, "abc", filter.Cursor)
+	assert.Equal(t, 2025, filter.From.Year())
+	assert.Nil(t, filter.To)
+
+	_, err = parseListFilter(httptest.NewRequest("GET", "/audit-logs?to=yesterday", nil))
+	assert.Error(t, err)
+
+	_, err = parseListFilter(httptest.NewRequest("GET", "/audit-logs?limit=ten", nil))
+	assert.Error(t, err)
+}
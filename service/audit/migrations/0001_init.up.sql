@@ -0,0 +1,15 @@
+CREATE TABLE IF NOT EXISTS audit_log
+(
+    id               TEXT PRIMARY KEY,
+    organisation_id  TEXT                     NOT NULL DEFAULT '',
+    actor_account_id TEXT                     NOT NULL DEFAULT '',
+    table_name       TEXT                     NOT NULL DEFAULT '',
+    record_id        TEXT                     NOT NULL DEFAULT '',
+    action           VARCHAR(50)              NOT NULL,
+    changes          JSONB                    NOT NULL DEFAULT '{}',
+    created_at       TIMESTAMP WITH TIME ZONE NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_audit_log_organisation_created_at ON audit_log (organisation_id, created_at DESC, id DESC);
+CREATE INDEX IF NOT EXISTS idx_audit_log_record ON audit_log (table_name, record_id);
+CREATE INDEX IF NOT EXISTS idx_audit_log_created_at ON audit_log (created_at);
@@ -0,0 +1,47 @@
+package outbox
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+type Config struct {
+	PollIntervalMS   int `mapstructure:"GOS_OUTBOX_POLL_INTERVAL_MS"`
+	BatchSize        int `mapstructure:"GOS_OUTBOX_BATCH_SIZE"`
+	MaxAttempts      int `mapstructure:"GOS_OUTBOX_MAX_ATTEMPTS"`
+	BaseBackoffMS    int `mapstructure:"GOS_OUTBOX_BASE_BACKOFF_MS"`
+	MaxBackoffMS     int `mapstructure:"GOS_OUTBOX_MAX_BACKOFF_MS"`
+	HandlerTimeoutMS int `mapstructure:"GOS_OUTBOX_HANDLER_TIMEOUT_MS"`
+}
+
+func SetDefault(key string, value any) {
+	viper.SetDefault(key, value)
+}
+
+func newConfig() (*Config, error) {
+	viper.AutomaticEnv()
+
+	SetDefault("GOS_OUTBOX_POLL_INTERVAL_MS", 1000)
+	SetDefault("GOS_OUTBOX_BATCH_SIZE", 100)
+	SetDefault("GOS_OUTBOX_MAX_ATTEMPTS", 10)
+	SetDefault("GOS_OUTBOX_BASE_BACKOFF_MS", 1000)
+	SetDefault("GOS_OUTBOX_MAX_BACKOFF_MS", 60*60*1000) // 1 hour
+	SetDefault("GOS_OUTBOX_HANDLER_TIMEOUT_MS", 30*1000)
+
+	var cfg Config
+	if err := viper.Unmarshal(&cfg); err != nil {
+		return nil, fmt.Errorf("unable to unmarshal config: %w", err)
+	}
+
+	return &cfg, nil
+}
+
+func (c *Config) pollInterval() time.Duration {
+	return time.Duration(c.PollIntervalMS) * time.Millisecond
+}
+
+func (c *Config) handlerTimeout() time.Duration {
+	return time.Duration(c.HandlerTimeoutMS) * time.Millisecond
+}
@@ -0,0 +1,21 @@
+CREATE TABLE IF NOT EXISTS outbox
+(
+    id              VARCHAR PRIMARY KEY,
+    topic           VARCHAR                  NOT NULL,
+    payload         JSONB                    NOT NULL,
+    idempotency_key VARCHAR                  NOT NULL,
+    status          VARCHAR(20)              NOT NULL,
+    attempts        INT                      NOT NULL DEFAULT 0,
+    last_error      TEXT,
+    available_at    TIMESTAMP WITH TIME ZONE NOT NULL,
+    delivered_at    TIMESTAMP WITH TIME ZONE,
+    created_at      TIMESTAMP WITH TIME ZONE NOT NULL,
+    updated_at      TIMESTAMP WITH TIME ZONE NOT NULL
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS outbox_idempotency_key_unq
+    ON outbox (idempotency_key);
+
+CREATE INDEX IF NOT EXISTS outbox_pending_idx
+    ON outbox (available_at)
+    WHERE status = 'pending';
@@ -0,0 +1,203 @@
+package outbox
+
+import (
+	"context"
+	"embed"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/tuongaz/go-saas/core"
+	"github.com/tuongaz/go-saas/pkg/timer"
+	"github.com/tuongaz/go-saas/pkg/uid"
+	"github.com/tuongaz/go-saas/service/scheduler"
+	"github.com/tuongaz/go-saas/store"
+	"github.com/tuongaz/go-saas/store/migrate"
+)
+
+//go:embed migrations/*.sql
+var migrations embed.FS
+
+func init() {
+	migrate.Register("outbox", migrations, "migrations")
+}
+
+const (
+	tableOutbox = "outbox"
+
+	lockID            = 123457
+	lockRetryInterval = 30 * time.Second
+
+	StatusPending   = "pending"
+	StatusDelivered = "delivered"
+	StatusDead      = "dead"
+)
+
+var _ Interface = &Service{}
+
+// Interface is the transactional outbox.
+// Messages are written in the same transaction as the business change and delivered
+// to the registered handlers by a relay worker running on the leader replica.
+// Delivery is at least once, handlers should use Message.IdempotencyKey to skip duplicates.
+type Interface interface {
+	Enqueue(ctx context.Context, tx *store.StoreTx, topic string, payload any, opts ...EnqueueOption) (*Message, error)
+	Register(topic string, handler Handler)
+}
+
+// Handler delivers a message. Returning an error schedules a retry with backoff.
+type Handler func(ctx context.Context, msg Message) error
+
+// Message is an outbox message
+type Message struct {
+	ID             string          `json:"id" db:"id"`
+	Topic          string          `json:"topic" db:"topic"`
+	Payload        json.RawMessage `json:"payload" db:"payload"`
+	IdempotencyKey string          `json:"idempotency_key" db:"idempotency_key"`
+	Status         string          `json:"status" db:"status"`
+	Attempts       int             `json:"attempts" db:"attempts"`
+	LastError      *string         `json:"last_error" db:"last_error"`
+	AvailableAt    time.Time       `json:"available_at" db:"available_at"`
+	DeliveredAt    *time.Time      `json:"delivered_at" db:"delivered_at"`
+	CreatedAt      time.Time       `json:"created_at" db:"created_at"`
+	UpdatedAt      time.Time       `json:"updated_at" db:"updated_at"`
+}
+
+// Decode decodes the message payload into obj
+func (m Message) Decode(obj any) error {
+	if err := json.Unmarshal(m.Payload, obj); err != nil {
+		return fmt.Errorf("decode outbox payload: %w", err)
+	}
+	return nil
+}
+
+type enqueueOptions struct {
+	idempotencyKey string
+	delay          time.Duration
+}
+
+// EnqueueOption configures an enqueued message
+type EnqueueOption func(*enqueueOptions)
+
+// WithIdempotencyKey sets the idempotency key of the message, it defaults to the message id.
+// Enqueueing a message with a key that already exists is a no-op.
+func WithIdempotencyKey(key string) EnqueueOption {
+	return func(o *enqueueOptions) {
+		o.idempotencyKey = key
+	}
+}
+
+// WithDelay delays the first delivery attempt of the message
+func WithDelay(d time.Duration) EnqueueOption {
+	return func(o *enqueueOptions) {
+		o.delay = d
+	}
+}
+
+type Service struct {
+	app core.AppInterface
+	cfg *Config
+
+	mu       sync.RWMutex
+	handlers map[string]Handler
+
+	lock   *scheduler.AdvisoryLock
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+func MustRegister(app core.AppInterface) *Service {
+	cfg, err := newConfig()
+	if err != nil {
+		panic(fmt.Errorf("new outbox config: %w", err))
+	}
+
+	s := &Service{
+		app:      app,
+		cfg:      cfg,
+		handlers: map[string]Handler{},
+	}
+
+	app.OnAfterBootstrap().Add(func(ctx context.Context, e *core.OnAfterBootstrapEvent) error {
+		s.lock = scheduler.NewAdvisoryLock(app.Store().DB(), lockID, "outbox")
+		s.lock.WaitToAcquire(lockRetryInterval)
+		s.start()
+
+		return nil
+	})
+
+	app.OnTerminate().Add(func(ctx context.Context, e *core.OnTerminateEvent) error {
+		s.stop()
+		if s.lock != nil {
+			s.lock.Release()
+		}
+		return nil
+	})
+
+	return s
+}
+
+// Register sets the handler for a topic, replacing any previous handler
+func (s *Service) Register(topic string, handler Handler) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.handlers[topic] = handler
+}
+
+func (s *Service) handler(topic string) (Handler, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	handler, ok := s.handlers[topic]
+	return handler, ok
+}
+
+// Enqueue writes a message to the outbox within tx, so that it is only delivered
+// if the transaction commits. When tx is nil the message is written directly.
+// Enqueuing an idempotency key again returns the message stored with it.
+func (s *Service) Enqueue(ctx context.Context, tx *store.StoreTx, topic string, payload any, opts ...EnqueueOption) (*Message, error) {
+	o := &enqueueOptions{}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("encode outbox payload: %w", err)
+	}
+
+	now := timer.Now()
+	msg := &Message{
+		ID:             uid.ID(),
+		Topic:          topic,
+		Payload:        data,
+		IdempotencyKey: o.idempotencyKey,
+		Status:         StatusPending,
+		AvailableAt:    now.Add(o.delay),
+		CreatedAt:      now,
+		UpdatedAt:      now,
+	}
+	if msg.IdempotencyKey == "" {
+		msg.IdempotencyKey = msg.ID
+	}
+
+	// A duplicate key touches the existing row, so that the stored message is returned
+	query := "INSERT INTO " + tableOutbox + ` (id, topic, payload, idempotency_key, status, attempts, available_at, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, 0, $6, $7, $8)
+		ON CONFLICT (idempotency_key) DO UPDATE SET idempotency_key = EXCLUDED.idempotency_key
+		RETURNING *`
+	args := []any{msg.ID, msg.Topic, string(msg.Payload), msg.IdempotencyKey, msg.Status, msg.AvailableAt, msg.CreatedAt, msg.UpdatedAt}
+
+	stored := &Message{}
+	if tx != nil {
+		err = tx.QueryValue(ctx, query, stored, args...)
+	} else {
+		err = s.app.Store().DB().GetContext(ctx, stored, query, args...)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("enqueue outbox message: %w", err)
+	}
+
+	return stored, nil
+}
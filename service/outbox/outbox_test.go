@@ -0,0 +1,50 @@
+//go:build cgo
+
+package outbox
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/tuongaz/go-saas/store"
+	"github.com/tuongaz/go-saas/store/migrate"
+	_ "github.com/tuongaz/go-saas/store/sqlite"
+	mocktimer "github.com/tuongaz/go-saas/testutils/mocks/timer"
+)
+
+func TestEnqueue_IdempotencyKey(t *testing.T) {
+	ctx := context.Background()
+	st, err := store.New("sqlite::memory:")
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	t.Cleanup(func() { _ = st.Close() })
+	_, err = migrate.New(st.DB(), migrate.Sources()...).Up(ctx)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	now := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+	mocktimer.MockTimer(now)
+	t.Cleanup(mocktimer.ResetTimer)
+	s := &Service{app: fakeApp{st: st}, handlers: map[string]Handler{}}
+
+	first, err := s.Enqueue(ctx, nil, "email", map[string]string{"to": "a@example.com"}, WithIdempotencyKey("welcome:a"))
+	assert.NoError(t, err)
+
+	// The duplicate returns the stored message, not the one it would have written
+	mocktimer.MockTimer(now.Add(time.Minute))
+	second, err := s.Enqueue(ctx, nil, "email", map[string]string{"to": "b@example.com"}, WithIdempotencyKey("welcome:a"))
+	assert.NoError(t, err)
+	assert.Equal(t, first.ID, second.ID)
+	assert.JSONEq(t, `{"to":"a@example.com"}`, string(second.Payload))
+	assert.True(t, now.Equal(second.CreatedAt))
+	assert.Equal(t, StatusPending, second.Status)
+
+	var count int
+	assert.NoError(t, st.QueryValue(ctx, "SELECT COUNT(*) FROM outbox", &count))
+	assert.Equal(t, 1, count)
+}
@@ -0,0 +1,174 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"time"
+
+	"github.com/tuongaz/go-saas/pkg/log"
+	"github.com/tuongaz/go-saas/pkg/timer"
+)
+
+// start runs the relay worker in the background
+func (s *Service) start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	s.cancel = cancel
+	s.done = make(chan struct{})
+
+	go func() {
+		defer close(s.done)
+
+		ticker := time.NewTicker(s.cfg.pollInterval())
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+
+			if !s.lock.IsLeader() {
+				continue
+			}
+
+			// Keep relaying while there are full batches waiting
+			for {
+				n, err := s.relay(ctx)
+				if err != nil {
+					log.Default().ErrorContext(ctx, "outbox relay error", log.ErrorAttr(err))
+					break
+				}
+				if n < s.cfg.BatchSize || ctx.Err() != nil {
+					break
+				}
+			}
+		}
+	}()
+}
+
+// stop stops the relay worker and waits for the current batch to finish
+func (s *Service) stop() {
+	if s.cancel == nil {
+		return
+	}
+
+	s.cancel()
+	<-s.done
+}
+
+// relay delivers a batch of due messages and returns the number of messages processed
+func (s *Service) relay(ctx context.Context) (int, error) {
+	var messages []Message
+	query := "SELECT * FROM " + tableOutbox + ` WHERE status = $1 AND available_at <= $2
+		ORDER BY available_at, id LIMIT $3`
+	if err := s.app.Store().DB().SelectContext(ctx, &messages, query, StatusPending, timer.Now(), s.cfg.BatchSize); err != nil {
+		return 0, fmt.Errorf("select outbox messages: %w", err)
+	}
+
+	for _, msg := range messages {
+		if ctx.Err() != nil {
+			break
+		}
+
+		if err := s.deliver(ctx, msg); err != nil {
+			return 0, err
+		}
+	}
+
+	return len(messages), nil
+}
+
+// errNoHandler is returned by callHandler for the messages whose topic has no handler
+var errNoHandler = errors.New("no handler registered")
+
+// deliver calls the handler of the message and records the outcome
+func (s *Service) deliver(ctx context.Context, msg Message) error {
+	err := s.callHandler(ctx, msg)
+	now := timer.Now()
+
+	// The handler may be registered by another version of the application, e.g. during a
+	// rolling deploy, so the message stays pending without using an attempt. It is put back
+	// after the base backoff so that it does not hold up the batches.
+	if errors.Is(err, errNoHandler) {
+		log.Default().WarnContext(ctx, "outbox message has no handler", "id", msg.ID, "topic", msg.Topic)
+		availableAt := now.Add(time.Duration(s.cfg.BaseBackoffMS) * time.Millisecond)
+		if err := s.app.Store().Exec(ctx,
+			"UPDATE "+tableOutbox+" SET available_at = $1, updated_at = $2 WHERE id = $3",
+			availableAt, now, msg.ID,
+		); err != nil {
+			return fmt.Errorf("postpone outbox message: %w", err)
+		}
+		return nil
+	}
+
+	if err == nil {
+		if err := s.app.Store().Exec(ctx,
+			"UPDATE "+tableOutbox+" SET status = $1, attempts = attempts + 1, delivered_at = $2, updated_at = $2 WHERE id = $3",
+			StatusDelivered, now, msg.ID,
+		); err != nil {
+			return fmt.Errorf("mark outbox message delivered: %w", err)
+		}
+		return nil
+	}
+
+	attempts := msg.Attempts + 1
+	status := StatusPending
+	if attempts >= s.cfg.MaxAttempts {
+		status = StatusDead
+		log.Default().ErrorContext(ctx, "outbox message exhausted its attempts", "id", msg.ID, "topic", msg.Topic, log.ErrorAttr(err))
+	}
+
+	availableAt := now.Add(backoff(attempts, s.cfg.BaseBackoffMS, s.cfg.MaxBackoffMS))
+	if err := s.app.Store().Exec(ctx,
+		"UPDATE "+tableOutbox+" SET status = $1, attempts = $2, last_error = $3, available_at = $4, updated_at = $5 WHERE id = $6",
+		status, attempts, err.Error(), availableAt, now, msg.ID,
+	); err != nil {
+		return fmt.Errorf("mark outbox message failed: %w", err)
+	}
+
+	return nil
+}
+
+// callHandler runs the handler registered for the message topic, recovering from panics
+func (s *Service) callHandler(ctx context.Context, msg Message) (err error) {
+	handler, ok := s.handler(msg.Topic)
+	if !ok {
+		return fmt.Errorf("%w for topic %s", errNoHandler, msg.Topic)
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("handler panic: %v", r)
+		}
+	}()
+
+	ctx, cancel := context.WithTimeout(ctx, s.cfg.handlerTimeout())
+	defer cancel()
+
+	return handler(ctx, msg)
+}
+
+// backoff returns the delay before the next attempt: exponential growth from base,
+// capped at max, with jitter so that failing messages do not retry in lockstep.
+func backoff(attempts int, baseMS, maxMS int) time.Duration {
+	base := time.Duration(baseMS) * time.Millisecond
+	maxDelay := time.Duration(maxMS) * time.Millisecond
+
+	d := base
+	for i := 1; i < attempts && d < maxDelay; i++ {
+		d *= 2
+	}
+	if d > maxDelay {
+		d = maxDelay
+	}
+	if d <= 0 {
+		return 0
+	}
+
+	// Equal jitter: between half and the full delay
+	half := d / 2
+	return half + time.Duration(rand.Int64N(int64(d-half)+1))
+}
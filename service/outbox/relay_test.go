@@ -0,0 +1,73 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/tuongaz/go-saas/core"
+	"github.com/tuongaz/go-saas/store"
+	mocks "github.com/tuongaz/go-saas/testutils/mocks/store"
+	mocktimer "github.com/tuongaz/go-saas/testutils/mocks/timer"
+)
+
+// fakeApp is an application whose store is st
+type fakeApp struct {
+	core.AppInterface
+	st store.Interface
+}
+
+func (a fakeApp) Store() store.Interface {
+	return a.st
+}
+
+func TestBackoff(t *testing.T) {
+	between := func(d, min, max time.Duration) {
+		assert.GreaterOrEqual(t, d, min)
+		assert.LessOrEqual(t, d, max)
+	}
+
+	between(backoff(1, 1000, 60000), 500*time.Millisecond, time.Second)
+	between(backoff(2, 1000, 60000), time.Second, 2*time.Second)
+	between(backoff(4, 1000, 60000), 4*time.Second, 8*time.Second)
+
+	t.Run("capped at max", func(t *testing.T) {
+		between(backoff(50, 1000, 60000), 30*time.Second, time.Minute)
+	})
+
+	t.Run("zero base", func(t *testing.T) {
+		assert.Equal(t, time.Duration(0), backoff(3, 0, 60000))
+	})
+}
+
+func TestDeliver(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+	mocktimer.MockTimer(now)
+	t.Cleanup(mocktimer.ResetTimer)
+
+	newService := func(t *testing.T) (*Service, *mocks.MockInterface) {
+		st := mocks.NewMockInterface(t)
+		cfg := &Config{MaxAttempts: 3, BaseBackoffMS: 1000, MaxBackoffMS: 60000, HandlerTimeoutMS: 1000}
+		return &Service{app: fakeApp{st: st}, cfg: cfg, handlers: map[string]Handler{}}, st
+	}
+
+	t.Run("without a handler the message stays pending", func(t *testing.T) {
+		s, st := newService(t)
+		st.EXPECT().Exec(ctx, "UPDATE outbox SET available_at = $1, updated_at = $2 WHERE id = $3", now.Add(time.Second), now, "m1").Return(nil)
+
+		assert.NoError(t, s.deliver(ctx, Message{ID: "m1", Topic: "unknown", Attempts: 2}))
+	})
+
+	t.Run("a failed attempt is counted", func(t *testing.T) {
+		s, st := newService(t)
+		s.Register("email", func(ctx context.Context, msg Message) error { return errors.New("smtp down") })
+		st.EXPECT().Exec(ctx, mock.AnythingOfType("string"), StatusPending, 1, "smtp down", mock.Anything, now, "m1").Return(nil)
+
+		assert.NoError(t, s.deliver(ctx, Message{ID: "m1", Topic: "email"}))
+	})
+}
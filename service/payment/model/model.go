@@ -15,39 +15,40 @@ const (
 )
 
 type Invoice struct {
-	ID            string    `json:"id"`
-	AccountID     string    `json:"account_id"`
-	AmountInCents int64     `json:"amount_in_cents"`
-	Currency      string    `json:"currency"`
-	Status        string    `json:"status"`
-	CreatedAt     time.Time `json:"created_at"`
-	UpdatedAt     time.Time `json:"updated_at"`
+	ID            string    `json:"id" db:"id"`
+	AccountID     string    `json:"account_id" db:"account_id"`
+	ReferenceID   string    `json:"reference_id" db:"reference_id"`
+	AmountInCents int64     `json:"amount_in_cents" db:"amount_in_cents"`
+	Currency      string    `json:"currency" db:"currency"`
+	Status        string    `json:"status" db:"status"`
+	CreatedAt     time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at" db:"updated_at"`
 }
 
 type PaymentMethod struct {
-	ID                 string    `json:"id"`
-	AccountID          string    `json:"account_id"`
-	ProviderCustomerID string    `json:"provider_customer_id"`
-	Provider           string    `json:"provider"`
-	Data               string    `json:"data"`
-	IsDefault          bool      `json:"is_default"`
-	CreatedAt          time.Time `json:"created_at"`
-	UpdatedAt          time.Time `json:"updated_at"`
+	ID                 string    `json:"id" db:"id"`
+	AccountID          string    `json:"account_id" db:"account_id"`
+	ProviderCustomerID string    `json:"provider_customer_id" db:"provider_customer_id"`
+	Provider           string    `json:"provider" db:"provider"`
+	Data               string    `json:"data" db:"data"`
+	IsDefault          bool      `json:"is_default" db:"is_default"`
+	CreatedAt          time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt          time.Time `json:"updated_at" db:"updated_at"`
 }
 
 type Payment struct {
-	ID              string    `json:"id"`
-	InvoiceID       string    `json:"invoice_id"`
-	PaymentMethodID string    `json:"payment_method_id"`
-	AmountInCents   int64     `json:"amount_in_cents"`
-	Currency        string    `json:"currency"`
-	Status          string    `json:"status"`
-	ProviderData    string    `json:"provider_data"`
-	CreatedAt       time.Time `json:"created_at"`
-	UpdatedAt       time.Time `json:"updated_at"`
+	ID              string    `json:"id" db:"id"`
+	InvoiceID       string    `json:"invoice_id" db:"invoice_id"`
+	PaymentMethodID string    `json:"payment_method_id" db:"payment_method_id"`
+	AmountInCents   int64     `json:"amount_in_cents" db:"amount_in_cents"`
+	Currency        string    `json:"currency" db:"currency"`
+	Status          string    `json:"status" db:"status"`
+	ProviderData    string    `json:"provider_data" db:"charge_data,omitempty"`
+	CreatedAt       time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt       time.Time `json:"updated_at" db:"updated_at"`
 }
 
 type StripeCustomer struct {
-	AccountID  string `json:"account_id"`
-	CustomerID string `json:"customer_id"`
+	AccountID  string `json:"account_id" db:"account_id"`
+	CustomerID string `json:"customer_id" db:"customer_id"`
 }
@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS stripe_customer;
+DROP TABLE IF EXISTS invoice;
+DROP TABLE IF EXISTS payment_method;
+DROP TABLE IF EXISTS payment;
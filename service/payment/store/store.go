@@ -2,14 +2,13 @@ package store
 
 import (
 	"context"
-	_ "embed"
+	"embed"
 	"encoding/json"
 	"fmt"
-	"time"
 
-	"github.com/tuongaz/go-saas/pkg/uid"
 	"github.com/tuongaz/go-saas/service/payment/model"
 	"github.com/tuongaz/go-saas/store"
+	"github.com/tuongaz/go-saas/store/migrate"
 	"github.com/tuongaz/go-saas/store/types"
 )
 
@@ -20,8 +19,12 @@ const (
 	tableStripeCustomer = "stripe_customer"
 )
 
-//go:embed postgres.sql
-var postgresSchema string
+//go:embed migrations/*.sql
+var migrations embed.FS
+
+func init() {
+	migrate.Register("payment", migrations, "migrations")
+}
 
 var _ Interface = (*Store)(nil)
 
@@ -43,97 +46,78 @@ type Store struct {
 	store store.Interface
 }
 
-func (s *Store) CreateStripeCustomer(ctx context.Context, accountID, customerID string) (*model.StripeCustomer, error) {
-	record := types.Record{
-		"account_id":  accountID,
-		"customer_id": customerID,
-	}
+func (s *Store) invoices() *store.Repo[model.Invoice] {
+	return store.NewRepo[model.Invoice](s.store.Collection(tableInvoice))
+}
 
-	if _, err := s.store.Collection(tableStripeCustomer).CreateRecord(ctx, record); err != nil {
-		return nil, fmt.Errorf("create stripe customer record: %w", err)
-	}
+func (s *Store) payments() *store.Repo[model.Payment] {
+	return store.NewRepo[model.Payment](s.store.Collection(tablePayment))
+}
+
+func (s *Store) paymentMethods() *store.Repo[model.PaymentMethod] {
+	return store.NewRepo[model.PaymentMethod](s.store.Collection(tablePaymentMethod))
+}
 
-	var stripeCustomer model.StripeCustomer
-	if err := record.Decode(&stripeCustomer); err != nil {
-		return nil, fmt.Errorf("decode to stripe customer: %w", err)
+func (s *Store) stripeCustomers() *store.Repo[model.StripeCustomer] {
+	return store.NewRepo[model.StripeCustomer](s.store.Collection(tableStripeCustomer))
+}
+
+func (s *Store) CreateStripeCustomer(ctx context.Context, accountID, customerID string) (*model.StripeCustomer, error) {
+	stripeCustomer, err := s.stripeCustomers().Create(ctx, model.StripeCustomer{
+		AccountID:  accountID,
+		CustomerID: customerID,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("create stripe customer record: %w", err)
 	}
 
-	return &stripeCustomer, nil
+	return stripeCustomer, nil
 }
 
 func (s *Store) GetStripeCustomer(ctx context.Context, accountID string) (*model.StripeCustomer, error) {
-	record, err := s.store.Collection(tableStripeCustomer).FindOne(ctx, store.Filter{
+	stripeCustomer, err := s.stripeCustomers().FindOne(ctx, store.Filter{
 		"account_id": accountID,
 	})
 	if err != nil {
 		return nil, fmt.Errorf("find stripe customer record: %w", err)
 	}
 
-	var stripeCustomer model.StripeCustomer
-	if err := record.Decode(&stripeCustomer); err != nil {
-		return nil, fmt.Errorf("decode to stripe customer: %w", err)
-	}
-
-	return &stripeCustomer, nil
+	return stripeCustomer, nil
 }
 
-func New(store store.Interface) (*Store, error) {
-	if err := store.Exec(context.Background(), postgresSchema); err != nil {
-		return nil, fmt.Errorf("failed to create payment schema: %w", err)
-	}
+func New(st store.Interface) (*Store, error) {
+	st.EnableAutoFields(store.StandardAutoFields, tableInvoice, tablePayment, tablePaymentMethod)
+
 	return &Store{
-		store: store,
+		store: st,
 	}, nil
 }
 
 func (s *Store) CreateInvoice(ctx context.Context, input model.CreateInvoiceInput) (*model.Invoice, error) {
-	record := types.Record{
-		"id":              uid.ID(),
-		"account_id":      input.AccountID,
-		"reference_id":    input.ReferenceID,
-		"amount_in_cents": input.AmountInCents,
-		"currency":        input.Currency,
-		"status":          input.Status,
-		"created_at":      time.Now(),
-		"updated_at":      time.Now(),
-	}
-
-	if _, err := s.store.Collection(tableInvoice).CreateRecord(ctx, record); err != nil {
+	invoice, err := s.invoices().Create(ctx, model.Invoice{
+		AccountID:     input.AccountID,
+		ReferenceID:   input.ReferenceID,
+		AmountInCents: input.AmountInCents,
+		Currency:      input.Currency,
+		Status:        input.Status,
+	})
+	if err != nil {
 		return nil, fmt.Errorf("create invoice record: %w", err)
 	}
 
-	var invoice model.Invoice
-	if err := record.Decode(&invoice); err != nil {
-		return nil, fmt.Errorf("decode to invoice: %w", err)
-	}
-
-	return &invoice, nil
+	return invoice, nil
 }
 
 func (s *Store) GetInvoice(ctx context.Context, id string) (*model.Invoice, error) {
-	record, err := s.store.Collection(tableInvoice).FindOne(ctx, store.Filter{
-		"id": id,
-	})
+	invoice, err := s.invoices().Get(ctx, id)
 	if err != nil {
 		return nil, fmt.Errorf("find invoice record: %w", err)
 	}
 
-	var invoice model.Invoice
-	if err := record.Decode(&invoice); err != nil {
-		return nil, fmt.Errorf("decode to invoice: %w", err)
-	}
-
-	return &invoice, nil
+	return invoice, nil
 }
 
 func (s *Store) UpdateInvoice(ctx context.Context, id string, input model.UpdateInvoiceInput) error {
-	_, err := s.store.Collection(tableInvoice).FindOne(ctx, store.Filter{
-		"id": id,
-	})
-	if err != nil {
-		return fmt.Errorf("find invoice record: %w", err)
-	}
-
 	var record = types.Record{}
 
 	if input.AccountID != nil {
@@ -152,9 +136,7 @@ func (s *Store) UpdateInvoice(ctx context.Context, id string, input model.Update
 		record["status"] = *input.Status
 	}
 
-	record["updated_at"] = time.Now()
-
-	if _, err := s.store.Collection(tableInvoice).UpdateRecord(ctx, id, record); err != nil {
+	if _, err := s.invoices().Patch(ctx, id, record); err != nil {
 		return fmt.Errorf("update invoice record: %w", err)
 	}
 
@@ -162,37 +144,21 @@ func (s *Store) UpdateInvoice(ctx context.Context, id string, input model.Update
 }
 
 func (s *Store) CreatePayment(ctx context.Context, input model.CreatePaymentInput) (*model.Payment, error) {
-	record := types.Record{
-		"id":                uid.ID(),
-		"invoice_id":        input.InvoiceID,
-		"payment_method_id": input.PaymentMethodID,
-		"amount_in_cents":   input.AmountInCents,
-		"currency":          input.Currency,
-		"status":            input.Status,
-		"created_at":        time.Now(),
-		"updated_at":        time.Now(),
-	}
-
-	if _, err := s.store.Collection(tablePayment).CreateRecord(ctx, record); err != nil {
+	payment, err := s.payments().Create(ctx, model.Payment{
+		InvoiceID:       input.InvoiceID,
+		PaymentMethodID: input.PaymentMethodID,
+		AmountInCents:   input.AmountInCents,
+		Currency:        input.Currency,
+		Status:          input.Status,
+	})
+	if err != nil {
 		return nil, fmt.Errorf("create payment record: %w", err)
 	}
 
-	var payment model.Payment
-	if err := record.Decode(&payment); err != nil {
-		return nil, fmt.Errorf("decode to payment: %w", err)
-	}
-
-	return &payment, nil
+	return payment, nil
 }
 
 func (s *Store) UpdatePayment(ctx context.Context, id string, input model.UpdatePaymentInput) error {
-	_, err := s.store.Collection(tablePayment).FindOne(ctx, store.Filter{
-		"id": id,
-	})
-	if err != nil {
-		return fmt.Errorf("find payment record: %w", err)
-	}
-
 	var record = types.Record{}
 
 	if input.Status != nil {
@@ -203,9 +169,7 @@ func (s *Store) UpdatePayment(ctx context.Context, id string, input model.Update
 		record["charge_data"] = *input.ChargeData
 	}
 
-	record["updated_at"] = time.Now()
-
-	if _, err := s.store.Collection(tablePayment).UpdateRecord(ctx, id, record); err != nil {
+	if _, err := s.payments().Patch(ctx, id, record); err != nil {
 		return fmt.Errorf("update payment record: %w", err)
 	}
 
@@ -225,39 +189,30 @@ func (s *Store) CreatePaymentMethod(ctx context.Context, input CreatePaymentMeth
 		return nil, fmt.Errorf("failed to marshal data: %w", err)
 	}
 
-	record := types.Record{
-		"id":                   uid.ID(),
-		"account_id":           input.AccountID,
-		"provider":             input.Provider,
-		"provider_customer_id": input.ProviderCustomerID,
-		"data":                 string(data),
-		"created_at":           time.Now(),
-		"updated_at":           time.Now(),
-	}
-
-	if _, err := s.store.Collection(tablePaymentMethod).CreateRecord(ctx, record); err != nil {
+	paymentMethod, err := s.paymentMethods().Create(ctx, model.PaymentMethod{
+		AccountID:          input.AccountID,
+		Provider:           input.Provider,
+		ProviderCustomerID: input.ProviderCustomerID,
+		Data:               string(data),
+	})
+	if err != nil {
 		return nil, fmt.Errorf("create payment method record: %w", err)
 	}
 
-	var paymentMethod model.PaymentMethod
-	if err := record.Decode(&paymentMethod); err != nil {
-		return nil, fmt.Errorf("decode to payment method: %w", err)
-	}
-
-	return &paymentMethod, nil
+	return paymentMethod, nil
 }
 
 func (s *Store) GetPaymentMethods(ctx context.Context, accountID string) ([]*model.PaymentMethod, error) {
-	records, err := s.store.Collection(tablePaymentMethod).Find(ctx, store.WithFilter(store.Filter{
+	items, _, err := s.paymentMethods().Find(ctx, store.WithFilter(store.Filter{
 		"account_id": accountID,
 	}))
 	if err != nil {
 		return nil, fmt.Errorf("find payment methods: %w", err)
 	}
 
-	var paymentMethods []*model.PaymentMethod
-	if err := records.Decode(&paymentMethods); err != nil {
-		return nil, fmt.Errorf("decode payment methods: %w", err)
+	paymentMethods := make([]*model.PaymentMethod, len(items))
+	for i := range items {
+		paymentMethods[i] = &items[i]
 	}
 
 	return paymentMethods, nil
@@ -0,0 +1,219 @@
+package realtime
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"golang.org/x/net/websocket"
+
+	"github.com/tuongaz/go-saas/core/auth"
+	"github.com/tuongaz/go-saas/core/auth/model"
+	"github.com/tuongaz/go-saas/pkg/apierror"
+	"github.com/tuongaz/go-saas/pkg/httputil"
+)
+
+const (
+	MessageSubscribe    = "subscribe"
+	MessageUnsubscribe  = "unsubscribe"
+	MessageSubscribed   = "subscribed"
+	MessageUnsubscribed = "unsubscribed"
+	MessageEvent        = "event"
+	MessageError        = "error"
+	MessagePing         = "ping"
+)
+
+// Message is a WebSocket message.
+// Clients send subscribe and unsubscribe messages, the server replies with subscribed,
+// unsubscribed or error messages and sends event and ping messages.
+type Message struct {
+	Type         string        `json:"type"`
+	Subscription *Subscription `json:"subscription,omitempty"`
+	Event        *Event        `json:"event,omitempty"`
+	Error        string        `json:"error,omitempty"`
+}
+
+// SSEHandler streams the events of the subscriptions given in the query string as Server-Sent Events,
+// e.g. ?subscribe=project&subscribe=task:123 for all projects and the task with id 123.
+// The event name is the action and the data is the JSON encoded Event.
+func (s *Service) SSEHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	subs, err := s.parseSubscriptions(r.URL.Query()["subscribe"])
+	if err != nil {
+		httputil.HandleResponse(ctx, w, nil, err)
+		return
+	}
+	if len(subs) == 0 {
+		httputil.HandleResponse(ctx, w, nil, apierror.NewValidationError("at least one subscription is required", nil))
+		return
+	}
+
+	c := s.connect(ctx, auth.PrincipalFromCtx(ctx))
+	defer s.disconnect(c)
+	for _, sub := range subs {
+		c.subscribe(sub)
+	}
+
+	rc := http.NewResponseController(w)
+	// The stream outlives the server write timeout
+	_ = rc.SetWriteDeadline(time.Time{})
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	if err := rc.Flush(); err != nil {
+		return
+	}
+
+	heartbeat := time.NewTicker(s.cfg.heartbeat())
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-c.done:
+			return
+		case event := <-c.events:
+			if err := writeSSE(w, event); err != nil {
+				return
+			}
+		case <-heartbeat.C:
+			if _, err := io.WriteString(w, ": ping\n\n"); err != nil {
+				return
+			}
+		}
+
+		if err := rc.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+func writeSSE(w io.Writer, event Event) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("encode realtime event: %w", err)
+	}
+
+	_, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event.Action, data)
+	return err
+}
+
+// WebSocketHandler upgrades the connection to a WebSocket.
+// Subscriptions can be given in the query string like for SSE, and changed with subscribe and unsubscribe messages.
+func (s *Service) WebSocketHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	subs, err := s.parseSubscriptions(r.URL.Query()["subscribe"])
+	if err != nil {
+		httputil.HandleResponse(ctx, w, nil, err)
+		return
+	}
+
+	principal := auth.PrincipalFromCtx(ctx)
+	server := websocket.Server{
+		// Browsers send the access token of the query string from any page, so only the
+		// allowed origins can open a connection
+		Handshake: func(_ *websocket.Config, r *http.Request) error {
+			if !originAllowed(r.Header.Get("Origin"), s.allowedOrigins) {
+				return fmt.Errorf("origin %s is not allowed", r.Header.Get("Origin"))
+			}
+			return nil
+		},
+		Handler: func(ws *websocket.Conn) {
+			s.serveWebSocket(ctx, principal, subs, ws)
+		},
+	}
+	server.ServeHTTP(w, r)
+}
+
+func (s *Service) serveWebSocket(ctx context.Context, principal model.Principal, subs []Subscription, ws *websocket.Conn) {
+	// The server read and write timeouts would otherwise close the connection
+	_ = ws.SetDeadline(time.Time{})
+
+	c := s.connect(ctx, principal)
+	defer s.disconnect(c)
+	for _, sub := range subs {
+		c.subscribe(sub)
+	}
+
+	go s.readWebSocket(c, ws)
+
+	heartbeat := time.NewTicker(s.cfg.heartbeat())
+	defer heartbeat.Stop()
+
+	for {
+		var msg Message
+		select {
+		case <-c.done:
+			return
+		case event := <-c.events:
+			msg = Message{Type: MessageEvent, Event: &event}
+		case <-heartbeat.C:
+			msg = Message{Type: MessagePing}
+		}
+
+		if err := websocket.JSON.Send(ws, msg); err != nil {
+			return
+		}
+	}
+}
+
+// readWebSocket handles the client messages until the connection is closed
+func (s *Service) readWebSocket(c *client, ws *websocket.Conn) {
+	defer c.close()
+
+	for {
+		var msg Message
+		if err := websocket.JSON.Receive(ws, &msg); err != nil {
+			return
+		}
+
+		if err := websocket.JSON.Send(ws, s.handleMessage(c, msg)); err != nil {
+			return
+		}
+	}
+}
+
+// handleMessage applies a client message and returns the reply
+func (s *Service) handleMessage(c *client, msg Message) Message {
+	if msg.Subscription == nil {
+		return Message{Type: MessageError, Error: "subscription is required"}
+	}
+
+	switch msg.Type {
+	case MessageSubscribe:
+		if err := s.subscribe(c, *msg.Subscription); err != nil {
+			return Message{Type: MessageError, Subscription: msg.Subscription, Error: err.Error()}
+		}
+		return Message{Type: MessageSubscribed, Subscription: msg.Subscription}
+	case MessageUnsubscribe:
+		c.unsubscribe(*msg.Subscription)
+		return Message{Type: MessageUnsubscribed, Subscription: msg.Subscription}
+	}
+
+	return Message{Type: MessageError, Error: fmt.Sprintf("unknown message type %q", msg.Type)}
+}
+
+// parseSubscriptions parses subscriptions in the table or table:record_id form
+func (s *Service) parseSubscriptions(values []string) ([]Subscription, error) {
+	subs := make([]Subscription, 0, len(values))
+	for _, value := range values {
+		table, recordID, _ := strings.Cut(value, ":")
+		sub := Subscription{Table: table, RecordID: recordID}
+		if err := s.validateSubscription(sub); err != nil {
+			return nil, err
+		}
+		subs = append(subs, sub)
+	}
+
+	return subs, nil
+}
@@ -0,0 +1,76 @@
+package realtime
+
+import (
+	"context"
+	"sync"
+
+	"github.com/tuongaz/go-saas/core/auth/model"
+	"github.com/tuongaz/go-saas/pkg/log"
+)
+
+// client is a connected subscriber
+type client struct {
+	ctx       context.Context
+	principal model.Principal
+	events    chan Event
+
+	done      chan struct{}
+	closeOnce sync.Once
+
+	mu            sync.RWMutex
+	subscriptions map[Subscription]bool
+}
+
+func newClient(ctx context.Context, principal model.Principal, buffer int) *client {
+	return &client{
+		ctx:           ctx,
+		principal:     principal,
+		events:        make(chan Event, buffer),
+		done:          make(chan struct{}),
+		subscriptions: map[Subscription]bool{},
+	}
+}
+
+func (c *client) subscribe(sub Subscription) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.subscriptions[sub] = true
+}
+
+func (c *client) unsubscribe(sub Subscription) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.subscriptions, sub)
+}
+
+func (c *client) subscribed(event Event) bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	for sub := range c.subscriptions {
+		if sub.matches(event) {
+			return true
+		}
+	}
+	return false
+}
+
+// send queues an event without blocking the publisher.
+// A client that cannot keep up is closed, it is expected to reconnect and reload.
+func (c *client) send(event Event) {
+	select {
+	case <-c.done:
+	case c.events <- event:
+	default:
+		log.Default().WarnContext(c.ctx, "realtime client is too slow, closing it", "account_id", c.principal.AccountID)
+		c.close()
+	}
+}
+
+func (c *client) close() {
+	c.closeOnce.Do(func() {
+		close(c.done)
+	})
+}
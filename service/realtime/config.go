@@ -0,0 +1,42 @@
+package realtime
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+type Config struct {
+	// Tables are the collections available to subscribers, their events are delivered
+	// to the members of the organisation that owns the record. The events of the tables
+	// published to the change feed reach the clients of every replica, the events of the
+	// other tables come from the record hooks and only reach the clients of the replica
+	// that wrote them.
+	Tables           []string `mapstructure:"GOS_REALTIME_TABLES"`
+	ClientBuffer     int      `mapstructure:"GOS_REALTIME_CLIENT_BUFFER"`
+	HeartbeatSeconds int      `mapstructure:"GOS_REALTIME_HEARTBEAT_SECONDS"`
+}
+
+func SetDefault(key string, value any) {
+	viper.SetDefault(key, value)
+}
+
+func newConfig() (*Config, error) {
+	viper.AutomaticEnv()
+
+	SetDefault("GOS_REALTIME_TABLES", []string{"organisation"})
+	SetDefault("GOS_REALTIME_CLIENT_BUFFER", 64)
+	SetDefault("GOS_REALTIME_HEARTBEAT_SECONDS", 25)
+
+	var cfg Config
+	if err := viper.Unmarshal(&cfg); err != nil {
+		return nil, fmt.Errorf("unable to unmarshal config: %w", err)
+	}
+
+	return &cfg, nil
+}
+
+func (c *Config) heartbeat() time.Duration {
+	return time.Duration(c.HeartbeatSeconds) * time.Second
+}
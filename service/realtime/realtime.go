@@ -0,0 +1,308 @@
+package realtime
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/tuongaz/go-saas/core"
+	"github.com/tuongaz/go-saas/core/auth/model"
+	authStore "github.com/tuongaz/go-saas/core/auth/store"
+	"github.com/tuongaz/go-saas/pkg/apierror"
+	"github.com/tuongaz/go-saas/pkg/log"
+	"github.com/tuongaz/go-saas/store"
+	"github.com/tuongaz/go-saas/store/changes"
+	"github.com/tuongaz/go-saas/store/types"
+)
+
+const (
+	ActionCreate  = changes.ActionCreate
+	ActionUpdate  = changes.ActionUpdate
+	ActionDelete  = changes.ActionDelete
+	ActionRestore = changes.ActionRestore
+)
+
+// Event is a record change delivered to the subscribers
+type Event struct {
+	Table    string       `json:"table"`
+	RecordID string       `json:"record_id"`
+	Action   string       `json:"action"`
+	Record   types.Record `json:"record"`
+}
+
+// Subscription selects the events of a collection, or of a single record when RecordID is set
+type Subscription struct {
+	Table    string `json:"table"`
+	RecordID string `json:"record_id,omitempty"`
+}
+
+func (s Subscription) matches(e Event) bool {
+	return s.Table == e.Table && (s.RecordID == "" || s.RecordID == e.RecordID)
+}
+
+// Authorizer checks if the principal may receive an event of a collection
+type Authorizer func(ctx context.Context, principal model.Principal, event Event) bool
+
+// TenantAuthorizer allows the events of the records owned by the organisation of the principal
+func TenantAuthorizer(ctx context.Context, principal model.Principal, event Event) bool {
+	organisationID := event.Record.String(store.TenantColumn)
+	if event.Table == authStore.TableOrganisation {
+		organisationID = event.RecordID
+	}
+
+	return organisationID != "" && organisationID == principal.OrganisationID
+}
+
+type Service struct {
+	app core.AppInterface
+	cfg *Config
+
+	// allowedOrigins are the origins allowed to open a WebSocket, see originAllowed
+	allowedOrigins []string
+
+	// feedTables are the tables published to the change feed, nil when the feed is disabled
+	feedTables []string
+
+	mu          sync.RWMutex
+	authorizers map[string]Authorizer
+	clients     map[*client]struct{}
+}
+
+func MustRegister(app core.AppInterface) *Service {
+	cfg, err := newConfig()
+	if err != nil {
+		panic(fmt.Errorf("new realtime config: %w", err))
+	}
+
+	s := &Service{
+		app:            app,
+		cfg:            cfg,
+		allowedOrigins: app.Config().CORSAllowedOrigins,
+		authorizers:    map[string]Authorizer{},
+		clients:        map[*client]struct{}{},
+	}
+
+	for _, table := range cfg.Tables {
+		s.Register(table, TenantAuthorizer)
+	}
+
+	// The change feed delivers the changes committed by every replica. The events of the
+	// other tables come from the record hooks, so only the clients of the replica that
+	// wrote the record receive them.
+	if app.Config().ChangeFeedEnabled {
+		s.feedTables = app.Config().ChangeFeedTables
+	}
+	for _, table := range cfg.Tables {
+		if !s.fromFeed(table) {
+			log.Default().Warn("realtime collection is not published to the change feed, its events are only delivered by this replica, add it to GOS_CHANGE_FEED_TABLES", "table", table)
+		}
+	}
+	app.Changes().Subscribe(changes.AllTables, s.publishChange)
+	s.registerRecordHooks()
+
+	app.OnBeforeServe().Add(func(ctx context.Context, e *core.OnBeforeServeEvent) error {
+		// Browsers cannot set headers on EventSource and WebSocket requests,
+		// so the access token can also be passed in the query string.
+		// The server logs the request URIs without it.
+		e.App.PublicRoute("/realtime", func(r core.Router) {
+			r.Use(tokenFromQuery, app.Auth().NewMiddleware())
+			r.Get("/sse", s.SSEHandler)
+			r.Get("/ws", s.WebSocketHandler)
+		})
+
+		return nil
+	})
+
+	return s
+}
+
+// Register makes a collection available to subscribers.
+// Every event of the collection is checked with authorize before it is delivered to a client.
+func (s *Service) Register(table string, authorize Authorizer) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.authorizers[table] = authorize
+}
+
+func (s *Service) authorizer(table string) (Authorizer, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	authorize, ok := s.authorizers[table]
+	return authorize, ok
+}
+
+// Publish delivers an event to the subscribed clients that are authorized to receive it
+func (s *Service) Publish(event Event) {
+	authorize, ok := s.authorizer(event.Table)
+	if !ok {
+		return
+	}
+
+	s.mu.RLock()
+	clients := slices.Collect(maps.Keys(s.clients))
+	s.mu.RUnlock()
+
+	for _, c := range clients {
+		if !c.subscribed(event) || !authorize(c.ctx, c.principal, event) {
+			continue
+		}
+		c.send(event)
+	}
+}
+
+// connect adds a client of the principal
+func (s *Service) connect(ctx context.Context, principal model.Principal) *client {
+	c := newClient(ctx, principal, s.cfg.ClientBuffer)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.clients[c] = struct{}{}
+	return c
+}
+
+// disconnect removes a client
+func (s *Service) disconnect(c *client) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.clients, c)
+	c.close()
+}
+
+// subscribe adds a subscription to a client
+func (s *Service) subscribe(c *client, sub Subscription) error {
+	if err := s.validateSubscription(sub); err != nil {
+		return err
+	}
+
+	c.subscribe(sub)
+	return nil
+}
+
+// validateSubscription checks that the collection of a subscription is registered
+func (s *Service) validateSubscription(sub Subscription) error {
+	if sub.Table == "" {
+		return apierror.NewValidationError("table is required", nil)
+	}
+	if _, ok := s.authorizer(sub.Table); !ok {
+		return apierror.NewValidationError(fmt.Sprintf("collection %s is not available for realtime subscriptions", sub.Table), nil)
+	}
+
+	return nil
+}
+
+func tokenFromQuery(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if token := r.URL.Query().Get("access_token"); token != "" && r.Header.Get("Authorization") == "" {
+			r.Header.Set("Authorization", "Bearer "+token)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// fromFeed checks if the events of the table are delivered by the change feed
+func (s *Service) fromFeed(table string) bool {
+	return slices.Contains(s.feedTables, table)
+}
+
+// registerRecordHooks publishes the record changes of the tables that are not published to the change feed
+func (s *Service) registerRecordHooks() {
+	s.app.OnAfterRecordCreated().Add(func(ctx context.Context, e *core.OnAfterRecordCreatedEvent) error {
+		s.publishRecord(e.Table, ActionCreate, e.Record)
+		return nil
+	})
+
+	s.app.OnAfterRecordUpdated().Add(func(ctx context.Context, e *core.OnAfterRecordUpdatedEvent) error {
+		s.publishRecord(e.Table, ActionUpdate, e.Record)
+		return nil
+	})
+
+	s.app.OnAfterRecordDeleted().Add(func(ctx context.Context, e *core.OnAfterRecordDeletedEvent) error {
+		s.publishRecord(e.Table, ActionDelete, e.Record)
+		return nil
+	})
+
+	s.app.OnAfterRecordRestored().Add(func(ctx context.Context, e *core.OnAfterRecordRestoredEvent) error {
+		s.publishRecord(e.Table, ActionRestore, e.Record)
+		return nil
+	})
+}
+
+// publishRecord publishes a record change of this replica, unless the change feed delivers it
+func (s *Service) publishRecord(table, action string, record types.Record) {
+	if s.fromFeed(table) {
+		return
+	}
+
+	s.Publish(newEvent(table, action, record))
+}
+
+// publishChange publishes a change of the feed to the subscribers of this replica
+func (s *Service) publishChange(ctx context.Context, change changes.Change) {
+	if change.Action == changes.ActionPurge || !s.fromFeed(change.Table) {
+		return
+	}
+
+	s.Publish(eventFromChange(change))
+}
+
+// eventFromChange converts a change to an event, the record is the change payload when the feed
+// includes it, otherwise only its id and organisation
+func eventFromChange(change changes.Change) Event {
+	record := types.Record{}
+	if len(change.Payload) > 0 {
+		if err := change.Decode(&record); err != nil {
+			log.Default().Warn("failed to decode realtime change payload", "table", change.Table, "seq", change.Seq, log.ErrorAttr(err))
+			record = types.Record{}
+		}
+	}
+	record["id"] = change.RecordID
+	if change.OrganisationID != "" && change.Table != authStore.TableOrganisation {
+		record[store.TenantColumn] = change.OrganisationID
+	}
+
+	return newEvent(change.Table, change.Action, record)
+}
+
+// originAllowed checks the origin of a WebSocket request against the allowed origins.
+// An allowed origin can contain one wildcard, e.g. https://*.example.com, like the CORS
+// allowed origins. Requests without an origin do not come from a browser and are allowed.
+func originAllowed(origin string, allowed []string) bool {
+	if origin == "" {
+		return true
+	}
+
+	origin = strings.ToLower(origin)
+	for _, pattern := range allowed {
+		pattern = strings.ToLower(pattern)
+		prefix, suffix, wildcard := strings.Cut(pattern, "*")
+		switch {
+		case pattern == "*":
+			return true
+		case wildcard:
+			if len(origin) >= len(prefix)+len(suffix) && strings.HasPrefix(origin, prefix) && strings.HasSuffix(origin, suffix) {
+				return true
+			}
+		case origin == pattern:
+			return true
+		}
+	}
+
+	return false
+}
+
+func newEvent(table, action string, record types.Record) Event {
+	return Event{
+		Table:    table,
+		RecordID: record.String("id"),
+		Action:   action,
+		Record:   record,
+	}
+}
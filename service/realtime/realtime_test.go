@@ -0,0 +1,235 @@
+package realtime
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/websocket"
+
+	"github.com/tuongaz/go-saas/core/auth"
+	"github.com/tuongaz/go-saas/core/auth/model"
+	"github.com/tuongaz/go-saas/store/changes"
+	"github.com/tuongaz/go-saas/store/types"
+)
+
+func newTestService() *Service {
+	s := &Service{
+		cfg:         &Config{ClientBuffer: 2, HeartbeatSeconds: 60},
+		authorizers: map[string]Authorizer{},
+		clients:     map[*client]struct{}{},
+	}
+	s.Register("organisation", TenantAuthorizer)
+	s.Register("project", TenantAuthorizer)
+	return s
+}
+
+func TestTenantAuthorizer(t *testing.T) {
+	principal := model.Principal{OrganisationID: "org1", AccountID: "acc1"}
+	ctx := context.Background()
+
+	assert.True(t, TenantAuthorizer(ctx, principal, Event{Table: "project", Record: types.Record{"organisation_id": "org1"}}))
+	assert.False(t, TenantAuthorizer(ctx, principal, Event{Table: "project", Record: types.Record{"organisation_id": "org2"}}))
+	assert.False(t, TenantAuthorizer(ctx, principal, Event{Table: "project", Record: types.Record{}}))
+	assert.True(t, TenantAuthorizer(ctx, principal, Event{Table: "organisation", RecordID: "org1", Record: types.Record{"id": "org1"}}))
+	assert.False(t, TenantAuthorizer(ctx, principal, Event{Table: "organisation", RecordID: "org2", Record: types.Record{"id": "org2"}}))
+}
+
+func TestPublish(t *testing.T) {
+	s := newTestService()
+	ctx := context.Background()
+
+	tenant1 := s.connect(ctx, model.Principal{OrganisationID: "org1"})
+	tenant2 := s.connect(ctx, model.Principal{OrganisationID: "org2"})
+	single := s.connect(ctx, model.Principal{OrganisationID: "org1"})
+	assert.NoError(t, s.subscribe(tenant1, Subscription{Table: "project"}))
+	assert.NoError(t, s.subscribe(tenant2, Subscription{Table: "project"}))
+	assert.NoError(t, s.subscribe(single, Subscription{Table: "project", RecordID: "p2"}))
+
+	s.Publish(newEvent("project", ActionCreate, types.Record{"id": "p1", "organisation_id": "org1"}))
+	s.Publish(newEvent("project", ActionUpdate, types.Record{"id": "p2", "organisation_id": "org1"}))
+	s.Publish(newEvent("account", ActionUpdate, types.Record{"id": "a1", "organisation_id": "org1"}))
+
+	assert.Len(t, tenant1.events, 2)
+	assert.Len(t, tenant2.events, 0)
+	assert.Len(t, single.events, 1)
+	assert.Equal(t, "p2", (<-single.events).RecordID)
+
+	t.Run("slow clients are closed", func(t *testing.T) {
+		s.Publish(newEvent("project", ActionDelete, types.Record{"id": "p3", "organisation_id": "org1"}))
+
+		select {
+		case <-tenant1.done:
+		default:
+			t.Error("expected the slow client to be closed")
+		}
+	})
+
+	t.Run("unsubscribe", func(t *testing.T) {
+		single.unsubscribe(Subscription{Table: "project", RecordID: "p2"})
+		s.Publish(newEvent("project", ActionUpdate, types.Record{"id": "p2", "organisation_id": "org1"}))
+		assert.Len(t, single.events, 0)
+	})
+
+	t.Run("unregistered collections are rejected", func(t *testing.T) {
+		assert.Error(t, s.subscribe(tenant2, Subscription{Table: "account"}))
+	})
+}
+
+func TestParseSubscriptions(t *testing.T) {
+	s := newTestService()
+
+	subs, err := s.parseSubscriptions([]string{"project", "project:p1"})
+	assert.NoError(t, err)
+	assert.Equal(t, []Subscription{{Table: "project"}, {Table: "project", RecordID: "p1"}}, subs)
+
+	_, err = s.parseSubscriptions([]string{"account"})
+	assert.Error(t, err)
+
+	_, err = s.parseSubscriptions([]string{":p1"})
+	assert.Error(t, err)
+}
+
+func TestHandleMessage(t *testing.T) {
+	s := newTestService()
+	c := newClient(context.Background(), model.Principal{OrganisationID: "org1"}, 1)
+
+	reply := s.handleMessage(c, Message{Type: MessageSubscribe, Subscription: &Subscription{Table: "project"}})
+	assert.Equal(t, MessageSubscribed, reply.Type)
+	assert.True(t, c.subscribed(Event{Table: "project", RecordID: "p1"}))
+
+	reply = s.handleMessage(c, Message{Type: MessageSubscribe, Subscription: &Subscription{Table: "account"}})
+	assert.Equal(t, MessageError, reply.Type)
+
+	reply = s.handleMessage(c, Message{Type: MessageUnsubscribe, Subscription: &Subscription{Table: "project"}})
+	assert.Equal(t, MessageUnsubscribed, reply.Type)
+	assert.False(t, c.subscribed(Event{Table: "project", RecordID: "p1"}))
+
+	reply = s.handleMessage(c, Message{Type: "unknown", Subscription: &Subscription{Table: "project"}})
+	assert.Equal(t, MessageError, reply.Type)
+}
+
+func TestSSEHandler(t *testing.T) {
+	s := newTestService()
+
+	ctx, cancel := context.WithCancel(auth.PrincipalToCtx(context.Background(), model.Principal{OrganisationID: "org1"}))
+	req := httptest.NewRequest(http.MethodGet, "/realtime/sse?subscribe=project", nil).WithContext(ctx)
+	w := httptest.NewRecorder()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		s.SSEHandler(w, req)
+	}()
+
+	// Wait for the client to connect
+	assert.Eventually(t, func() bool {
+		s.mu.RLock()
+		defer s.mu.RUnlock()
+		return len(s.clients) == 1
+	}, time.Second, time.Millisecond)
+
+	s.Publish(newEvent("project", ActionCreate, types.Record{"id": "p1", "organisation_id": "org1"}))
+	s.Publish(newEvent("project", ActionCreate, types.Record{"id": "p2", "organisation_id": "org2"}))
+
+	assert.Eventually(t, func() bool {
+		s.mu.RLock()
+		defer s.mu.RUnlock()
+		for c := range s.clients {
+			return len(c.events) == 0
+		}
+		return false
+	}, time.Second, time.Millisecond)
+
+	cancel()
+	<-done
+
+	body := w.Body.String()
+	assert.Equal(t, "text/event-stream", w.Header().Get("Content-Type"))
+	assert.True(t, strings.Contains(body, "event: create\ndata: {"), body)
+	assert.True(t, strings.Contains(body, `"record_id":"p1"`), body)
+	assert.False(t, strings.Contains(body, `"record_id":"p2"`), body)
+	assert.Empty(t, s.clients)
+}
+
+func TestPublish_FeedAndHooks(t *testing.T) {
+	s := newTestService()
+	s.feedTables = []string{"project"}
+	c := s.connect(context.Background(), model.Principal{OrganisationID: "org1"})
+	assert.NoError(t, s.subscribe(c, Subscription{Table: "organisation"}))
+	assert.NoError(t, s.subscribe(c, Subscription{Table: "project"}))
+
+	// The project events come from the feed only, so they are not delivered twice
+	s.publishRecord("project", ActionCreate, types.Record{"id": "p1", "organisation_id": "org1"})
+	s.publishChange(context.Background(), changes.Change{Table: "project", RecordID: "p1", Action: changes.ActionCreate, OrganisationID: "org1"})
+	// The organisation table is not published to the feed, its events come from the hooks
+	s.publishRecord("organisation", ActionUpdate, types.Record{"id": "org1"})
+
+	if assert.Len(t, c.events, 2) {
+		assert.Equal(t, "project", (<-c.events).Table)
+		assert.Equal(t, "organisation", (<-c.events).Table)
+	}
+
+	t.Run("without the change feed", func(t *testing.T) {
+		s.feedTables = nil
+		s.publishRecord("project", ActionUpdate, types.Record{"id": "p1", "organisation_id": "org1"})
+		if assert.Len(t, c.events, 1) {
+			assert.Equal(t, ActionUpdate, (<-c.events).Action)
+		}
+	})
+}
+
+func TestEventFromChange(t *testing.T) {
+	event := eventFromChange(changes.Change{Table: "project", RecordID: "p1", Action: changes.ActionUpdate, OrganisationID: "org1"})
+	assert.Equal(t, newEvent("project", ActionUpdate, types.Record{"id": "p1", "organisation_id": "org1"}), event)
+
+	event = eventFromChange(changes.Change{
+		Table:          "project",
+		RecordID:       "p1",
+		Action:         changes.ActionCreate,
+		OrganisationID: "org1",
+		Payload:        []byte(`{"id":"p1","organisation_id":"org1","name":"Apollo"}`),
+	})
+	assert.Equal(t, "Apollo", event.Record.String("name"))
+	assert.True(t, TenantAuthorizer(context.Background(), model.Principal{OrganisationID: "org1"}, event))
+
+	event = eventFromChange(changes.Change{Table: "organisation", RecordID: "org1", Action: changes.ActionUpdate, OrganisationID: "org1"})
+	assert.Equal(t, types.Record{"id": "org1"}, event.Record)
+}
+
+func TestOriginAllowed(t *testing.T) {
+	allowed := []string{"https://app.example.com", "https://*.example.org"}
+
+	assert.True(t, originAllowed("", allowed))
+	assert.True(t, originAllowed("https://app.example.com", allowed))
+	assert.True(t, originAllowed("https://APP.example.com", allowed))
+	assert.True(t, originAllowed("https://admin.example.org", allowed))
+	assert.False(t, originAllowed("https://evil.com", allowed))
+	assert.False(t, originAllowed("http://app.example.com", allowed))
+	assert.True(t, originAllowed("https://evil.com", []string{"*"}))
+	assert.False(t, originAllowed("https://evil.com", nil))
+}
+
+func TestWebSocketHandler_Origin(t *testing.T) {
+	s := newTestService()
+	s.allowedOrigins = []string{"https://app.example.com"}
+	srv := httptest.NewServer(http.HandlerFunc(s.WebSocketHandler))
+	defer srv.Close()
+
+	dial := func(origin string) error {
+		cfg, err := websocket.NewConfig(strings.Replace(srv.URL, "http", "ws", 1), origin)
+		assert.NoError(t, err)
+		ws, err := websocket.DialConfig(cfg)
+		if err == nil {
+			_ = ws.Close()
+		}
+		return err
+	}
+
+	assert.NoError(t, dial("https://app.example.com"))
+	assert.Error(t, dial("https://evil.com"))
+}
@@ -1,40 +1,125 @@
 package scheduler
 
 import (
+	"context"
+	"sync"
 	"time"
 
+	"github.com/jmoiron/sqlx"
 	"github.com/tuongaz/go-saas/pkg/log"
+	"github.com/tuongaz/go-saas/store"
 )
 
-const lockID = 123456
+const (
+	lockID = 123456
 
-func (s *Scheduler) waitToAcquireAdvisoryLock() {
+	lockRetryInterval = 30 * time.Second
+)
+
+// AdvisoryLock elects a leader across replicas using the lock of the store dialect.
+// On Postgres it is a session-level advisory lock held on a dedicated connection,
+// so that it is released on the same session that acquired it, and lost automatically
+// if that connection dies.
+type AdvisoryLock struct {
+	db      *sqlx.DB
+	dialect store.Dialect
+	id      int64
+	name    string
+
+	mu       sync.Mutex
+	lock     store.Lock
+	isLeader bool
+	stop     chan struct{}
+}
+
+// NewAdvisoryLock creates a new advisory lock, name is only used for logging
+func NewAdvisoryLock(db *sqlx.DB, id int64, name string) *AdvisoryLock {
+	return &AdvisoryLock{
+		db:      db,
+		dialect: store.DialectOf(db),
+		id:      id,
+		name:    name,
+		stop:    make(chan struct{}),
+	}
+}
+
+// IsLeader reports whether the lock is currently held by this process
+func (l *AdvisoryLock) IsLeader() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.isLeader
+}
+
+// WaitToAcquire keeps trying to acquire the lock in the background until it is released
+func (l *AdvisoryLock) WaitToAcquire(interval time.Duration) {
 	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
 		for {
-			if v := s.tryAdvisoryLock(); v {
-				if s.isLeader == false {
-					log.Info("Acquired scheduler lock, become leader")
-				}
-				s.isLeader = true
+			l.TryAcquire(context.Background())
+
+			select {
+			case <-l.stop:
+				return
+			case <-ticker.C:
 			}
-			time.Sleep(30 * time.Second)
 		}
 	}()
 }
 
-func (s *Scheduler) tryAdvisoryLock() bool {
-	var success bool
-	err := s.app.Store().DB().QueryRow("SELECT pg_try_advisory_lock($1)", lockID).Scan(&success)
+// TryAcquire tries to acquire the lock and returns whether this process is the leader
+func (l *AdvisoryLock) TryAcquire(ctx context.Context) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.lock != nil {
+		// Make sure the lock is still held, e.g. by the session that acquired it
+		if err := l.lock.Check(ctx); err == nil {
+			return l.isLeader
+		}
+		log.Error("Lost advisory lock connection", "lock", l.name)
+		_ = l.lock.Release(ctx)
+		l.lock = nil
+		l.isLeader = false
+	}
+
+	lock, err := l.dialect.TryLock(ctx, l.db, l.id)
 	if err != nil {
-		log.Error("Error acquiring lock:", err)
+		log.Error("Error acquiring lock", "lock", l.name, "err", err)
 		return false
 	}
-	return success
+
+	if lock == nil {
+		return false
+	}
+
+	log.Info("Acquired advisory lock, become leader", "lock", l.name)
+	l.lock = lock
+	l.isLeader = true
+
+	return true
 }
 
-func (s *Scheduler) releaseAdvisoryLock() {
-	_, err := s.app.Store().DB().Exec("SELECT pg_advisory_unlock($1)", lockID)
-	if err != nil {
-		log.Error("Error releasing lock:", err)
+// Release stops trying to acquire the lock and releases it if it is held
+func (l *AdvisoryLock) Release() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	select {
+	case <-l.stop:
+	default:
+		close(l.stop)
+	}
+
+	if l.lock == nil {
+		return
+	}
+
+	if err := l.lock.Release(context.Background()); err != nil {
+		log.Error("Error releasing lock", "lock", l.name, "err", err)
 	}
+	l.lock = nil
+	l.isLeader = false
 }
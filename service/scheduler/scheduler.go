@@ -23,7 +23,7 @@ type Interface interface {
 type Scheduler struct {
 	scheduler gocron.Scheduler
 	app       core.AppInterface
-	isLeader  bool
+	lock      *AdvisoryLock
 }
 
 func newScheduler(app core.AppInterface) (*Scheduler, error) {
@@ -46,13 +46,16 @@ func MustRegister(app core.AppInterface) *Scheduler {
 	s.scheduler.Start()
 
 	app.OnDatabaseReady().Add(func(ctx context.Context, event *core.OnDatabaseReadyEvent) error {
-		s.waitToAcquireAdvisoryLock()
+		s.lock = NewAdvisoryLock(app.Store().DB(), lockID, "scheduler")
+		s.lock.WaitToAcquire(lockRetryInterval)
 
 		return nil
 	})
 
 	app.OnTerminate().Add(func(ctx context.Context, event *core.OnTerminateEvent) error {
-		s.releaseAdvisoryLock()
+		if s.lock != nil {
+			s.lock.Release()
+		}
 		return nil
 	})
 
@@ -82,7 +85,7 @@ func (s *Scheduler) RunEvery(d time.Duration, job func(), tags ...string) (id st
 	j, err := s.scheduler.NewJob(
 		gocron.DurationJob(d),
 		gocron.NewTask(func() {
-			if s.isLeader {
+			if s.isLeader() {
 				job()
 			}
 		}),
@@ -100,7 +103,7 @@ func (s *Scheduler) RunCron(cron string, job func(), tags ...string) (id string,
 	j, err := s.scheduler.NewJob(
 		gocron.CronJob(cron, true),
 		gocron.NewTask(func() {
-			if s.isLeader {
+			if s.isLeader() {
 				job()
 			}
 		}),
@@ -119,7 +122,7 @@ func (s *Scheduler) RunAt(t time.Time, job func(), tags ...string) (id string, e
 		gocron.OneTimeJob(gocron.OneTimeJobStartDateTime(t)),
 		gocron.NewTask(
 			func() {
-				if s.isLeader {
+				if s.isLeader() {
 					job()
 				}
 			},
@@ -133,6 +136,11 @@ func (s *Scheduler) RunAt(t time.Time, job func(), tags ...string) (id string, e
 	return j.ID().String(), nil
 }
 
+// isLeader reports whether this replica holds the scheduler lock and should run jobs
+func (s *Scheduler) isLeader() bool {
+	return s.lock != nil && s.lock.IsLeader()
+}
+
 func (s *Scheduler) uuid(id string) (uuid.UUID, error) {
 	uid, err := uuid.Parse(id)
 	if err != nil {
@@ -0,0 +1,303 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tuongaz/go-saas/store/types"
+)
+
+// AggregateFunc is a SQL aggregate function
+type AggregateFunc string
+
+const (
+	AggCount         AggregateFunc = "COUNT"
+	AggCountDistinct AggregateFunc = "COUNT DISTINCT"
+	AggSum           AggregateFunc = "SUM"
+	AggAvg           AggregateFunc = "AVG"
+	AggMin           AggregateFunc = "MIN"
+	AggMax           AggregateFunc = "MAX"
+)
+
+// Aggregation computes a value over the records of each group
+type Aggregation struct {
+	Func AggregateFunc
+
+	// Field is the aggregated column, it is empty to count the records
+	Field string
+
+	// Alias is the key of the value in the result rows
+	Alias string
+}
+
+// CountAll counts the records, as count
+func CountAll() Aggregation {
+	return Aggregation{Func: AggCount, Alias: "count"}
+}
+
+// CountDistinct counts the distinct non null values of the field, as count_distinct_{field}
+func CountDistinct(field string) Aggregation {
+	return Aggregation{Func: AggCountDistinct, Field: field, Alias: "count_distinct_" + field}
+}
+
+// Sum sums the field, as sum_{field}
+func Sum(field string) Aggregation {
+	return Aggregation{Func: AggSum, Field: field, Alias: "sum_" + field}
+}
+
+// Avg averages the field, as avg_{field}
+func Avg(field string) Aggregation {
+	return Aggregation{Func: AggAvg, Field: field, Alias: "avg_" + field}
+}
+
+// Min returns the smallest value of the field, as min_{field}
+func Min(field string) Aggregation {
+	return Aggregation{Func: AggMin, Field: field, Alias: "min_" + field}
+}
+
+// Max returns the largest value of the field, as max_{field}
+func Max(field string) Aggregation {
+	return Aggregation{Func: AggMax, Field: field, Alias: "max_" + field}
+}
+
+// As renames the aggregation in the result rows
+func (a Aggregation) As(alias string) Aggregation {
+	a.Alias = alias
+	return a
+}
+
+// expression returns the SQL of the aggregation. Sums and averages are returned as
+// double precision and counts as bigint, so that the values scan into float64 and int64.
+func (a Aggregation) expression() (string, error) {
+	if a.Field == "" {
+		if a.Func != AggCount {
+			return "", fmt.Errorf("aggregation %s requires a field", a.Func)
+		}
+		return "COUNT(*)", nil
+	}
+	if !ValidIdentifierName(a.Field) {
+		return "", fmt.Errorf("invalid aggregation field: %s", a.Field)
+	}
+
+	switch a.Func {
+	case AggCount:
+		return fmt.Sprintf("COUNT(%s)", a.Field), nil
+	case AggCountDistinct:
+		return fmt.Sprintf("COUNT(DISTINCT %s)", a.Field), nil
+	case AggSum, AggAvg:
+		return fmt.Sprintf("CAST(%s(%s) AS DOUBLE PRECISION)", a.Func, a.Field), nil
+	case AggMin, AggMax:
+		return fmt.Sprintf("%s(%s)", a.Func, a.Field), nil
+	}
+
+	return "", fmt.Errorf("unknown aggregate function: %s", a.Func)
+}
+
+// DatePart is the precision date groups are truncated to
+type DatePart string
+
+const (
+	DateHour    DatePart = "hour"
+	DateDay     DatePart = "day"
+	DateWeek    DatePart = "week"
+	DateMonth   DatePart = "month"
+	DateQuarter DatePart = "quarter"
+	DateYear    DatePart = "year"
+)
+
+// GroupBy groups the records by the value of a field
+type GroupBy struct {
+	Field string
+
+	// Truncate groups a timestamp field by date part, e.g. per month
+	Truncate DatePart
+
+	// Alias is the key of the group value in the result rows, it defaults to the field
+	Alias string
+}
+
+// GroupByField groups the records by the value of the field
+func GroupByField(field string) GroupBy {
+	return GroupBy{Field: field}
+}
+
+// GroupByDate groups the records by the timestamp field truncated to the date part,
+// in the time zone of the database session. The group values are the start of the periods.
+func GroupByDate(field string, part DatePart) GroupBy {
+	return GroupBy{Field: field, Truncate: part}
+}
+
+// As renames the group in the result rows
+func (g GroupBy) As(alias string) GroupBy {
+	g.Alias = alias
+	return g
+}
+
+func (g GroupBy) alias() string {
+	if g.Alias != "" {
+		return g.Alias
+	}
+	return g.Field
+}
+
+func (g GroupBy) expression() (string, error) {
+	if !ValidIdentifierName(g.Field) {
+		return "", fmt.Errorf("invalid group by field: %s", g.Field)
+	}
+
+	switch g.Truncate {
+	case "":
+		return g.Field, nil
+	case DateHour, DateDay, DateWeek, DateMonth, DateQuarter, DateYear:
+		return fmt.Sprintf("date_trunc('%s', %s)", g.Truncate, g.Field), nil
+	}
+
+	return "", fmt.Errorf("invalid date part: %s", g.Truncate)
+}
+
+// buildAggregateQuery builds the query of Aggregate
+func (c *collection) buildAggregateQuery(groups []GroupBy, aggregations []Aggregation, options *FindOptions) (string, []any, error) {
+	if len(aggregations) == 0 {
+		return "", nil, fmt.Errorf("aggregate requires at least one aggregation")
+	}
+	if len(options.Fields) > 0 || options.Cursor != nil || len(options.Expand) > 0 || options.Search != nil || options.Nearest != nil {
+		return "", nil, fmt.Errorf("aggregate does not support fields, cursor, expand, search or nearest options")
+	}
+
+	aliases := map[string]bool{}
+	columns := make([]string, 0, len(groups)+len(aggregations))
+	addColumn := func(expr, alias string) error {
+		if !ValidIdentifierName(alias) {
+			return fmt.Errorf("invalid alias: %s", alias)
+		}
+		if aliases[alias] {
+			return fmt.Errorf("duplicate alias: %s", alias)
+		}
+		aliases[alias] = true
+		columns = append(columns, expr+" AS "+alias)
+		return nil
+	}
+
+	groupBy := make([]string, len(groups))
+	for i, group := range groups {
+		expr, err := group.expression()
+		if err != nil {
+			return "", nil, err
+		}
+		if err := addColumn(expr, group.alias()); err != nil {
+			return "", nil, err
+		}
+		groupBy[i] = strconv.Itoa(i + 1)
+	}
+	for _, aggregation := range aggregations {
+		expr, err := aggregation.expression()
+		if err != nil {
+			return "", nil, err
+		}
+		if err := addColumn(expr, aggregation.Alias); err != nil {
+			return "", nil, err
+		}
+	}
+
+	for field := range options.Filter {
+		if !ValidIdentifierName(field) {
+			return "", nil, fmt.Errorf("invalid filter field: %s", field)
+		}
+	}
+	where := &whereBuilder{}
+	if options.AdvancedFilter != nil {
+		where.addExpression(options.AdvancedFilter.Expression)
+	} else {
+		where.addFilter(options.Filter)
+	}
+	c.addDeletedScope(where, options.Deleted)
+	c.addTenantScope(where)
+
+	query := "SELECT " + strings.Join(columns, ", ") + " FROM " + c.table + where.String()
+	if len(groupBy) > 0 {
+		query += " GROUP BY " + strings.Join(groupBy, ", ")
+	}
+
+	if len(options.Sort) > 0 {
+		for _, s := range options.Sort {
+			if !aliases[s.Field] || s.Operator != "" {
+				return "", nil, fmt.Errorf("aggregate can only be sorted by a group or an aggregation, not %s", s.Field)
+			}
+		}
+		orderBy, err := buildOrderBy(options.Sort)
+		if err != nil {
+			return "", nil, err
+		}
+		query += orderBy
+	} else if len(groupBy) > 0 {
+		query += " ORDER BY " + strings.Join(groupBy, ", ")
+	}
+
+	if options.Pagination != nil {
+		if options.Pagination.Limit > 0 {
+			query += fmt.Sprintf(" LIMIT %d", options.Pagination.Limit)
+		}
+		if options.Pagination.Offset > 0 {
+			query += fmt.Sprintf(" OFFSET %d", options.Pagination.Offset)
+		}
+	}
+
+	return query, where.args, nil
+}
+
+// Aggregate computes the aggregations over the records matching the find options, one row per group.
+// The rows are keyed by the group and aggregation aliases. Counts are int64, sums and averages float64,
+// and date groups time.Time. The filter, sort, pagination and deleted find options apply,
+// and the rows can only be sorted by the aliases.
+//
+// Example, the number and amount of the paid invoices per month:
+//
+//	collection.Aggregate(ctx,
+//		[]GroupBy{GroupByDate("created_at", DateMonth).As("month")},
+//		[]Aggregation{CountAll(), Sum("amount_in_cents").As("amount")},
+//		WithFilter(Filter{"status": "paid"}),
+//	)
+func (c *collection) Aggregate(ctx context.Context, groups []GroupBy, aggregations []Aggregation, opts ...FindOption) ([]types.Record, error) {
+	query, args, err := c.buildAggregateQuery(groups, aggregations, findOptions(opts))
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := c.reads().QueryxContext(ctx, query, args...)
+	if err != nil {
+		return nil, handleDBError(err)
+	}
+	defer rows.Close()
+
+	recs, err := scanRecords(rows)
+	if err != nil {
+		return nil, err
+	}
+	for _, rec := range recs {
+		normaliseAggregateRow(rec)
+	}
+
+	return recs, nil
+}
+
+// normaliseAggregateRow converts the numeric values, returned as text by the driver, into numbers
+func normaliseAggregateRow(row types.Record) {
+	for key, value := range row {
+		switch v := value.(type) {
+		case []byte:
+			s := string(v)
+			if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+				row[key] = n
+			} else if f, err := strconv.ParseFloat(s, 64); err == nil {
+				row[key] = f
+			} else {
+				row[key] = s
+			}
+		case time.Time:
+			row[key] = v.UTC()
+		}
+	}
+}
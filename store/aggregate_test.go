@@ -0,0 +1,94 @@
+package store
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/tuongaz/go-saas/store/types"
+)
+
+func TestCollection_BuildAggregateQuery(t *testing.T) {
+	c := &collection{table: "invoice", softDelete: true, organisationID: "org_1"}
+
+	query, args, err := c.buildAggregateQuery(
+		[]GroupBy{GroupByDate("created_at", DateMonth).As("month"), GroupByField("status")},
+		[]Aggregation{CountAll(), Sum("amount").As("total"), CountDistinct("customer_id")},
+		findOptions([]FindOption{WithFilter(Filter{"currency": "usd"}), WithPagination(12, 0)}),
+	)
+	assert.NoError(t, err)
+	assert.Equal(t, "SELECT date_trunc('month', created_at) AS month, status AS status, COUNT(*) AS count, "+
+		"CAST(SUM(amount) AS DOUBLE PRECISION) AS total, COUNT(DISTINCT customer_id) AS count_distinct_customer_id "+
+		"FROM invoice WHERE currency = $1 AND deleted_at IS NULL AND organisation_id = $2 "+
+		"GROUP BY 1, 2 ORDER BY 1, 2 LIMIT 12", query)
+	assert.Equal(t, []any{"usd", "org_1"}, args)
+
+	t.Run("without groups", func(t *testing.T) {
+		query, args, err := (&collection{table: "invoice"}).buildAggregateQuery(nil, []Aggregation{Avg("amount"), Max("created_at")}, findOptions(nil))
+		assert.NoError(t, err)
+		assert.Equal(t, "SELECT CAST(AVG(amount) AS DOUBLE PRECISION) AS avg_amount, MAX(created_at) AS max_created_at FROM invoice", query)
+		assert.Empty(t, args)
+	})
+
+	t.Run("sorted by an alias", func(t *testing.T) {
+		query, _, err := (&collection{table: "invoice"}).buildAggregateQuery(
+			[]GroupBy{GroupByField("status")},
+			[]Aggregation{CountAll()},
+			findOptions([]FindOption{WithSort(SortOption{Field: "count", Direction: SortDesc})}),
+		)
+		assert.NoError(t, err)
+		assert.Equal(t, "SELECT status AS status, COUNT(*) AS count FROM invoice GROUP BY 1 ORDER BY count DESC", query)
+	})
+}
+
+func TestCollection_BuildAggregateQueryErrors(t *testing.T) {
+	c := &collection{table: "invoice"}
+	tests := map[string]struct {
+		groups       []GroupBy
+		aggregations []Aggregation
+		opts         []FindOption
+		err          string
+	}{
+		"no aggregation":      {groups: []GroupBy{GroupByField("status")}, err: "aggregate requires at least one aggregation"},
+		"group field":         {groups: []GroupBy{GroupByField("status; DROP TABLE invoice")}, aggregations: []Aggregation{CountAll()}, err: "invalid group by field: status; DROP TABLE invoice"},
+		"date part":           {groups: []GroupBy{GroupByDate("created_at", "month', created_at)")}, aggregations: []Aggregation{CountAll()}, err: "invalid date part: month', created_at)"},
+		"aggregation field":   {aggregations: []Aggregation{Sum("amount)")}, err: "invalid aggregation field: amount)"},
+		"missing field":       {aggregations: []Aggregation{{Func: AggSum, Alias: "total"}}, err: "aggregation SUM requires a field"},
+		"unknown function":    {aggregations: []Aggregation{{Func: "pg_sleep", Field: "amount", Alias: "total"}}, err: "unknown aggregate function: pg_sleep"},
+		"alias":               {aggregations: []Aggregation{CountAll().As("count(*)")}, err: "invalid alias: count(*)"},
+		"duplicate alias":     {groups: []GroupBy{GroupByField("count")}, aggregations: []Aggregation{CountAll()}, err: "duplicate alias: count"},
+		"filter field":        {aggregations: []Aggregation{CountAll()}, opts: []FindOption{WithFilter(Filter{"1=1 OR status": "paid"})}, err: "invalid filter field: 1=1 OR status"},
+		"sort by a column":    {aggregations: []Aggregation{CountAll()}, opts: []FindOption{WithSort(SortOption{Field: "amount"})}, err: "aggregate can only be sorted by a group or an aggregation, not amount"},
+		"unsupported options": {aggregations: []Aggregation{CountAll()}, opts: []FindOption{WithFields("id")}, err: "aggregate does not support fields, cursor, expand, search or nearest options"},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, _, err := c.buildAggregateQuery(tt.groups, tt.aggregations, findOptions(tt.opts))
+			assert.EqualError(t, err, tt.err)
+		})
+	}
+}
+
+func TestNormaliseAggregateRow(t *testing.T) {
+	month := time.Date(2025, 3, 1, 0, 0, 0, 0, time.FixedZone("AEDT", 11*60*60))
+	row := types.Record{
+		"month":  month,
+		"status": "paid",
+		"count":  int64(3),
+		"total":  []byte("1250.5"),
+		"max":    []byte("42"),
+		"code":   []byte("AUD"),
+	}
+	normaliseAggregateRow(row)
+
+	assert.Equal(t, types.Record{
+		"month":  month.UTC(),
+		"status": "paid",
+		"count":  int64(3),
+		"total":  1250.5,
+		"max":    int64(42),
+		"code":   "AUD",
+	}, row)
+}
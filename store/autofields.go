@@ -0,0 +1,95 @@
+package store
+
+import (
+	"time"
+
+	"github.com/tuongaz/go-saas/pkg/uid"
+	"github.com/tuongaz/go-saas/store/types"
+)
+
+// createdAtColumn is the column set on insert by the CreatedAt auto field
+const createdAtColumn = "created_at"
+
+// AutoFields are the fields a collection sets on writes.
+// Times come from the timer package, so tests can freeze them with timer.SetDefaultTimer.
+// Values set by the caller are kept on insert, empty strings and zero times count as unset.
+// On update, updated_at is always bumped and created_at is never written.
+type AutoFields struct {
+	// ID generates the id of inserted records
+	ID bool
+
+	// CreatedAt sets created_at on insert
+	CreatedAt bool
+
+	// UpdatedAt sets updated_at on insert and on every update
+	UpdatedAt bool
+
+	// Generator generates the ids, it defaults to uid.Default
+	Generator uid.Interface
+}
+
+// StandardAutoFields manage the id, created_at and updated_at columns
+var StandardAutoFields = AutoFields{ID: true, CreatedAt: true, UpdatedAt: true}
+
+// WithAutoFields makes the collection set the auto fields on writes
+func WithAutoFields(fields AutoFields) CollectionOption {
+	return func(c *collection) {
+		c.autoFields = &fields
+	}
+}
+
+func (f AutoFields) generateID() string {
+	if f.Generator != nil {
+		return f.Generator.Generate()
+	}
+	return uid.ID()
+}
+
+// setCreateFields sets the unset auto fields of a record to insert and returns the fields it set
+func (c *collection) setCreateFields(record types.Record, now time.Time) map[string]bool {
+	set := map[string]bool{}
+	if c.autoFields == nil {
+		return set
+	}
+
+	setField := func(enabled bool, field string, value func() any) {
+		if enabled && isUnset(record[field]) {
+			record[field] = value()
+			set[field] = true
+		}
+	}
+
+	setField(c.autoFields.ID, idColumn, func() any { return c.autoFields.generateID() })
+	setField(c.autoFields.CreatedAt, createdAtColumn, func() any { return now })
+	setField(c.autoFields.UpdatedAt, updatedAtColumn, func() any { return now })
+
+	return set
+}
+
+// setUpdateFields bumps the updated_at field of a record to update and drops its created_at field,
+// so that the stale values of a loaded record are not written back
+func (c *collection) setUpdateFields(record types.Record, now time.Time) {
+	if c.autoFields == nil {
+		return
+	}
+	if c.autoFields.CreatedAt {
+		delete(record, createdAtColumn)
+	}
+	if c.autoFields.UpdatedAt {
+		record[updatedAtColumn] = now
+	}
+}
+
+func isUnset(value any) bool {
+	switch v := value.(type) {
+	case nil:
+		return true
+	case string:
+		return v == ""
+	case time.Time:
+		return v.IsZero()
+	case *time.Time:
+		return v == nil || v.IsZero()
+	}
+	return false
+}
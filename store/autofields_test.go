@@ -0,0 +1,101 @@
+package store
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/tuongaz/go-saas/pkg/timer"
+	"github.com/tuongaz/go-saas/store/types"
+)
+
+type frozenTimer struct {
+	now time.Time
+}
+
+func (f frozenTimer) Now() time.Time {
+	return f.now
+}
+
+type fixedID string
+
+func (f fixedID) Generate() string {
+	return string(f)
+}
+
+func freezeTime(t *testing.T, now time.Time) {
+	previous := timer.DefaultTimer
+	timer.SetDefaultTimer(frozenTimer{now: now})
+	t.Cleanup(func() { timer.SetDefaultTimer(previous) })
+}
+
+func TestCollection_SetCreateFields(t *testing.T) {
+	now := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+	c := &collection{table: "invoice", autoFields: &AutoFields{ID: true, CreatedAt: true, UpdatedAt: true, Generator: fixedID("inv_1")}}
+
+	record := types.Record{"id": "", "amount": 100, "created_at": time.Time{}}
+	set := c.setCreateFields(record, now)
+	assert.Equal(t, types.Record{"id": "inv_1", "amount": 100, "created_at": now, "updated_at": now}, record)
+	assert.Equal(t, map[string]bool{"id": true, "created_at": true, "updated_at": true}, set)
+
+	t.Run("keeps the values set by the caller", func(t *testing.T) {
+		createdAt := now.Add(-time.Hour)
+		record := types.Record{"id": "inv_0", "created_at": createdAt}
+		set := c.setCreateFields(record, now)
+		assert.Equal(t, types.Record{"id": "inv_0", "created_at": createdAt, "updated_at": now}, record)
+		assert.Equal(t, map[string]bool{"updated_at": true}, set)
+	})
+
+	t.Run("only sets the enabled fields", func(t *testing.T) {
+		c := &collection{table: "audit_log", autoFields: &AutoFields{CreatedAt: true}}
+		record := types.Record{"action": "login"}
+		c.setCreateFields(record, now)
+		assert.Equal(t, types.Record{"action": "login", "created_at": now}, record)
+	})
+}
+
+func TestCollection_UpdateBumpsUpdatedAt(t *testing.T) {
+	now := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+	freezeTime(t, now)
+
+	ctx := context.Background()
+	db := &execRecorder{}
+	c := NewCollection("invoice", db, nil, WithAutoFields(StandardAutoFields))
+
+	_, err := c.Update(ctx, types.Record{"status": "paid"}, "id", "inv_1")
+	assert.NoError(t, err)
+	assert.Contains(t, db.query, "updated_at = $")
+	assert.Contains(t, db.args, now)
+
+	t.Run("not without auto fields", func(t *testing.T) {
+		c := NewCollection("invoice", db, nil)
+		_, err := c.Update(ctx, types.Record{"status": "paid"}, "id", "inv_1")
+		assert.NoError(t, err)
+		assert.Equal(t, "UPDATE invoice SET status = $1 WHERE id = $2", db.query)
+	})
+}
+
+// idList is an id generator of a non-comparable type
+type idList []string
+
+func (l idList) Generate() string {
+	return l[0]
+}
+
+func TestStore_AutoFields(t *testing.T) {
+	s := &Store{}
+	assert.Nil(t, s.AutoFields("invoice"))
+
+	s.EnableAutoFields(AutoFields{ID: true, Generator: idList{"inv_1"}}, "invoice")
+	assert.NotPanics(t, func() {
+		c := &collection{}
+		for _, opt := range s.collectionOptions("invoice") {
+			opt(c)
+		}
+		if assert.NotNil(t, c.autoFields) {
+			assert.Equal(t, "inv_1", c.autoFields.generateID())
+		}
+	})
+}
@@ -0,0 +1,217 @@
+package changes
+
+import (
+	"context"
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/tuongaz/go-saas/pkg/log"
+	"github.com/tuongaz/go-saas/store"
+	"github.com/tuongaz/go-saas/store/migrate"
+)
+
+//go:embed migrations/*.sql
+var migrations embed.FS
+
+func init() {
+	migrate.Register("changes", migrations, "migrations")
+}
+
+const (
+	tableChangeLog = "change_log"
+
+	// AllTables subscribes to the changes of every table
+	AllTables = "*"
+
+	ActionCreate  = store.ActionCreate
+	ActionUpdate  = store.ActionUpdate
+	ActionDelete  = store.ActionDelete
+	ActionRestore = store.ActionRestore
+	ActionPurge   = store.ActionPurge
+)
+
+var (
+	_ Interface = &Feed{}
+
+	ErrNotStarted = errors.New("change feed is not started")
+)
+
+// Interface is the change feed.
+// Published changes are stored in a sequence table and announced with Postgres NOTIFY,
+// so that every replica running the feed delivers them to its subscribers, in order.
+// A change committed after the gap timeout of its sequence number is delivered late,
+// out of order.
+type Interface interface {
+	Publish(ctx context.Context, change Change) error
+	PublishTx(ctx context.Context, tx *store.StoreTx, change Change) error
+	Subscribe(table string, fn Handler) (unsubscribe func())
+}
+
+// Handler receives the changes of a subscription.
+// Handlers are called one at a time from the feed worker, long running work should be handed off.
+type Handler func(ctx context.Context, change Change)
+
+// Change is a committed record change
+type Change struct {
+	Seq            int64           `json:"seq" db:"seq"`
+	Table          string          `json:"table" db:"table_name"`
+	RecordID       string          `json:"record_id" db:"record_id"`
+	Action         string          `json:"action" db:"action"`
+	OrganisationID string          `json:"organisation_id,omitempty" db:"organisation_id"`
+	Payload        json.RawMessage `json:"payload,omitempty" db:"payload"`
+	CreatedAt      time.Time       `json:"created_at" db:"created_at"`
+}
+
+// Decode decodes the change payload into obj
+func (c Change) Decode(obj any) error {
+	if err := json.Unmarshal(c.Payload, obj); err != nil {
+		return fmt.Errorf("decode change payload: %w", err)
+	}
+	return nil
+}
+
+type subscription struct {
+	table string
+	fn    Handler
+}
+
+// Feed publishes changes and delivers them to the subscribers of this process
+type Feed struct {
+	opts *options
+
+	db dbInterface
+
+	// tryLock acquires the lock electing the replica that purges the expired changes
+	tryLock func(ctx context.Context) (store.Lock, error)
+
+	mu            sync.RWMutex
+	subscriptions map[int]subscription
+	nextID        int
+
+	// lastSeq, gapSince and skipped are only used by the worker.
+	// skipped are the sequence numbers skipped after the gap timeout, with the time they were skipped.
+	lastSeq  int64
+	gapSince time.Time
+	skipped  map[int64]time.Time
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// New creates a change feed. Subscriptions can be added before the feed is started.
+func New(opts ...Option) *Feed {
+	o := defaultOptions()
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	return &Feed{
+		opts:          o,
+		subscriptions: map[int]subscription{},
+		skipped:       map[int64]time.Time{},
+	}
+}
+
+// Subscribe registers fn for the changes of table, or of every table with AllTables.
+// Only the changes published after the feed is started are delivered.
+func (f *Feed) Subscribe(table string, fn Handler) (unsubscribe func()) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	id := f.nextID
+	f.nextID++
+	f.subscriptions[id] = subscription{table: table, fn: fn}
+
+	return func() {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+
+		delete(f.subscriptions, id)
+	}
+}
+
+// Publish stores the change and notifies the listening replicas.
+// The change should already be committed, Seq and CreatedAt are set by the database.
+// Use PublishTx to publish the change of a record in the transaction writing it.
+func (f *Feed) Publish(ctx context.Context, change Change) error {
+	if f.db == nil {
+		return ErrNotStarted
+	}
+
+	if _, err := f.db.ExecContext(ctx, publishQuery, f.publishArgs(change)...); err != nil {
+		return fmt.Errorf("publish change: %w", err)
+	}
+
+	return nil
+}
+
+// PublishTx stores the change in the transaction, so that it is only published when the
+// transaction commits. The notification is delivered by Postgres on commit as well.
+func (f *Feed) PublishTx(ctx context.Context, tx *store.StoreTx, change Change) error {
+	if f.db == nil {
+		return ErrNotStarted
+	}
+
+	if err := tx.Exec(ctx, publishQuery, f.publishArgs(change)...); err != nil {
+		return fmt.Errorf("publish change: %w", err)
+	}
+
+	return nil
+}
+
+// publishQuery inserts a change and notifies its sequence.
+// The notification only carries the sequence, listeners read the changes from the table.
+const publishQuery = `WITH inserted AS (
+		INSERT INTO ` + tableChangeLog + ` (table_name, record_id, action, organisation_id, payload)
+		VALUES ($1, $2, $3, $4, $5) RETURNING seq
+	)
+	SELECT pg_notify($6, seq::text) FROM inserted`
+
+func (f *Feed) publishArgs(change Change) []any {
+	var payload any
+	if len(change.Payload) > 0 {
+		payload = string(change.Payload)
+	}
+
+	return []any{change.Table, change.RecordID, change.Action, change.OrganisationID, payload, f.opts.channel}
+}
+
+func (f *Feed) handlers(table string) []Handler {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	// Call the handlers in subscription order
+	var handlers []Handler
+	for _, id := range slices.Sorted(maps.Keys(f.subscriptions)) {
+		sub := f.subscriptions[id]
+		if sub.table == table || sub.table == AllTables {
+			handlers = append(handlers, sub.fn)
+		}
+	}
+
+	return handlers
+}
+
+// dispatch delivers a change to the subscribers of its table
+func (f *Feed) dispatch(ctx context.Context, change Change) {
+	for _, fn := range f.handlers(change.Table) {
+		f.call(ctx, fn, change)
+	}
+}
+
+// call runs a handler, recovering from panics so that one subscriber cannot stop the feed
+func (f *Feed) call(ctx context.Context, fn Handler, change Change) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Default().ErrorContext(ctx, "change feed handler panic", "table", change.Table, "seq", change.Seq, "panic", r)
+		}
+	}()
+
+	fn(ctx, change)
+}
@@ -0,0 +1,240 @@
+package changes
+
+import (
+	"context"
+	"database/sql"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/tuongaz/go-saas/store"
+	mocktimer "github.com/tuongaz/go-saas/testutils/mocks/timer"
+)
+
+// fakeDB serves the change log rows after the requested sequence, or with the requested sequences
+type fakeDB struct {
+	dbInterface
+	rows  []Change
+	execs []string
+}
+
+func (db *fakeDB) SelectContext(ctx context.Context, dest any, query string, args ...any) error {
+	out := dest.(*[]Change)
+	if seqs, ok := args[0].(pq.Int64Array); ok {
+		for _, row := range db.rows {
+			if slices.Contains(seqs, row.Seq) {
+				*out = append(*out, row)
+			}
+		}
+		return nil
+	}
+
+	after := args[0].(int64)
+	limit := args[1].(int)
+	for _, row := range db.rows {
+		if row.Seq > after && len(*out) < limit {
+			*out = append(*out, row)
+		}
+	}
+	return nil
+}
+
+func (db *fakeDB) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	db.execs = append(db.execs, query)
+	return nil, nil
+}
+
+type fakeLock struct {
+	store.Lock
+	released bool
+}
+
+func (l *fakeLock) Release(ctx context.Context) error {
+	l.released = true
+	return nil
+}
+
+func TestSubscribe(t *testing.T) {
+	f := New()
+
+	var got []string
+	f.Subscribe("account", func(ctx context.Context, c Change) {
+		got = append(got, "account:"+c.RecordID)
+	})
+	unsubscribe := f.Subscribe(AllTables, func(ctx context.Context, c Change) {
+		got = append(got, "all:"+c.RecordID)
+	})
+	f.Subscribe("account", func(ctx context.Context, c Change) {
+		panic("boom")
+	})
+
+	f.dispatch(context.Background(), Change{Table: "account", RecordID: "1"})
+	f.dispatch(context.Background(), Change{Table: "organisation", RecordID: "2"})
+	unsubscribe()
+	f.dispatch(context.Background(), Change{Table: "organisation", RecordID: "3"})
+
+	assert.Equal(t, []string{"account:1", "all:1", "all:2"}, got)
+}
+
+func TestCatchUp(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("delivers in order across batches", func(t *testing.T) {
+		f := New(WithBatchSize(2))
+		f.db = &fakeDB{rows: []Change{{Seq: 1}, {Seq: 2}, {Seq: 3, Payload: []byte("null")}}}
+
+		var seqs []int64
+		f.Subscribe(AllTables, func(ctx context.Context, c Change) {
+			seqs = append(seqs, c.Seq)
+			assert.Nil(t, c.Payload)
+		})
+
+		pending, err := f.catchUp(ctx)
+		assert.NoError(t, err)
+		assert.False(t, pending)
+		assert.Equal(t, []int64{1, 2, 3}, seqs)
+		assert.Equal(t, int64(3), f.lastSeq)
+	})
+
+	t.Run("replays from the last delivered sequence", func(t *testing.T) {
+		f := New()
+		f.db = &fakeDB{rows: []Change{{Seq: 1}, {Seq: 2}, {Seq: 3}}}
+		f.lastSeq = 2
+
+		var seqs []int64
+		f.Subscribe(AllTables, func(ctx context.Context, c Change) {
+			seqs = append(seqs, c.Seq)
+		})
+
+		_, err := f.catchUp(ctx)
+		assert.NoError(t, err)
+		assert.Equal(t, []int64{3}, seqs)
+	})
+
+	t.Run("waits for a gap then skips it", func(t *testing.T) {
+		db := &fakeDB{rows: []Change{{Seq: 1}, {Seq: 3}}}
+		f := New(WithGapTimeout(time.Hour))
+		f.db = db
+
+		var seqs []int64
+		f.Subscribe(AllTables, func(ctx context.Context, c Change) {
+			seqs = append(seqs, c.Seq)
+		})
+
+		pending, err := f.catchUp(ctx)
+		assert.NoError(t, err)
+		assert.True(t, pending)
+		assert.Equal(t, []int64{1}, seqs)
+
+		// The missing change commits
+		db.rows = []Change{{Seq: 1}, {Seq: 2}, {Seq: 3}}
+		pending, err = f.catchUp(ctx)
+		assert.NoError(t, err)
+		assert.False(t, pending)
+		assert.Equal(t, []int64{1, 2, 3}, seqs)
+
+		// The missing change never commits
+		db.rows = append(db.rows, Change{Seq: 5})
+		f.opts.gapTimeout = 0
+		pending, err = f.catchUp(ctx)
+		assert.NoError(t, err)
+		assert.False(t, pending)
+		assert.Equal(t, []int64{1, 2, 3, 5}, seqs)
+	})
+}
+
+func TestCatchUp_LateCommit(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+	mocktimer.MockTimer(now)
+	t.Cleanup(mocktimer.ResetTimer)
+
+	db := &fakeDB{rows: []Change{{Seq: 1}, {Seq: 3}, {Seq: 4}}}
+	f := New(WithGapTimeout(5*time.Second), WithSkipWindow(time.Hour))
+	f.db = db
+
+	var seqs []int64
+	f.Subscribe(AllTables, func(ctx context.Context, c Change) {
+		seqs = append(seqs, c.Seq)
+	})
+
+	pending, err := f.catchUp(ctx)
+	assert.NoError(t, err)
+	assert.True(t, pending)
+
+	// The gap timeout passes before the transaction writing seq 2 commits
+	mocktimer.MockTimer(now.Add(10 * time.Second))
+	pending, err = f.catchUp(ctx)
+	assert.NoError(t, err)
+	assert.False(t, pending)
+	assert.Equal(t, []int64{1, 3, 4}, seqs)
+	assert.True(t, f.isNew("2"))
+
+	// The transaction commits a minute later, its notification and the next poll deliver it
+	mocktimer.MockTimer(now.Add(time.Minute))
+	db.rows = []Change{{Seq: 1}, {Seq: 2}, {Seq: 3}, {Seq: 4}}
+	_, err = f.catchUp(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, []int64{1, 3, 4, 2}, seqs)
+	assert.False(t, f.isNew("2"))
+
+	// A rolled back sequence number is forgotten after the skip window
+	db.rows = append(db.rows, Change{Seq: 6})
+	pending, err = f.catchUp(ctx)
+	assert.NoError(t, err)
+	assert.True(t, pending)
+	mocktimer.MockTimer(now.Add(time.Minute + 10*time.Second))
+	_, err = f.catchUp(ctx)
+	assert.NoError(t, err)
+	assert.Contains(t, f.skipped, int64(5))
+
+	mocktimer.MockTimer(now.Add(3 * time.Hour))
+	_, err = f.catchUp(ctx)
+	assert.NoError(t, err)
+	assert.Empty(t, f.skipped)
+	assert.Equal(t, []int64{1, 3, 4, 2, 6}, seqs)
+}
+
+func TestIsNew(t *testing.T) {
+	f := New()
+	f.lastSeq = 10
+
+	assert.False(t, f.isNew("9"))
+	assert.False(t, f.isNew("10"))
+	assert.True(t, f.isNew("11"))
+	assert.True(t, f.isNew(""))
+}
+
+func TestPublishNotStarted(t *testing.T) {
+	err := New().Publish(context.Background(), Change{Table: "account", RecordID: "1", Action: ActionCreate})
+	assert.ErrorIs(t, err, ErrNotStarted)
+}
+
+func TestPurgeExpired(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("purges while holding the lock", func(t *testing.T) {
+		db := &fakeDB{}
+		lock := &fakeLock{}
+		f := New()
+		f.db = db
+		f.tryLock = func(ctx context.Context) (store.Lock, error) { return lock, nil }
+
+		f.purgeExpired(ctx)
+		assert.Len(t, db.execs, 1)
+		assert.True(t, lock.released)
+	})
+
+	t.Run("skips when another replica holds the lock", func(t *testing.T) {
+		db := &fakeDB{}
+		f := New()
+		f.db = db
+		f.tryLock = func(ctx context.Context) (store.Lock, error) { return nil, nil }
+
+		f.purgeExpired(ctx)
+		assert.Empty(t, db.execs)
+	})
+}
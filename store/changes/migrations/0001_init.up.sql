@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS change_log
+(
+    seq             BIGSERIAL PRIMARY KEY,
+    table_name      VARCHAR                  NOT NULL,
+    record_id       VARCHAR                  NOT NULL,
+    action          VARCHAR(20)              NOT NULL,
+    organisation_id VARCHAR                  NOT NULL DEFAULT '',
+    payload         JSONB,
+    created_at      TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS change_log_created_at_idx
+    ON change_log (created_at);
@@ -0,0 +1,86 @@
+package changes
+
+import (
+	"time"
+)
+
+type options struct {
+	channel              string
+	batchSize            int
+	pollInterval         time.Duration
+	gapTimeout           time.Duration
+	skipWindow           time.Duration
+	retention            time.Duration
+	minReconnectInterval time.Duration
+	maxReconnectInterval time.Duration
+}
+
+func defaultOptions() *options {
+	return &options{
+		channel:              "gos_changes",
+		batchSize:            500,
+		pollInterval:         30 * time.Second,
+		gapTimeout:           5 * time.Second,
+		skipWindow:           time.Hour,
+		retention:            24 * time.Hour,
+		minReconnectInterval: time.Second,
+		maxReconnectInterval: time.Minute,
+	}
+}
+
+// Option configures a change feed
+type Option func(*options)
+
+// WithChannel sets the NOTIFY channel, replicas sharing a database must use the same channel
+func WithChannel(channel string) Option {
+	return func(o *options) {
+		o.channel = channel
+	}
+}
+
+// WithBatchSize sets the number of changes read from the sequence table at a time
+func WithBatchSize(size int) Option {
+	return func(o *options) {
+		o.batchSize = size
+	}
+}
+
+// WithPollInterval sets how often the sequence table is checked and the connection pinged
+// when no notification arrives
+func WithPollInterval(d time.Duration) Option {
+	return func(o *options) {
+		o.pollInterval = d
+	}
+}
+
+// WithGapTimeout sets how long the feed waits for a missing sequence number, e.g. of a
+// change that is still being written by another replica, before skipping it
+func WithGapTimeout(d time.Duration) Option {
+	return func(o *options) {
+		o.gapTimeout = d
+	}
+}
+
+// WithSkipWindow sets how long the sequence numbers skipped after the gap timeout are read
+// again, so that the change of a transaction committing later, e.g. a long transaction or
+// one retried with a backoff, is still delivered
+func WithSkipWindow(d time.Duration) Option {
+	return func(o *options) {
+		o.skipWindow = d
+	}
+}
+
+// WithRetention sets how long changes are kept for replay, zero keeps them forever
+func WithRetention(d time.Duration) Option {
+	return func(o *options) {
+		o.retention = d
+	}
+}
+
+// WithReconnectInterval sets the backoff bounds of the listener reconnects
+func WithReconnectInterval(min, max time.Duration) Option {
+	return func(o *options) {
+		o.minReconnectInterval = min
+		o.maxReconnectInterval = max
+	}
+}
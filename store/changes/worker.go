@@ -0,0 +1,251 @@
+package changes
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+
+	"github.com/tuongaz/go-saas/pkg/log"
+	"github.com/tuongaz/go-saas/pkg/timer"
+	"github.com/tuongaz/go-saas/store"
+)
+
+const (
+	purgeInterval = time.Hour
+
+	// purgeLockID elects the replica purging the expired changes, next to the locks
+	// of the scheduler and the outbox
+	purgeLockID = 123458
+)
+
+type dbInterface interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	GetContext(ctx context.Context, dest any, query string, args ...any) error
+	SelectContext(ctx context.Context, dest any, query string, args ...any) error
+}
+
+// Start listens for changes on a dedicated connection opened from dataSource and delivers
+// them to the subscribers. The listener reconnects automatically, and the changes missed
+// while it was disconnected are replayed from the sequence table.
+func (f *Feed) Start(ctx context.Context, db *sqlx.DB, dataSource string) error {
+	if f.cancel != nil {
+		return fmt.Errorf("change feed is already started")
+	}
+	if dialect := store.DialectOf(db); dialect != store.Postgres {
+		return fmt.Errorf("change feed requires Postgres, not %s", dialect.Name())
+	}
+
+	listener := pq.NewListener(dataSource, f.opts.minReconnectInterval, f.opts.maxReconnectInterval, f.logListenerEvent)
+	if err := listener.Listen(f.opts.channel); err != nil {
+		_ = listener.Close()
+		return fmt.Errorf("listen to channel %s: %w", f.opts.channel, err)
+	}
+
+	// Start after the latest change, history is only replayed after a disconnect
+	if err := db.GetContext(ctx, &f.lastSeq, "SELECT COALESCE(MAX(seq), 0) FROM "+tableChangeLog); err != nil {
+		_ = listener.Close()
+		return fmt.Errorf("get latest change sequence: %w", err)
+	}
+
+	f.db = db
+	f.tryLock = func(ctx context.Context) (store.Lock, error) {
+		return store.DialectOf(db).TryLock(ctx, db, purgeLockID)
+	}
+
+	runCtx, cancel := context.WithCancel(context.Background())
+	f.cancel = cancel
+	f.done = make(chan struct{})
+
+	go f.run(runCtx, listener)
+
+	return nil
+}
+
+// Stop stops the feed worker and closes the listener connection
+func (f *Feed) Stop() {
+	if f.cancel == nil {
+		return
+	}
+
+	f.cancel()
+	<-f.done
+}
+
+func (f *Feed) run(ctx context.Context, listener *pq.Listener) {
+	defer close(f.done)
+	defer listener.Close()
+
+	poll := time.NewTimer(f.opts.pollInterval)
+	defer poll.Stop()
+
+	purge := time.NewTicker(purgeInterval)
+	defer purge.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case n := <-listener.Notify:
+			// A nil notification is sent after a reconnect, changes may have been missed meanwhile
+			if n != nil && !f.isNew(n.Extra) {
+				continue
+			}
+		case <-poll.C:
+			if err := listener.Ping(); err != nil {
+				log.Default().WarnContext(ctx, "change feed listener ping failed", log.ErrorAttr(err))
+			}
+		case <-purge.C:
+			f.purgeExpired(ctx)
+			continue
+		}
+
+		pending, err := f.catchUp(ctx)
+		if err != nil {
+			log.Default().ErrorContext(ctx, "change feed catch up error", log.ErrorAttr(err))
+		}
+
+		next := f.opts.pollInterval
+		if pending {
+			next = f.opts.gapTimeout
+		}
+		poll.Reset(next)
+	}
+}
+
+// isNew checks if the sequence of a notification has not been delivered yet
+func (f *Feed) isNew(extra string) bool {
+	seq, err := strconv.ParseInt(extra, 10, 64)
+	if err != nil {
+		return true
+	}
+
+	_, skipped := f.skipped[seq]
+	return seq > f.lastSeq || skipped
+}
+
+// changeColumns are the columns of the changes read by the worker.
+// A NULL payload cannot be scanned into json.RawMessage.
+const changeColumns = "seq, table_name, record_id, action, organisation_id, COALESCE(payload, 'null') AS payload, created_at"
+
+// catchUp delivers the changes after the last delivered one, in sequence order.
+// A missing sequence number can belong to a change that is not committed yet, so delivery
+// stops there until the gap is filled or the gap timeout passes. The skipped sequence numbers
+// are read again until the skip window passes. It returns true when waiting for a gap.
+func (f *Feed) catchUp(ctx context.Context) (bool, error) {
+	if err := f.catchUpSkipped(ctx); err != nil {
+		return false, err
+	}
+
+	for {
+		var changes []Change
+		query := `SELECT ` + changeColumns + ` FROM ` + tableChangeLog + ` WHERE seq > $1 ORDER BY seq LIMIT $2`
+		if err := f.db.SelectContext(ctx, &changes, query, f.lastSeq, f.opts.batchSize); err != nil {
+			return false, fmt.Errorf("select changes: %w", err)
+		}
+
+		for _, change := range changes {
+			if change.Seq != f.lastSeq+1 {
+				if f.gapSince.IsZero() {
+					f.gapSince = timer.Now()
+				}
+				if timer.Now().Sub(f.gapSince) < f.opts.gapTimeout {
+					return true, nil
+				}
+				// The missing sequence numbers were rolled back, or belong to transactions
+				// that are still running and are read again by catchUpSkipped
+				for seq := f.lastSeq + 1; seq < change.Seq; seq++ {
+					f.skipped[seq] = timer.Now()
+				}
+			}
+
+			f.gapSince = time.Time{}
+			f.lastSeq = change.Seq
+			f.deliver(ctx, change)
+		}
+
+		if len(changes) < f.opts.batchSize || ctx.Err() != nil {
+			return false, nil
+		}
+	}
+}
+
+// catchUpSkipped delivers the changes of the skipped sequence numbers that committed since,
+// and forgets the sequence numbers skipped for longer than the skip window
+func (f *Feed) catchUpSkipped(ctx context.Context) error {
+	for seq, skippedAt := range f.skipped {
+		if timer.Now().Sub(skippedAt) > f.opts.skipWindow {
+			delete(f.skipped, seq)
+		}
+	}
+	if len(f.skipped) == 0 {
+		return nil
+	}
+
+	var changes []Change
+	query := `SELECT ` + changeColumns + ` FROM ` + tableChangeLog + ` WHERE seq = ANY($1) ORDER BY seq`
+	seqs := pq.Int64Array(slices.Sorted(maps.Keys(f.skipped)))
+	if err := f.db.SelectContext(ctx, &changes, query, seqs); err != nil {
+		return fmt.Errorf("select skipped changes: %w", err)
+	}
+
+	for _, change := range changes {
+		delete(f.skipped, change.Seq)
+		f.deliver(ctx, change)
+	}
+	return nil
+}
+
+// deliver dispatches a change read from the change log
+func (f *Feed) deliver(ctx context.Context, change Change) {
+	if string(change.Payload) == "null" {
+		change.Payload = nil
+	}
+	f.dispatch(ctx, change)
+}
+
+// purgeExpired deletes the changes older than the retention.
+// Only the replica holding the purge lock deletes them, the others skip the run.
+func (f *Feed) purgeExpired(ctx context.Context) {
+	if f.opts.retention <= 0 {
+		return
+	}
+
+	lock, err := f.tryLock(ctx)
+	if err != nil {
+		log.Default().ErrorContext(ctx, "failed to acquire change feed purge lock", log.ErrorAttr(err))
+		return
+	}
+	if lock == nil {
+		return
+	}
+	defer func() {
+		if err := lock.Release(ctx); err != nil {
+			log.Default().WarnContext(ctx, "failed to release change feed purge lock", log.ErrorAttr(err))
+		}
+	}()
+
+	if _, err := f.db.ExecContext(ctx,
+		"DELETE FROM "+tableChangeLog+" WHERE created_at < $1",
+		timer.Now().Add(-f.opts.retention),
+	); err != nil {
+		log.Default().ErrorContext(ctx, "failed to purge expired changes", log.ErrorAttr(err))
+	}
+}
+
+func (f *Feed) logListenerEvent(event pq.ListenerEventType, err error) {
+	switch event {
+	case pq.ListenerEventDisconnected:
+		log.Default().Warn("change feed listener disconnected", log.ErrorAttr(err))
+	case pq.ListenerEventReconnected:
+		log.Info("change feed listener reconnected, replaying missed changes")
+	case pq.ListenerEventConnectionAttemptFailed:
+		log.Default().Error("change feed listener connection attempt failed", log.ErrorAttr(err))
+	}
+}
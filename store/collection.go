@@ -6,29 +6,95 @@ import (
 	"errors"
 	"fmt"
 	"math"
+	"slices"
+	"sort"
 	"strings"
+	"time"
 
+	"github.com/tuongaz/go-saas/pkg/timer"
 	"github.com/tuongaz/go-saas/store/types"
 )
 
 // collection represents a database table and provides methods to interact with it
 type collection struct {
-	table string
-	db    dbInterface
-	store Interface
+	table      string
+	db         dbInterface
+	store      RecordEvents
+	softDelete bool
+
+	// schema validates the writes when set
+	schema *Schema
+
+	// autoFields are set on inserts and updates, nil when the collection has none
+	autoFields *AutoFields
+
+	// relations are expanded with WithExpand
+	relations RelationResolver
+
+	// loadRelated loads the related records of the expanded relations, queryRelated when nil
+	loadRelated func(ctx context.Context, relation Relation, keys []any) ([]types.Record, error)
+
+	// search is the index of WithSearch when set
+	search *SearchIndex
+
+	// reader runs the read queries when set, e.g. on the read replicas
+	reader dbInterface
+
+	// dialect builds the queries that differ between databases, Postgres when nil
+	dialect Dialect
+
+	// organisationID scopes the collection to a tenant when set
+	organisationID string
+}
+
+// CollectionOption configures a collection
+type CollectionOption func(*collection)
+
+// SoftDelete makes deletes set the deleted_at column instead of removing the rows
+func SoftDelete() CollectionOption {
+	return func(c *collection) {
+		c.softDelete = true
+	}
 }
 
 // NewCollection creates a new collection
-func NewCollection(table string, db dbInterface, store Interface) CollectionInterface {
+func NewCollection(table string, db dbInterface, store RecordEvents, opts ...CollectionOption) CollectionInterface {
 	if !ValidTableName(table) {
 		panic(fmt.Sprintf("invalid table name: %s", table))
 	}
 
-	return &collection{
+	c := &collection{
 		table: table,
 		db:    db,
 		store: store,
 	}
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	return c
+}
+
+const (
+	// upsertInsertedColumn is the extra column returned by Upsert to tell inserts from updates
+	upsertInsertedColumn = "_upsert_inserted"
+)
+
+// recordKeys returns the sorted union of the keys of the records
+func recordKeys(records []types.Record) []string {
+	seen := map[string]bool{}
+	keys := make([]string, 0)
+	for _, record := range records {
+		for key := range record {
+			if !seen[key] {
+				seen[key] = true
+				keys = append(keys, key)
+			}
+		}
+	}
+	sort.Strings(keys)
+
+	return keys
 }
 
 // handleDBError tries to convert a generic database error into a more specific error type
@@ -42,22 +108,11 @@ func handleDBError(err error) error {
 		return NewNotFoundErr(err)
 	}
 
-	// Convert to string to check for specific Postgres error types
-	errStr := err.Error()
-
-	// Check for unique constraint violation (Postgres code 23505)
-	if strings.Contains(errStr, "23505") {
-		return NewDuplicateKeyErr(err)
-	}
-
-	// Check for foreign key constraint violation (Postgres code 23503)
-	if strings.Contains(errStr, "23503") {
-		return NewForeignKeyErr(err)
-	}
-
-	// Check for not null constraint violation (Postgres code 23502)
-	if strings.Contains(errStr, "23502") {
-		return NewNotNullErr(err)
+	// Constraint violations are recognised by the dialect of the driver that returned them
+	for _, dialect := range registeredDialects() {
+		if classified := dialect.ClassifyError(err); classified != nil {
+			return classified
+		}
 	}
 
 	// Return the original error if we don't recognize it
@@ -68,34 +123,358 @@ func (c *collection) Table() string {
 	return c.table
 }
 
-// CreateRecord creates a new record and handles specific errors
+// CreateRecord creates a new record and handles specific errors.
+// The returned record is read back with RETURNING *, so it includes database defaults and generated columns.
 func (c *collection) CreateRecord(ctx context.Context, record types.Record) (*types.Record, error) {
+	if err := c.stampTenant(record); err != nil {
+		return nil, err
+	}
+	c.setCreateFields(record, timer.Now())
+
 	if err := c.store.OnBeforeRecordCreated(ctx, c.table, record); err != nil {
 		return nil, fmt.Errorf("before create event handler error: %w", err)
 	}
 
+	if err := c.validateCreate(record); err != nil {
+		return nil, err
+	}
+
 	keys, values, placeholders, err := record.PrepareForDB()
 	if err != nil {
 		return nil, fmt.Errorf("prepare record for database insertion: %w", err)
 	}
 
-	query := fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)", c.table, strings.Join(keys, ", "), strings.Join(placeholders, ", "))
-	_, err = c.db.ExecContext(ctx, query, values...)
+	query := fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s) RETURNING *", c.table, strings.Join(keys, ", "), strings.Join(placeholders, ", "))
+	created, err := c.queryRecord(ctx, query, values...)
 	if err != nil {
-		return nil, handleDBError(err)
+		return nil, c.schemaError(err)
 	}
 
-	if err := c.store.OnAfterRecordCreated(ctx, c.table, record); err != nil {
+	if err := c.store.OnAfterRecordCreated(ctx, c.table, *created); err != nil {
 		return nil, fmt.Errorf("after create event handler error: %w", err)
 	}
 
-	return &record, nil
+	return created, nil
+}
+
+// CreateRecords creates multiple records using multi-row inserts.
+// Columns missing from a record are inserted with their DEFAULT value.
+// Records are inserted in batches, wrap the call in a transaction to make it atomic.
+func (c *collection) CreateRecords(ctx context.Context, records []types.Record) ([]types.Record, error) {
+	if len(records) == 0 {
+		return []types.Record{}, nil
+	}
+
+	now := timer.Now()
+	for _, record := range records {
+		if err := c.stampTenant(record); err != nil {
+			return nil, err
+		}
+		c.setCreateFields(record, now)
+		if err := c.store.OnBeforeRecordCreated(ctx, c.table, record); err != nil {
+			return nil, fmt.Errorf("before create event handler error: %w", err)
+		}
+	}
+
+	for i, record := range records {
+		if err := c.validateCreate(record); err != nil {
+			return nil, fmt.Errorf("record %d: %w", i, err)
+		}
+	}
+
+	created := make([]types.Record, 0, len(records))
+	for _, group := range c.insertGroups(records) {
+		batch, err := c.insertRecords(ctx, group)
+		if err != nil {
+			return nil, err
+		}
+		created = append(created, batch...)
+	}
+
+	for _, record := range created {
+		if err := c.store.OnAfterRecordCreated(ctx, c.table, record); err != nil {
+			return nil, fmt.Errorf("after create event handler error: %w", err)
+		}
+	}
+
+	return created, nil
+}
+
+// insertGroups splits the records into the groups inserted together. Databases without
+// a DEFAULT value in multi-row inserts insert each run of records with the same columns together.
+func (c *collection) insertGroups(records []types.Record) [][]types.Record {
+	if c.sqlDialect().InsertDefault() != "" {
+		return [][]types.Record{records}
+	}
+
+	var groups [][]types.Record
+	var lastKeys string
+	for _, record := range records {
+		keys := strings.Join(recordKeys([]types.Record{record}), ",")
+		if len(groups) == 0 || keys != lastKeys {
+			groups = append(groups, nil)
+			lastKeys = keys
+		}
+		groups[len(groups)-1] = append(groups[len(groups)-1], record)
+	}
+	return groups
+}
+
+// insertRecords inserts the records using multi-row inserts, in batches of at most
+// the maximum number of parameters of the database
+func (c *collection) insertRecords(ctx context.Context, records []types.Record) ([]types.Record, error) {
+	keys := recordKeys(records)
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("records have no columns")
+	}
+	for _, key := range keys {
+		if !ValidIdentifierName(key) {
+			return nil, fmt.Errorf("invalid column name: %s", key)
+		}
+	}
+
+	batchSize := c.sqlDialect().MaxParams() / len(keys)
+	created := make([]types.Record, 0, len(records))
+	for start := 0; start < len(records); start += batchSize {
+		end := min(start+batchSize, len(records))
+
+		rows := make([]string, 0, end-start)
+		args := make([]any, 0, (end-start)*len(keys))
+		for _, record := range records[start:end] {
+			values, err := record.PrepareValuesForDB(keys)
+			if err != nil {
+				return nil, fmt.Errorf("prepare record for database insertion: %w", err)
+			}
+
+			placeholders := make([]string, len(keys))
+			for i, key := range keys {
+				if _, ok := record[key]; !ok {
+					placeholders[i] = c.sqlDialect().InsertDefault()
+					continue
+				}
+				args = append(args, values[i])
+				placeholders[i] = fmt.Sprintf("$%d", len(args))
+			}
+			rows = append(rows, "("+strings.Join(placeholders, ", ")+")")
+		}
+
+		query := fmt.Sprintf("INSERT INTO %s (%s) VALUES %s RETURNING *", c.table, strings.Join(keys, ", "), strings.Join(rows, ", "))
+		batch, err := c.queryRecords(ctx, query, args...)
+		if err != nil {
+			return nil, c.schemaError(err)
+		}
+		created = append(created, batch...)
+	}
+
+	return created, nil
+}
+
+// Upsert inserts a record, or updates the existing row when it conflicts on conflictColumns.
+// Only updateColumns are overwritten on conflict, when empty every column of the record
+// that is not a conflict column is updated.
+// Create or update events are fired depending on whether the row was inserted or updated.
+func (c *collection) Upsert(ctx context.Context, record types.Record, conflictColumns []string, updateColumns []string) (*types.Record, error) {
+	if len(conflictColumns) == 0 {
+		return nil, fmt.Errorf("upsert requires at least one conflict column")
+	}
+
+	if err := c.stampTenant(record); err != nil {
+		return nil, err
+	}
+	autoSet := c.setCreateFields(record, timer.Now())
+
+	conflictFilter, updateColumns, err := c.upsertColumns(record, conflictColumns, updateColumns, autoSet)
+	if err != nil {
+		return nil, err
+	}
+
+	setStatements := make([]string, len(updateColumns))
+	for i, column := range updateColumns {
+		setStatements[i] = fmt.Sprintf("%s = EXCLUDED.%s", column, column)
+	}
+
+	// Look up the existing row so that update events receive the old record
+	oldRecord, err := c.FindOne(WithPrimary(ctx), conflictFilter, WithDeleted())
+	if err != nil && !IsNotFoundError(err) {
+		return nil, fmt.Errorf("get existing record: %w", err)
+	}
+
+	if oldRecord != nil {
+		if err := c.store.OnBeforeRecordUpdated(ctx, c.table, record, *oldRecord); err != nil {
+			return nil, fmt.Errorf("before update event handler error: %w", err)
+		}
+	} else {
+		if err := c.store.OnBeforeRecordCreated(ctx, c.table, record); err != nil {
+			return nil, fmt.Errorf("before create event handler error: %w", err)
+		}
+	}
+
+	// The conflicting row is updated, so the record is validated as an update: the required
+	// fields it leaves out keep their value. Otherwise it is inserted and validated in full,
+	// the defaults only fill the insert, they are not in the update columns.
+	validate := c.validateCreate
+	if oldRecord != nil {
+		validate = c.validateUpdate
+	}
+	if err := validate(record); err != nil {
+		return nil, err
+	}
+
+	// The columns are sorted so that the statement is the same for the same columns
+	keys := recordKeys([]types.Record{record})
+	values, err := record.PrepareValuesForDB(keys)
+	if err != nil {
+		return nil, fmt.Errorf("prepare record for database upsert: %w", err)
+	}
+	placeholders := make([]string, len(keys))
+	for i := range keys {
+		placeholders[i] = fmt.Sprintf("$%d", i+1)
+	}
+
+	// Never update a conflicting row of another organisation
+	conflictWhere := ""
+	if c.organisationID != "" {
+		values = append(values, c.organisationID)
+		conflictWhere = fmt.Sprintf(" WHERE %s.%s = $%d", c.table, TenantColumn, len(values))
+	}
+
+	returning := "*"
+	if inserted := c.sqlDialect().UpsertInserted(); inserted != "" {
+		returning += ", " + inserted + " AS " + upsertInsertedColumn
+	}
+
+	query := fmt.Sprintf(
+		"INSERT INTO %s (%s) VALUES (%s) ON CONFLICT (%s) DO UPDATE SET %s%s RETURNING %s",
+		c.table,
+		strings.Join(keys, ", "),
+		strings.Join(placeholders, ", "),
+		strings.Join(conflictColumns, ", "),
+		strings.Join(setStatements, ", "),
+		conflictWhere,
+		returning,
+	)
+	upserted, err := c.queryRecord(ctx, query, values...)
+	if err != nil {
+		if IsNotFoundError(err) && conflictWhere != "" {
+			return nil, NewTenantErr(fmt.Errorf("%s record conflicts with a record of another organisation", c.table))
+		}
+		return nil, c.schemaError(err)
+	}
+
+	// Without the inserted column, the row was inserted unless the lookup found it
+	inserted, ok := (*upserted)[upsertInsertedColumn].(bool)
+	if !ok {
+		inserted = oldRecord == nil
+	}
+	delete(*upserted, upsertInsertedColumn)
+
+	if inserted {
+		if err := c.store.OnAfterRecordCreated(ctx, c.table, *upserted); err != nil {
+			return nil, fmt.Errorf("after create event handler error: %w", err)
+		}
+		return upserted, nil
+	}
+
+	if oldRecord == nil {
+		// The row was inserted concurrently after the lookup
+		oldRecord = &types.Record{}
+	}
+	if err := c.store.OnAfterRecordUpdated(ctx, c.table, *upserted, *oldRecord); err != nil {
+		return nil, fmt.Errorf("after update event handler error: %w", err)
+	}
+
+	return upserted, nil
+}
+
+// upsertColumns returns the filter matching the row the record conflicts with, and the columns
+// to update on conflict. autoSet are the auto fields set on the record.
+func (c *collection) upsertColumns(record types.Record, conflictColumns, updateColumns []string, autoSet map[string]bool) (Filter, []string, error) {
+	conflictFilter := Filter{}
+	for _, column := range conflictColumns {
+		if !ValidIdentifierName(column) {
+			return nil, nil, fmt.Errorf("invalid conflict column name: %s", column)
+		}
+		conflictFilter[column] = record[column]
+	}
+
+	if len(updateColumns) == 0 {
+		for key := range record {
+			// A conflict keeps the generated id and created_at of the existing row
+			if _, ok := conflictFilter[key]; ok || (autoSet[key] && key != updatedAtColumn) {
+				continue
+			}
+			updateColumns = append(updateColumns, key)
+		}
+		sort.Strings(updateColumns)
+	}
+	if c.autoFields != nil && c.autoFields.UpdatedAt && !slices.Contains(updateColumns, updatedAtColumn) {
+		updateColumns = append(slices.Clip(updateColumns), updatedAtColumn)
+	}
+
+	if len(updateColumns) == 0 {
+		// Nothing to update, touch a conflict column so that the existing row is returned
+		updateColumns = conflictColumns[:1]
+	}
+
+	for _, column := range updateColumns {
+		if !ValidIdentifierName(column) {
+			return nil, nil, fmt.Errorf("invalid update column name: %s", column)
+		}
+	}
+
+	return conflictFilter, updateColumns, nil
+}
+
+// queryRecord executes a query and returns the first row as a normalised record
+func (c *collection) queryRecord(ctx context.Context, query string, args ...any) (*types.Record, error) {
+	recs, err := c.queryRecords(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(recs) == 0 {
+		return nil, NewNotFoundErr(fmt.Errorf("record not found"))
+	}
+
+	return &recs[0], nil
+}
+
+// queryRecords executes a query and returns all rows as normalised records
+func (c *collection) queryRecords(ctx context.Context, query string, args ...any) ([]types.Record, error) {
+	return c.queryRecordsOn(ctx, c.db, query, args...)
+}
+
+// readRecords executes a read only query on the reader and returns all rows as normalised records
+func (c *collection) readRecords(ctx context.Context, query string, args ...any) ([]types.Record, error) {
+	return c.queryRecordsOn(ctx, c.reads(), query, args...)
+}
+
+func (c *collection) queryRecordsOn(ctx context.Context, db dbInterface, query string, args ...any) ([]types.Record, error) {
+	rows, err := db.QueryxContext(ctx, query, args...)
+	if err != nil {
+		return nil, handleDBError(err)
+	}
+	defer rows.Close()
+
+	recs, err := scanRecords(rows)
+	if err != nil {
+		return nil, handleDBError(err)
+	}
+	normaliseRecords(recs)
+
+	return recs, nil
 }
 
 // GetRecord retrieves a record by its id
-func (c *collection) GetRecord(ctx context.Context, id any) (*types.Record, error) {
-	query := "SELECT * FROM " + c.table + " WHERE id = $1 LIMIT 1"
-	rows, err := c.db.QueryxContext(ctx, query, id)
+func (c *collection) GetRecord(ctx context.Context, id any, opts ...FindOption) (*types.Record, error) {
+	options := findOptions(opts)
+	where := &whereBuilder{}
+	where.add("id = $1", id)
+	c.addDeletedScope(where, options.Deleted)
+	c.addTenantScope(where)
+
+	query := "SELECT * FROM " + c.table + where.String() + " LIMIT 1"
+	rows, err := c.reads().QueryxContext(ctx, query, where.args...)
 	if err != nil {
 		return nil, fmt.Errorf("query execution failed: %v", err)
 	}
@@ -114,21 +493,50 @@ func (c *collection) GetRecord(ctx context.Context, id any) (*types.Record, erro
 	}
 	rec.Normalise()
 
+	// The relations are read on the same connection in a transaction
+	if err = rows.Close(); err != nil {
+		return nil, fmt.Errorf("error closing rows: %v", err)
+	}
+	if err := c.expand(ctx, []types.Record{rec}, options.Expand); err != nil {
+		return nil, err
+	}
+
 	return &rec, nil
 }
 
-// UpdateRecord updates a record by its id and returns the updated record
-func (c *collection) UpdateRecord(ctx context.Context, id any, record types.Record) (*types.Record, error) {
+// UpdateRecord updates a record by its id and returns the updated record.
+// Preconditions given with WithVersion or WithUnmodifiedSince are checked in the WHERE clause,
+// a ConflictErr is returned when the record was modified concurrently.
+func (c *collection) UpdateRecord(ctx context.Context, id any, record types.Record, opts ...UpdateOption) (*types.Record, error) {
+	options := &UpdateOptions{}
+	for _, opt := range opts {
+		opt(options)
+	}
+
+	if err := c.checkTenant(record); err != nil {
+		return nil, err
+	}
+	c.setUpdateFields(record, timer.Now())
+
 	// Get the old record for the event
-	oldRecord, err := c.GetRecord(ctx, id)
+	oldRecord, err := c.GetRecord(WithPrimary(ctx), id)
 	if err != nil {
 		return nil, fmt.Errorf("get old record: %w", err)
 	}
 
+	// Fail early so that before event handlers do not run for a stale update
+	if err := checkPreconditions(*oldRecord, options); err != nil {
+		return nil, err
+	}
+
 	if err := c.store.OnBeforeRecordUpdated(ctx, c.table, record, *oldRecord); err != nil {
 		return nil, fmt.Errorf("before update event handler error: %w", err)
 	}
 
+	if err := c.validateUpdate(record); err != nil {
+		return nil, err
+	}
+
 	keys, values, _, err := record.PrepareForDB()
 	if err != nil {
 		return nil, fmt.Errorf("failed to prepare record for database update: %w", err)
@@ -138,24 +546,39 @@ func (c *collection) UpdateRecord(ctx context.Context, id any, record types.Reco
 	for i, key := range keys {
 		setStatements[i] = fmt.Sprintf("%s = $%d", key, i+1)
 	}
+	if _, ok := record[versionColumn]; options.IncrementVersion && !ok {
+		setStatements = append(setStatements, fmt.Sprintf("%s = %s + 1", versionColumn, versionColumn))
+	}
 
 	// Add the ID to the parameters list for the WHERE clause
 	values = append(values, id)
+	conditions := []string{fmt.Sprintf("id = $%d", len(values))}
+
+	if options.Version != nil {
+		values = append(values, *options.Version)
+		conditions = append(conditions, fmt.Sprintf("%s = $%d", versionColumn, len(values)))
+	}
+	if options.UnmodifiedSince != nil {
+		values = append(values, options.UnmodifiedSince.Truncate(time.Second))
+		conditions = append(conditions, fmt.Sprintf("date_trunc('second', %s) <= $%d", updatedAtColumn, len(values)))
+	}
+	if c.organisationID != "" {
+		values = append(values, c.organisationID)
+		conditions = append(conditions, fmt.Sprintf("%s = $%d", TenantColumn, len(values)))
+	}
 
-	query := fmt.Sprintf("UPDATE %s SET %s WHERE id = $%d",
+	query := fmt.Sprintf("UPDATE %s SET %s WHERE %s RETURNING *",
 		c.table,
 		strings.Join(setStatements, ", "),
-		len(values))
-
-	_, err = c.db.ExecContext(ctx, query, values...)
-	if err != nil {
-		return nil, handleDBError(err)
-	}
+		strings.Join(conditions, " AND "))
 
 	// Get the updated record
-	updatedRecord, err := c.GetRecord(ctx, id)
+	updatedRecord, err := c.queryRecord(ctx, query, values...)
 	if err != nil {
-		return nil, fmt.Errorf("get updated record: %w", err)
+		if IsNotFoundError(err) && (options.Version != nil || options.UnmodifiedSince != nil) {
+			return nil, NewConflictErr(fmt.Errorf("record %v of %s was modified concurrently", id, c.table))
+		}
+		return nil, c.schemaError(err)
 	}
 
 	if err := c.store.OnAfterRecordUpdated(ctx, c.table, *updatedRecord, *oldRecord); err != nil {
@@ -167,6 +590,15 @@ func (c *collection) UpdateRecord(ctx context.Context, id any, record types.Reco
 
 // Update updates records based on the provided record and conditions
 func (c *collection) Update(ctx context.Context, record types.Record, args ...any) (int64, error) {
+	if err := c.checkTenant(record); err != nil {
+		return 0, err
+	}
+	c.setUpdateFields(record, timer.Now())
+
+	if err := c.validateUpdate(record); err != nil {
+		return 0, err
+	}
+
 	keys, values, _, err := record.PrepareForDB()
 	if err != nil {
 		return 0, fmt.Errorf("failed to prepare record for database update: %w", err)
@@ -181,40 +613,43 @@ func (c *collection) Update(ctx context.Context, record types.Record, args ...an
 	query := fmt.Sprintf("UPDATE %s SET %s", c.table, strings.Join(setStatements, ", "))
 
 	// Process additional arguments (WHERE conditions)
-	if len(args) > 0 {
-		if len(args)%2 != 0 {
-			return 0, fmt.Errorf("invalid number of arguments: must be key-value pairs")
-		}
+	if len(args)%2 != 0 {
+		return 0, fmt.Errorf("invalid number of arguments: must be key-value pairs")
+	}
 
-		whereConditions := make([]string, 0, len(args)/2)
-		for i := 0; i < len(args); i += 2 {
-			key, ok := args[i].(string)
-			if !ok {
-				return 0, fmt.Errorf("argument %d must be a string (column name)", i)
-			}
-			whereConditions = append(whereConditions, fmt.Sprintf("%s = $%d", key, len(values)+1))
-			values = append(values, args[i+1])
+	whereConditions := make([]string, 0, len(args)/2+1)
+	for i := 0; i < len(args); i += 2 {
+		key, ok := args[i].(string)
+		if !ok {
+			return 0, fmt.Errorf("argument %d must be a string (column name)", i)
 		}
+		whereConditions = append(whereConditions, fmt.Sprintf("%s = $%d", key, len(values)+1))
+		values = append(values, args[i+1])
+	}
+	if c.organisationID != "" {
+		whereConditions = append(whereConditions, fmt.Sprintf("%s = $%d", TenantColumn, len(values)+1))
+		values = append(values, c.organisationID)
+	}
 
-		if len(whereConditions) > 0 {
-			query += " WHERE " + strings.Join(whereConditions, " AND ")
-		}
+	if len(whereConditions) > 0 {
+		query += " WHERE " + strings.Join(whereConditions, " AND ")
 	}
 
 	// Execute the query
 	result, err := c.db.ExecContext(ctx, query, values...)
 	if err != nil {
-		return 0, handleDBError(err)
+		return 0, c.schemaError(handleDBError(err))
 	}
 
 	// Return the number of affected rows
 	return result.RowsAffected()
 }
 
-// DeleteRecord deletes a record by its id
+// DeleteRecord deletes a record by its id.
+// On soft delete collections the deleted_at column is set instead.
 func (c *collection) DeleteRecord(ctx context.Context, id any) error {
 	// Get the record before deletion for the event
-	record, err := c.GetRecord(ctx, id)
+	record, err := c.GetRecord(WithPrimary(ctx), id)
 	if err != nil {
 		return fmt.Errorf("get record before deletion: %w", err)
 	}
@@ -223,10 +658,25 @@ func (c *collection) DeleteRecord(ctx context.Context, id any) error {
 		return fmt.Errorf("before delete event handler error: %w", err)
 	}
 
-	query := "DELETE FROM " + c.table + " WHERE id = $1"
-	_, err = c.db.ExecContext(ctx, query, id)
-	if err != nil {
-		return handleDBError(err)
+	if c.softDelete {
+		where := &whereBuilder{}
+		where.add("id = $1", id)
+		where.add(deletedAtColumn + " IS NULL")
+		c.addTenantScope(where)
+
+		query := fmt.Sprintf("UPDATE %s SET %s = $%d", c.table, deletedAtColumn, where.nextIdx()) + where.String() + " RETURNING *"
+		record, err = c.queryRecord(ctx, query, append(where.args, timer.Now())...)
+		if err != nil {
+			return err
+		}
+	} else {
+		where := &whereBuilder{}
+		where.add("id = $1", id)
+		c.addTenantScope(where)
+
+		if _, err := c.db.ExecContext(ctx, "DELETE FROM "+c.table+where.String(), where.args...); err != nil {
+			return handleDBError(err)
+		}
 	}
 
 	if err := c.store.OnAfterRecordDeleted(ctx, c.table, *record); err != nil {
@@ -236,9 +686,21 @@ func (c *collection) DeleteRecord(ctx context.Context, id any) error {
 	return nil
 }
 
-// DeleteRecords deletes records matching the filter
+// DeleteRecords deletes records matching the filter.
+// On soft delete collections the deleted_at column of live records is set instead.
 func (c *collection) DeleteRecords(ctx context.Context, filter Filter) error {
-	query, args := buildQuery("DELETE FROM "+c.table, filter)
+	where := &whereBuilder{}
+	where.addFilter(filter)
+	c.addTenantScope(where)
+	query, args := "DELETE FROM "+c.table+where.String(), where.args
+	if c.softDelete {
+		where := &whereBuilder{}
+		where.add(fmt.Sprintf("%s IS NULL", deletedAtColumn))
+		where.addFilter(filter)
+		c.addTenantScope(where)
+		query = fmt.Sprintf("UPDATE %s SET %s = $%d", c.table, deletedAtColumn, where.nextIdx()) + where.String()
+		args = append(where.args, timer.Now())
+	}
 
 	_, err := c.db.ExecContext(ctx, query, args...)
 	if err != nil {
@@ -249,10 +711,15 @@ func (c *collection) DeleteRecords(ctx context.Context, filter Filter) error {
 }
 
 // FindOne retrieves a single record matching the filter
-func (c *collection) FindOne(ctx context.Context, filter Filter) (*types.Record, error) {
+func (c *collection) FindOne(ctx context.Context, filter Filter, opts ...FindOption) (*types.Record, error) {
 	var rec types.Record = make(map[string]interface{})
-	query, args := buildQuery("SELECT * FROM "+c.table, filter)
-	rows, err := c.db.QueryxContext(ctx, query+" LIMIT 1", args...)
+	options := findOptions(opts)
+	where := &whereBuilder{}
+	where.addFilter(filter)
+	c.addDeletedScope(where, options.Deleted)
+	c.addTenantScope(where)
+
+	rows, err := c.reads().QueryxContext(ctx, "SELECT * FROM "+c.table+where.String()+" LIMIT 1", where.args...)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, NewNotFoundErr(err)
@@ -275,6 +742,14 @@ func (c *collection) FindOne(ctx context.Context, filter Filter) (*types.Record,
 
 	rec.Normalise()
 
+	// The relations are read on the same connection in a transaction
+	if err = rows.Close(); err != nil {
+		return nil, fmt.Errorf("error closing rows: %v", err)
+	}
+	if err := c.expand(ctx, []types.Record{rec}, options.Expand); err != nil {
+		return nil, err
+	}
+
 	return &rec, nil
 }
 
@@ -290,100 +765,90 @@ func (c *collection) Find(ctx context.Context, opts ...FindOption) (*List, error
 		opt(options)
 	}
 
-	var query string
-	var args []any
-
-	// Determine which filter to use
-	if options.AdvancedFilter != nil {
-		// Use advanced filter
-		baseQuery := "SELECT"
-
-		// Handle field selection
-		if len(options.Fields) > 0 {
-			// Validate field names
-			for _, field := range options.Fields {
-				if !ValidIdentifierName(field) {
-					return nil, fmt.Errorf("invalid field name: %s", field)
-				}
-			}
-			baseQuery += " " + strings.Join(options.Fields, ", ")
-		} else {
-			baseQuery += " *"
-		}
-
-		baseQuery += " FROM " + c.table
-		query, args = buildAdvancedQuery(baseQuery, *options.AdvancedFilter)
-	} else {
-		// Use simple filter
-		baseQuery := "SELECT"
-
-		// Handle field selection
-		if len(options.Fields) > 0 {
-			// Validate field names
-			for _, field := range options.Fields {
-				if !ValidIdentifierName(field) {
-					return nil, fmt.Errorf("invalid field name: %s", field)
-				}
+	// Handle field selection
+	fields := "*"
+	if len(options.Fields) > 0 {
+		// Validate field names
+		for _, field := range options.Fields {
+			if !ValidIdentifierName(field) {
+				return nil, fmt.Errorf("invalid field name: %s", field)
 			}
-			baseQuery += " " + strings.Join(options.Fields, ", ")
-		} else {
-			baseQuery += " *"
 		}
-
-		baseQuery += " FROM " + c.table
-		query, args = buildQuery(baseQuery, options.Filter)
+		fields = strings.Join(options.Fields, ", ")
 	}
 
-	// Get total count for metadata
-	var totalCount int
-	var err error
+	// Determine which filter to use
+	where := &whereBuilder{}
 	if options.AdvancedFilter != nil {
-		countQuery, countArgs := buildAdvancedQuery("SELECT COUNT(*) FROM "+c.table, *options.AdvancedFilter)
-		err = c.db.GetContext(ctx, &totalCount, countQuery, countArgs...)
+		where.addExpression(options.AdvancedFilter.Expression)
 	} else {
-		totalCount, err = c.Count(ctx, options.Filter)
+		where.addFilter(options.Filter)
 	}
+	c.addDeletedScope(where, options.Deleted)
+	c.addTenantScope(where)
+
+	searchColumns, err := c.addSearch(where, options)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get total count: %w", err)
+		return nil, err
 	}
 
-	// Apply sorting
-	if len(options.Sort) > 0 {
-		sortClauses := make([]string, 0, len(options.Sort))
-		for _, opt := range options.Sort {
-			// Validate field name to prevent SQL injection
-			if !ValidIdentifierName(opt.Field) {
-				return nil, fmt.Errorf("invalid field name for sorting: %s", opt.Field)
-			}
-
-			// Validate sort direction
-			if opt.Direction != SortAsc && opt.Direction != SortDesc {
-				opt.Direction = SortAsc // Default to ascending if invalid
-			}
-
-			// Support optional operator-based ordering (e.g., Postgres distance operators)
-			if strings.TrimSpace(opt.Operator) != "" {
-				sortClauses = append(sortClauses, fmt.Sprintf("%s %s %s %s", opt.Field, opt.Operator, opt.Right, opt.Direction))
-				continue
-			}
+	// The query vector is bound after the where args, it is not used by the count query
+	distanceColumn, vector, err := nearestColumn(options, where.nextIdx())
+	if err != nil {
+		return nil, err
+	}
+	args := where.args
+	if distanceColumn != "" {
+		args = append(slices.Clip(where.args), vector)
+	}
 
-			sortClauses = append(sortClauses, fmt.Sprintf("%s %s", opt.Field, opt.Direction))
+	// Get total count for metadata
+	meta := Metadata{}
+	if !options.SkipTotal {
+		countQuery := "SELECT COUNT(*) FROM " + c.table + where.String()
+		if err := c.reads().GetContext(ctx, &meta.Total, countQuery, where.args...); err != nil {
+			return nil, fmt.Errorf("failed to get total count: %w", err)
 		}
+	}
 
-		if len(sortClauses) > 0 {
-			query += " ORDER BY " + strings.Join(sortClauses, ", ")
+	if options.Cursor != nil {
+		list, err := c.findWithCursor(ctx, options, fields, where, meta)
+		if err != nil {
+			return nil, err
 		}
+		if err := c.expand(ctx, list.Records, options.Expand); err != nil {
+			return nil, err
+		}
+		return list, nil
 	}
 
-	// Apply pagination if provided
-	meta := Metadata{
-		Total: totalCount,
+	query := "SELECT " + fields + searchColumns + distanceColumn + " FROM " + c.table + where.String()
+
+	// Apply sorting, nearest records are ordered by distance and search results by relevance by default
+	orderBy, err := buildOrderBy(options.Sort)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case distanceColumn != "":
+		orderBy = " ORDER BY " + DistanceField
+	case orderBy == "" && searchColumns != "":
+		orderBy = " ORDER BY " + SearchRankField + " DESC"
+	}
+	query += orderBy
+
+	if distanceColumn != "" {
+		meta.Limit = options.Nearest.K
+		query += fmt.Sprintf(" LIMIT %d", options.Nearest.K)
 	}
 
+	// Apply pagination if provided
 	if options.Pagination != nil {
 		meta.Limit = options.Pagination.Limit
 		meta.Offset = options.Pagination.Offset
-		meta.TotalPages = int(math.Ceil(float64(totalCount) / float64(options.Pagination.Limit)))
+		if !options.SkipTotal && options.Pagination.Limit > 0 {
+			meta.TotalPages = int(math.Ceil(float64(meta.Total) / float64(options.Pagination.Limit)))
+		}
 
 		if options.Pagination.Limit > 0 {
 			query += fmt.Sprintf(" LIMIT %d", options.Pagination.Limit)
@@ -395,7 +860,7 @@ func (c *collection) Find(ctx context.Context, opts ...FindOption) (*List, error
 	}
 
 	// Execute the query
-	rows, err := c.db.QueryxContext(ctx, query, args...)
+	rows, err := c.reads().QueryxContext(ctx, query, args...)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, NewNotFoundErr(err)
@@ -405,18 +870,71 @@ func (c *collection) Find(ctx context.Context, opts ...FindOption) (*List, error
 	defer rows.Close()
 
 	// Process the results
-	var recs []types.Record
-	for rows.Next() {
-		rec := make(types.Record)
-		if err := rows.MapScan(rec); err != nil {
-			return nil, fmt.Errorf("failed to scan record into map: %v", err)
-		}
-		rec.Normalise()
+	recs, err := scanRecords(rows)
+	if err != nil {
+		return nil, err
+	}
+	normaliseRecords(recs)
+	if searchColumns != "" {
+		nestHighlights(recs, options.Search.Highlight)
+	}
 
-		recs = append(recs, rec)
+	if err := c.expand(ctx, recs, options.Expand); err != nil {
+		return nil, err
 	}
-	if err = rows.Err(); err != nil {
-		return nil, fmt.Errorf("error processing rows: %v", err)
+
+	return &List{
+		Records: recs,
+		Meta:    meta,
+	}, nil
+}
+
+// findWithCursor fetches a page of records using keyset pagination
+func (c *collection) findWithCursor(ctx context.Context, options *FindOptions, fields string, where *whereBuilder, meta Metadata) (*List, error) {
+	if options.Cursor.Limit <= 0 {
+		return nil, fmt.Errorf("cursor limit must be greater than zero")
+	}
+
+	sort, err := cursorSort(options.Sort, cursorTiebreaker)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := ensureCursorFields(options.Fields, sort); err != nil {
+		return nil, err
+	}
+
+	token, err := decodeCursor(options.Cursor.After, sort)
+	if err != nil {
+		return nil, err
+	}
+
+	backward := false
+	if token != nil {
+		backward = token.Backward
+		clause, args := buildKeysetCondition(sort, token.Values, backward, where.nextIdx())
+		where.add(clause, args...)
+	}
+
+	// Fetch one extra row to find out whether there is another page
+	query := "SELECT " + fields + " FROM " + c.table + where.String() +
+		buildCursorOrderBy(sort, backward) +
+		fmt.Sprintf(" LIMIT %d", options.Cursor.Limit+1)
+
+	rows, err := c.reads().QueryxContext(ctx, query, where.args...)
+	if err != nil {
+		return nil, handleDBError(err)
+	}
+	defer rows.Close()
+
+	recs, err := scanRecords(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	recs, err = cursorPage(recs, sort, options.Cursor.Limit, token, &meta)
+	if err != nil {
+		return nil, err
 	}
 
 	return &List{
@@ -426,10 +944,14 @@ func (c *collection) Find(ctx context.Context, opts ...FindOption) (*List, error
 }
 
 // Count returns the number of records matching the filter
-func (c *collection) Count(ctx context.Context, filter Filter) (int, error) {
+func (c *collection) Count(ctx context.Context, filter Filter, opts ...FindOption) (int, error) {
 	var count int
-	query, args := buildQuery("SELECT COUNT(*) FROM "+c.table, filter)
-	err := c.db.GetContext(ctx, &count, query, args...)
+	where := &whereBuilder{}
+	where.addFilter(filter)
+	c.addDeletedScope(where, findOptions(opts).Deleted)
+	c.addTenantScope(where)
+
+	err := c.reads().GetContext(ctx, &count, "SELECT COUNT(*) FROM "+c.table+where.String(), where.args...)
 	if err != nil {
 		return 0, err
 	}
@@ -437,8 +959,8 @@ func (c *collection) Count(ctx context.Context, filter Filter) (int, error) {
 }
 
 // Exists checks if any records match the filter
-func (c *collection) Exists(ctx context.Context, filter Filter) (bool, error) {
-	count, err := c.Count(ctx, filter)
+func (c *collection) Exists(ctx context.Context, filter Filter, opts ...FindOption) (bool, error) {
+	count, err := c.Count(ctx, filter, opts...)
 	if err != nil {
 		return false, err
 	}
@@ -2,6 +2,7 @@ package store
 
 import (
 	"context"
+	"time"
 
 	"github.com/tuongaz/go-saas/store/types"
 )
@@ -16,30 +17,46 @@ type CollectionInterface interface {
 	// CreateRecord creates a new record and handles specific errors
 	CreateRecord(ctx context.Context, record types.Record) (*types.Record, error)
 
+	// CreateRecords creates multiple records using multi-row inserts
+	CreateRecords(ctx context.Context, records []types.Record) ([]types.Record, error)
+
+	// Upsert inserts a record or updates the existing row on conflict
+	Upsert(ctx context.Context, record types.Record, conflictColumns []string, updateColumns []string) (*types.Record, error)
+
 	// GetRecord retrieves a record by its id
-	GetRecord(ctx context.Context, id any) (*types.Record, error)
+	GetRecord(ctx context.Context, id any, opts ...FindOption) (*types.Record, error)
 
-	// UpdateRecord updates a record by its id and returns the updated record
-	UpdateRecord(ctx context.Context, id any, record types.Record) (*types.Record, error)
+	// UpdateRecord updates a record by its id and returns the updated record.
+	// It returns a ConflictErr when a WithVersion or WithUnmodifiedSince precondition fails.
+	UpdateRecord(ctx context.Context, id any, record types.Record, opts ...UpdateOption) (*types.Record, error)
 
 	// Update updates records based on the provided record and conditions
 	Update(ctx context.Context, record types.Record, args ...any) (int64, error)
 
-	// DeleteRecord deletes a record by its id
+	// DeleteRecord deletes a record by its id, or soft deletes it on soft delete collections
 	DeleteRecord(ctx context.Context, id any) error
 
-	// DeleteRecords deletes records matching the filter
+	// DeleteRecords deletes records matching the filter, or soft deletes them on soft delete collections
 	DeleteRecords(ctx context.Context, filter Filter) error
 
+	// Restore restores a soft deleted record
+	Restore(ctx context.Context, id any) (*types.Record, error)
+
+	// PurgeDeletedBefore permanently deletes the records soft deleted before t
+	PurgeDeletedBefore(ctx context.Context, t time.Time) (int64, error)
+
 	// FindOne retrieves a single record matching the filter
-	FindOne(ctx context.Context, filter Filter) (*types.Record, error)
+	FindOne(ctx context.Context, filter Filter, opts ...FindOption) (*types.Record, error)
 
 	// Find fetches records using the options pattern
 	Find(ctx context.Context, opts ...FindOption) (*List, error)
 
 	// Count returns the number of records matching the filter
-	Count(ctx context.Context, filter Filter) (int, error)
+	Count(ctx context.Context, filter Filter, opts ...FindOption) (int, error)
 
 	// Exists checks if any records match the filter
-	Exists(ctx context.Context, filter Filter) (bool, error)
+	Exists(ctx context.Context, filter Filter, opts ...FindOption) (bool, error)
+
+	// Aggregate computes the aggregations over the records matching the options, grouped by the groups
+	Aggregate(ctx context.Context, groups []GroupBy, aggregations []Aggregation, opts ...FindOption) ([]types.Record, error)
 }
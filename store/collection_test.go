@@ -0,0 +1,243 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/tuongaz/go-saas/store/events"
+	"github.com/tuongaz/go-saas/store/types"
+)
+
+func TestRecordKeys(t *testing.T) {
+	keys := recordKeys([]types.Record{
+		{"name": "John", "id": "1"},
+		{"id": "2", "email": "jane@example.com"},
+	})
+
+	assert.Equal(t, []string{"email", "id", "name"}, keys)
+}
+
+// recordingConn is a database/sql connection that records the queries and answers them with respond
+type recordingConn struct {
+	queries []string
+	args    [][]any
+	respond func(query string, args []any) (columns []string, rows [][]any)
+}
+
+func newRecordingDB(respond func(query string, args []any) ([]string, [][]any)) (*sqlx.DB, *recordingConn) {
+	conn := &recordingConn{respond: respond}
+	return sqlx.NewDb(sql.OpenDB(conn), "postgres"), conn
+}
+
+func (c *recordingConn) Connect(context.Context) (driver.Conn, error) { return c, nil }
+func (c *recordingConn) Driver() driver.Driver                        { return nil }
+func (c *recordingConn) Prepare(string) (driver.Stmt, error)          { return nil, errors.New("not supported") }
+func (c *recordingConn) Close() error                                 { return nil }
+func (c *recordingConn) Begin() (driver.Tx, error)                    { return nil, errors.New("not supported") }
+
+func (c *recordingConn) QueryContext(ctx context.Context, query string, named []driver.NamedValue) (driver.Rows, error) {
+	args := make([]any, len(named))
+	for i, v := range named {
+		args[i] = v.Value
+	}
+	c.queries = append(c.queries, query)
+	c.args = append(c.args, args)
+
+	columns, rows := c.respond(query, args)
+	return &recordingRows{columns: columns, rows: rows}, nil
+}
+
+type recordingRows struct {
+	columns []string
+	rows    [][]any
+}
+
+func (r *recordingRows) Columns() []string { return r.columns }
+func (r *recordingRows) Close() error      { return nil }
+
+func (r *recordingRows) Next(dest []driver.Value) error {
+	if len(r.rows) == 0 {
+		return io.EOF
+	}
+	for i, value := range r.rows[0] {
+		dest[i] = value
+	}
+	r.rows = r.rows[1:]
+	return nil
+}
+
+// smallDialect is Postgres with few parameters per statement
+type smallDialect struct {
+	Dialect
+}
+
+func (smallDialect) MaxParams() int {
+	return 4
+}
+
+type writeHandler struct {
+	events.Handler
+	writes []string
+}
+
+func (h *writeHandler) OnBeforeCreate(ctx context.Context, event *events.OnBeforeRecordCreatedEvent) error {
+	return nil
+}
+
+func (h *writeHandler) OnAfterCreate(ctx context.Context, event *events.OnAfterRecordCreatedEvent) error {
+	h.writes = append(h.writes, "create:"+event.Record.String("id"))
+	return nil
+}
+
+func (h *writeHandler) OnBeforeUpdate(ctx context.Context, event *events.OnBeforeRecordUpdatedEvent) error {
+	return nil
+}
+
+func (h *writeHandler) OnAfterUpdate(ctx context.Context, event *events.OnAfterRecordUpdatedEvent) error {
+	h.writes = append(h.writes, "update:"+event.Record.String("id"))
+	return nil
+}
+
+func newWriteStore() (*Store, *writeHandler) {
+	handler := &writeHandler{}
+	st := &Store{}
+	st.AddEventHandler(handler)
+	return st, handler
+}
+
+func TestCollection_CreateRecords(t *testing.T) {
+	ctx := context.Background()
+
+	// Every insert returns the ids of its rows
+	respond := func(query string, args []any) ([]string, [][]any) {
+		var rows [][]any
+		for _, arg := range args {
+			if id, ok := arg.(string); ok && strings.HasPrefix(id, "u") {
+				rows = append(rows, []any{id})
+			}
+		}
+		return []string{"id"}, rows
+	}
+
+	t.Run("multi-row insert", func(t *testing.T) {
+		db, conn := newRecordingDB(respond)
+		st, handler := newWriteStore()
+		c := NewCollection("users", db, st)
+
+		created, err := c.CreateRecords(ctx, []types.Record{
+			{"id": "u1", "name": "Ann"},
+			{"id": "u2", "email": "bob@example.com"},
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, []any{"u1", "u2"}, recordIDs(created))
+		assert.Equal(t, []string{
+			"INSERT INTO users (email, id, name) VALUES (DEFAULT, $1, $2), ($3, $4, DEFAULT) RETURNING *",
+		}, conn.queries)
+		assert.Equal(t, [][]any{{"u1", "Ann", "bob@example.com", "u2"}}, conn.args)
+		assert.Equal(t, []string{"create:u1", "create:u2"}, handler.writes)
+	})
+
+	t.Run("batches of the maximum parameters", func(t *testing.T) {
+		db, conn := newRecordingDB(respond)
+		st, _ := newWriteStore()
+		c := NewCollection("users", db, st, withDialect(smallDialect{Dialect: Postgres}))
+
+		records := make([]types.Record, 5)
+		for i := range records {
+			records[i] = types.Record{"id": fmt.Sprintf("u%d", i+1), "name": "member"}
+		}
+		created, err := c.CreateRecords(ctx, records)
+		assert.NoError(t, err)
+		assert.Equal(t, []any{"u1", "u2", "u3", "u4", "u5"}, recordIDs(created))
+		assert.Equal(t, []string{
+			"INSERT INTO users (id, name) VALUES ($1, $2), ($3, $4) RETURNING *",
+			"INSERT INTO users (id, name) VALUES ($1, $2), ($3, $4) RETURNING *",
+			"INSERT INTO users (id, name) VALUES ($1, $2) RETURNING *",
+		}, conn.queries)
+		assert.Equal(t, []any{"u5", "member"}, conn.args[2])
+	})
+}
+
+func TestCollection_Upsert(t *testing.T) {
+	ctx := context.Background()
+	const upsertQuery = "INSERT INTO users (email, id, name) VALUES ($1, $2, $3) ON CONFLICT (email) DO UPDATE SET name = EXCLUDED.name RETURNING *, (xmax = 0) AS _upsert_inserted"
+
+	// existing is the row found by the lookup of the conflicting row, inserted tells the upsert result
+	newDB := func(existing []any, inserted bool) (*sqlx.DB, *recordingConn) {
+		return newRecordingDB(func(query string, args []any) ([]string, [][]any) {
+			if strings.HasPrefix(query, "SELECT") {
+				if existing == nil {
+					return []string{"id", "email", "name"}, nil
+				}
+				return []string{"id", "email", "name"}, [][]any{existing}
+			}
+			return []string{"id", "email", "name", upsertInsertedColumn}, [][]any{{"u1", "ann@example.com", "Ann", inserted}}
+		})
+	}
+	record := func() types.Record {
+		return types.Record{"id": "u1", "email": "ann@example.com", "name": "Ann"}
+	}
+
+	t.Run("inserted", func(t *testing.T) {
+		db, conn := newDB(nil, true)
+		st, handler := newWriteStore()
+		c := NewCollection("users", db, st)
+
+		upserted, err := c.Upsert(ctx, record(), []string{"email"}, []string{"name"})
+		assert.NoError(t, err)
+		assert.Equal(t, types.Record{"id": "u1", "email": "ann@example.com", "name": "Ann"}, *upserted)
+		if assert.Len(t, conn.queries, 2) {
+			assert.Equal(t, upsertQuery, conn.queries[1])
+			assert.Equal(t, []any{"ann@example.com", "u1", "Ann"}, conn.args[1])
+		}
+		assert.Equal(t, []string{"create:u1"}, handler.writes)
+	})
+
+	t.Run("updated", func(t *testing.T) {
+		db, conn := newDB([]any{"u1", "ann@example.com", "Anne"}, false)
+		st, handler := newWriteStore()
+		c := NewCollection("users", db, st)
+
+		_, err := c.Upsert(ctx, record(), []string{"email"}, []string{"name"})
+		assert.NoError(t, err)
+		if assert.Len(t, conn.queries, 2) {
+			assert.Equal(t, upsertQuery, conn.queries[1])
+		}
+		assert.Equal(t, []string{"update:u1"}, handler.writes)
+	})
+
+	t.Run("tenant", func(t *testing.T) {
+		db, conn := newDB(nil, true)
+		st, _ := newWriteStore()
+		c := NewCollection("users", db, st, TenantScoped("org1"))
+
+		_, err := c.Upsert(ctx, record(), []string{"email"}, []string{"name"})
+		assert.NoError(t, err)
+		if assert.Len(t, conn.queries, 2) {
+			assert.Equal(t, "INSERT INTO users (email, id, name, organisation_id) VALUES ($1, $2, $3, $4) ON CONFLICT (email) DO UPDATE SET name = EXCLUDED.name WHERE users.organisation_id = $5 RETURNING *, (xmax = 0) AS _upsert_inserted", conn.queries[1])
+			assert.Equal(t, []any{"ann@example.com", "u1", "Ann", "org1", "org1"}, conn.args[1])
+		}
+	})
+
+	t.Run("invalid columns", func(t *testing.T) {
+		db, conn := newDB(nil, true)
+		c := NewCollection("users", db, &Store{})
+
+		_, err := c.Upsert(ctx, record(), nil, nil)
+		assert.Error(t, err)
+		_, err = c.Upsert(ctx, record(), []string{"email; drop"}, nil)
+		assert.Error(t, err)
+		_, err = c.Upsert(ctx, record(), []string{"email"}, []string{"name; drop"})
+		assert.Error(t, err)
+		assert.Empty(t, conn.queries)
+	})
+}
@@ -0,0 +1,37 @@
+package store
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/tuongaz/go-saas/store/types"
+)
+
+const (
+	// versionColumn is the column checked by WithVersion
+	versionColumn = "version"
+
+	// updatedAtColumn is the column checked by WithUnmodifiedSince
+	updatedAtColumn = "updated_at"
+)
+
+// checkPreconditions checks the update preconditions against the current record
+func checkPreconditions(current types.Record, options *UpdateOptions) error {
+	if options.Version != nil {
+		if _, ok := current[versionColumn]; !ok {
+			return fmt.Errorf("record has no %s column", versionColumn)
+		}
+		if version := current.Int64(versionColumn); version != *options.Version {
+			return NewConflictErr(fmt.Errorf("expected version %d, got %d", *options.Version, version))
+		}
+	}
+
+	if options.UnmodifiedSince != nil {
+		updatedAt := current.Time(updatedAtColumn)
+		if updatedAt.After(options.UnmodifiedSince.Truncate(time.Second)) {
+			return NewConflictErr(fmt.Errorf("record was modified at %s", updatedAt.Format(time.RFC3339)))
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,48 @@
+package store
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tuongaz/go-saas/store/types"
+)
+
+func TestCheckPreconditions(t *testing.T) {
+	updatedAt := time.Date(2025, 1, 1, 10, 0, 0, 0, time.UTC)
+	current := types.Record{
+		"id":         "org1",
+		"version":    int64(3),
+		"updated_at": updatedAt.Format(time.RFC3339),
+	}
+
+	options := func(opts ...UpdateOption) *UpdateOptions {
+		o := &UpdateOptions{}
+		for _, opt := range opts {
+			opt(o)
+		}
+		return o
+	}
+
+	assert.NoError(t, checkPreconditions(current, options()))
+	assert.NoError(t, checkPreconditions(current, options(WithVersion(3))))
+	assert.True(t, IsConflictError(checkPreconditions(current, options(WithVersion(2)))))
+
+	assert.NoError(t, checkPreconditions(current, options(WithUnmodifiedSince(updatedAt.Add(500*time.Millisecond)))))
+	assert.True(t, IsConflictError(checkPreconditions(current, options(WithUnmodifiedSince(updatedAt.Add(-time.Second))))))
+
+	t.Run("missing version column", func(t *testing.T) {
+		err := checkPreconditions(types.Record{"id": "org1"}, options(WithVersion(1)))
+		assert.Error(t, err)
+		assert.False(t, IsConflictError(err))
+	})
+}
+
+func TestConflictErr(t *testing.T) {
+	err := NewConflictErr(errors.New("stale"))
+	assert.Equal(t, "conflict: stale", err.Error())
+	assert.True(t, IsConflictError(err))
+	assert.False(t, IsConflictError(NewNotFoundErr(errors.New("missing"))))
+	assert.False(t, IsConflictError(nil))
+}
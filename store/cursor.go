@@ -0,0 +1,266 @@
+package store
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/tuongaz/go-saas/store/types"
+)
+
+// cursorTiebreaker is the column appended to the sort options of a collection when it is
+// not already present, so that every row has a unique position in the ordering.
+const cursorTiebreaker = "id"
+
+// ErrInvalidCursor is returned when a cursor cannot be decoded or does not match the sort options
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// Cursor represents keyset pagination parameters.
+// After is an opaque cursor taken from Metadata.NextCursor or Metadata.PrevCursor,
+// an empty value starts from the first page.
+type Cursor struct {
+	After string
+	Limit int
+}
+
+// cursorToken is the decoded form of an opaque cursor
+type cursorToken struct {
+	Values   []any `json:"v"`
+	Backward bool  `json:"b,omitempty"`
+}
+
+// encodeCursor encodes the sort values of a boundary row into an opaque cursor
+func encodeCursor(values []any, backward bool) (string, error) {
+	data, err := json.Marshal(cursorToken{Values: values, Backward: backward})
+	if err != nil {
+		return "", fmt.Errorf("encode cursor: %w", err)
+	}
+
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+// decodeCursor decodes an opaque cursor. It returns nil for an empty cursor.
+func decodeCursor(cursor string, sort []SortOption) (*cursorToken, error) {
+	if cursor == "" {
+		return nil, nil
+	}
+
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidCursor, err)
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+
+	token := &cursorToken{}
+	if err := decoder.Decode(token); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidCursor, err)
+	}
+
+	if len(token.Values) != len(sort) {
+		return nil, fmt.Errorf("%w: expected %d values, got %d", ErrInvalidCursor, len(sort), len(token.Values))
+	}
+
+	return token, nil
+}
+
+// cursorSort validates the sort options used for keyset pagination and appends the
+// tiebreaker column, unique to every row, when it is missing.
+func cursorSort(sort []SortOption, tiebreaker string) ([]SortOption, error) {
+	if !ValidIdentifierName(tiebreaker) {
+		return nil, fmt.Errorf("invalid cursor key: %s", tiebreaker)
+	}
+
+	out := make([]SortOption, 0, len(sort)+1)
+	hasTiebreaker := false
+
+	for _, opt := range sort {
+		if !ValidIdentifierName(opt.Field) {
+			return nil, fmt.Errorf("invalid field name for sorting: %s", opt.Field)
+		}
+
+		if strings.TrimSpace(opt.Operator) != "" {
+			return nil, fmt.Errorf("operator-based sorting is not supported with cursor pagination: %s", opt.Field)
+		}
+
+		if opt.Direction != SortAsc && opt.Direction != SortDesc {
+			opt.Direction = SortAsc
+		}
+
+		if opt.Field == tiebreaker {
+			hasTiebreaker = true
+		}
+
+		out = append(out, opt)
+	}
+
+	if !hasTiebreaker {
+		out = append(out, SortOption{Field: tiebreaker, Direction: SortAsc})
+	}
+
+	return out, nil
+}
+
+// buildCursorOrderBy builds the ORDER BY clause for keyset pagination.
+// When reverse is true every direction is flipped, which is used to walk backwards.
+// NULLs sort after the other values in ascending order, whatever the database.
+func buildCursorOrderBy(sort []SortOption, reverse bool) string {
+	clauses := make([]string, len(sort))
+	for i, opt := range sort {
+		direction := opt.Direction
+		if reverse {
+			direction = reverseDirection(direction)
+		}
+		nulls := "LAST"
+		if direction == SortDesc {
+			nulls = "FIRST"
+		}
+		clauses[i] = fmt.Sprintf("%s %s NULLS %s", opt.Field, direction, nulls)
+	}
+
+	return " ORDER BY " + strings.Join(clauses, ", ")
+}
+
+// buildKeysetCondition builds the condition selecting rows positioned after
+// (or before, when backward is true) the given sort values.
+//
+// For sort (a ASC, b DESC) moving forward the condition is:
+//
+//	((a > $1 OR a IS NULL) OR (a = $1 AND b < $2))
+//
+// NULLs are greater than the other values, like in the order of buildCursorOrderBy,
+// and the NULL sort values are matched with IS NULL rather than bound.
+func buildKeysetCondition(sort []SortOption, values []any, backward bool, startIdx int) (string, []any) {
+	var args []any
+	params := make([]string, len(values))
+	param := func(i int) string {
+		if params[i] == "" {
+			params[i] = fmt.Sprintf("$%d", startIdx+len(args))
+			args = append(args, values[i])
+		}
+		return params[i]
+	}
+
+	ors := make([]string, 0, len(sort))
+	for i, opt := range sort {
+		ands := make([]string, 0, i+1)
+		for j := 0; j < i; j++ {
+			if values[j] == nil {
+				ands = append(ands, sort[j].Field+" IS NULL")
+				continue
+			}
+			ands = append(ands, fmt.Sprintf("%s = %s", sort[j].Field, param(j)))
+		}
+
+		greater := (opt.Direction == SortDesc) == backward
+		switch {
+		case values[i] == nil && greater:
+			// Nothing is greater than NULL
+			continue
+		case values[i] == nil:
+			ands = append(ands, opt.Field+" IS NOT NULL")
+		case greater:
+			ands = append(ands, fmt.Sprintf("(%s > %s OR %s IS NULL)", opt.Field, param(i), opt.Field))
+		default:
+			ands = append(ands, fmt.Sprintf("%s < %s", opt.Field, param(i)))
+		}
+
+		if len(ands) == 1 {
+			ors = append(ors, ands[0])
+			continue
+		}
+		ors = append(ors, "("+strings.Join(ands, " AND ")+")")
+	}
+
+	if len(ors) == 0 {
+		return "1 = 0", nil
+	}
+	return "(" + strings.Join(ors, " OR ") + ")", args
+}
+
+// ensureCursorFields checks that the selected fields include every sort field
+func ensureCursorFields(fields []string, sort []SortOption) error {
+	if len(fields) == 0 {
+		return nil
+	}
+
+	selected := make(map[string]bool, len(fields))
+	for _, field := range fields {
+		selected[field] = true
+	}
+
+	for _, opt := range sort {
+		if !selected[opt.Field] {
+			return fmt.Errorf("cursor pagination requires sort field %s to be selected", opt.Field)
+		}
+	}
+
+	return nil
+}
+
+// cursorValues extracts the sort values from a scanned record before it is normalised,
+// so that timestamps keep their full precision.
+func cursorValues(rec types.Record, sort []SortOption) []any {
+	values := make([]any, len(sort))
+	for i, opt := range sort {
+		values[i] = rec[opt.Field]
+	}
+
+	return values
+}
+
+// cursorPage trims the over-fetched rows of a keyset query, restores the
+// natural order when walking backwards and sets the cursors on the metadata.
+// recs must hold up to limit+1 records scanned but not yet normalised.
+func cursorPage(recs []types.Record, sort []SortOption, limit int, token *cursorToken, meta *Metadata) ([]types.Record, error) {
+	backward := token != nil && token.Backward
+	hasMore := len(recs) > limit
+	if hasMore {
+		recs = recs[:limit]
+	}
+
+	if backward {
+		for i, j := 0, len(recs)-1; i < j; i, j = i+1, j-1 {
+			recs[i], recs[j] = recs[j], recs[i]
+		}
+	}
+
+	meta.Limit = limit
+	if len(recs) == 0 {
+		return recs, nil
+	}
+
+	first := cursorValues(recs[0], sort)
+	last := cursorValues(recs[len(recs)-1], sort)
+
+	var err error
+	if backward || hasMore {
+		if meta.NextCursor, err = encodeCursor(last, false); err != nil {
+			return nil, err
+		}
+	}
+
+	if (backward && hasMore) || (!backward && token != nil) {
+		if meta.PrevCursor, err = encodeCursor(first, true); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, rec := range recs {
+		rec.Normalise()
+	}
+
+	return recs, nil
+}
+
+func reverseDirection(direction SortDirection) SortDirection {
+	if direction == SortDesc {
+		return SortAsc
+	}
+
+	return SortDesc
+}
@@ -0,0 +1,191 @@
+package store
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tuongaz/go-saas/store/types"
+)
+
+func TestCursor_EncodeDecode(t *testing.T) {
+	sort := []SortOption{
+		{Field: "created_at", Direction: SortDesc},
+		{Field: "id", Direction: SortAsc},
+	}
+
+	cursor, err := encodeCursor([]any{"2025-01-01T10:00:00.123456Z", "abc"}, true)
+	assert.NoError(t, err)
+
+	token, err := decodeCursor(cursor, sort)
+	assert.NoError(t, err)
+	assert.True(t, token.Backward)
+	assert.Equal(t, []any{"2025-01-01T10:00:00.123456Z", "abc"}, token.Values)
+
+	t.Run("keeps number precision", func(t *testing.T) {
+		cursor, err := encodeCursor([]any{int64(9007199254740993), "abc"}, false)
+		assert.NoError(t, err)
+
+		token, err := decodeCursor(cursor, sort)
+		assert.NoError(t, err)
+		assert.Equal(t, json.Number("9007199254740993"), token.Values[0])
+	})
+
+	t.Run("empty cursor", func(t *testing.T) {
+		token, err := decodeCursor("", sort)
+		assert.NoError(t, err)
+		assert.Nil(t, token)
+	})
+
+	t.Run("invalid cursor", func(t *testing.T) {
+		_, err := decodeCursor("not a cursor!", sort)
+		assert.True(t, errors.Is(err, ErrInvalidCursor))
+	})
+
+	t.Run("mismatched sort", func(t *testing.T) {
+		cursor, err := encodeCursor([]any{"abc"}, false)
+		assert.NoError(t, err)
+
+		_, err = decodeCursor(cursor, sort)
+		assert.True(t, errors.Is(err, ErrInvalidCursor))
+	})
+}
+
+func TestCursorSort(t *testing.T) {
+	t.Run("appends id tiebreaker", func(t *testing.T) {
+		sort, err := cursorSort([]SortOption{{Field: "created_at", Direction: SortDesc}}, cursorTiebreaker)
+		assert.NoError(t, err)
+		assert.Equal(t, []SortOption{
+			{Field: "created_at", Direction: SortDesc},
+			{Field: "id", Direction: SortAsc},
+		}, sort)
+	})
+
+	t.Run("keeps existing id", func(t *testing.T) {
+		sort, err := cursorSort([]SortOption{{Field: "id", Direction: SortDesc}}, cursorTiebreaker)
+		assert.NoError(t, err)
+		assert.Equal(t, []SortOption{{Field: "id", Direction: SortDesc}}, sort)
+	})
+
+	t.Run("rejects operators", func(t *testing.T) {
+		_, err := cursorSort([]SortOption{{Field: "embedding", Operator: "<->", Right: "query"}}, cursorTiebreaker)
+		assert.Error(t, err)
+	})
+
+	t.Run("rejects invalid fields", func(t *testing.T) {
+		_, err := cursorSort([]SortOption{{Field: "name; DROP TABLE users"}}, cursorTiebreaker)
+		assert.Error(t, err)
+
+		_, err = cursorSort(nil, "")
+		assert.Error(t, err)
+	})
+
+	t.Run("appends the given key", func(t *testing.T) {
+		sort, err := cursorSort([]SortOption{{Field: "total", Direction: SortDesc}}, "invoice_id")
+		assert.NoError(t, err)
+		assert.Equal(t, []SortOption{
+			{Field: "total", Direction: SortDesc},
+			{Field: "invoice_id", Direction: SortAsc},
+		}, sort)
+	})
+}
+
+func TestBuildKeysetCondition(t *testing.T) {
+	sort := []SortOption{
+		{Field: "created_at", Direction: SortDesc},
+		{Field: "id", Direction: SortAsc},
+	}
+
+	clause, args := buildKeysetCondition(sort, []any{"t", "abc"}, false, 2)
+	assert.Equal(t, "(created_at < $2 OR (created_at = $2 AND (id > $3 OR id IS NULL)))", clause)
+	assert.Equal(t, []any{"t", "abc"}, args)
+
+	clause, _ = buildKeysetCondition(sort, []any{"t", "abc"}, true, 1)
+	assert.Equal(t, "((created_at > $1 OR created_at IS NULL) OR (created_at = $1 AND id < $2))", clause)
+
+	t.Run("NULL values", func(t *testing.T) {
+		clause, args := buildKeysetCondition(sort, []any{nil, "abc"}, false, 1)
+		assert.Equal(t, "(created_at IS NOT NULL OR (created_at IS NULL AND (id > $1 OR id IS NULL)))", clause)
+		assert.Equal(t, []any{"abc"}, args)
+
+		clause, args = buildKeysetCondition(sort, []any{nil, "abc"}, true, 1)
+		assert.Equal(t, "((created_at IS NULL AND id < $1))", clause)
+		assert.Equal(t, []any{"abc"}, args)
+
+		clause, args = buildKeysetCondition([]SortOption{{Field: "id", Direction: SortAsc}}, []any{nil}, false, 1)
+		assert.Equal(t, "1 = 0", clause)
+		assert.Empty(t, args)
+	})
+
+	assert.Equal(t, " ORDER BY created_at DESC NULLS FIRST, id ASC NULLS LAST", buildCursorOrderBy(sort, false))
+	assert.Equal(t, " ORDER BY created_at ASC NULLS LAST, id DESC NULLS FIRST", buildCursorOrderBy(sort, true))
+}
+
+func TestCursorPage(t *testing.T) {
+	sort := []SortOption{{Field: "id", Direction: SortAsc}}
+	records := func(ids ...string) []types.Record {
+		recs := make([]types.Record, len(ids))
+		for i, id := range ids {
+			recs[i] = types.Record{"id": id}
+		}
+		return recs
+	}
+	values := func(cursor string) []any {
+		token, err := decodeCursor(cursor, sort)
+		assert.NoError(t, err)
+		return token.Values
+	}
+
+	t.Run("first page with more rows", func(t *testing.T) {
+		meta := Metadata{}
+		recs, err := cursorPage(records("a", "b", "c"), sort, 2, nil, &meta)
+		assert.NoError(t, err)
+		assert.Equal(t, records("a", "b"), recs)
+		assert.Equal(t, []any{"b"}, values(meta.NextCursor))
+		assert.Empty(t, meta.PrevCursor)
+		assert.Equal(t, 2, meta.Limit)
+	})
+
+	t.Run("last page", func(t *testing.T) {
+		meta := Metadata{}
+		recs, err := cursorPage(records("c"), sort, 2, &cursorToken{Values: []any{"b"}}, &meta)
+		assert.NoError(t, err)
+		assert.Equal(t, records("c"), recs)
+		assert.Empty(t, meta.NextCursor)
+		assert.Equal(t, []any{"c"}, values(meta.PrevCursor))
+	})
+
+	t.Run("backward page restores order", func(t *testing.T) {
+		meta := Metadata{}
+		recs, err := cursorPage(records("c", "b", "a"), sort, 2, &cursorToken{Values: []any{"d"}, Backward: true}, &meta)
+		assert.NoError(t, err)
+		assert.Equal(t, records("b", "c"), recs)
+		assert.Equal(t, []any{"c"}, values(meta.NextCursor))
+		assert.Equal(t, []any{"b"}, values(meta.PrevCursor))
+	})
+
+	t.Run("empty page", func(t *testing.T) {
+		meta := Metadata{}
+		recs, err := cursorPage(nil, sort, 2, nil, &meta)
+		assert.NoError(t, err)
+		assert.Empty(t, recs)
+		assert.Empty(t, meta.NextCursor)
+		assert.Empty(t, meta.PrevCursor)
+	})
+}
+
+func TestWhereBuilder(t *testing.T) {
+	where := &whereBuilder{}
+	assert.Equal(t, "", where.String())
+
+	where.addFilter(Filter{"status": "active"})
+	where.addExpression(NewOrGroup(
+		NewCondition("age", FilterOpGreater, 21),
+		NewCondition("deleted_at", FilterOpIsNull, nil),
+	))
+
+	assert.Equal(t, " WHERE status = $1 AND (age > $2 OR deleted_at IS NULL)", where.String())
+	assert.Equal(t, []any{"active", 21}, where.args)
+	assert.Equal(t, 3, where.nextIdx())
+}
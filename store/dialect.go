@@ -0,0 +1,253 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+// Dialect is the SQL flavour of a database. The store writes its queries for Postgres,
+// with $n placeholders, and the dialect adapts what differs between databases.
+// Postgres is the default dialect, others are registered with RegisterDialect and
+// selected by the scheme of the datasource, e.g. sqlite:app.db.
+type Dialect interface {
+	// Name is the scheme of the datasources of the dialect
+	Name() string
+
+	// DriverName is the database/sql driver of the connections opened by the dialect
+	DriverName() string
+
+	// Open opens the datasource without connecting to it
+	Open(datasource string) (*sqlx.DB, error)
+
+	// Rebind rewrites a query written for Postgres for the database. The drivers of the
+	// dialects apply it to every statement, so that raw queries are rewritten too.
+	Rebind(query string) string
+
+	// InArray returns the condition matching the expression against the values bound to the param with Array
+	InArray(expr string, param int) string
+	Array(values []any) any
+
+	// UpsertInserted returns the expression that is true when an upserted row was inserted,
+	// or an empty string when the database cannot tell
+	UpsertInserted() string
+
+	// InsertDefault is the value inserting the default of a column in a multi-row insert,
+	// or an empty string when the database has none
+	InsertDefault() string
+
+	// MaxParams is the maximum number of parameters of a statement
+	MaxParams() int
+
+	// ClassifyError converts the constraint violations of the database into DuplicateKeyErr,
+	// ForeignKeyErr and NotNullErr, it returns nil for the other errors
+	ClassifyError(err error) error
+
+	// Lock acquires the lock with the id, waiting for it to be released by other processes
+	Lock(ctx context.Context, db *sqlx.DB, id int64) (Lock, error)
+
+	// TryLock acquires the lock with the id, it returns a nil lock when the lock is held
+	TryLock(ctx context.Context, db *sqlx.DB, id int64) (Lock, error)
+}
+
+// Lock is a lock acquired with a dialect, e.g. to elect a leader among the replicas
+type Lock interface {
+	// Check returns an error when the lock was lost, e.g. with the connection holding it
+	Check(ctx context.Context) error
+	Release(ctx context.Context) error
+}
+
+// Postgres is the default dialect
+var Postgres Dialect = postgresDialect{}
+
+var (
+	dialectsMu sync.RWMutex
+	dialects   = []Dialect{Postgres}
+)
+
+// RegisterDialect makes the datasources prefixed with the name of the dialect open with it,
+// usually from an init function. It panics when a dialect is registered twice.
+func RegisterDialect(dialect Dialect) {
+	dialectsMu.Lock()
+	defer dialectsMu.Unlock()
+
+	for _, d := range dialects {
+		if d.Name() == dialect.Name() || d.DriverName() == dialect.DriverName() {
+			panic(fmt.Sprintf("store: dialect %q registered twice", dialect.Name()))
+		}
+	}
+	dialects = append(dialects, dialect)
+}
+
+func registeredDialects() []Dialect {
+	dialectsMu.RLock()
+	defer dialectsMu.RUnlock()
+
+	return append([]Dialect(nil), dialects...)
+}
+
+// DialectOf returns the dialect of the connections of the database, Postgres unless
+// they were opened by another registered dialect
+func DialectOf(db *sqlx.DB) Dialect {
+	if db == nil {
+		return Postgres
+	}
+	for _, dialect := range registeredDialects() {
+		if dialect.DriverName() == db.DriverName() {
+			return dialect
+		}
+	}
+	return Postgres
+}
+
+// dialectFor returns the dialect of the datasource, selected by its scheme
+func dialectFor(datasource string) Dialect {
+	for _, dialect := range registeredDialects() {
+		if dialect != Postgres && strings.HasPrefix(datasource, dialect.Name()+":") {
+			return dialect
+		}
+	}
+	return Postgres
+}
+
+// openDatasource opens the datasource with its dialect
+func openDatasource(datasource string) (*sqlx.DB, Dialect, error) {
+	dialect := dialectFor(datasource)
+	db, err := dialect.Open(datasource)
+	if err != nil {
+		return nil, nil, err
+	}
+	return db, dialect, nil
+}
+
+// withDialect makes the collection build its queries for the dialect
+func withDialect(dialect Dialect) CollectionOption {
+	return func(c *collection) {
+		c.dialect = dialect
+	}
+}
+
+// sqlDialect returns the dialect of the collection, Postgres by default
+func (c *collection) sqlDialect() Dialect {
+	if c.dialect != nil {
+		return c.dialect
+	}
+	return Postgres
+}
+
+type postgresDialect struct{}
+
+func (postgresDialect) Name() string {
+	return "postgres"
+}
+
+func (postgresDialect) DriverName() string {
+	return "postgres"
+}
+
+func (postgresDialect) Open(datasource string) (*sqlx.DB, error) {
+	return sqlx.Open("postgres", datasource)
+}
+
+func (postgresDialect) Rebind(query string) string {
+	return query
+}
+
+func (postgresDialect) InArray(expr string, param int) string {
+	return fmt.Sprintf("%s = ANY($%d)", expr, param)
+}
+
+func (postgresDialect) Array(values []any) any {
+	return pq.Array(values)
+}
+
+// UpsertInserted uses the system column of the row, which is zero for inserted rows
+func (postgresDialect) UpsertInserted() string {
+	return "(xmax = 0)"
+}
+
+func (postgresDialect) InsertDefault() string {
+	return "DEFAULT"
+}
+
+func (postgresDialect) MaxParams() int {
+	return 65535
+}
+
+func (postgresDialect) ClassifyError(err error) error {
+	code := ""
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		code = string(pqErr.Code)
+	}
+
+	switch {
+	case code == "23505" || strings.Contains(err.Error(), "23505"):
+		return NewDuplicateKeyErr(err)
+	case code == "23503" || strings.Contains(err.Error(), "23503"):
+		return NewForeignKeyErr(err)
+	case code == "23502" || strings.Contains(err.Error(), "23502"):
+		return NewNotNullErr(err)
+	}
+	return nil
+}
+
+// Lock acquires a session-level advisory lock, held on a dedicated connection so that it is
+// released on the session that acquired it, and lost automatically if that connection dies
+func (postgresDialect) Lock(ctx context.Context, db *sqlx.DB, id int64) (Lock, error) {
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("get connection: %w", err)
+	}
+
+	if _, err := conn.ExecContext(ctx, "SELECT pg_advisory_lock($1)", id); err != nil {
+		_ = conn.Close()
+		return nil, fmt.Errorf("acquire advisory lock: %w", err)
+	}
+
+	return &advisoryLock{conn: conn, id: id}, nil
+}
+
+func (postgresDialect) TryLock(ctx context.Context, db *sqlx.DB, id int64) (Lock, error) {
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("get connection: %w", err)
+	}
+
+	var acquired bool
+	if err := conn.QueryRowContext(ctx, "SELECT pg_try_advisory_lock($1)", id).Scan(&acquired); err != nil {
+		_ = conn.Close()
+		return nil, fmt.Errorf("acquire advisory lock: %w", err)
+	}
+	if !acquired {
+		_ = conn.Close()
+		return nil, nil
+	}
+
+	return &advisoryLock{conn: conn, id: id}, nil
+}
+
+// advisoryLock is a Postgres advisory lock and the connection of the session holding it
+type advisoryLock struct {
+	conn *sql.Conn
+	id   int64
+}
+
+func (l *advisoryLock) Check(ctx context.Context) error {
+	return l.conn.PingContext(ctx)
+}
+
+func (l *advisoryLock) Release(ctx context.Context) error {
+	defer l.conn.Close()
+
+	if _, err := l.conn.ExecContext(ctx, "SELECT pg_advisory_unlock($1)", l.id); err != nil {
+		return fmt.Errorf("release advisory lock: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,190 @@
+package store
+
+import (
+	"context"
+
+	"github.com/tuongaz/go-saas/store/events"
+	"github.com/tuongaz/go-saas/store/types"
+)
+
+// eventDispatcher dispatches the record events of a store to its event handlers
+type eventDispatcher struct {
+	handlers []events.Handler
+
+	txEventRegistry
+}
+
+// AddEventHandler adds an event handler to the store. The handlers implementing
+// events.RestoreHandler or events.PurgeHandler also receive the restores and purges.
+func (d *eventDispatcher) AddEventHandler(handler events.Handler) {
+	d.handlers = append(d.handlers, handler)
+}
+
+// Database events
+func (d *eventDispatcher) OnBeforeRecordCreated(ctx context.Context, table string, record types.Record) error {
+	event := &events.OnBeforeRecordCreatedEvent{
+		DatabaseEvent: events.DatabaseEvent{
+			Table:  table,
+			Record: record,
+		},
+	}
+	for _, handler := range d.handlers {
+		if err := handler.OnBeforeCreate(ctx, event); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (d *eventDispatcher) OnAfterRecordCreated(ctx context.Context, table string, record types.Record) error {
+	event := &events.OnAfterRecordCreatedEvent{
+		DatabaseEvent: events.DatabaseEvent{
+			Table:  table,
+			Record: record,
+		},
+	}
+	for _, handler := range d.handlers {
+		if err := handler.OnAfterCreate(ctx, event); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (d *eventDispatcher) OnBeforeRecordUpdated(ctx context.Context, table string, record types.Record, oldRecord types.Record) error {
+	event := &events.OnBeforeRecordUpdatedEvent{
+		DatabaseEvent: events.DatabaseEvent{
+			Table:  table,
+			Record: record,
+		},
+		OldRecord: oldRecord,
+	}
+	for _, handler := range d.handlers {
+		if err := handler.OnBeforeUpdate(ctx, event); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (d *eventDispatcher) OnAfterRecordUpdated(ctx context.Context, table string, record types.Record, oldRecord types.Record) error {
+	event := &events.OnAfterRecordUpdatedEvent{
+		DatabaseEvent: events.DatabaseEvent{
+			Table:  table,
+			Record: record,
+		},
+		OldRecord: oldRecord,
+	}
+	for _, handler := range d.handlers {
+		if err := handler.OnAfterUpdate(ctx, event); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (d *eventDispatcher) OnBeforeRecordDeleted(ctx context.Context, table string, record types.Record) error {
+	event := &events.OnBeforeRecordDeletedEvent{
+		DatabaseEvent: events.DatabaseEvent{
+			Table:  table,
+			Record: record,
+		},
+	}
+	for _, handler := range d.handlers {
+		if err := handler.OnBeforeDelete(ctx, event); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (d *eventDispatcher) OnAfterRecordDeleted(ctx context.Context, table string, record types.Record) error {
+	event := &events.OnAfterRecordDeletedEvent{
+		DatabaseEvent: events.DatabaseEvent{
+			Table:  table,
+			Record: record,
+		},
+	}
+	for _, handler := range d.handlers {
+		if err := handler.OnAfterDelete(ctx, event); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (d *eventDispatcher) OnBeforeRecordRestored(ctx context.Context, table string, record types.Record) error {
+	event := &events.OnBeforeRecordRestoredEvent{
+		DatabaseEvent: events.DatabaseEvent{
+			Table:  table,
+			Record: record,
+		},
+	}
+	for _, handler := range d.handlers {
+		h, ok := handler.(events.RestoreHandler)
+		if !ok {
+			continue
+		}
+		if err := h.OnBeforeRestore(ctx, event); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (d *eventDispatcher) OnAfterRecordRestored(ctx context.Context, table string, record types.Record) error {
+	event := &events.OnAfterRecordRestoredEvent{
+		DatabaseEvent: events.DatabaseEvent{
+			Table:  table,
+			Record: record,
+		},
+	}
+	for _, handler := range d.handlers {
+		h, ok := handler.(events.RestoreHandler)
+		if !ok {
+			continue
+		}
+		if err := h.OnAfterRestore(ctx, event); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (d *eventDispatcher) OnBeforeRecordPurged(ctx context.Context, table string, record types.Record) error {
+	event := &events.OnBeforeRecordPurgedEvent{
+		DatabaseEvent: events.DatabaseEvent{
+			Table:  table,
+			Record: record,
+		},
+	}
+	for _, handler := range d.handlers {
+		h, ok := handler.(events.PurgeHandler)
+		if !ok {
+			continue
+		}
+		if err := h.OnBeforePurge(ctx, event); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (d *eventDispatcher) OnAfterRecordPurged(ctx context.Context, table string, record types.Record) error {
+	event := &events.OnAfterRecordPurgedEvent{
+		DatabaseEvent: events.DatabaseEvent{
+			Table:  table,
+			Record: record,
+		},
+	}
+	for _, handler := range d.handlers {
+		h, ok := handler.(events.PurgeHandler)
+		if !ok {
+			continue
+		}
+		if err := h.OnAfterPurge(ctx, event); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,42 @@
+package store
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/tuongaz/go-saas/store/events"
+	"github.com/tuongaz/go-saas/store/types"
+)
+
+type restoringHandler struct {
+	events.Handler
+	restored []string
+}
+
+func (h *restoringHandler) OnBeforeRestore(ctx context.Context, event *events.OnBeforeRecordRestoredEvent) error {
+	h.restored = append(h.restored, "before:"+event.Table)
+	return nil
+}
+
+func (h *restoringHandler) OnAfterRestore(ctx context.Context, event *events.OnAfterRecordRestoredEvent) error {
+	h.restored = append(h.restored, "after:"+event.Table)
+	return nil
+}
+
+func TestEventDispatcher_OptionalHandlers(t *testing.T) {
+	ctx := context.Background()
+	d := &eventDispatcher{}
+	restoring := &restoringHandler{}
+	d.AddEventHandler(&recordingHandler{})
+	d.AddEventHandler(restoring)
+
+	// The handlers without restore or purge methods are skipped
+	assert.NoError(t, d.OnBeforeRecordRestored(ctx, "books", types.Record{}))
+	assert.NoError(t, d.OnAfterRecordRestored(ctx, "books", types.Record{}))
+	assert.NoError(t, d.OnBeforeRecordPurged(ctx, "books", types.Record{}))
+	assert.NoError(t, d.OnAfterRecordPurged(ctx, "books", types.Record{}))
+
+	assert.Equal(t, []string{"before:books", "after:books"}, restoring.restored)
+}
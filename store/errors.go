@@ -2,6 +2,10 @@ package store
 
 import (
 	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
 )
 
 func NewNotFoundErr(err error) error {
@@ -103,6 +107,56 @@ func IsNotNullError(err error) bool {
 	return errors.As(err, &e)
 }
 
+// ConflictErr represents a failed optimistic concurrency check, the record was modified concurrently
+type ConflictErr struct {
+	err error
+}
+
+func NewConflictErr(err error) error {
+	return &ConflictErr{err: err}
+}
+
+func (e ConflictErr) Error() string {
+	return "conflict: " + e.err.Error()
+}
+
+func (e ConflictErr) Unwrap() error {
+	return e.err
+}
+
+func IsConflictError(err error) bool {
+	if err == nil {
+		return false
+	}
+	var e *ConflictErr
+	return errors.As(err, &e)
+}
+
+// TenantErr is returned when a write would cross into another organisation
+type TenantErr struct {
+	err error
+}
+
+func NewTenantErr(err error) error {
+	return &TenantErr{err: err}
+}
+
+func (e TenantErr) Error() string {
+	return "tenant violation: " + e.err.Error()
+}
+
+func (e TenantErr) Unwrap() error {
+	return e.err
+}
+
+func IsTenantError(err error) bool {
+	if err == nil {
+		return false
+	}
+	var e *TenantErr
+	return errors.As(err, &e)
+}
+
 type DBError struct {
 	Err error
 }
@@ -118,3 +172,30 @@ func (e *DBError) Error() string {
 func (e *DBError) Unwrap() error {
 	return e.Err
 }
+
+// ValidationErr is returned when a record does not match the schema of its collection.
+// Fields maps the invalid fields to the problem found, e.g. "name": "is required".
+type ValidationErr struct {
+	Table  string
+	Fields map[string]string
+}
+
+func NewValidationErr(table string, fields map[string]string) error {
+	return &ValidationErr{Table: table, Fields: fields}
+}
+
+func (e ValidationErr) Error() string {
+	problems := make([]string, 0, len(e.Fields))
+	for _, field := range slices.Sorted(maps.Keys(e.Fields)) {
+		problems = append(problems, field+" "+e.Fields[field])
+	}
+	return fmt.Sprintf("invalid %s record: %s", e.Table, strings.Join(problems, ", "))
+}
+
+func IsValidationError(err error) bool {
+	if err == nil {
+		return false
+	}
+	var e *ValidationErr
+	return errors.As(err, &e)
+}
@@ -44,6 +44,26 @@ type OnAfterRecordDeletedEvent struct {
 	DatabaseEvent
 }
 
+// OnBeforeRecordRestoredEvent represents an event that is triggered before a soft deleted record is restored
+type OnBeforeRecordRestoredEvent struct {
+	DatabaseEvent
+}
+
+// OnAfterRecordRestoredEvent represents an event that is triggered after a soft deleted record is restored
+type OnAfterRecordRestoredEvent struct {
+	DatabaseEvent
+}
+
+// OnBeforeRecordPurgedEvent represents an event that is triggered before a soft deleted record is permanently deleted
+type OnBeforeRecordPurgedEvent struct {
+	DatabaseEvent
+}
+
+// OnAfterRecordPurgedEvent represents an event that is triggered after a soft deleted record is permanently deleted
+type OnAfterRecordPurgedEvent struct {
+	DatabaseEvent
+}
+
 // Handler defines the interface for database event handlers
 type Handler interface {
 	OnBeforeCreate(ctx context.Context, event *OnBeforeRecordCreatedEvent) error
@@ -53,3 +73,15 @@ type Handler interface {
 	OnBeforeDelete(ctx context.Context, event *OnBeforeRecordDeletedEvent) error
 	OnAfterDelete(ctx context.Context, event *OnAfterRecordDeletedEvent) error
 }
+
+// RestoreHandler is implemented by the handlers that also handle the restores of soft deleted records
+type RestoreHandler interface {
+	OnBeforeRestore(ctx context.Context, event *OnBeforeRecordRestoredEvent) error
+	OnAfterRestore(ctx context.Context, event *OnAfterRecordRestoredEvent) error
+}
+
+// PurgeHandler is implemented by the handlers that also handle the purges of soft deleted records
+type PurgeHandler interface {
+	OnBeforePurge(ctx context.Context, event *OnBeforeRecordPurgedEvent) error
+	OnAfterPurge(ctx context.Context, event *OnAfterRecordPurgedEvent) error
+}
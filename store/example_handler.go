@@ -51,6 +51,30 @@ func (h *ExampleHandler) OnAfterDelete(ctx context.Context, event *events.OnAfte
 	return nil
 }
 
+// OnBeforeRestore is called before a soft deleted record is restored
+func (h *ExampleHandler) OnBeforeRestore(ctx context.Context, event *events.OnBeforeRecordRestoredEvent) error {
+	fmt.Printf("Before restoring record in table %s: %+v\n", event.Table, event.Record)
+	return nil
+}
+
+// OnAfterRestore is called after a soft deleted record is restored
+func (h *ExampleHandler) OnAfterRestore(ctx context.Context, event *events.OnAfterRecordRestoredEvent) error {
+	fmt.Printf("After restoring record in table %s: %+v\n", event.Table, event.Record)
+	return nil
+}
+
+// OnBeforePurge is called before a soft deleted record is permanently deleted
+func (h *ExampleHandler) OnBeforePurge(ctx context.Context, event *events.OnBeforeRecordPurgedEvent) error {
+	fmt.Printf("Before purging record in table %s: %+v\n", event.Table, event.Record)
+	return nil
+}
+
+// OnAfterPurge is called after a soft deleted record is permanently deleted
+func (h *ExampleHandler) OnAfterPurge(ctx context.Context, event *events.OnAfterRecordPurgedEvent) error {
+	fmt.Printf("After purging record in table %s: %+v\n", event.Table, event.Record)
+	return nil
+}
+
 // Example usage:
 /*
 func main() {
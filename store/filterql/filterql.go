@@ -0,0 +1,389 @@
+// Package filterql parses a compact filter language used in query strings into store filter
+// expressions and sort options, e.g.
+//
+//	?filter=(status='active' || plan='pro') && created_at>'2025-01-01'&sort=-created_at,name
+//
+// Conditions compare a field with a value using =, !=, >, >=, <, <= or ~ (case insensitive
+// contains, % and _ are wildcards). "field = null" and "field != null" check for NULL, and
+// "field in ('a', 'b')" and "field not in (1, 2)" match a list of values. Conditions are
+// combined with && and ||, && binds tighter, and grouped with parentheses.
+// Only the fields of the allowlist can be used, and values are converted to the field types.
+package filterql
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tuongaz/go-saas/pkg/apierror"
+	"github.com/tuongaz/go-saas/store"
+)
+
+const (
+	maxDepth      = 10
+	maxConditions = 50
+)
+
+// Type is the type a field value is converted to
+type Type int
+
+const (
+	// TypeAny keeps the value as written, numbers are converted to int64 or float64
+	TypeAny Type = iota
+	TypeString
+	TypeInt
+	TypeFloat
+	TypeBool
+	TypeTime
+)
+
+func (t Type) String() string {
+	switch t {
+	case TypeString:
+		return "a string"
+	case TypeInt:
+		return "an integer"
+	case TypeFloat:
+		return "a number"
+	case TypeBool:
+		return "a boolean"
+	case TypeTime:
+		return "a time"
+	}
+	return "a value"
+}
+
+// Fields is the allowlist of the fields that can be filtered and sorted on, with their types
+type Fields map[string]Type
+
+// NewFields creates an allowlist of untyped fields
+func NewFields(names ...string) Fields {
+	fields := make(Fields, len(names))
+	for _, name := range names {
+		fields[name] = TypeAny
+	}
+	return fields
+}
+
+// SchemaFields creates an allowlist of fields typed after the fields of the schema,
+// the fields the schema does not declare are untyped
+func SchemaFields(schema *store.Schema, names ...string) Fields {
+	fields := NewFields(names...)
+	for _, name := range names {
+		f, ok := schema.Field(name)
+		if !ok {
+			continue
+		}
+		switch f.Type {
+		case store.FieldText, store.FieldEnum, store.FieldRelation:
+			fields[name] = TypeString
+		case store.FieldInt:
+			fields[name] = TypeInt
+		case store.FieldFloat:
+			fields[name] = TypeFloat
+		case store.FieldBool:
+			fields[name] = TypeBool
+		case store.FieldTimestamp:
+			fields[name] = TypeTime
+		}
+	}
+	return fields
+}
+
+func newError(pos int, format string, args ...any) error {
+	return apierror.NewValidationError(
+		fmt.Sprintf("invalid filter at position %d: %s", pos, fmt.Sprintf(format, args...)),
+		nil,
+		map[string]any{"position": pos},
+	)
+}
+
+// ParseFilter compiles a filter into an expression. It returns nil for an empty filter.
+// Errors are validation errors that describe the problem and its position.
+func ParseFilter(input string, fields Fields) (store.FilterExpression, error) {
+	if strings.TrimSpace(input) == "" {
+		return nil, nil
+	}
+
+	tokens, err := lex(input)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &parser{tokens: tokens, fields: fields}
+	expr, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if tok := p.peek(); tok.kind != tokEOF {
+		return nil, newError(tok.pos, "unexpected %s", tok)
+	}
+
+	return expr, nil
+}
+
+// ParseSort parses a comma separated list of fields, prefixed with - for descending order
+func ParseSort(input string, fields Fields) ([]store.SortOption, error) {
+	if strings.TrimSpace(input) == "" {
+		return nil, nil
+	}
+
+	var sort []store.SortOption
+	for _, field := range strings.Split(input, ",") {
+		field = strings.TrimSpace(field)
+		direction := store.SortAsc
+		if strings.HasPrefix(field, "-") {
+			direction = store.SortDesc
+			field = field[1:]
+		} else if strings.HasPrefix(field, "+") {
+			field = field[1:]
+		}
+
+		if _, ok := fields[field]; !ok {
+			return nil, apierror.NewValidationError(fmt.Sprintf("invalid sort: cannot sort by %q", field), nil)
+		}
+		sort = append(sort, store.SortOption{Field: field, Direction: direction})
+	}
+
+	return sort, nil
+}
+
+type parser struct {
+	tokens     []token
+	pos        int
+	fields     Fields
+	depth      int
+	conditions int
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	tok := p.tokens[p.pos]
+	if tok.kind != tokEOF {
+		p.pos++
+	}
+	return tok
+}
+
+func (p *parser) expect(kind tokenKind, what string) (token, error) {
+	tok := p.next()
+	if tok.kind != kind {
+		return tok, newError(tok.pos, "expected %s, got %s", what, tok)
+	}
+	return tok, nil
+}
+
+func (p *parser) parseOr() (store.FilterExpression, error) {
+	return p.parseGroup(tokOr, p.parseAnd, store.NewOrGroup)
+}
+
+func (p *parser) parseAnd() (store.FilterExpression, error) {
+	return p.parseGroup(tokAnd, p.parsePrimary, store.NewAndGroup)
+}
+
+// parseGroup parses operands separated by the operator, a single operand is returned as is
+func (p *parser) parseGroup(op tokenKind, operand func() (store.FilterExpression, error), group func(...store.FilterExpression) store.FilterGroup) (store.FilterExpression, error) {
+	first, err := operand()
+	if err != nil {
+		return nil, err
+	}
+
+	exprs := []store.FilterExpression{first}
+	for p.peek().kind == op {
+		p.next()
+		expr, err := operand()
+		if err != nil {
+			return nil, err
+		}
+		exprs = append(exprs, expr)
+	}
+
+	if len(exprs) == 1 {
+		return first, nil
+	}
+	return group(exprs...), nil
+}
+
+func (p *parser) parsePrimary() (store.FilterExpression, error) {
+	tok := p.peek()
+	if tok.kind == tokLParen {
+		p.next()
+		if p.depth++; p.depth > maxDepth {
+			return nil, newError(tok.pos, "too many nested groups, at most %d are allowed", maxDepth)
+		}
+
+		expr, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if _, err := p.expect(tokRParen, "\")\""); err != nil {
+			return nil, err
+		}
+
+		p.depth--
+		return expr, nil
+	}
+
+	return p.parseCondition()
+}
+
+func (p *parser) parseCondition() (store.FilterExpression, error) {
+	fieldTok, err := p.expect(tokIdent, "a field name")
+	if err != nil {
+		return nil, err
+	}
+	fieldType, ok := p.fields[fieldTok.text]
+	if !ok {
+		return nil, newError(fieldTok.pos, "cannot filter by %q", fieldTok.text)
+	}
+	if p.conditions++; p.conditions > maxConditions {
+		return nil, newError(fieldTok.pos, "too many conditions, at most %d are allowed", maxConditions)
+	}
+
+	opTok := p.next()
+	switch {
+	case isKeyword(opTok, "in"):
+		return p.parseIn(fieldTok.text, fieldType, store.FilterOpIn)
+	case isKeyword(opTok, "not"):
+		if tok := p.next(); !isKeyword(tok, "in") {
+			return nil, newError(tok.pos, "expected \"in\" after \"not\", got %s", tok)
+		}
+		return p.parseIn(fieldTok.text, fieldType, store.FilterOpNotIn)
+	case opTok.kind != tokOp:
+		return nil, newError(opTok.pos, "expected an operator after %q, got %s", fieldTok.text, opTok)
+	}
+
+	valueTok := p.next()
+	if isKeyword(valueTok, "null") {
+		switch opTok.text {
+		case "=":
+			return store.NewCondition(fieldTok.text, store.FilterOpIsNull, nil), nil
+		case "!=":
+			return store.NewCondition(fieldTok.text, store.FilterOpIsNotNull, nil), nil
+		}
+		return nil, newError(valueTok.pos, "null can only be compared with = or !=")
+	}
+
+	value, err := coerce(valueTok, fieldTok.text, fieldType)
+	if err != nil {
+		return nil, err
+	}
+
+	switch opTok.text {
+	case "~":
+		s, ok := value.(string)
+		if !ok || (fieldType != TypeString && fieldType != TypeAny) {
+			return nil, newError(opTok.pos, "~ can only be used with text")
+		}
+		if !strings.ContainsAny(s, "%_") {
+			s = "%" + s + "%"
+		}
+		return store.NewCondition(fieldTok.text, store.FilterOpILike, s), nil
+	case ">", ">=", "<", "<=":
+		if _, ok := value.(bool); ok {
+			return nil, newError(opTok.pos, "%s cannot be used with a boolean", opTok.text)
+		}
+	}
+
+	return store.NewCondition(fieldTok.text, store.FilterOp(opTok.text), value), nil
+}
+
+// parseIn parses the value list of an in or not in condition
+func (p *parser) parseIn(field string, fieldType Type, op store.FilterOp) (store.FilterExpression, error) {
+	if _, err := p.expect(tokLParen, "\"(\""); err != nil {
+		return nil, err
+	}
+
+	var values []any
+	for {
+		value, err := coerce(p.next(), field, fieldType)
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+
+		tok := p.next()
+		if tok.kind == tokRParen {
+			break
+		}
+		if tok.kind != tokComma {
+			return nil, newError(tok.pos, "expected \",\" or \")\", got %s", tok)
+		}
+	}
+
+	return store.NewCondition(field, op, values), nil
+}
+
+func isKeyword(tok token, keyword string) bool {
+	return tok.kind == tokIdent && strings.EqualFold(tok.text, keyword)
+}
+
+// coerce converts a literal to the type of the field
+func coerce(tok token, field string, fieldType Type) (any, error) {
+	invalid := func() error {
+		return newError(tok.pos, "%s is not %s, as expected for %q", tok, fieldType, field)
+	}
+
+	switch tok.kind {
+	case tokString, tokNumber:
+	case tokIdent:
+		if isKeyword(tok, "true") || isKeyword(tok, "false") {
+			if fieldType != TypeAny && fieldType != TypeBool {
+				return nil, invalid()
+			}
+			return isKeyword(tok, "true"), nil
+		}
+		return nil, newError(tok.pos, "expected a value, got %s, quote text values", tok)
+	default:
+		return nil, newError(tok.pos, "expected a value, got %s", tok)
+	}
+
+	switch fieldType {
+	case TypeString:
+		return tok.text, nil
+	case TypeInt:
+		n, err := strconv.ParseInt(tok.text, 10, 64)
+		if err != nil {
+			return nil, invalid()
+		}
+		return n, nil
+	case TypeFloat:
+		f, err := strconv.ParseFloat(tok.text, 64)
+		if err != nil {
+			return nil, invalid()
+		}
+		return f, nil
+	case TypeBool:
+		b, err := strconv.ParseBool(tok.text)
+		if err != nil {
+			return nil, invalid()
+		}
+		return b, nil
+	case TypeTime:
+		if tok.kind != tokString {
+			return nil, invalid()
+		}
+		for _, layout := range []string{time.RFC3339Nano, time.DateOnly} {
+			if t, err := time.Parse(layout, tok.text); err == nil {
+				return t, nil
+			}
+		}
+		return nil, invalid()
+	}
+
+	if tok.kind == tokNumber {
+		if n, err := strconv.ParseInt(tok.text, 10, 64); err == nil {
+			return n, nil
+		}
+		f, err := strconv.ParseFloat(tok.text, 64)
+		if err != nil {
+			return nil, newError(tok.pos, "%s is not a valid number", tok)
+		}
+		return f, nil
+	}
+	return tok.text, nil
+}
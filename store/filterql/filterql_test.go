@@ -0,0 +1,182 @@
+package filterql
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/tuongaz/go-saas/pkg/apierror"
+	"github.com/tuongaz/go-saas/store"
+)
+
+var testFields = Fields{
+	"status":     TypeString,
+	"plan":       TypeString,
+	"seats":      TypeInt,
+	"price":      TypeFloat,
+	"active":     TypeBool,
+	"created_at": TypeTime,
+	"name":       TypeAny,
+}
+
+func TestParseFilter(t *testing.T) {
+	tests := []struct {
+		name   string
+		filter string
+		want   store.FilterExpression
+	}{
+		{
+			name:   "empty",
+			filter: "  ",
+			want:   nil,
+		},
+		{
+			name:   "single condition",
+			filter: "status='active'",
+			want:   store.NewCondition("status", store.FilterOpEqual, "active"),
+		},
+		{
+			name:   "and binds tighter than or",
+			filter: `status = "active" || plan='pro' && seats >= 5`,
+			want: store.NewOrGroup(
+				store.NewCondition("status", store.FilterOpEqual, "active"),
+				store.NewAndGroup(
+					store.NewCondition("plan", store.FilterOpEqual, "pro"),
+					store.NewCondition("seats", store.FilterOpGreaterEqual, int64(5)),
+				),
+			),
+		},
+		{
+			name:   "groups",
+			filter: "(status='active' || plan='pro') && created_at>'2025-01-01'",
+			want: store.NewAndGroup(
+				store.NewOrGroup(
+					store.NewCondition("status", store.FilterOpEqual, "active"),
+					store.NewCondition("plan", store.FilterOpEqual, "pro"),
+				),
+				store.NewCondition("created_at", store.FilterOpGreater, time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)),
+			),
+		},
+		{
+			name:   "type coercion",
+			filter: "seats='3' && price<9.5 && active=true && name=-2 && created_at<='2025-01-02T03:04:05Z'",
+			want: store.NewAndGroup(
+				store.NewCondition("seats", store.FilterOpEqual, int64(3)),
+				store.NewCondition("price", store.FilterOpLess, 9.5),
+				store.NewCondition("active", store.FilterOpEqual, true),
+				store.NewCondition("name", store.FilterOpEqual, int64(-2)),
+				store.NewCondition("created_at", store.FilterOpLessEqual, time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)),
+			),
+		},
+		{
+			name:   "null checks",
+			filter: "plan = null && status != NULL",
+			want: store.NewAndGroup(
+				store.NewCondition("plan", store.FilterOpIsNull, nil),
+				store.NewCondition("status", store.FilterOpIsNotNull, nil),
+			),
+		},
+		{
+			name:   "in lists",
+			filter: "status in ('active', 'trial') && seats not in (1,2)",
+			want: store.NewAndGroup(
+				store.NewCondition("status", store.FilterOpIn, []any{"active", "trial"}),
+				store.NewCondition("seats", store.FilterOpNotIn, []any{int64(1), int64(2)}),
+			),
+		},
+		{
+			name:   "contains",
+			filter: `name ~ 'acme' && plan ~ 'pro%' && status='it\'s'`,
+			want: store.NewAndGroup(
+				store.NewCondition("name", store.FilterOpILike, "%acme%"),
+				store.NewCondition("plan", store.FilterOpILike, "pro%"),
+				store.NewCondition("status", store.FilterOpEqual, "it's"),
+			),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseFilter(tt.filter, testFields)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestParseFilterErrors(t *testing.T) {
+	tests := []struct {
+		filter  string
+		message string
+	}{
+		{"secret='x'", `invalid filter at position 1: cannot filter by "secret"`},
+		{"status", "invalid filter at position 7: expected an operator after \"status\", got end of filter"},
+		{"status=active", `invalid filter at position 8: expected a value, got "active", quote text values`},
+		{"seats='many'", `invalid filter at position 7: 'many' is not an integer, as expected for "seats"`},
+		{"created_at>'yesterday'", `invalid filter at position 12: 'yesterday' is not a time, as expected for "created_at"`},
+		{"active>true", "invalid filter at position 7: > cannot be used with a boolean"},
+		{"seats~'1'", "invalid filter at position 6: ~ can only be used with text"},
+		{"plan>null", "invalid filter at position 6: null can only be compared with = or !="},
+		{"(status='a'", `invalid filter at position 12: expected ")", got end of filter`},
+		{"status='a' plan='b'", `invalid filter at position 12: unexpected "plan"`},
+		{"status='a", "invalid filter at position 8: unterminated string"},
+		{"status='a' & plan='b'", "invalid filter at position 12: unexpected character '&'"},
+		{"status in ('a' 'b')", `invalid filter at position 16: expected "," or ")", got 'b'`},
+		{"status not ('a')", `invalid filter at position 12: expected "in" after "not", got "("`},
+		{"((((((((((((status='a'))))))))))))", "invalid filter at position 11: too many nested groups, at most 10 are allowed"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.filter, func(t *testing.T) {
+			_, err := ParseFilter(tt.filter, testFields)
+
+			var apiErr *apierror.APIError
+			if assert.ErrorAs(t, err, &apiErr) {
+				assert.Equal(t, http.StatusBadRequest, apiErr.Code)
+				assert.Equal(t, tt.message, apiErr.Message)
+			}
+		})
+	}
+}
+
+func TestParseSort(t *testing.T) {
+	sort, err := ParseSort("-created_at, name,+seats", testFields)
+	assert.NoError(t, err)
+	assert.Equal(t, []store.SortOption{
+		{Field: "created_at", Direction: store.SortDesc},
+		{Field: "name", Direction: store.SortAsc},
+		{Field: "seats", Direction: store.SortAsc},
+	}, sort)
+
+	sort, err = ParseSort("", testFields)
+	assert.NoError(t, err)
+	assert.Nil(t, sort)
+
+	_, err = ParseSort("-secret", testFields)
+	assert.EqualError(t, err, `invalid sort: cannot sort by "secret"`)
+
+	_, err = ParseSort("name,", testFields)
+	assert.Error(t, err)
+}
+
+func TestSchemaFields(t *testing.T) {
+	schema := &store.Schema{
+		Table: "projects",
+		Fields: []store.Field{
+			{Name: "status", Type: store.FieldEnum, Values: []string{"active"}},
+			{Name: "seats", Type: store.FieldInt},
+			{Name: "settings", Type: store.FieldJSON},
+			{Name: "due_at", Type: store.FieldTimestamp},
+		},
+	}
+
+	assert.Equal(t, Fields{
+		"id":       TypeAny,
+		"status":   TypeString,
+		"seats":    TypeInt,
+		"settings": TypeAny,
+		"due_at":   TypeTime,
+	}, SchemaFields(schema, "id", "status", "seats", "settings", "due_at"))
+}
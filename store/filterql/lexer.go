@@ -0,0 +1,129 @@
+package filterql
+
+import (
+	"fmt"
+	"strings"
+)
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokIdent
+	tokString
+	tokNumber
+	tokOp
+	tokAnd
+	tokOr
+	tokLParen
+	tokRParen
+	tokComma
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	pos  int
+}
+
+func (t token) String() string {
+	switch t.kind {
+	case tokEOF:
+		return "end of filter"
+	case tokString:
+		return fmt.Sprintf("'%s'", t.text)
+	}
+	return fmt.Sprintf("%q", t.text)
+}
+
+func isIdentStart(c byte) bool {
+	return c == '_' || ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z')
+}
+
+func isIdentChar(c byte) bool {
+	return isIdentStart(c) || isDigit(c)
+}
+
+func isDigit(c byte) bool {
+	return '0' <= c && c <= '9'
+}
+
+// lex splits the input into tokens, positions are byte offsets starting at 1
+func lex(input string) ([]token, error) {
+	var tokens []token
+	i := 0
+	for i < len(input) {
+		c := input[i]
+		start := i
+
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+			continue
+		case c == '(':
+			tokens = append(tokens, token{kind: tokLParen, text: "(", pos: start + 1})
+			i++
+		case c == ')':
+			tokens = append(tokens, token{kind: tokRParen, text: ")", pos: start + 1})
+			i++
+		case c == ',':
+			tokens = append(tokens, token{kind: tokComma, text: ",", pos: start + 1})
+			i++
+		case strings.HasPrefix(input[i:], "&&"):
+			tokens = append(tokens, token{kind: tokAnd, text: "&&", pos: start + 1})
+			i += 2
+		case strings.HasPrefix(input[i:], "||"):
+			tokens = append(tokens, token{kind: tokOr, text: "||", pos: start + 1})
+			i += 2
+		case strings.HasPrefix(input[i:], "!="), strings.HasPrefix(input[i:], ">="), strings.HasPrefix(input[i:], "<="):
+			tokens = append(tokens, token{kind: tokOp, text: input[i : i+2], pos: start + 1})
+			i += 2
+		case c == '=' || c == '>' || c == '<' || c == '~':
+			tokens = append(tokens, token{kind: tokOp, text: string(c), pos: start + 1})
+			i++
+		case c == '\'' || c == '"':
+			text, end, err := lexString(input, i)
+			if err != nil {
+				return nil, err
+			}
+			tokens = append(tokens, token{kind: tokString, text: text, pos: start + 1})
+			i = end
+		case isDigit(c) || (c == '-' && i+1 < len(input) && isDigit(input[i+1])):
+			i++
+			for i < len(input) && (isDigit(input[i]) || input[i] == '.') {
+				i++
+			}
+			tokens = append(tokens, token{kind: tokNumber, text: input[start:i], pos: start + 1})
+		case isIdentStart(c):
+			for i < len(input) && isIdentChar(input[i]) {
+				i++
+			}
+			tokens = append(tokens, token{kind: tokIdent, text: input[start:i], pos: start + 1})
+		default:
+			return nil, newError(start+1, "unexpected character %q", c)
+		}
+	}
+
+	return append(tokens, token{kind: tokEOF, pos: len(input) + 1}), nil
+}
+
+// lexString reads a quoted string starting at input[start], a backslash escapes the next character
+func lexString(input string, start int) (string, int, error) {
+	quote := input[start]
+	var b strings.Builder
+	for i := start + 1; i < len(input); i++ {
+		switch input[i] {
+		case '\\':
+			if i+1 < len(input) {
+				i++
+				b.WriteByte(input[i])
+			}
+		case quote:
+			return b.String(), i + 1, nil
+		default:
+			b.WriteByte(input[i])
+		}
+	}
+
+	return "", 0, newError(start+1, "unterminated string")
+}
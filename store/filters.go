@@ -114,6 +114,7 @@ type SortOption struct {
 	Operator string
 	// Right is the optional right-hand side identifier used together with
 	// Operator, e.g. ORDER BY embedding <-> query_vector DESC
-	// If Operator is empty, Right is ignored.
+	// If Operator is empty, Right is ignored. To order by the distance to
+	// a query vector, use WithNearest which binds the vector as a parameter.
 	Right string
 }
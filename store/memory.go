@@ -0,0 +1,392 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"reflect"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+
+	"github.com/tuongaz/go-saas/store/types"
+)
+
+var _ Interface = (*MemoryStore)(nil)
+
+// ErrMemoryUnsupported is returned by the memory store for the features that need a database,
+// i.e. raw SQL queries, full-text search and vector search
+var ErrMemoryUnsupported = errors.New("not supported by the memory store")
+
+// MemoryStore is an in-memory implementation of Interface, for tests that run without a database.
+// Its collections honour the filters, sorting, pagination, soft delete, tenant scopes, schemas,
+// auto fields, relations and event handlers like the collections of a Store.
+// Transactions see their own writes, which are applied to the store on commit and dropped on rollback.
+//
+// Tables need no migrations: rows take any columns, and a missing column reads as NULL.
+// The id column is unique, records inserted without an id get a generated one. The other
+// constraints and column defaults of the tables are declared with RegisterTable, the unique
+// fields and defaults of registered schemas apply too.
+// DB returns nil, and raw SQL, full-text and vector search return ErrMemoryUnsupported.
+type MemoryStore struct {
+	tableRegistry
+	eventDispatcher
+
+	// mu guards the rows, the definitions and the transaction writes
+	mu          sync.Mutex
+	tables      memoryTables
+	definitions map[string]MemoryTable
+	seq         int64
+}
+
+// MemoryTable declares the constraints and column defaults a database schema would give a table
+type MemoryTable struct {
+	Name string
+
+	// Unique are the unique constraints of the table, each made of one or more columns.
+	// Rows with a NULL in the columns of a constraint do not conflict, like in Postgres.
+	Unique [][]string
+
+	// Defaults are set on insert for the columns missing from the records, e.g. a version of 1
+	Defaults types.Record
+}
+
+// memoryRow is a row of a memory table. The values are stored as the store reads them from
+// the database: int64, float64, bool, string, time.Time in UTC, nil, or decoded JSON.
+type memoryRow struct {
+	seq    int64
+	values types.Record
+}
+
+// memoryTables are the rows of the tables, by table and id
+type memoryTables map[string]map[string]*memoryRow
+
+// NewMemory creates an empty memory store
+func NewMemory() *MemoryStore {
+	return &MemoryStore{tables: memoryTables{}}
+}
+
+// RegisterTable declares the unique constraints and column defaults of a table.
+// It panics when a table or column name is invalid.
+func (s *MemoryStore) RegisterTable(table MemoryTable) {
+	if !ValidTableName(table.Name) {
+		panic(fmt.Sprintf("invalid table name: %s", table.Name))
+	}
+	for _, columns := range table.Unique {
+		if len(columns) == 0 {
+			panic(fmt.Sprintf("empty unique constraint of %s", table.Name))
+		}
+		for _, column := range columns {
+			if !ValidIdentifierName(column) {
+				panic(fmt.Sprintf("invalid unique column of %s: %s", table.Name, column))
+			}
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.definitions == nil {
+		s.definitions = map[string]MemoryTable{}
+	}
+	s.definitions[table.Name] = table
+}
+
+func (s *MemoryStore) Collection(table string) CollectionInterface {
+	if !ValidTableName(table) {
+		panic(fmt.Sprintf("invalid table name: %s", table))
+	}
+
+	return withTxEvents(s.collection(table, s, nil, ""), s, s.WithTx)
+}
+
+func (s *MemoryStore) tenantCollection(table, organisationID string) CollectionInterface {
+	return withTxEvents(s.collection(table, s, nil, organisationID), s, s.ForOrganisation(organisationID).WithTx)
+}
+
+// collection returns a collection of the table, reading the writes of tx when set.
+// The events are dispatched to events, the store or the transaction.
+func (s *MemoryStore) collection(table string, events RecordEvents, tx *memoryTx, organisationID string) CollectionInterface {
+	c := &collection{
+		table:          table,
+		store:          events,
+		softDelete:     s.SoftDeleteEnabled(table),
+		schema:         s.Schema(table),
+		autoFields:     s.AutoFields(table),
+		relations:      s,
+		organisationID: organisationID,
+	}
+	if index, ok := s.SearchIndex(table); ok {
+		c.search = &index
+	}
+
+	m := &memoryCollection{collection: c, memory: s, tx: tx}
+	c.loadRelated = m.loadRelated
+	return m
+}
+
+// ForOrganisation returns a view of the store whose collections are scoped to the organisation
+func (s *MemoryStore) ForOrganisation(organisationID string) *TenantStore {
+	if organisationID == "" {
+		panic("tenant store requires an organisation id")
+	}
+
+	return &TenantStore{
+		store:          s,
+		organisationID: organisationID,
+	}
+}
+
+// Exec returns ErrMemoryUnsupported, the memory store does not run SQL
+func (s *MemoryStore) Exec(ctx context.Context, query string, args ...any) error {
+	return fmt.Errorf("exec query: %w", ErrMemoryUnsupported)
+}
+
+// DB returns nil, the memory store has no database
+func (s *MemoryStore) DB() *sqlx.DB {
+	return nil
+}
+
+// Tx begins a transaction. Its writes are only visible to its collections until it is committed.
+func (s *MemoryStore) Tx(ctx context.Context) (*StoreTx, error) {
+	return &StoreTx{
+		tx:    &memoryTx{store: s, writes: memoryTables{}},
+		store: s,
+		ctx:   ctx,
+	}, nil
+}
+
+func (s *MemoryStore) Close() error {
+	return nil
+}
+
+// nextSeq returns the insertion sequence of a new row, with s.mu held
+func (s *MemoryStore) nextSeq() int64 {
+	s.seq++
+	return s.seq
+}
+
+// uniqueConstraints returns the unique constraints of the table other than the id, with s.mu held.
+// The constraints of the unique schema fields are returned with their field.
+func (s *MemoryStore) uniqueConstraints(table string, schema *Schema) (constraints [][]string, fields []string) {
+	constraints = append(constraints, s.definitions[table].Unique...)
+	fields = make([]string, len(constraints))
+
+	if schema != nil {
+		for _, f := range schema.Fields {
+			if f.Unique {
+				constraints = append(constraints, []string{f.Name})
+				fields = append(fields, f.Name)
+			}
+		}
+	}
+
+	return constraints, fields
+}
+
+// memoryTx is the transaction of a memory store, it holds the rows written in the transaction.
+// The SQL methods return ErrMemoryUnsupported.
+type memoryTx struct {
+	store *MemoryStore
+
+	// writes are the rows written in the transaction, nil rows were deleted
+	writes memoryTables
+	done   bool
+}
+
+var _ dbxInterface = (*memoryTx)(nil)
+
+func (tx *memoryTx) GetContext(ctx context.Context, dest any, query string, args ...any) error {
+	return ErrMemoryUnsupported
+}
+
+func (tx *memoryTx) SelectContext(ctx context.Context, dest any, query string, args ...any) error {
+	return ErrMemoryUnsupported
+}
+
+func (tx *memoryTx) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	return nil, ErrMemoryUnsupported
+}
+
+func (tx *memoryTx) QueryxContext(ctx context.Context, query string, args ...any) (*sqlx.Rows, error) {
+	return nil, ErrMemoryUnsupported
+}
+
+// Commit applies the writes of the transaction to the store
+func (tx *memoryTx) Commit() error {
+	tx.store.mu.Lock()
+	defer tx.store.mu.Unlock()
+
+	if tx.done {
+		return sql.ErrTxDone
+	}
+	tx.done = true
+
+	if err := tx.checkConflicts(); err != nil {
+		tx.writes = nil
+		return err
+	}
+
+	for table, rows := range tx.writes {
+		if tx.store.tables[table] == nil {
+			tx.store.tables[table] = map[string]*memoryRow{}
+		}
+		for key, row := range rows {
+			if row == nil {
+				delete(tx.store.tables[table], key)
+				continue
+			}
+			tx.store.tables[table][key] = row
+		}
+	}
+	tx.writes = nil
+
+	return nil
+}
+
+// checkConflicts checks the rows written in the transaction against the rows committed since
+// it began, with the store locked. A row inserted with the key of a committed row, or with
+// the unique values of another row, fails the commit like it would fail in the database.
+func (tx *memoryTx) checkConflicts() error {
+	for table, writes := range tx.writes {
+		committed := tx.store.tables[table]
+		merged := maps.Clone(committed)
+		if merged == nil {
+			merged = map[string]*memoryRow{}
+		}
+		for key, row := range writes {
+			if row == nil {
+				delete(merged, key)
+				continue
+			}
+			merged[key] = row
+		}
+		rows := slices.Collect(maps.Values(merged))
+
+		constraints, _ := tx.store.uniqueConstraints(table, tx.store.Schema(table))
+		for key, row := range writes {
+			if row == nil {
+				continue
+			}
+			// the rows read and written by the transaction keep their sequence
+			if existing := committed[key]; existing != nil && existing.seq != row.seq {
+				return NewDuplicateKeyErr(fmt.Errorf("duplicate key value violates the primary key of %s: %s", table, key))
+			}
+			for _, columns := range constraints {
+				if conflicts(rows, key, columns, row.values) {
+					return NewDuplicateKeyErr(fmt.Errorf("duplicate key value violates unique constraint: %s", qualifiedColumns(table, columns)))
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// Rollback drops the writes of the transaction
+func (tx *memoryTx) Rollback() error {
+	tx.store.mu.Lock()
+	defer tx.store.mu.Unlock()
+
+	if tx.done {
+		return sql.ErrTxDone
+	}
+	tx.done = true
+	tx.writes = nil
+
+	return nil
+}
+
+// memoryKey is the key of a row, from its id
+func memoryKey(id any) string {
+	return fmt.Sprint(id)
+}
+
+// memoryValues converts the values of a record into the values stored by the memory store
+func memoryValues(record types.Record) (types.Record, error) {
+	values := make(types.Record, len(record))
+	for key, value := range record {
+		v, err := memoryValue(value)
+		if err != nil {
+			return nil, fmt.Errorf("failed to convert value of key %s: %w", key, err)
+		}
+		values[key] = v
+	}
+	return values, nil
+}
+
+// memoryValue converts a value like the database would store it. Pointers are dereferenced,
+// numbers become int64 or float64, times are in UTC and the other values are stored as JSON,
+// e.g. a json.RawMessage is stored decoded.
+func memoryValue(value any) (any, error) {
+	switch v := value.(type) {
+	case nil:
+		return nil, nil
+	case string, bool, int64, float64:
+		return v, nil
+	case int:
+		return int64(v), nil
+	case time.Time:
+		return v.UTC(), nil
+	}
+
+	rv := reflect.ValueOf(value)
+	switch rv.Kind() {
+	case reflect.Pointer:
+		if rv.IsNil() {
+			return nil, nil
+		}
+		return memoryValue(rv.Elem().Interface())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return rv.Int(), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return int64(rv.Uint()), nil
+	case reflect.Float32, reflect.Float64:
+		return rv.Float(), nil
+	case reflect.String:
+		return rv.String(), nil
+	case reflect.Bool:
+		return rv.Bool(), nil
+	}
+
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	var decoded any
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return nil, err
+	}
+	return decoded, nil
+}
+
+// cloneValue copies the maps and slices of decoded JSON, so that callers cannot modify the stored rows
+func cloneValue(value any) any {
+	switch v := value.(type) {
+	case map[string]any:
+		clone := make(map[string]any, len(v))
+		for key, item := range v {
+			clone[key] = cloneValue(item)
+		}
+		return clone
+	case []any:
+		clone := make([]any, len(v))
+		for i, item := range v {
+			clone[i] = cloneValue(item)
+		}
+		return clone
+	}
+	return value
+}
+
+func cloneRecord(record types.Record) types.Record {
+	clone := make(types.Record, len(record))
+	for key, value := range record {
+		clone[key] = cloneValue(value)
+	}
+	return clone
+}
@@ -0,0 +1,1023 @@
+package store
+
+import (
+	"cmp"
+	"context"
+	"database/sql"
+	"fmt"
+	"maps"
+	"math"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/tuongaz/go-saas/pkg/timer"
+	"github.com/tuongaz/go-saas/pkg/uid"
+	"github.com/tuongaz/go-saas/store/types"
+)
+
+var _ CollectionInterface = (*memoryCollection)(nil)
+
+// memoryCollection is a collection of a memory store. It shares the configuration and the
+// write helpers of the SQL collection, and reads and writes the rows of the store instead.
+type memoryCollection struct {
+	*collection
+	memory *MemoryStore
+
+	// tx is the transaction of the collection, if any
+	tx *memoryTx
+}
+
+// lock locks the rows of the store, it fails once the transaction of the collection has ended
+func (m *memoryCollection) lock() error {
+	m.memory.mu.Lock()
+	if m.tx != nil && m.tx.done {
+		m.memory.mu.Unlock()
+		return sql.ErrTxDone
+	}
+	return nil
+}
+
+func (m *memoryCollection) unlock() {
+	m.memory.mu.Unlock()
+}
+
+// rows returns the rows of the table in insertion order, with the writes of the transaction
+func (m *memoryCollection) rows(table string) []*memoryRow {
+	rows := maps.Clone(m.memory.tables[table])
+	if rows == nil {
+		rows = map[string]*memoryRow{}
+	}
+	if m.tx != nil {
+		for key, row := range m.tx.writes[table] {
+			if row == nil {
+				delete(rows, key)
+				continue
+			}
+			rows[key] = row
+		}
+	}
+
+	return slices.SortedFunc(maps.Values(rows), func(a, b *memoryRow) int {
+		return cmp.Compare(a.seq, b.seq)
+	})
+}
+
+// row returns the row of the table with the key, or nil
+func (m *memoryCollection) row(table, key string) *memoryRow {
+	if m.tx != nil {
+		if row, ok := m.tx.writes[table][key]; ok {
+			return row
+		}
+	}
+	return m.memory.tables[table][key]
+}
+
+// put writes the row of the collection table with the key, a nil row deletes it
+func (m *memoryCollection) put(key string, row *memoryRow) {
+	tables := m.memory.tables
+	if m.tx != nil {
+		tables = m.tx.writes
+	}
+
+	if tables[m.table] == nil {
+		tables[m.table] = map[string]*memoryRow{}
+	}
+	if row == nil && m.tx == nil {
+		delete(tables[m.table], key)
+		return
+	}
+	tables[m.table][key] = row
+}
+
+// snapshot returns the rows written by put, so that a failed statement can restore them
+func (m *memoryCollection) snapshot() map[string]*memoryRow {
+	if m.tx != nil {
+		return maps.Clone(m.tx.writes[m.table])
+	}
+	return maps.Clone(m.memory.tables[m.table])
+}
+
+func (m *memoryCollection) restore(snapshot map[string]*memoryRow) {
+	if m.tx != nil {
+		m.tx.writes[m.table] = snapshot
+		return
+	}
+	m.memory.tables[m.table] = snapshot
+}
+
+// inScope tells whether the row is visible to the collection: it belongs to the organisation
+// of a tenant scoped collection, and is in the deleted scope of a soft delete collection
+func (m *memoryCollection) inScope(values types.Record, deleted DeletedScope) bool {
+	if m.organisationID != "" && fmt.Sprint(values[TenantColumn]) != m.organisationID {
+		return false
+	}
+	if !m.softDelete {
+		return true
+	}
+
+	switch deleted {
+	case DeletedScopeExclude:
+		return values[deletedAtColumn] == nil
+	case DeletedScopeOnly:
+		return values[deletedAtColumn] != nil
+	}
+	return true
+}
+
+// selectRecords returns copies of the values of the rows matching the condition, in insertion order
+func (m *memoryCollection) selectRecords(match func(types.Record) bool) ([]types.Record, error) {
+	if err := m.lock(); err != nil {
+		return nil, err
+	}
+	defer m.unlock()
+
+	var records []types.Record
+	for _, row := range m.rows(m.table) {
+		if match(row.values) {
+			records = append(records, cloneRecord(row.values))
+		}
+	}
+	return records, nil
+}
+
+// scopedMatch matches the rows of the filter, or of the advanced filter when set, in the scopes of the collection
+func (m *memoryCollection) scopedMatch(filter Filter, expression FilterExpression, deleted DeletedScope) func(types.Record) bool {
+	var match func(types.Record) bool
+	if expression != nil {
+		match = matchExpression(expression)
+	} else {
+		match = matchFilter(filter)
+	}
+
+	return func(values types.Record) bool {
+		return m.inScope(values, deleted) && match(values)
+	}
+}
+
+// insert stores a new row, with the store locked. The id is generated when missing.
+func (m *memoryCollection) insert(record types.Record) (types.Record, error) {
+	values, err := memoryValues(record)
+	if err != nil {
+		return nil, fmt.Errorf("prepare record for database insertion: %w", err)
+	}
+
+	for key, value := range m.memory.definitions[m.table].Defaults {
+		if _, ok := values[key]; ok {
+			continue
+		}
+		if values[key], err = memoryValue(value); err != nil {
+			return nil, fmt.Errorf("default of %s: %w", key, err)
+		}
+	}
+	if values[idColumn] == nil {
+		values[idColumn] = uid.ID()
+	}
+
+	if err := m.write("", m.memory.nextSeq(), values); err != nil {
+		return nil, err
+	}
+	return values, nil
+}
+
+// write stores the values of a row after checking the unique constraints, with the store locked.
+// oldKey is the key of the updated row, it is empty for a new row.
+func (m *memoryCollection) write(oldKey string, seq int64, values types.Record) error {
+	key := memoryKey(values[idColumn])
+	if key != oldKey && m.row(m.table, key) != nil {
+		return NewDuplicateKeyErr(fmt.Errorf("duplicate key value violates the primary key of %s: %s", m.table, key))
+	}
+
+	constraints, fields := m.memory.uniqueConstraints(m.table, m.schema)
+	for i, columns := range constraints {
+		if conflicts(m.rows(m.table), oldKey, columns, values) {
+			if fields[i] != "" {
+				return NewValidationErr(m.schema.Table, map[string]string{fields[i]: "must be unique"})
+			}
+			return NewDuplicateKeyErr(fmt.Errorf("duplicate key value violates unique constraint: %s", qualifiedColumns(m.table, columns)))
+		}
+	}
+
+	if oldKey != "" && oldKey != key {
+		m.put(oldKey, nil)
+	}
+	m.put(key, &memoryRow{seq: seq, values: values})
+
+	return nil
+}
+
+// qualifiedColumns lists the columns prefixed with their table, like SQLite reports the
+// columns of a violated constraint
+func qualifiedColumns(table string, columns []string) string {
+	qualified := make([]string, len(columns))
+	for i, column := range columns {
+		qualified[i] = table + "." + column
+	}
+	return strings.Join(qualified, ", ")
+}
+
+// conflicts tells whether another of the rows has the same values in the columns. NULLs never conflict.
+func conflicts(rows []*memoryRow, oldKey string, columns []string, values types.Record) bool {
+	for _, column := range columns {
+		if values[column] == nil {
+			return false
+		}
+	}
+
+	for _, row := range rows {
+		if memoryKey(row.values[idColumn]) == oldKey {
+			continue
+		}
+		if !slices.ContainsFunc(columns, func(column string) bool {
+			return !equalValues(row.values[column], values[column])
+		}) {
+			return true
+		}
+	}
+	return false
+}
+
+// updatedValues returns a copy of the values with the columns of the record overwritten
+func updatedValues(values types.Record, record types.Record) (types.Record, error) {
+	changes, err := memoryValues(record)
+	if err != nil {
+		return nil, fmt.Errorf("failed to prepare record for database update: %w", err)
+	}
+
+	updated := cloneRecord(values)
+	maps.Copy(updated, changes)
+	return updated, nil
+}
+
+// memoryRecord returns the record of the values, normalised like the records read from the database
+func memoryRecord(values types.Record) types.Record {
+	record := cloneRecord(values)
+	record.Normalise()
+	return record
+}
+
+func (m *memoryCollection) CreateRecord(ctx context.Context, record types.Record) (*types.Record, error) {
+	if err := m.stampTenant(record); err != nil {
+		return nil, err
+	}
+	m.setCreateFields(record, timer.Now())
+
+	if err := m.store.OnBeforeRecordCreated(ctx, m.table, record); err != nil {
+		return nil, fmt.Errorf("before create event handler error: %w", err)
+	}
+
+	if err := m.validateCreate(record); err != nil {
+		return nil, err
+	}
+
+	if err := m.lock(); err != nil {
+		return nil, err
+	}
+	values, err := m.insert(record)
+	m.unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	created := memoryRecord(values)
+	if err := m.store.OnAfterRecordCreated(ctx, m.table, created); err != nil {
+		return nil, fmt.Errorf("after create event handler error: %w", err)
+	}
+
+	return &created, nil
+}
+
+// CreateRecords creates the records, either all of them or none
+func (m *memoryCollection) CreateRecords(ctx context.Context, records []types.Record) ([]types.Record, error) {
+	if len(records) == 0 {
+		return []types.Record{}, nil
+	}
+
+	now := timer.Now()
+	for _, record := range records {
+		if err := m.stampTenant(record); err != nil {
+			return nil, err
+		}
+		m.setCreateFields(record, now)
+		if err := m.store.OnBeforeRecordCreated(ctx, m.table, record); err != nil {
+			return nil, fmt.Errorf("before create event handler error: %w", err)
+		}
+	}
+
+	for i, record := range records {
+		if err := m.validateCreate(record); err != nil {
+			return nil, fmt.Errorf("record %d: %w", i, err)
+		}
+	}
+
+	if err := m.lock(); err != nil {
+		return nil, err
+	}
+	snapshot := m.snapshot()
+	created := make([]types.Record, 0, len(records))
+	for _, record := range records {
+		values, err := m.insert(record)
+		if err != nil {
+			m.restore(snapshot)
+			m.unlock()
+			return nil, err
+		}
+		created = append(created, memoryRecord(values))
+	}
+	m.unlock()
+
+	for _, record := range created {
+		if err := m.store.OnAfterRecordCreated(ctx, m.table, record); err != nil {
+			return nil, fmt.Errorf("after create event handler error: %w", err)
+		}
+	}
+
+	return created, nil
+}
+
+func (m *memoryCollection) Upsert(ctx context.Context, record types.Record, conflictColumns []string, updateColumns []string) (*types.Record, error) {
+	if len(conflictColumns) == 0 {
+		return nil, fmt.Errorf("upsert requires at least one conflict column")
+	}
+
+	if err := m.stampTenant(record); err != nil {
+		return nil, err
+	}
+	autoSet := m.setCreateFields(record, timer.Now())
+
+	conflictFilter, updateColumns, err := m.upsertColumns(record, conflictColumns, updateColumns, autoSet)
+	if err != nil {
+		return nil, err
+	}
+
+	// Look up the existing row so that update events receive the old record
+	oldRecord, err := m.FindOne(ctx, conflictFilter, WithDeleted())
+	if err != nil && !IsNotFoundError(err) {
+		return nil, fmt.Errorf("get existing record: %w", err)
+	}
+
+	if oldRecord != nil {
+		if err := m.store.OnBeforeRecordUpdated(ctx, m.table, record, *oldRecord); err != nil {
+			return nil, fmt.Errorf("before update event handler error: %w", err)
+		}
+	} else {
+		if err := m.store.OnBeforeRecordCreated(ctx, m.table, record); err != nil {
+			return nil, fmt.Errorf("before create event handler error: %w", err)
+		}
+	}
+
+	// The conflicting row is updated, so the record is validated as an update, see collection.Upsert
+	validate := m.validateCreate
+	if oldRecord != nil {
+		validate = m.validateUpdate
+	}
+	if err := validate(record); err != nil {
+		return nil, err
+	}
+
+	if err := m.lock(); err != nil {
+		return nil, err
+	}
+	values, inserted, err := m.upsert(record, conflictColumns, updateColumns)
+	m.unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	upserted := memoryRecord(values)
+	if inserted {
+		if err := m.store.OnAfterRecordCreated(ctx, m.table, upserted); err != nil {
+			return nil, fmt.Errorf("after create event handler error: %w", err)
+		}
+		return &upserted, nil
+	}
+
+	if oldRecord == nil {
+		oldRecord = &types.Record{}
+	}
+	if err := m.store.OnAfterRecordUpdated(ctx, m.table, upserted, *oldRecord); err != nil {
+		return nil, fmt.Errorf("after update event handler error: %w", err)
+	}
+
+	return &upserted, nil
+}
+
+// upsert inserts the record or updates the columns of the row it conflicts with, with the store locked
+func (m *memoryCollection) upsert(record types.Record, conflictColumns, updateColumns []string) (types.Record, bool, error) {
+	conflict := types.Record{}
+	for _, column := range conflictColumns {
+		conflict[column] = record[column]
+	}
+	conflict, err := memoryValues(conflict)
+	if err != nil {
+		return nil, false, fmt.Errorf("prepare record for database upsert: %w", err)
+	}
+
+	var existing *memoryRow
+	if !slices.ContainsFunc(conflictColumns, func(column string) bool { return conflict[column] == nil }) {
+		for _, row := range m.rows(m.table) {
+			if matchFilter(Filter(conflict))(row.values) {
+				existing = row
+				break
+			}
+		}
+	}
+
+	if existing == nil {
+		values, err := m.insert(record)
+		return values, true, err
+	}
+
+	// Never update a conflicting row of another organisation
+	if m.organisationID != "" && fmt.Sprint(existing.values[TenantColumn]) != m.organisationID {
+		return nil, false, NewTenantErr(fmt.Errorf("%s record conflicts with a record of another organisation", m.table))
+	}
+
+	// Like EXCLUDED, the columns missing from the record take their default
+	changes := types.Record{}
+	for _, column := range updateColumns {
+		value, ok := record[column]
+		if !ok {
+			value = m.memory.definitions[m.table].Defaults[column]
+		}
+		changes[column] = value
+	}
+
+	values, err := updatedValues(existing.values, changes)
+	if err != nil {
+		return nil, false, err
+	}
+	if err := m.write(memoryKey(existing.values[idColumn]), existing.seq, values); err != nil {
+		return nil, false, err
+	}
+
+	return values, false, nil
+}
+
+func (m *memoryCollection) GetRecord(ctx context.Context, id any, opts ...FindOption) (*types.Record, error) {
+	options := findOptions(opts)
+
+	if err := m.lock(); err != nil {
+		return nil, err
+	}
+	row := m.row(m.table, memoryKey(id))
+	if row == nil || !m.inScope(row.values, options.Deleted) {
+		m.unlock()
+		return nil, sql.ErrNoRows
+	}
+	rec := memoryRecord(row.values)
+	m.unlock()
+
+	if err := m.expand(ctx, []types.Record{rec}, options.Expand); err != nil {
+		return nil, err
+	}
+
+	return &rec, nil
+}
+
+func (m *memoryCollection) UpdateRecord(ctx context.Context, id any, record types.Record, opts ...UpdateOption) (*types.Record, error) {
+	options := &UpdateOptions{}
+	for _, opt := range opts {
+		opt(options)
+	}
+
+	if err := m.checkTenant(record); err != nil {
+		return nil, err
+	}
+	m.setUpdateFields(record, timer.Now())
+
+	oldRecord, err := m.GetRecord(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("get old record: %w", err)
+	}
+
+	if err := checkPreconditions(*oldRecord, options); err != nil {
+		return nil, err
+	}
+
+	if err := m.store.OnBeforeRecordUpdated(ctx, m.table, record, *oldRecord); err != nil {
+		return nil, fmt.Errorf("before update event handler error: %w", err)
+	}
+
+	if err := m.validateUpdate(record); err != nil {
+		return nil, err
+	}
+
+	if err := m.lock(); err != nil {
+		return nil, err
+	}
+	values, err := m.update(id, record, options)
+	m.unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	updated := memoryRecord(values)
+	if err := m.store.OnAfterRecordUpdated(ctx, m.table, updated, *oldRecord); err != nil {
+		return nil, fmt.Errorf("after update event handler error: %w", err)
+	}
+
+	return &updated, nil
+}
+
+// update updates the row with the id, with the store locked.
+// The preconditions are checked again in case the row changed since it was read.
+func (m *memoryCollection) update(id any, record types.Record, options *UpdateOptions) (types.Record, error) {
+	key := memoryKey(id)
+	row := m.row(m.table, key)
+	checked := options.Version != nil || options.UnmodifiedSince != nil
+	if row == nil || !m.inScope(row.values, DeletedScopeInclude) ||
+		(checked && checkPreconditions(memoryRecord(row.values), options) != nil) {
+		if checked {
+			return nil, NewConflictErr(fmt.Errorf("record %v of %s was modified concurrently", id, m.table))
+		}
+		return nil, NewNotFoundErr(fmt.Errorf("record not found"))
+	}
+
+	values, err := updatedValues(row.values, record)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := record[versionColumn]; options.IncrementVersion && !ok {
+		values[versionColumn] = row.values.Int64(versionColumn) + 1
+	}
+
+	if err := m.write(key, row.seq, values); err != nil {
+		return nil, err
+	}
+	return values, nil
+}
+
+// Update updates the rows whose columns equal the values of the key value arguments
+func (m *memoryCollection) Update(ctx context.Context, record types.Record, args ...any) (int64, error) {
+	if err := m.checkTenant(record); err != nil {
+		return 0, err
+	}
+	m.setUpdateFields(record, timer.Now())
+
+	if err := m.validateUpdate(record); err != nil {
+		return 0, err
+	}
+
+	if len(args)%2 != 0 {
+		return 0, fmt.Errorf("invalid number of arguments: must be key-value pairs")
+	}
+
+	conditions := make([]FilterExpression, 0, len(args)/2)
+	for i := 0; i < len(args); i += 2 {
+		key, ok := args[i].(string)
+		if !ok {
+			return 0, fmt.Errorf("argument %d must be a string (column name)", i)
+		}
+		conditions = append(conditions, NewCondition(key, FilterOpEqual, args[i+1]))
+	}
+	match := m.scopedMatch(nil, NewAndGroup(conditions...), DeletedScopeInclude)
+
+	if err := m.lock(); err != nil {
+		return 0, err
+	}
+	defer m.unlock()
+
+	snapshot := m.snapshot()
+	var updated int64
+	for _, row := range m.rows(m.table) {
+		if !match(row.values) {
+			continue
+		}
+
+		values, err := updatedValues(row.values, record)
+		if err == nil {
+			err = m.write(memoryKey(row.values[idColumn]), row.seq, values)
+		}
+		if err != nil {
+			m.restore(snapshot)
+			return 0, err
+		}
+		updated++
+	}
+
+	return updated, nil
+}
+
+func (m *memoryCollection) DeleteRecord(ctx context.Context, id any) error {
+	record, err := m.GetRecord(ctx, id)
+	if err != nil {
+		return fmt.Errorf("get record before deletion: %w", err)
+	}
+
+	if err := m.store.OnBeforeRecordDeleted(ctx, m.table, *record); err != nil {
+		return fmt.Errorf("before delete event handler error: %w", err)
+	}
+
+	if err := m.lock(); err != nil {
+		return err
+	}
+	key := memoryKey(id)
+	row := m.row(m.table, key)
+	if m.softDelete {
+		if row == nil || !m.inScope(row.values, DeletedScopeExclude) {
+			m.unlock()
+			return NewNotFoundErr(fmt.Errorf("record not found"))
+		}
+
+		values := cloneRecord(row.values)
+		values[deletedAtColumn] = timer.Now().UTC()
+		if err := m.write(key, row.seq, values); err != nil {
+			m.unlock()
+			return err
+		}
+		*record = memoryRecord(values)
+	} else if row != nil && m.inScope(row.values, DeletedScopeInclude) {
+		m.put(key, nil)
+	}
+	m.unlock()
+
+	if err := m.store.OnAfterRecordDeleted(ctx, m.table, *record); err != nil {
+		return fmt.Errorf("after delete event handler error: %w", err)
+	}
+
+	return nil
+}
+
+func (m *memoryCollection) DeleteRecords(ctx context.Context, filter Filter) error {
+	match := m.scopedMatch(filter, nil, DeletedScopeInclude)
+	if m.softDelete {
+		match = m.scopedMatch(filter, nil, DeletedScopeExclude)
+	}
+
+	if err := m.lock(); err != nil {
+		return err
+	}
+	defer m.unlock()
+
+	now := timer.Now().UTC()
+	for _, row := range m.rows(m.table) {
+		if !match(row.values) {
+			continue
+		}
+
+		key := memoryKey(row.values[idColumn])
+		if !m.softDelete {
+			m.put(key, nil)
+			continue
+		}
+		values := cloneRecord(row.values)
+		values[deletedAtColumn] = now
+		m.put(key, &memoryRow{seq: row.seq, values: values})
+	}
+
+	return nil
+}
+
+func (m *memoryCollection) Restore(ctx context.Context, id any) (*types.Record, error) {
+	if err := m.requireSoftDelete(); err != nil {
+		return nil, err
+	}
+
+	record, err := m.GetRecord(ctx, id, OnlyDeleted())
+	if err != nil {
+		return nil, fmt.Errorf("get deleted record: %w", err)
+	}
+
+	if err := m.store.OnBeforeRecordRestored(ctx, m.table, *record); err != nil {
+		return nil, fmt.Errorf("before restore event handler error: %w", err)
+	}
+
+	if err := m.lock(); err != nil {
+		return nil, err
+	}
+	key := memoryKey(id)
+	row := m.row(m.table, key)
+	if row == nil || !m.inScope(row.values, DeletedScopeOnly) {
+		m.unlock()
+		return nil, NewNotFoundErr(fmt.Errorf("record not found"))
+	}
+	values := cloneRecord(row.values)
+	values[deletedAtColumn] = nil
+	m.put(key, &memoryRow{seq: row.seq, values: values})
+	m.unlock()
+
+	restored := memoryRecord(values)
+	if err := m.store.OnAfterRecordRestored(ctx, m.table, restored); err != nil {
+		return nil, fmt.Errorf("after restore event handler error: %w", err)
+	}
+
+	return &restored, nil
+}
+
+func (m *memoryCollection) PurgeDeletedBefore(ctx context.Context, t time.Time) (int64, error) {
+	if err := m.requireSoftDelete(); err != nil {
+		return 0, err
+	}
+
+	deletedBefore := func(values types.Record) bool {
+		c, ok := compareValues(values[deletedAtColumn], t.UTC())
+		return m.inScope(values, DeletedScopeOnly) && ok && c < 0
+	}
+
+	records, err := m.selectRecords(deletedBefore)
+	if err != nil {
+		return 0, fmt.Errorf("find deleted records: %w", err)
+	}
+
+	if len(records) == 0 {
+		return 0, nil
+	}
+
+	for i, record := range records {
+		records[i] = memoryRecord(record)
+		if err := m.store.OnBeforeRecordPurged(ctx, m.table, records[i]); err != nil {
+			return 0, fmt.Errorf("before purge event handler error: %w", err)
+		}
+	}
+
+	// Only purge the records that were announced to the handlers and are still deleted
+	if err := m.lock(); err != nil {
+		return 0, err
+	}
+	var purged []types.Record
+	for _, record := range records {
+		key := memoryKey(record[idColumn])
+		if row := m.row(m.table, key); row != nil && deletedBefore(row.values) {
+			m.put(key, nil)
+			purged = append(purged, memoryRecord(row.values))
+		}
+	}
+	m.unlock()
+
+	for _, record := range purged {
+		if err := m.store.OnAfterRecordPurged(ctx, m.table, record); err != nil {
+			return 0, fmt.Errorf("after purge event handler error: %w", err)
+		}
+	}
+
+	return int64(len(purged)), nil
+}
+
+func (m *memoryCollection) FindOne(ctx context.Context, filter Filter, opts ...FindOption) (*types.Record, error) {
+	options := findOptions(opts)
+
+	records, err := m.selectRecords(m.scopedMatch(filter, nil, options.Deleted))
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, NewNotFoundErr(fmt.Errorf("record not found"))
+	}
+
+	rec := memoryRecord(records[0])
+	if err := m.expand(ctx, []types.Record{rec}, options.Expand); err != nil {
+		return nil, err
+	}
+
+	return &rec, nil
+}
+
+// Find fetches records using the options pattern. Search, nearest and operator sort options
+// return ErrMemoryUnsupported.
+func (m *memoryCollection) Find(ctx context.Context, opts ...FindOption) (*List, error) {
+	options := &FindOptions{
+		Filter: Filter{},
+	}
+	for _, opt := range opts {
+		opt(options)
+	}
+
+	if (options.Search != nil && strings.TrimSpace(options.Search.Query) != "") || options.Nearest != nil {
+		return nil, fmt.Errorf("find %s: %w", m.table, ErrMemoryUnsupported)
+	}
+	for _, field := range options.Fields {
+		if !ValidIdentifierName(field) {
+			return nil, fmt.Errorf("invalid field name: %s", field)
+		}
+	}
+
+	var expression FilterExpression
+	if options.AdvancedFilter != nil {
+		expression = options.AdvancedFilter.Expression
+	}
+	records, err := m.selectRecords(m.scopedMatch(options.Filter, expression, options.Deleted))
+	if err != nil {
+		return nil, err
+	}
+
+	meta := Metadata{}
+	if !options.SkipTotal {
+		meta.Total = len(records)
+	}
+
+	var list *List
+	if options.Cursor != nil {
+		list, err = m.findWithCursor(records, options, meta)
+	} else {
+		list, err = m.findPage(records, options, meta)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := m.expand(ctx, list.Records, options.Expand); err != nil {
+		return nil, err
+	}
+
+	return list, nil
+}
+
+// findPage sorts and paginates the records found
+func (m *memoryCollection) findPage(records []types.Record, options *FindOptions, meta Metadata) (*List, error) {
+	if err := sortRecords(records, options.Sort); err != nil {
+		return nil, err
+	}
+
+	if options.Pagination != nil {
+		meta.Limit = options.Pagination.Limit
+		meta.Offset = options.Pagination.Offset
+		if !options.SkipTotal && options.Pagination.Limit > 0 {
+			meta.TotalPages = int(math.Ceil(float64(meta.Total) / float64(options.Pagination.Limit)))
+		}
+		records = paginate(records, options.Pagination.Limit, options.Pagination.Offset)
+	}
+
+	recs := make([]types.Record, len(records))
+	for i, record := range records {
+		recs[i] = memoryRecord(selectFields(record, options.Fields))
+	}
+
+	return &List{
+		Records: recs,
+		Meta:    meta,
+	}, nil
+}
+
+// findWithCursor fetches a page of the records found using keyset pagination
+func (m *memoryCollection) findWithCursor(records []types.Record, options *FindOptions, meta Metadata) (*List, error) {
+	if options.Cursor.Limit <= 0 {
+		return nil, fmt.Errorf("cursor limit must be greater than zero")
+	}
+
+	sort, err := cursorSort(options.Sort, cursorTiebreaker)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := ensureCursorFields(options.Fields, sort); err != nil {
+		return nil, err
+	}
+
+	token, err := decodeCursor(options.Cursor.After, sort)
+	if err != nil {
+		return nil, err
+	}
+
+	backward := false
+	if token != nil {
+		backward = token.Backward
+		records = slices.DeleteFunc(records, func(record types.Record) bool {
+			return !afterKeyset(record, sort, token.Values, backward)
+		})
+	}
+
+	order := slices.Clone(sort)
+	if backward {
+		for i := range order {
+			order[i].Direction = reverseDirection(order[i].Direction)
+		}
+	}
+	if err := sortRecords(records, order); err != nil {
+		return nil, err
+	}
+
+	// Keep one extra record to find out whether there is another page
+	records = paginate(records, options.Cursor.Limit+1, 0)
+	for i, record := range records {
+		records[i] = selectFields(record, options.Fields)
+	}
+
+	recs, err := cursorPage(records, sort, options.Cursor.Limit, token, &meta)
+	if err != nil {
+		return nil, err
+	}
+
+	return &List{
+		Records: recs,
+		Meta:    meta,
+	}, nil
+}
+
+func (m *memoryCollection) Count(ctx context.Context, filter Filter, opts ...FindOption) (int, error) {
+	records, err := m.selectRecords(m.scopedMatch(filter, nil, findOptions(opts).Deleted))
+	if err != nil {
+		return 0, err
+	}
+	return len(records), nil
+}
+
+func (m *memoryCollection) Exists(ctx context.Context, filter Filter, opts ...FindOption) (bool, error) {
+	count, err := m.Count(ctx, filter, opts...)
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// loadRelated loads the records related to the keys, with the key they relate to in the expand key column
+func (m *memoryCollection) loadRelated(ctx context.Context, relation Relation, keys []any) ([]types.Record, error) {
+	softDelete := m.relations.SoftDeleteEnabled(relation.Table)
+	wanted := make(map[string]bool, len(keys))
+	for _, key := range keys {
+		wanted[memoryKey(key)] = true
+	}
+
+	if err := m.lock(); err != nil {
+		return nil, err
+	}
+	defer m.unlock()
+
+	// The related records of a tenant scoped collection are those of its organisation
+	inOrganisation := func(values types.Record) bool {
+		return !m.scopesRelation(relation) || fmt.Sprint(values[relation.TenantKey]) == m.organisationID
+	}
+
+	var related []types.Record
+	add := func(values types.Record, key any) {
+		if softDelete && values[deletedAtColumn] != nil {
+			return
+		}
+		record := cloneRecord(values)
+		record[expandKeyColumn] = key
+		related = append(related, record)
+	}
+
+	switch relation.Kind {
+	case BelongsTo, HasMany:
+		column := idColumn
+		if relation.Kind == HasMany {
+			column = relation.ForeignKey
+		}
+		for _, row := range m.rows(relation.Table) {
+			if key := row.values[column]; key != nil && wanted[memoryKey(key)] && inOrganisation(row.values) {
+				add(row.values, key)
+			}
+		}
+	case ManyToMany:
+		for _, join := range m.rows(relation.Through) {
+			key := join.values[relation.ForeignKey]
+			if key == nil || !wanted[memoryKey(key)] || join.values[relation.OtherKey] == nil || !inOrganisation(join.values) {
+				continue
+			}
+			if row := m.row(relation.Table, memoryKey(join.values[relation.OtherKey])); row != nil {
+				add(row.values, key)
+			}
+		}
+	}
+
+	if err := sortRecords(related, []SortOption{{Field: idColumn, Direction: SortAsc}}); err != nil {
+		return nil, err
+	}
+	for i, record := range related {
+		related[i] = memoryRecord(record)
+	}
+
+	return related, nil
+}
+
+// Aggregate computes the aggregations over the records matching the find options, like the SQL collections
+func (m *memoryCollection) Aggregate(ctx context.Context, groups []GroupBy, aggregations []Aggregation, opts ...FindOption) ([]types.Record, error) {
+	options := findOptions(opts)
+	if _, _, err := m.buildAggregateQuery(groups, aggregations, options); err != nil {
+		return nil, err
+	}
+
+	var expression FilterExpression
+	if options.AdvancedFilter != nil {
+		expression = options.AdvancedFilter.Expression
+	}
+	records, err := m.selectRecords(m.scopedMatch(options.Filter, expression, options.Deleted))
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := aggregate(records, groups, aggregations)
+	if err != nil {
+		return nil, err
+	}
+
+	sort := options.Sort
+	if len(sort) == 0 {
+		for _, group := range groups {
+			sort = append(sort, SortOption{Field: group.alias(), Direction: SortAsc})
+		}
+	}
+	if err := sortRecords(rows, sort); err != nil {
+		return nil, err
+	}
+
+	if options.Pagination != nil {
+		rows = paginate(rows, options.Pagination.Limit, options.Pagination.Offset)
+	}
+	for _, row := range rows {
+		normaliseAggregateRow(row)
+	}
+
+	return rows, nil
+}
@@ -0,0 +1,507 @@
+package store
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/tuongaz/go-saas/store/types"
+)
+
+// matchFilter matches the rows whose columns equal the values of the filter, nil values match NULL
+func matchFilter(filter Filter) func(types.Record) bool {
+	values := make(Filter, len(filter))
+	for key, value := range filter {
+		values[key] = memoryArg(value)
+	}
+
+	return func(row types.Record) bool {
+		for key, value := range values {
+			if value == nil {
+				if row[key] != nil {
+					return false
+				}
+				continue
+			}
+			if !equalValues(row[key], value) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// matchExpression matches the rows of an advanced filter expression
+func matchExpression(expression FilterExpression) func(types.Record) bool {
+	match, ok := expressionMatcher(expression)
+	if !ok {
+		return func(types.Record) bool { return true }
+	}
+	return match
+}
+
+// expressionMatcher returns the matcher of the expression, it returns false when the expression
+// has no condition, like the groups and IN conditions the SQL collections leave out
+func expressionMatcher(expression FilterExpression) (func(types.Record) bool, bool) {
+	switch expr := expression.(type) {
+	case FilterCondition:
+		return conditionMatcher(expr)
+	case FilterGroup:
+		var matchers []func(types.Record) bool
+		for _, sub := range expr.Expressions {
+			if match, ok := expressionMatcher(sub); ok {
+				matchers = append(matchers, match)
+			}
+		}
+		if len(matchers) == 0 {
+			return nil, false
+		}
+
+		or := expr.Logic == LogicOpOr
+		return func(row types.Record) bool {
+			for _, match := range matchers {
+				if match(row) == or {
+					return or
+				}
+			}
+			return !or
+		}, true
+	}
+
+	return nil, false
+}
+
+// conditionMatcher returns the matcher of a condition. Like in SQL, comparisons with NULL never match.
+func conditionMatcher(condition FilterCondition) (func(types.Record) bool, bool) {
+	field := condition.Field
+
+	switch condition.Op {
+	case FilterOpIsNull:
+		return func(row types.Record) bool { return row[field] == nil }, true
+	case FilterOpIsNotNull:
+		return func(row types.Record) bool { return row[field] != nil }, true
+	case FilterOpIn, FilterOpNotIn:
+		values, ok := condition.Value.([]any)
+		if !ok || len(values) == 0 {
+			return nil, false
+		}
+		in := condition.Op == FilterOpIn
+		return func(row types.Record) bool {
+			if row[field] == nil {
+				return false
+			}
+			for _, value := range values {
+				if equalValues(row[field], memoryArg(value)) {
+					return in
+				}
+			}
+			return !in
+		}, true
+	case FilterOpLike, FilterOpILike:
+		pattern, ok := condition.Value.(string)
+		if !ok {
+			return func(types.Record) bool { return false }, true
+		}
+		re := likeRegexp(pattern, condition.Op == FilterOpILike)
+		return func(row types.Record) bool {
+			s, ok := row[field].(string)
+			return ok && re.MatchString(s)
+		}, true
+	}
+
+	value := memoryArg(condition.Value)
+	return func(row types.Record) bool {
+		if row[field] == nil || value == nil {
+			return false
+		}
+		if condition.Op == FilterOpEqual || condition.Op == FilterOpNotEqual {
+			return equalValues(row[field], value) == (condition.Op == FilterOpEqual)
+		}
+
+		c, ok := compareValues(row[field], value)
+		if !ok {
+			return false
+		}
+		switch condition.Op {
+		case FilterOpGreater:
+			return c > 0
+		case FilterOpGreaterEqual:
+			return c >= 0
+		case FilterOpLess:
+			return c < 0
+		case FilterOpLessEqual:
+			return c <= 0
+		}
+		return false
+	}, true
+}
+
+// memoryArg converts a query argument like the values of the rows
+func memoryArg(value any) any {
+	if converted, err := memoryValue(value); err == nil {
+		return converted
+	}
+	return value
+}
+
+// likeRegexp converts a LIKE pattern into a regular expression. % matches any text,
+// _ any character and a backslash escapes the next character.
+func likeRegexp(pattern string, insensitive bool) *regexp.Regexp {
+	var b strings.Builder
+	if insensitive {
+		b.WriteString("(?is)^")
+	} else {
+		b.WriteString("(?s)^")
+	}
+
+	runes := []rune(pattern)
+	for i := 0; i < len(runes); i++ {
+		switch r := runes[i]; {
+		case r == '\\' && i+1 < len(runes):
+			i++
+			b.WriteString(regexp.QuoteMeta(string(runes[i])))
+		case r == '%':
+			b.WriteString(".*")
+		case r == '_':
+			b.WriteString(".")
+		default:
+			b.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	b.WriteString("$")
+
+	return regexp.MustCompile(b.String())
+}
+
+// compareValues compares two values that are not NULL. Numbers compare with numbers,
+// times with times and RFC 3339 strings, strings with strings and booleans with booleans.
+// It returns false when the values cannot be compared.
+func compareValues(a, b any) (int, bool) {
+	if x, ok := a.(int64); ok {
+		if y, ok := b.(int64); ok {
+			return cmp.Compare(x, y), true
+		}
+	}
+	if x, ok := toFloat(a); ok {
+		if y, ok := toFloat(b); ok {
+			return cmp.Compare(x, y), true
+		}
+		return 0, false
+	}
+
+	if x, ok := toTime(a); ok {
+		if y, ok := toTime(b); ok {
+			return x.Compare(y), true
+		}
+	}
+
+	switch x := a.(type) {
+	case string:
+		if y, ok := b.(string); ok {
+			return strings.Compare(x, y), true
+		}
+	case bool:
+		if y, ok := b.(bool); ok {
+			switch {
+			case x == y:
+				return 0, true
+			case y:
+				return -1, true
+			}
+			return 1, true
+		}
+	}
+
+	return 0, false
+}
+
+// equalValues tells whether two values are equal, comparable values are compared by value
+func equalValues(a, b any) bool {
+	if a == nil || b == nil {
+		return false
+	}
+	if c, ok := compareValues(a, b); ok {
+		return c == 0
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+func toFloat(value any) (float64, bool) {
+	switch v := value.(type) {
+	case int64:
+		return float64(v), true
+	case float64:
+		return v, true
+	case json.Number:
+		f, err := v.Float64()
+		return f, err == nil
+	}
+	return 0, false
+}
+
+// toTime returns the time of a time value, or of a RFC 3339 string when compared with a time
+func toTime(value any) (time.Time, bool) {
+	switch v := value.(type) {
+	case time.Time:
+		return v, true
+	case string:
+		t, err := time.Parse(time.RFC3339Nano, v)
+		return t, err == nil
+	}
+	return time.Time{}, false
+}
+
+// sortRecords sorts the records in place, keeping the insertion order of equal records.
+// Like in Postgres, NULLs sort last in ascending order and first in descending order.
+func sortRecords(records []types.Record, sortOptions []SortOption) error {
+	if _, err := buildOrderBy(sortOptions); err != nil {
+		return err
+	}
+	for _, opt := range sortOptions {
+		if strings.TrimSpace(opt.Operator) != "" {
+			return fmt.Errorf("sort by %s %s %s: %w", opt.Field, opt.Operator, opt.Right, ErrMemoryUnsupported)
+		}
+	}
+	if len(sortOptions) == 0 {
+		return nil
+	}
+
+	sort.SliceStable(records, func(i, j int) bool {
+		for _, opt := range sortOptions {
+			c := compareSortValues(records[i][opt.Field], records[j][opt.Field])
+			if c == 0 {
+				continue
+			}
+			if opt.Direction == SortDesc {
+				return c > 0
+			}
+			return c < 0
+		}
+		return false
+	})
+
+	return nil
+}
+
+// compareSortValues compares two values in ascending order, NULLs are greater than the other values
+func compareSortValues(a, b any) int {
+	switch {
+	case a == nil && b == nil:
+		return 0
+	case a == nil:
+		return 1
+	case b == nil:
+		return -1
+	}
+
+	if c, ok := compareValues(a, b); ok {
+		return c
+	}
+	return strings.Compare(fmt.Sprint(a), fmt.Sprint(b))
+}
+
+// afterKeyset tells whether the record is positioned after the sort values,
+// or before them when backward is true, like the condition of buildKeysetCondition
+func afterKeyset(record types.Record, sort []SortOption, values []any, backward bool) bool {
+	for i, opt := range sort {
+		var c int
+		if record[opt.Field] == nil || values[i] == nil {
+			c = compareSortValues(record[opt.Field], values[i])
+		} else {
+			var ok bool
+			if c, ok = compareValues(record[opt.Field], values[i]); !ok {
+				return false
+			}
+		}
+
+		if (opt.Direction == SortDesc) != backward {
+			c = -c
+		}
+		if c != 0 {
+			return c > 0
+		}
+	}
+	return false
+}
+
+// paginate returns the records of the page, a limit of zero returns all the records after the offset
+func paginate[T any](records []T, limit, offset int) []T {
+	if offset > 0 {
+		records = records[min(offset, len(records)):]
+	}
+	if limit > 0 && limit < len(records) {
+		records = records[:limit]
+	}
+	return records
+}
+
+// selectFields returns the record with only the fields, all of them when there are none
+func selectFields(record types.Record, fields []string) types.Record {
+	if len(fields) == 0 {
+		return record
+	}
+
+	selected := make(types.Record, len(fields))
+	for _, field := range fields {
+		selected[field] = record[field]
+	}
+	return selected
+}
+
+// aggregate computes the aggregations over the records, one row per group in the order the groups
+// are found. Without groups a single row is returned, even when there are no records.
+func aggregate(records []types.Record, groups []GroupBy, aggregations []Aggregation) ([]types.Record, error) {
+	var keys []string
+	members := map[string][]types.Record{}
+	values := map[string]types.Record{}
+	if len(groups) == 0 {
+		keys = []string{""}
+		members[""] = records
+		values[""] = types.Record{}
+	}
+
+	for _, record := range records {
+		if len(groups) == 0 {
+			break
+		}
+
+		groupValues := make(types.Record, len(groups))
+		parts := make([]string, len(groups))
+		for i, group := range groups {
+			value, err := groupValue(record[group.Field], group.Truncate)
+			if err != nil {
+				return nil, err
+			}
+			groupValues[group.alias()] = value
+			parts[i] = fmt.Sprintf("%T:%v", value, value)
+		}
+
+		key := strings.Join(parts, "\x00")
+		if _, ok := members[key]; !ok {
+			keys = append(keys, key)
+			values[key] = groupValues
+		}
+		members[key] = append(members[key], record)
+	}
+
+	rows := make([]types.Record, 0, len(keys))
+	for _, key := range keys {
+		row := values[key]
+		for _, aggregation := range aggregations {
+			value, err := aggregateValue(members[key], aggregation)
+			if err != nil {
+				return nil, err
+			}
+			row[aggregation.Alias] = value
+		}
+		rows = append(rows, row)
+	}
+
+	return rows, nil
+}
+
+// groupValue returns the value a record is grouped by, times are truncated to the date part in UTC
+func groupValue(value any, part DatePart) (any, error) {
+	if part == "" || value == nil {
+		return value, nil
+	}
+
+	t, ok := toTime(value)
+	if !ok {
+		return nil, fmt.Errorf("group by %s: %v is not a time", part, value)
+	}
+	t = t.UTC()
+
+	switch part {
+	case DateHour:
+		t = t.Truncate(time.Hour)
+	case DateDay:
+		t = time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	case DateWeek:
+		t = time.Date(t.Year(), t.Month(), t.Day()-(int(t.Weekday())+6)%7, 0, 0, 0, 0, time.UTC)
+	case DateMonth:
+		t = time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+	case DateQuarter:
+		t = time.Date(t.Year(), t.Month()-(t.Month()-1)%3, 1, 0, 0, 0, 0, time.UTC)
+	case DateYear:
+		t = time.Date(t.Year(), time.January, 1, 0, 0, 0, 0, time.UTC)
+	}
+
+	return t, nil
+}
+
+// aggregateValue computes the aggregation over the records of a group, NULLs are ignored.
+// Sums, averages, minimums and maximums of no values are NULL.
+func aggregateValue(records []types.Record, aggregation Aggregation) (any, error) {
+	if aggregation.Field == "" {
+		return int64(len(records)), nil
+	}
+
+	var values []any
+	for _, record := range records {
+		if value := record[aggregation.Field]; value != nil {
+			values = append(values, value)
+		}
+	}
+
+	switch aggregation.Func {
+	case AggCount:
+		return int64(len(values)), nil
+	case AggCountDistinct:
+		var distinct []any
+		for _, value := range values {
+			if !containsValue(distinct, value) {
+				distinct = append(distinct, value)
+			}
+		}
+		return int64(len(distinct)), nil
+	}
+
+	if len(values) == 0 {
+		return nil, nil
+	}
+
+	switch aggregation.Func {
+	case AggSum, AggAvg:
+		var sum float64
+		for _, value := range values {
+			f, ok := toFloat(value)
+			if !ok {
+				return nil, fmt.Errorf("%s of %s: %v is not a number", aggregation.Func, aggregation.Field, value)
+			}
+			sum += f
+		}
+		if aggregation.Func == AggAvg {
+			return sum / float64(len(values)), nil
+		}
+		return sum, nil
+	case AggMin, AggMax:
+		result := values[0]
+		for _, value := range values[1:] {
+			c, ok := compareValues(value, result)
+			if !ok {
+				return nil, fmt.Errorf("%s of %s: %v cannot be compared", aggregation.Func, aggregation.Field, value)
+			}
+			if (aggregation.Func == AggMin && c < 0) || (aggregation.Func == AggMax && c > 0) {
+				result = value
+			}
+		}
+		return result, nil
+	}
+
+	return nil, fmt.Errorf("unknown aggregate function: %s", aggregation.Func)
+}
+
+func containsValue(values []any, value any) bool {
+	for _, v := range values {
+		if equalValues(v, value) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,391 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tuongaz/go-saas/store/types"
+)
+
+func newMemoryNotes(t *testing.T) (*MemoryStore, CollectionInterface) {
+	t.Helper()
+
+	st := NewMemory()
+	st.RegisterTable(MemoryTable{
+		Name:     "notes",
+		Unique:   [][]string{{"title"}},
+		Defaults: types.Record{"body": "empty", "version": 1},
+	})
+	notes := st.Collection("notes")
+
+	_, err := notes.CreateRecords(context.Background(), []types.Record{
+		{"id": "n1", "title": "First", "rank": 3, "data": json.RawMessage(`{"a":1}`)},
+		{"id": "n2", "title": "Second", "rank": 1, "body": "text"},
+		{"id": "n3", "title": "Third", "rank": nil},
+	})
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	return st, notes
+}
+
+func recordIDs(records []types.Record) []any {
+	ids := make([]any, len(records))
+	for i, record := range records {
+		ids[i] = record["id"]
+	}
+	return ids
+}
+
+func TestMemoryStore_Collection(t *testing.T) {
+	ctx := context.Background()
+	_, notes := newMemoryNotes(t)
+
+	t.Run("get record", func(t *testing.T) {
+		rec, err := notes.GetRecord(ctx, "n1")
+		assert.NoError(t, err)
+		assert.Equal(t, "empty", rec.Get("body"))
+		assert.Equal(t, int64(1), rec.Get("version"))
+		assert.Equal(t, map[string]any{"a": float64(1)}, rec.Get("data"))
+
+		_, err = notes.GetRecord(ctx, "missing")
+		assert.ErrorIs(t, err, sql.ErrNoRows)
+	})
+
+	t.Run("duplicate keys", func(t *testing.T) {
+		_, err := notes.CreateRecord(ctx, types.Record{"id": "n1", "title": "Other"})
+		assert.True(t, IsDuplicateKeyError(err))
+
+		_, err = notes.CreateRecord(ctx, types.Record{"title": "First"})
+		assert.True(t, IsDuplicateKeyError(err))
+
+		_, err = notes.CreateRecords(ctx, []types.Record{{"id": "n4", "title": "Fourth"}, {"id": "n5", "title": "Fourth"}})
+		assert.True(t, IsDuplicateKeyError(err))
+		_, err = notes.GetRecord(ctx, "n4")
+		assert.ErrorIs(t, err, sql.ErrNoRows)
+	})
+
+	t.Run("filters", func(t *testing.T) {
+		count, err := notes.Count(ctx, Filter{"body": "empty"})
+		assert.NoError(t, err)
+		assert.Equal(t, 2, count)
+
+		count, err = notes.Count(ctx, Filter{"rank": nil})
+		assert.NoError(t, err)
+		assert.Equal(t, 1, count)
+
+		list, err := notes.Find(ctx, WithOrGroup(
+			NewCondition("title", FilterOpILike, "%IRS%"),
+			NewCondition("rank", FilterOpLess, 2),
+		))
+		assert.NoError(t, err)
+		assert.Equal(t, []any{"n1", "n2"}, recordIDs(list.Records))
+
+		list, err = notes.Find(ctx, WithAdvancedFilter(NewCondition("id", FilterOpNotIn, []any{"n1"})))
+		assert.NoError(t, err)
+		assert.Equal(t, []any{"n2", "n3"}, recordIDs(list.Records))
+
+		list, err = notes.Find(ctx, WithAdvancedFilter(NewCondition("rank", FilterOpNotEqual, 1)))
+		assert.NoError(t, err)
+		assert.Equal(t, []any{"n1"}, recordIDs(list.Records))
+	})
+
+	t.Run("sort and pagination", func(t *testing.T) {
+		list, err := notes.Find(ctx,
+			WithSort(SortOption{Field: "rank", Direction: SortDesc}),
+			WithPagination(2, 0),
+			WithFields("id", "rank"),
+		)
+		assert.NoError(t, err)
+		assert.Equal(t, []any{"n3", "n1"}, recordIDs(list.Records))
+		assert.Equal(t, types.Record{"id": "n1", "rank": int64(3)}, list.Records[1])
+		assert.Equal(t, Metadata{Total: 3, Limit: 2, TotalPages: 2}, list.Meta)
+
+		_, err = notes.Find(ctx, WithSort(SortOption{Field: "embedding", Operator: "<->", Right: "query"}))
+		assert.ErrorIs(t, err, ErrMemoryUnsupported)
+	})
+
+	t.Run("cursor", func(t *testing.T) {
+		sort := WithSort(SortOption{Field: "title", Direction: SortAsc})
+		first, err := notes.Find(ctx, sort, WithCursor("", 2))
+		assert.NoError(t, err)
+		assert.Equal(t, []any{"n1", "n2"}, recordIDs(first.Records))
+
+		next, err := notes.Find(ctx, sort, WithCursor(first.Meta.NextCursor, 2))
+		assert.NoError(t, err)
+		assert.Equal(t, []any{"n3"}, recordIDs(next.Records))
+		assert.Empty(t, next.Meta.NextCursor)
+
+		prev, err := notes.Find(ctx, sort, WithCursor(next.Meta.PrevCursor, 2))
+		assert.NoError(t, err)
+		assert.Equal(t, []any{"n1", "n2"}, recordIDs(prev.Records))
+	})
+
+	t.Run("update record", func(t *testing.T) {
+		rec, err := notes.UpdateRecord(ctx, "n2", types.Record{"body": "changed"}, WithVersion(1))
+		assert.NoError(t, err)
+		assert.Equal(t, "changed", rec.Get("body"))
+		assert.Equal(t, int64(2), rec.Get("version"))
+
+		_, err = notes.UpdateRecord(ctx, "n2", types.Record{"body": "stale"}, WithVersion(1))
+		assert.True(t, IsConflictError(err))
+
+		_, err = notes.UpdateRecord(ctx, "n2", types.Record{"title": "First"})
+		assert.True(t, IsDuplicateKeyError(err))
+	})
+
+	t.Run("update and delete records", func(t *testing.T) {
+		updated, err := notes.Update(ctx, types.Record{"body": "bulk"}, "body", "empty")
+		assert.NoError(t, err)
+		assert.Equal(t, int64(2), updated)
+
+		assert.NoError(t, notes.DeleteRecords(ctx, Filter{"body": "bulk"}))
+		exists, err := notes.Exists(ctx, Filter{"id": "n1"})
+		assert.NoError(t, err)
+		assert.False(t, exists)
+	})
+
+	t.Run("upsert", func(t *testing.T) {
+		rec, err := notes.Upsert(ctx, types.Record{"id": "n2", "title": "Second", "body": "upserted"}, []string{"id"}, []string{"body"})
+		assert.NoError(t, err)
+		assert.Equal(t, "upserted", rec.Get("body"))
+		assert.Equal(t, int64(2), rec.Get("version"))
+
+		rec, err = notes.Upsert(ctx, types.Record{"id": "n6", "title": "Sixth"}, []string{"id"}, nil)
+		assert.NoError(t, err)
+		assert.Equal(t, "empty", rec.Get("body"))
+	})
+}
+
+func TestMemoryStore_Tx(t *testing.T) {
+	ctx := context.Background()
+	st, notes := newMemoryNotes(t)
+	handler := &recordingHandler{}
+	st.AddEventHandler(handler)
+
+	t.Run("rollback", func(t *testing.T) {
+		tx, err := st.Tx(ctx)
+		assert.NoError(t, err)
+
+		_, err = tx.Collection("notes").CreateRecord(ctx, types.Record{"id": "n4", "title": "Fourth"})
+		assert.NoError(t, err)
+		assert.NoError(t, tx.Collection("notes").DeleteRecords(ctx, Filter{"id": "n1"}))
+
+		count, err := tx.Collection("notes").Count(ctx, nil)
+		assert.NoError(t, err)
+		assert.Equal(t, 3, count)
+		count, err = notes.Count(ctx, nil)
+		assert.NoError(t, err)
+		assert.Equal(t, 3, count)
+
+		assert.NoError(t, tx.Rollback())
+		assert.Equal(t, []string{"before:notes"}, handler.created)
+		_, err = notes.GetRecord(ctx, "n4")
+		assert.ErrorIs(t, err, sql.ErrNoRows)
+		_, err = notes.GetRecord(ctx, "n1")
+		assert.NoError(t, err)
+
+		_, err = tx.Collection("notes").Count(ctx, nil)
+		assert.ErrorIs(t, err, sql.ErrTxDone)
+	})
+
+	t.Run("commit", func(t *testing.T) {
+		handler.created = nil
+		tx, err := st.Tx(ctx)
+		assert.NoError(t, err)
+
+		_, err = tx.Collection("notes").CreateRecord(ctx, types.Record{"id": "n4", "title": "Fourth"})
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"before:notes"}, handler.created)
+
+		assert.NoError(t, tx.Commit())
+		assert.Equal(t, []string{"before:notes", "after:notes"}, handler.created)
+		_, err = notes.GetRecord(ctx, "n4")
+		assert.NoError(t, err)
+		assert.ErrorIs(t, tx.Commit(), sql.ErrTxDone)
+	})
+
+	t.Run("raw queries", func(t *testing.T) {
+		assert.ErrorIs(t, st.Exec(ctx, "DELETE FROM notes"), ErrMemoryUnsupported)
+
+		tx, err := st.Tx(ctx)
+		assert.NoError(t, err)
+		_, err = tx.Query(ctx, "SELECT * FROM notes")
+		assert.ErrorIs(t, err, ErrMemoryUnsupported)
+		assert.NoError(t, tx.Rollback())
+	})
+}
+
+func TestMemoryStore_TxConflicts(t *testing.T) {
+	ctx := context.Background()
+	st := NewMemory()
+	st.RegisterTable(MemoryTable{Name: "users", Unique: [][]string{{"email"}}})
+	users := st.Collection("users")
+
+	t.Run("unique values", func(t *testing.T) {
+		tx1, err := st.Tx(ctx)
+		assert.NoError(t, err)
+		tx2, err := st.Tx(ctx)
+		assert.NoError(t, err)
+
+		_, err = tx1.Collection("users").CreateRecord(ctx, types.Record{"id": "u1", "email": "john@example.com"})
+		assert.NoError(t, err)
+		_, err = tx2.Collection("users").CreateRecord(ctx, types.Record{"id": "u2", "email": "john@example.com"})
+		assert.NoError(t, err)
+
+		assert.NoError(t, tx1.Commit())
+		err = tx2.Commit()
+		assert.True(t, IsDuplicateKeyError(err))
+
+		list, err := users.Find(ctx)
+		assert.NoError(t, err)
+		if assert.Len(t, list.Records, 1) {
+			assert.Equal(t, "u1", list.Records[0].Get("id"))
+		}
+	})
+
+	t.Run("primary key", func(t *testing.T) {
+		tx1, err := st.Tx(ctx)
+		assert.NoError(t, err)
+		tx2, err := st.Tx(ctx)
+		assert.NoError(t, err)
+
+		_, err = tx1.Collection("users").CreateRecord(ctx, types.Record{"id": "u3", "email": "jane@example.com"})
+		assert.NoError(t, err)
+		_, err = tx2.Collection("users").CreateRecord(ctx, types.Record{"id": "u3", "email": "jim@example.com"})
+		assert.NoError(t, err)
+
+		assert.NoError(t, tx1.Commit())
+		assert.True(t, IsDuplicateKeyError(tx2.Commit()))
+
+		record, err := users.GetRecord(ctx, "u3")
+		assert.NoError(t, err)
+		assert.Equal(t, "jane@example.com", record.Get("email"))
+	})
+
+	t.Run("updates of committed rows", func(t *testing.T) {
+		tx, err := st.Tx(ctx)
+		assert.NoError(t, err)
+		_, err = tx.Collection("users").UpdateRecord(ctx, "u1", types.Record{"email": "john@example.org"})
+		assert.NoError(t, err)
+		assert.NoError(t, tx.Commit())
+
+		record, err := users.GetRecord(ctx, "u1")
+		assert.NoError(t, err)
+		assert.Equal(t, "john@example.org", record.Get("email"))
+	})
+}
+
+func TestMemoryStore_Scopes(t *testing.T) {
+	ctx := context.Background()
+	st := NewMemory()
+	st.EnableSoftDelete("books")
+	st.RegisterRelations("books",
+		Relation{Name: "author", Kind: BelongsTo, Table: "authors", ForeignKey: "author_id", TenantKey: TenantColumn},
+		Relation{Name: "writer", Kind: BelongsTo, Table: "authors", ForeignKey: "author_id"},
+	)
+	st.RegisterRelations("authors",
+		Relation{Name: "books", Kind: HasMany, Table: "books", ForeignKey: "author_id", TenantKey: TenantColumn},
+		Relation{Name: "reviews", Kind: HasMany, Table: "reviews", ForeignKey: "author_id"},
+	)
+
+	org1 := st.ForOrganisation("org1")
+	_, err := org1.Collection("authors").CreateRecord(ctx, types.Record{"id": "a1", "name": "Ann"})
+	assert.NoError(t, err)
+	books := org1.Collection("books")
+	_, err = books.CreateRecords(ctx, []types.Record{
+		{"id": "b1", "author_id": "a1", "title": "One"},
+		{"id": "b2", "author_id": "a1", "title": "Two"},
+	})
+	assert.NoError(t, err)
+
+	t.Run("tenant", func(t *testing.T) {
+		other := st.ForOrganisation("org2").Collection("books")
+		count, err := other.Count(ctx, nil)
+		assert.NoError(t, err)
+		assert.Equal(t, 0, count)
+
+		_, err = other.Upsert(ctx, types.Record{"id": "b1", "title": "Stolen"}, []string{"id"}, nil)
+		assert.True(t, IsTenantError(err))
+
+		_, err = books.CreateRecord(ctx, types.Record{"id": "b3", TenantColumn: "org2"})
+		assert.True(t, IsTenantError(err))
+	})
+
+	t.Run("soft delete and expand", func(t *testing.T) {
+		assert.NoError(t, books.DeleteRecord(ctx, "b2"))
+		// A book of another organisation is not expanded
+		_, err := st.ForOrganisation("org2").Collection("books").CreateRecord(ctx, types.Record{"id": "b9", "author_id": "a1"})
+		assert.NoError(t, err)
+
+		_, err = org1.Collection("authors").GetRecord(ctx, "a1", WithExpand("reviews"))
+		assert.True(t, IsTenantError(err))
+		_, err = books.GetRecord(ctx, "b1", WithExpand("writer"))
+		assert.True(t, IsTenantError(err))
+
+		author, err := org1.Collection("authors").GetRecord(ctx, "a1", WithExpand("books.author"))
+		assert.NoError(t, err)
+		if related := author.Get("books").([]types.Record); assert.Len(t, related, 1) {
+			assert.Equal(t, "Ann", related[0].Get("author").(types.Record).Get("name"))
+		}
+
+		_, err = books.Restore(ctx, "b2")
+		assert.NoError(t, err)
+		assert.NoError(t, books.DeleteRecord(ctx, "b2"))
+
+		purged, err := books.PurgeDeletedBefore(ctx, time.Now().Add(time.Minute))
+		assert.NoError(t, err)
+		assert.Equal(t, int64(1), purged)
+
+		count, err := books.Count(ctx, nil, WithDeleted())
+		assert.NoError(t, err)
+		assert.Equal(t, 1, count)
+
+		all, err := st.Collection("authors").GetRecord(ctx, "a1", WithExpand("books"))
+		assert.NoError(t, err)
+		assert.Len(t, all.Get("books"), 2)
+	})
+
+	t.Run("foreign key of another organisation", func(t *testing.T) {
+		_, err := st.ForOrganisation("org2").Collection("authors").CreateRecord(ctx, types.Record{"id": "a9", "name": "Eve"})
+		assert.NoError(t, err)
+		_, err = books.CreateRecord(ctx, types.Record{"id": "b8", "author_id": "a9"})
+		assert.NoError(t, err)
+
+		book, err := books.GetRecord(ctx, "b8", WithExpand("author"))
+		assert.NoError(t, err)
+		assert.Nil(t, book.Get("author"))
+	})
+}
+
+func TestMemoryStore_Aggregate(t *testing.T) {
+	ctx := context.Background()
+	invoices := NewMemory().Collection("invoices")
+	_, err := invoices.CreateRecords(ctx, []types.Record{
+		{"status": "paid", "amount": 100, "created_at": time.Date(2024, 1, 10, 0, 0, 0, 0, time.UTC)},
+		{"status": "paid", "amount": 300, "created_at": time.Date(2024, 1, 20, 0, 0, 0, 0, time.UTC)},
+		{"status": "paid", "amount": 50, "created_at": time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)},
+		{"status": "open", "amount": 70, "created_at": time.Date(2024, 2, 3, 0, 0, 0, 0, time.UTC)},
+	})
+	assert.NoError(t, err)
+
+	rows, err := invoices.Aggregate(ctx,
+		[]GroupBy{GroupByDate("created_at", DateMonth).As("month")},
+		[]Aggregation{CountAll(), Sum("amount").As("amount"), Max("amount")},
+		WithFilter(Filter{"status": "paid"}),
+		WithSort(SortOption{Field: "month", Direction: SortDesc}),
+	)
+	assert.NoError(t, err)
+	assert.Equal(t, []types.Record{
+		{"month": time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC), "count": int64(1), "amount": float64(50), "max_amount": int64(50)},
+		{"month": time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), "count": int64(2), "amount": float64(400), "max_amount": int64(300)},
+	}, rows)
+
+	rows, err = invoices.Aggregate(ctx, nil, []Aggregation{CountAll(), Avg("amount")}, WithFilter(Filter{"status": "void"}))
+	assert.NoError(t, err)
+	assert.Equal(t, []types.Record{{"count": int64(0), "avg_amount": nil}}, rows)
+}
@@ -0,0 +1,102 @@
+package migrate
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"time"
+)
+
+const usage = `Usage: migrate <command>
+
+Commands:
+  up               apply all pending migrations
+  down [-steps N]  roll back the last N migrations (default 1)
+  status           show the status of all migrations
+  redo             roll back the last migration and apply it again
+`
+
+// RunCommand runs a migrate command with its arguments, writing the result to out
+func RunCommand(ctx context.Context, m *Migrator, args []string, out io.Writer) error {
+	if len(args) == 0 {
+		fmt.Fprint(out, usage)
+		return fmt.Errorf("missing migrate command")
+	}
+
+	switch args[0] {
+	case "up":
+		done, err := m.Up(ctx)
+		printMigrations(out, "Applied", done)
+		return err
+	case "down":
+		fs := flag.NewFlagSet("down", flag.ContinueOnError)
+		fs.SetOutput(out)
+		steps := fs.Int("steps", 1, "number of migrations to roll back")
+		if err := fs.Parse(args[1:]); err != nil {
+			return err
+		}
+		if *steps < 1 {
+			return fmt.Errorf("steps must be at least 1")
+		}
+
+		done, err := m.Down(ctx, *steps)
+		printMigrations(out, "Rolled back", done)
+		return err
+	case "redo":
+		mig, err := m.Redo(ctx)
+		if mig != nil {
+			fmt.Fprintf(out, "Redone %s\n", mig)
+		} else if err == nil {
+			fmt.Fprintln(out, "No migrations to redo")
+		}
+		return err
+	case "status":
+		statuses, err := m.Status(ctx)
+		if err != nil {
+			return err
+		}
+		return printStatus(out, statuses)
+	default:
+		fmt.Fprint(out, usage)
+		return fmt.Errorf("unknown migrate command %q", args[0])
+	}
+}
+
+func printMigrations(out io.Writer, verb string, migrations []Migration) {
+	if len(migrations) == 0 {
+		fmt.Fprintln(out, "No migrations to run")
+		return
+	}
+
+	for _, mig := range migrations {
+		fmt.Fprintf(out, "%s %s\n", verb, mig)
+	}
+}
+
+func printStatus(out io.Writer, statuses []Status) error {
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "NAMESPACE\tVERSION\tNAME\tSTATUS\tAPPLIED AT")
+
+	for _, s := range statuses {
+		state := "pending"
+		switch {
+		case s.Missing:
+			state = "missing"
+		case s.Modified:
+			state = "modified"
+		case s.Applied:
+			state = "applied"
+		}
+
+		appliedAt := ""
+		if s.AppliedAt != nil {
+			appliedAt = s.AppliedAt.Format(time.RFC3339)
+		}
+
+		fmt.Fprintf(w, "%s\t%d\t%s\t%s\t%s\n", s.Namespace, s.Version, s.Name, state, appliedAt)
+	}
+
+	return w.Flush()
+}
@@ -0,0 +1,369 @@
+package migrate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+
+	"github.com/tuongaz/go-saas/pkg/log"
+	"github.com/tuongaz/go-saas/store"
+)
+
+const (
+	tableMigrations = "schema_migrations"
+
+	// lockID is the lock held while migrating, so that only one replica migrates at a time
+	lockID = 123455
+)
+
+var (
+	ErrChecksumMismatch = errors.New("migration checksum mismatch")
+	ErrNoDownScript     = errors.New("migration has no down script")
+	ErrUnknownMigration = errors.New("applied migration not found in sources")
+)
+
+// Status is the state of a migration in the database
+type Status struct {
+	Migration
+	Applied   bool
+	AppliedAt *time.Time
+	// Modified is set when the applied checksum differs from the source
+	Modified bool
+	// Missing is set when the migration is applied but no longer in the sources
+	Missing bool
+}
+
+type appliedMigration struct {
+	ID        int64     `db:"id"`
+	Namespace string    `db:"namespace"`
+	Version   int64     `db:"version"`
+	Name      string    `db:"name"`
+	Checksum  string    `db:"checksum"`
+	AppliedAt time.Time `db:"applied_at"`
+}
+
+type migrationKey struct {
+	namespace string
+	version   int64
+}
+
+type Migrator struct {
+	db      *sqlx.DB
+	dialect store.Dialect
+	sources []Source
+}
+
+// New creates a migrator for the sources, use Sources() for the registered ones.
+// The migrations of the dialect of the database replace the default ones, see LoadDialect.
+func New(db *sqlx.DB, sources ...Source) *Migrator {
+	return &Migrator{
+		db:      db,
+		dialect: store.DialectOf(db),
+		sources: sources,
+	}
+}
+
+func (m *Migrator) load() ([]Migration, error) {
+	return LoadDialect(m.dialect.Name(), m.sources...)
+}
+
+// Up applies all pending migrations and returns the applied ones
+func (m *Migrator) Up(ctx context.Context) ([]Migration, error) {
+	migrations, err := m.load()
+	if err != nil {
+		return nil, err
+	}
+
+	var done []Migration
+	err = m.withLock(ctx, func(conn *sqlx.Conn) error {
+		applied, err := listApplied(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		if err := verify(migrations, applied); err != nil {
+			return err
+		}
+
+		for _, mig := range pending(migrations, applied) {
+			if err := apply(ctx, conn, mig, true); err != nil {
+				return err
+			}
+			done = append(done, mig)
+		}
+
+		return nil
+	})
+
+	return done, err
+}
+
+// Down rolls back the last steps applied migrations and returns the rolled back ones
+func (m *Migrator) Down(ctx context.Context, steps int) ([]Migration, error) {
+	migrations, err := m.load()
+	if err != nil {
+		return nil, err
+	}
+
+	var done []Migration
+	err = m.withLock(ctx, func(conn *sqlx.Conn) error {
+		done, err = down(ctx, conn, migrations, steps)
+		return err
+	})
+
+	return done, err
+}
+
+// Redo rolls back the last applied migration and applies it again
+func (m *Migrator) Redo(ctx context.Context) (*Migration, error) {
+	migrations, err := m.load()
+	if err != nil {
+		return nil, err
+	}
+
+	var redone *Migration
+	err = m.withLock(ctx, func(conn *sqlx.Conn) error {
+		done, err := down(ctx, conn, migrations, 1)
+		if err != nil {
+			return err
+		}
+		if len(done) == 0 {
+			return nil
+		}
+
+		if err := apply(ctx, conn, done[0], true); err != nil {
+			return err
+		}
+		redone = &done[0]
+
+		return nil
+	})
+
+	return redone, err
+}
+
+// Status returns the status of all source migrations, followed by applied migrations missing from the sources
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	migrations, err := m.load()
+	if err != nil {
+		return nil, err
+	}
+
+	var statuses []Status
+	err = m.withLock(ctx, func(conn *sqlx.Conn) error {
+		applied, err := listApplied(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		statuses = status(migrations, applied)
+		return nil
+	})
+
+	return statuses, err
+}
+
+// withLock runs fn on a dedicated connection while holding the migration lock of the dialect
+func (m *Migrator) withLock(ctx context.Context, fn func(conn *sqlx.Conn) error) error {
+	lock, err := m.dialect.Lock(ctx, m.db, lockID)
+	if err != nil {
+		return fmt.Errorf("acquire migration lock: %w", err)
+	}
+	defer func() {
+		if err := lock.Release(context.WithoutCancel(ctx)); err != nil {
+			log.Error("Error releasing migration lock", "err", err)
+		}
+	}()
+
+	conn, err := m.db.Connx(ctx)
+	if err != nil {
+		return fmt.Errorf("get connection: %w", err)
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, `
+		CREATE TABLE IF NOT EXISTS `+tableMigrations+` (
+			id         BIGSERIAL PRIMARY KEY,
+			namespace  VARCHAR                  NOT NULL,
+			version    BIGINT                   NOT NULL,
+			name       VARCHAR                  NOT NULL,
+			checksum   VARCHAR                  NOT NULL,
+			applied_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+			UNIQUE (namespace, version)
+		)
+	`); err != nil {
+		return fmt.Errorf("create migrations table: %w", err)
+	}
+
+	return fn(conn)
+}
+
+func listApplied(ctx context.Context, conn *sqlx.Conn) ([]appliedMigration, error) {
+	var applied []appliedMigration
+	if err := conn.SelectContext(ctx, &applied,
+		"SELECT id, namespace, version, name, checksum, applied_at FROM "+tableMigrations+" ORDER BY id",
+	); err != nil {
+		return nil, fmt.Errorf("list applied migrations: %w", err)
+	}
+
+	return applied, nil
+}
+
+func down(ctx context.Context, conn *sqlx.Conn, migrations []Migration, steps int) ([]Migration, error) {
+	applied, err := listApplied(ctx, conn)
+	if err != nil {
+		return nil, err
+	}
+
+	targets, err := rollbackTargets(migrations, applied, steps)
+	if err != nil {
+		return nil, err
+	}
+
+	var done []Migration
+	for _, mig := range targets {
+		if err := apply(ctx, conn, mig, false); err != nil {
+			return done, err
+		}
+		done = append(done, mig)
+	}
+
+	return done, nil
+}
+
+// apply runs the up or down script of the migration and records it in a single transaction
+func apply(ctx context.Context, conn *sqlx.Conn, mig Migration, up bool) error {
+	tx, err := conn.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin transaction for %s: %w", mig, err)
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	script, direction := mig.Up, "up"
+	if !up {
+		script, direction = mig.Down, "down"
+	}
+
+	if _, err := tx.ExecContext(ctx, script); err != nil {
+		return fmt.Errorf("run %s migration %s: %w", direction, mig, err)
+	}
+
+	if up {
+		_, err = tx.ExecContext(ctx,
+			"INSERT INTO "+tableMigrations+" (namespace, version, name, checksum) VALUES ($1, $2, $3, $4)",
+			mig.Namespace, mig.Version, mig.Name, mig.Checksum,
+		)
+	} else {
+		_, err = tx.ExecContext(ctx,
+			"DELETE FROM "+tableMigrations+" WHERE namespace = $1 AND version = $2",
+			mig.Namespace, mig.Version,
+		)
+	}
+	if err != nil {
+		return fmt.Errorf("record migration %s: %w", mig, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit migration %s: %w", mig, err)
+	}
+
+	log.Info("Migrated", "migration", mig.String(), "direction", direction)
+
+	return nil
+}
+
+// verify makes sure that applied migrations have not been edited since
+func verify(migrations []Migration, applied []appliedMigration) error {
+	byKey := indexMigrations(migrations)
+	for _, a := range applied {
+		mig, ok := byKey[migrationKey{a.Namespace, a.Version}]
+		if ok && mig.Checksum != a.Checksum {
+			return fmt.Errorf("%w: %s", ErrChecksumMismatch, mig)
+		}
+	}
+
+	return nil
+}
+
+// pending returns the migrations that are not applied yet, in source order
+func pending(migrations []Migration, applied []appliedMigration) []Migration {
+	done := map[migrationKey]bool{}
+	for _, a := range applied {
+		done[migrationKey{a.Namespace, a.Version}] = true
+	}
+
+	var result []Migration
+	for _, mig := range migrations {
+		if !done[migrationKey{mig.Namespace, mig.Version}] {
+			result = append(result, mig)
+		}
+	}
+
+	return result
+}
+
+// rollbackTargets returns the last steps applied migrations, most recent first
+func rollbackTargets(migrations []Migration, applied []appliedMigration, steps int) ([]Migration, error) {
+	byKey := indexMigrations(migrations)
+
+	var result []Migration
+	for i := len(applied) - 1; i >= 0 && len(result) < steps; i-- {
+		a := applied[i]
+		mig, ok := byKey[migrationKey{a.Namespace, a.Version}]
+		if !ok {
+			return nil, fmt.Errorf("%w: %s/%d_%s", ErrUnknownMigration, a.Namespace, a.Version, a.Name)
+		}
+		if mig.Down == "" {
+			return nil, fmt.Errorf("%w: %s", ErrNoDownScript, mig)
+		}
+		result = append(result, mig)
+	}
+
+	return result, nil
+}
+
+func status(migrations []Migration, applied []appliedMigration) []Status {
+	byKey := map[migrationKey]appliedMigration{}
+	for _, a := range applied {
+		byKey[migrationKey{a.Namespace, a.Version}] = a
+	}
+
+	statuses := make([]Status, 0, len(migrations))
+	for _, mig := range migrations {
+		s := Status{Migration: mig}
+		if a, ok := byKey[migrationKey{mig.Namespace, mig.Version}]; ok {
+			s.Applied = true
+			s.AppliedAt = &a.AppliedAt
+			s.Modified = a.Checksum != mig.Checksum
+		}
+		statuses = append(statuses, s)
+	}
+
+	known := indexMigrations(migrations)
+	for _, a := range applied {
+		if _, ok := known[migrationKey{a.Namespace, a.Version}]; ok {
+			continue
+		}
+		statuses = append(statuses, Status{
+			Migration: Migration{Namespace: a.Namespace, Version: a.Version, Name: a.Name, Checksum: a.Checksum},
+			Applied:   true,
+			AppliedAt: &a.AppliedAt,
+			Missing:   true,
+		})
+	}
+
+	return statuses
+}
+
+func indexMigrations(migrations []Migration) map[migrationKey]Migration {
+	byKey := make(map[migrationKey]Migration, len(migrations))
+	for _, mig := range migrations {
+		byKey[migrationKey{mig.Namespace, mig.Version}] = mig
+	}
+	return byKey
+}
@@ -0,0 +1,174 @@
+package migrate
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func testSource() Source {
+	return Source{
+		Namespace: "test",
+		Dir:       "migrations",
+		FS: fstest.MapFS{
+			"migrations/0002_add_name.up.sql":   {Data: []byte("ALTER TABLE a ADD name TEXT;")},
+			"migrations/0002_add_name.down.sql": {Data: []byte("ALTER TABLE a DROP name;")},
+			"migrations/0001_init.up.sql":       {Data: []byte("CREATE TABLE a (id TEXT);")},
+			"migrations/0001_init.down.sql":     {Data: []byte("DROP TABLE a;")},
+			"migrations/README.md":              {Data: []byte("ignored")},
+		},
+	}
+}
+
+func TestLoad(t *testing.T) {
+	migrations, err := Load(testSource())
+	assert.NoError(t, err)
+	assert.Len(t, migrations, 2)
+
+	assert.Equal(t, int64(1), migrations[0].Version)
+	assert.Equal(t, "init", migrations[0].Name)
+	assert.Equal(t, "CREATE TABLE a (id TEXT);", migrations[0].Up)
+	assert.Equal(t, "DROP TABLE a;", migrations[0].Down)
+	assert.Equal(t, checksum("CREATE TABLE a (id TEXT);"), migrations[0].Checksum)
+	assert.Equal(t, "test/2_add_name", migrations[1].String())
+
+	t.Run("missing up script", func(t *testing.T) {
+		_, err := Load(Source{Namespace: "test", FS: fstest.MapFS{
+			"0001_init.down.sql": {Data: []byte("DROP TABLE a;")},
+		}})
+		assert.Error(t, err)
+	})
+
+	t.Run("duplicate version", func(t *testing.T) {
+		_, err := Load(Source{Namespace: "test", FS: fstest.MapFS{
+			"0001_init.up.sql":  {Data: []byte("SELECT 1;")},
+			"0001_other.up.sql": {Data: []byte("SELECT 1;")},
+		}})
+		assert.Error(t, err)
+	})
+
+	t.Run("invalid file name", func(t *testing.T) {
+		_, err := Load(Source{Namespace: "test", FS: fstest.MapFS{
+			"init.sql": {Data: []byte("SELECT 1;")},
+		}})
+		assert.Error(t, err)
+	})
+
+	t.Run("dialect migrations", func(t *testing.T) {
+		src := testSource()
+		src.FS.(fstest.MapFS)["migrations/sqlite/0002_add_name.up.sql"] = &fstest.MapFile{Data: []byte("ALTER TABLE a ADD COLUMN name TEXT;")}
+
+		migrations, err := LoadDialect("sqlite", src)
+		assert.NoError(t, err)
+		assert.Len(t, migrations, 2)
+		assert.Equal(t, "CREATE TABLE a (id TEXT);", migrations[0].Up)
+		assert.Equal(t, "ALTER TABLE a ADD COLUMN name TEXT;", migrations[1].Up)
+		assert.Equal(t, "", migrations[1].Down)
+
+		migrations, err = Load(src)
+		assert.NoError(t, err)
+		assert.Equal(t, "ALTER TABLE a ADD name TEXT;", migrations[1].Up)
+
+		migrations, err = LoadDialect("mysql", src)
+		assert.NoError(t, err)
+		assert.Equal(t, "ALTER TABLE a ADD name TEXT;", migrations[1].Up)
+
+		src.FS.(fstest.MapFS)["migrations/sqlite/0002_other.up.sql"] = &fstest.MapFile{Data: []byte("SELECT 1;")}
+		delete(src.FS.(fstest.MapFS), "migrations/sqlite/0002_add_name.up.sql")
+		_, err = LoadDialect("sqlite", src)
+		assert.Error(t, err)
+	})
+}
+
+func TestRegister(t *testing.T) {
+	Register("register_test", fstest.MapFS{}, ".")
+
+	assert.Panics(t, func() {
+		Register("register_test", fstest.MapFS{}, ".")
+	})
+	assert.Panics(t, func() {
+		Register("Invalid Namespace", fstest.MapFS{}, ".")
+	})
+
+	var found bool
+	for _, src := range Sources() {
+		if src.Namespace == "register_test" {
+			found = true
+		}
+	}
+	assert.True(t, found)
+}
+
+func TestPlan(t *testing.T) {
+	migrations, err := Load(testSource())
+	assert.NoError(t, err)
+
+	now := time.Now()
+	applied := []appliedMigration{
+		{ID: 1, Namespace: "test", Version: 1, Name: "init", Checksum: migrations[0].Checksum, AppliedAt: now},
+	}
+
+	t.Run("pending", func(t *testing.T) {
+		assert.Equal(t, migrations[1:], pending(migrations, applied))
+	})
+
+	t.Run("verify", func(t *testing.T) {
+		assert.NoError(t, verify(migrations, applied))
+
+		edited := []appliedMigration{{Namespace: "test", Version: 1, Checksum: "other"}}
+		assert.True(t, errors.Is(verify(migrations, edited), ErrChecksumMismatch))
+	})
+
+	t.Run("rollback targets", func(t *testing.T) {
+		all := append(applied, appliedMigration{ID: 2, Namespace: "test", Version: 2, Name: "add_name"})
+
+		targets, err := rollbackTargets(migrations, all, 1)
+		assert.NoError(t, err)
+		assert.Equal(t, []Migration{migrations[1]}, targets)
+
+		targets, err = rollbackTargets(migrations, all, 5)
+		assert.NoError(t, err)
+		assert.Equal(t, []Migration{migrations[1], migrations[0]}, targets)
+
+		unknown := append(applied, appliedMigration{ID: 3, Namespace: "other", Version: 1, Name: "init"})
+		_, err = rollbackTargets(migrations, unknown, 1)
+		assert.True(t, errors.Is(err, ErrUnknownMigration))
+
+		noDown := []Migration{{Namespace: "test", Version: 1, Name: "init"}}
+		_, err = rollbackTargets(noDown, applied, 1)
+		assert.True(t, errors.Is(err, ErrNoDownScript))
+	})
+
+	t.Run("status", func(t *testing.T) {
+		withMissing := append(applied, appliedMigration{ID: 3, Namespace: "other", Version: 7, Name: "gone", AppliedAt: now})
+
+		statuses := status(migrations, withMissing)
+		assert.Len(t, statuses, 3)
+		assert.True(t, statuses[0].Applied)
+		assert.False(t, statuses[0].Modified)
+		assert.False(t, statuses[1].Applied)
+		assert.True(t, statuses[2].Missing)
+		assert.Equal(t, "other/7_gone", statuses[2].String())
+
+		var out bytes.Buffer
+		assert.NoError(t, printStatus(&out, statuses))
+		assert.Contains(t, out.String(), "applied")
+		assert.Contains(t, out.String(), "pending")
+		assert.Contains(t, out.String(), "missing")
+	})
+}
+
+func TestRunCommand_Invalid(t *testing.T) {
+	var out bytes.Buffer
+	m := New(nil)
+
+	assert.Error(t, RunCommand(context.Background(), m, nil, &out))
+	assert.Error(t, RunCommand(context.Background(), m, []string{"sideways"}, &out))
+	assert.Error(t, RunCommand(context.Background(), m, []string{"down", "-steps", "0"}, &out))
+	assert.Contains(t, out.String(), "Usage: migrate")
+}
@@ -0,0 +1,184 @@
+package migrate
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"sync"
+)
+
+var (
+	fileNameRegex  = regexp.MustCompile(`^(\d+)_([a-zA-Z0-9_\-]+)\.(up|down)\.sql$`)
+	namespaceRegex = regexp.MustCompile(`^[a-z][a-z0-9_\-]*$`)
+
+	registryMu sync.Mutex
+	registry   []Source
+)
+
+// Source is a set of migrations owned by a module.
+// Files in Dir are named <version>_<name>.up.sql and <version>_<name>.down.sql.
+// Migrations written for another database than Postgres go in a subdirectory named
+// after the dialect, e.g. Dir/sqlite, and replace the migrations of the same version.
+type Source struct {
+	Namespace string
+	FS        fs.FS
+	Dir       string
+}
+
+// Migration is a single versioned migration of a namespace
+type Migration struct {
+	Namespace string
+	Version   int64
+	Name      string
+	Up        string
+	Down      string
+	// Checksum is the sha256 of the up script, used to detect edited migrations
+	Checksum string
+}
+
+func (m Migration) String() string {
+	return fmt.Sprintf("%s/%d_%s", m.Namespace, m.Version, m.Name)
+}
+
+// Register registers the migrations of a namespace, usually from an embed.FS in an init function.
+// Namespaces are migrated in the order they are registered.
+func Register(namespace string, fsys fs.FS, dir string) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	if !namespaceRegex.MatchString(namespace) {
+		panic(fmt.Sprintf("migrate: invalid namespace %q", namespace))
+	}
+	for _, src := range registry {
+		if src.Namespace == namespace {
+			panic(fmt.Sprintf("migrate: namespace %q registered twice", namespace))
+		}
+	}
+
+	registry = append(registry, Source{Namespace: namespace, FS: fsys, Dir: dir})
+}
+
+// Sources returns the registered migration sources
+func Sources() []Source {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	return append([]Source(nil), registry...)
+}
+
+// Load reads the migrations of the sources, ordered by source then version
+func Load(sources ...Source) ([]Migration, error) {
+	return LoadDialect("", sources...)
+}
+
+// LoadDialect reads the migrations of the sources for the dialect, the migrations
+// of the dialect subdirectory of the sources replace the default ones
+func LoadDialect(dialect string, sources ...Source) ([]Migration, error) {
+	var migrations []Migration
+	for _, src := range sources {
+		migs, err := loadSource(src, dialect)
+		if err != nil {
+			return nil, fmt.Errorf("load %s migrations: %w", src.Namespace, err)
+		}
+		migrations = append(migrations, migs...)
+	}
+
+	return migrations, nil
+}
+
+func loadSource(src Source, dialect string) ([]Migration, error) {
+	dir := src.Dir
+	if dir == "" {
+		dir = "."
+	}
+
+	byVersion, err := loadDir(src, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	if dialect != "" {
+		overrides, err := loadDir(src, path.Join(dir, dialect))
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return nil, err
+		}
+		for version, mig := range overrides {
+			if base, ok := byVersion[version]; ok && base.Name != mig.Name {
+				return nil, fmt.Errorf("%s migration %s does not match %s", dialect, mig, base)
+			}
+			byVersion[version] = mig
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, mig := range byVersion {
+		if mig.Up == "" {
+			return nil, fmt.Errorf("migration %s has no up script", mig)
+		}
+		mig.Checksum = checksum(mig.Up)
+		migrations = append(migrations, *mig)
+	}
+
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+
+	return migrations, nil
+}
+
+// loadDir reads the migration files of the directory by version
+func loadDir(src Source, dir string) (map[int64]*Migration, error) {
+	entries, err := fs.ReadDir(src.FS, dir)
+	if err != nil {
+		return nil, fmt.Errorf("read dir: %w", err)
+	}
+
+	byVersion := map[int64]*Migration{}
+	for _, entry := range entries {
+		if entry.IsDir() || path.Ext(entry.Name()) != ".sql" {
+			continue
+		}
+
+		matches := fileNameRegex.FindStringSubmatch(entry.Name())
+		if matches == nil {
+			return nil, fmt.Errorf("invalid migration file name %s", entry.Name())
+		}
+
+		version, err := strconv.ParseInt(matches[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid migration version %s: %w", entry.Name(), err)
+		}
+
+		content, err := fs.ReadFile(src.FS, path.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("read %s: %w", entry.Name(), err)
+		}
+
+		mig, ok := byVersion[version]
+		if !ok {
+			mig = &Migration{Namespace: src.Namespace, Version: version, Name: matches[2]}
+			byVersion[version] = mig
+		} else if mig.Name != matches[2] {
+			return nil, fmt.Errorf("duplicate migration version %d: %s and %s", version, mig.Name, matches[2])
+		}
+
+		if matches[3] == "up" {
+			mig.Up = string(content)
+		} else {
+			mig.Down = string(content)
+		}
+	}
+
+	return byVersion, nil
+}
+
+func checksum(script string) string {
+	sum := sha256.Sum256([]byte(script))
+	return hex.EncodeToString(sum[:])
+}
@@ -19,6 +19,9 @@ type Metadata struct {
 	Limit      int `json:"limit"`
 	Offset     int `json:"offset"`
 	TotalPages int `json:"total_pages"`
+	// NextCursor and PrevCursor are set when cursor pagination is used
+	NextCursor string `json:"next_cursor,omitempty"`
+	PrevCursor string `json:"prev_cursor,omitempty"`
 }
 
 func (l List) Decode(obj any) error {
@@ -1,5 +1,7 @@
 package store
 
+import "time"
+
 // QueryOptions represents the legacy options struct (kept for backward compatibility)
 type QueryOptions struct {
 	Fields     []string     // Fields to select (defaults to all fields if empty)
@@ -21,8 +23,26 @@ type FindOptions struct {
 	Fields         []string        // Fields to select (defaults to all fields if empty)
 	Sort           []SortOption    // Sort options
 	Pagination     *Pagination     // Pagination options
+	Cursor         *Cursor         // Keyset pagination options, takes precedence over Pagination
+	SkipTotal      bool            // Skip the total count query
+	Deleted        DeletedScope    // Which soft deleted records to return
+	Expand         []string        // Relations to load and nest in the records
+	Search         *SearchOptions  // Full-text search, see WithSearch
+	Nearest        *NearestOptions // Vector similarity search, see WithNearest
 }
 
+// DeletedScope controls which records are returned from a soft delete collection
+type DeletedScope int
+
+const (
+	// DeletedScopeExclude excludes soft deleted records, this is the default
+	DeletedScopeExclude DeletedScope = iota
+	// DeletedScopeInclude returns both live and soft deleted records
+	DeletedScopeInclude
+	// DeletedScopeOnly returns only soft deleted records
+	DeletedScopeOnly
+)
+
 // WithFilter sets the simple filter option for equality-based filtering.
 // Filter is a map where keys are column names and values are the values to match.
 // Multiple conditions are combined with AND logic.
@@ -114,3 +134,102 @@ func WithPagination(limit, offset int) FindOption {
 		}
 	}
 }
+
+// WithCursor enables keyset (cursor) pagination driven by the sort options.
+// After is an opaque cursor returned in Metadata.NextCursor or Metadata.PrevCursor,
+// an empty value returns the first page. The "id" column is appended to the sort
+// options as a tiebreaker when it is not already present.
+//
+// Example:
+//
+//	list, _ := collection.Find(ctx,
+//	  WithSort(SortOption{Field: "created_at", Direction: SortDesc}),
+//	  WithCursor("", 20),
+//	)
+//	next, _ := collection.Find(ctx,
+//	  WithSort(SortOption{Field: "created_at", Direction: SortDesc}),
+//	  WithCursor(list.Meta.NextCursor, 20),
+//	)
+func WithCursor(after string, limit int) FindOption {
+	return func(o *FindOptions) {
+		o.Cursor = &Cursor{
+			After: after,
+			Limit: limit,
+		}
+	}
+}
+
+// WithoutTotal skips the COUNT query used to fill Metadata.Total and Metadata.TotalPages.
+// This is useful on large tables where counting is expensive.
+func WithoutTotal() FindOption {
+	return func(o *FindOptions) {
+		o.SkipTotal = true
+	}
+}
+
+// WithDeleted includes soft deleted records in the results.
+// It has no effect on collections without soft delete.
+func WithDeleted() FindOption {
+	return func(o *FindOptions) {
+		o.Deleted = DeletedScopeInclude
+	}
+}
+
+// OnlyDeleted returns only soft deleted records.
+// It has no effect on collections without soft delete.
+func OnlyDeleted() FindOption {
+	return func(o *FindOptions) {
+		o.Deleted = DeletedScopeOnly
+	}
+}
+
+// WithExpand loads the related records of the relations and nests them in the returned records.
+// Nested relations are separated by dots, and the relations are registered with Store.RegisterRelations.
+// Each relation is loaded with a single query, whatever the number of records.
+//
+// Example: WithExpand("members.account", "owner")
+func WithExpand(paths ...string) FindOption {
+	return func(o *FindOptions) {
+		o.Expand = append(o.Expand, paths...)
+	}
+}
+
+// UpdateOption is a function that modifies UpdateOptions.
+type UpdateOption func(*UpdateOptions)
+
+// UpdateOptions holds the optimistic concurrency preconditions of UpdateRecord.
+// When a precondition does not hold, UpdateRecord returns a ConflictErr.
+type UpdateOptions struct {
+	Version          *int64     // Expected value of the version column
+	UnmodifiedSince  *time.Time // The record must not have been updated after this time
+	IncrementVersion bool       // Increment the version column
+}
+
+// WithVersion only updates the record when its version column equals version,
+// and increments the version column.
+//
+// Example:
+//
+//	collection.UpdateRecord(ctx, id, record, WithVersion(3))
+func WithVersion(version int64) UpdateOption {
+	return func(o *UpdateOptions) {
+		o.Version = &version
+		o.IncrementVersion = true
+	}
+}
+
+// WithVersionIncrement increments the version column without checking it,
+// so that concurrent writers using WithVersion detect the change.
+func WithVersionIncrement() UpdateOption {
+	return func(o *UpdateOptions) {
+		o.IncrementVersion = true
+	}
+}
+
+// WithUnmodifiedSince only updates the record when its updated_at column is not after t.
+// The comparison uses second precision, as records expose timestamps in RFC3339.
+func WithUnmodifiedSince(t time.Time) UpdateOption {
+	return func(o *UpdateOptions) {
+		o.UnmodifiedSince = &t
+	}
+}
@@ -0,0 +1,182 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+
+	"github.com/tuongaz/go-saas/pkg/log"
+)
+
+const (
+	defaultConnectRetryDelay = time.Second
+	maxConnectRetryDelay     = 30 * time.Second
+)
+
+// PoolConfig configures the connection pools of the primary and the read replicas.
+// The zero values keep the defaults of database/sql.
+type PoolConfig struct {
+	// MaxOpenConns is the maximum number of open connections, unlimited by default
+	MaxOpenConns int
+	// MaxIdleConns is the maximum number of idle connections kept open, 2 by default
+	MaxIdleConns int
+	// ConnMaxLifetime is how long a connection is reused, e.g. to spread the connections
+	// over the servers behind a load balancer, forever by default
+	ConnMaxLifetime time.Duration
+	// ConnMaxIdleTime is how long a connection stays idle before it is closed, forever by default
+	ConnMaxIdleTime time.Duration
+}
+
+func (p PoolConfig) apply(db *sqlx.DB) {
+	// The dialect limits the pool to one connection when the database lives in it,
+	// e.g. an in-memory SQLite database, which must stay open
+	if db.Stats().MaxOpenConnections == 1 {
+		return
+	}
+
+	if p.MaxOpenConns > 0 {
+		db.SetMaxOpenConns(p.MaxOpenConns)
+	}
+	if p.MaxIdleConns > 0 {
+		db.SetMaxIdleConns(p.MaxIdleConns)
+	}
+	if p.ConnMaxLifetime > 0 {
+		db.SetConnMaxLifetime(p.ConnMaxLifetime)
+	}
+	if p.ConnMaxIdleTime > 0 {
+		db.SetConnMaxIdleTime(p.ConnMaxIdleTime)
+	}
+}
+
+// WithPool configures the connection pools of the primary and the read replicas.
+// In-memory SQLite databases keep their single connection and ignore it.
+func WithPool(pool PoolConfig) Option {
+	return func(o *options) {
+		o.pool = pool
+	}
+}
+
+// WithStatementTimeout aborts the statements running longer than the timeout, with the
+// statement_timeout setting of the Postgres sessions. Other dialects ignore it.
+func WithStatementTimeout(timeout time.Duration) Option {
+	return func(o *options) {
+		o.statementTimeout = timeout
+	}
+}
+
+// WithConnectRetry retries the initial connection to the primary when the database cannot be
+// reached, e.g. while it starts next to the app. The delay, 1 second by default, doubles after
+// each attempt up to 30 seconds. Other errors, e.g. authentication failures, are not retried.
+func WithConnectRetry(retries int, delay time.Duration) Option {
+	return func(o *options) {
+		o.connectRetries = retries
+		if delay > 0 {
+			o.connectRetryDelay = delay
+		}
+	}
+}
+
+// open opens the datasource with the statement timeout and the pool settings of the options
+func (o *options) open(datasource string) (*sqlx.DB, Dialect, error) {
+	if o.statementTimeout > 0 && dialectFor(datasource) == Postgres {
+		datasource = withStatementTimeoutParam(datasource, o.statementTimeout)
+	}
+
+	db, dialect, err := openDatasource(datasource)
+	if err != nil {
+		return nil, nil, err
+	}
+	o.pool.apply(db)
+
+	return db, dialect, nil
+}
+
+// withStatementTimeoutParam adds the statement_timeout run-time parameter to a Postgres
+// datasource, a URL or key=value pairs, unless it sets one already
+func withStatementTimeoutParam(datasource string, timeout time.Duration) string {
+	ms := strconv.FormatInt(max(timeout.Milliseconds(), 1), 10)
+
+	if strings.HasPrefix(datasource, "postgres://") || strings.HasPrefix(datasource, "postgresql://") {
+		u, err := url.Parse(datasource)
+		if err != nil {
+			// Left for the driver to report
+			return datasource
+		}
+		query := u.Query()
+		if !query.Has("statement_timeout") {
+			query.Set("statement_timeout", ms)
+			u.RawQuery = query.Encode()
+		}
+		return u.String()
+	}
+
+	if strings.Contains(datasource, "statement_timeout=") {
+		return datasource
+	}
+	return strings.TrimSpace(datasource + " statement_timeout=" + ms)
+}
+
+// connect pings the database, retrying the connection errors with a doubling delay
+func connect(ping func() error, retries int, delay time.Duration) error {
+	for attempt := 1; ; attempt++ {
+		err := ping()
+		if err == nil || attempt > retries || !isConnectionError(err) {
+			return err
+		}
+
+		log.Default().Warn("database is not reachable, retrying", "attempt", attempt, "delay", delay, log.ErrorAttr(err))
+		time.Sleep(delay)
+		delay = min(delay*2, maxConnectRetryDelay)
+	}
+}
+
+// PoolStats is the state of the connection pool of the primary
+type PoolStats struct {
+	// MaxOpenConnections is the maximum number of open connections, 0 when unlimited
+	MaxOpenConnections int
+	OpenConnections    int
+	InUse              int
+	Idle               int
+
+	// WaitCount is the number of queries that waited for a free connection, for WaitDuration in total
+	WaitCount    int64
+	WaitDuration time.Duration
+
+	// Saturation is the share of the maximum open connections in use, from 0 to 1.
+	// It is 0 when the number of connections is unlimited.
+	Saturation float64
+}
+
+func newPoolStats(stats sql.DBStats) PoolStats {
+	s := PoolStats{
+		MaxOpenConnections: stats.MaxOpenConnections,
+		OpenConnections:    stats.OpenConnections,
+		InUse:              stats.InUse,
+		Idle:               stats.Idle,
+		WaitCount:          stats.WaitCount,
+		WaitDuration:       stats.WaitDuration,
+	}
+	if stats.MaxOpenConnections > 0 {
+		s.Saturation = float64(stats.InUse) / float64(stats.MaxOpenConnections)
+	}
+	return s
+}
+
+// Ping checks that the primary can be reached
+func (s *Store) Ping(ctx context.Context) error {
+	if err := s.db.PingContext(ctx); err != nil {
+		return fmt.Errorf("ping db: %w", err)
+	}
+	return nil
+}
+
+// Stats returns the state of the connection pool of the primary
+func (s *Store) Stats() PoolStats {
+	return newPoolStats(s.db.Stats())
+}
@@ -3,45 +3,77 @@ package store
 import (
 	"context"
 	"fmt"
+	"math"
 	"strings"
 
+	"github.com/jmoiron/sqlx"
 	"github.com/tuongaz/go-saas/store/types"
 )
 
 // buildQuery helps in constructing SQL query strings based on a simple filter.
 func buildQuery(baseQuery string, filter Filter) (string, []any) {
-	if len(filter) == 0 {
-		return baseQuery, nil
-	}
+	where := &whereBuilder{}
+	where.addFilter(filter)
 
-	var parts []string
-	var args []any
-	i := 1
+	return baseQuery + where.String(), where.args
+}
+
+// buildAdvancedQuery constructs a SQL query with advanced filtering options
+func buildAdvancedQuery(baseQuery string, filter AdvancedFilter) (string, []any) {
+	where := &whereBuilder{}
+	where.addExpression(filter.Expression)
+
+	return baseQuery + where.String(), where.args
+}
 
+// whereBuilder accumulates WHERE conditions together with their positional arguments.
+// Conditions are combined with AND logic.
+type whereBuilder struct {
+	parts []string
+	args  []any
+}
+
+// nextIdx returns the index of the next positional argument
+func (w *whereBuilder) nextIdx() int {
+	return len(w.args) + 1
+}
+
+// add appends a condition whose placeholders start at nextIdx
+func (w *whereBuilder) add(clause string, args ...any) {
+	if clause == "" {
+		return
+	}
+	w.parts = append(w.parts, clause)
+	w.args = append(w.args, args...)
+}
+
+// addFilter appends an equality condition for each key of the filter
+func (w *whereBuilder) addFilter(filter Filter) {
 	for k, v := range filter {
 		if v == nil {
-			parts = append(parts, fmt.Sprintf("%s IS NULL", k))
+			w.add(fmt.Sprintf("%s IS NULL", k))
 			continue
 		}
-		parts = append(parts, fmt.Sprintf("%s = $%d", k, i))
-		args = append(args, v)
-		i++
+		w.add(fmt.Sprintf("%s = $%d", k, w.nextIdx()), v)
 	}
-	return baseQuery + " WHERE " + strings.Join(parts, " AND "), args
 }
 
-// buildAdvancedQuery constructs a SQL query with advanced filtering options
-func buildAdvancedQuery(baseQuery string, filter AdvancedFilter) (string, []any) {
-	if filter.Expression == nil {
-		return baseQuery, nil
+// addExpression appends an advanced filter expression
+func (w *whereBuilder) addExpression(expr FilterExpression) {
+	if expr == nil {
+		return
 	}
+	clause, args := buildFilterExpression(expr, w.nextIdx())
+	w.add(clause, args...)
+}
 
-	whereClause, args := buildFilterExpression(filter.Expression, 1)
-	if whereClause != "" {
-		return baseQuery + " WHERE " + whereClause, args
+// String returns the WHERE clause, or an empty string when there are no conditions
+func (w *whereBuilder) String() string {
+	if len(w.parts) == 0 {
+		return ""
 	}
 
-	return baseQuery, nil
+	return " WHERE " + strings.Join(w.parts, " AND ")
 }
 
 // buildFilterExpression recursively builds SQL for a filter expression
@@ -118,6 +150,61 @@ func buildConditionClause(condition FilterCondition, idx int) (string, []any, in
 	return clause, args, nextIdx
 }
 
+// buildOrderBy builds the ORDER BY clause for the sort options
+func buildOrderBy(sort []SortOption) (string, error) {
+	if len(sort) == 0 {
+		return "", nil
+	}
+
+	sortClauses := make([]string, 0, len(sort))
+	for _, opt := range sort {
+		// Validate field name to prevent SQL injection
+		if !ValidIdentifierName(opt.Field) {
+			return "", fmt.Errorf("invalid field name for sorting: %s", opt.Field)
+		}
+
+		// Validate sort direction
+		if opt.Direction != SortAsc && opt.Direction != SortDesc {
+			opt.Direction = SortAsc // Default to ascending if invalid
+		}
+
+		// Support optional operator-based ordering (e.g., Postgres distance operators)
+		if strings.TrimSpace(opt.Operator) != "" {
+			sortClauses = append(sortClauses, fmt.Sprintf("%s %s %s %s", opt.Field, opt.Operator, opt.Right, opt.Direction))
+			continue
+		}
+
+		sortClauses = append(sortClauses, fmt.Sprintf("%s %s", opt.Field, opt.Direction))
+	}
+
+	return " ORDER BY " + strings.Join(sortClauses, ", "), nil
+}
+
+// scanRecords scans all rows into records without normalising them
+func scanRecords(rows *sqlx.Rows) ([]types.Record, error) {
+	var recs []types.Record
+	for rows.Next() {
+		rec := make(types.Record)
+		if err := rows.MapScan(rec); err != nil {
+			return nil, fmt.Errorf("failed to scan record into map: %v", err)
+		}
+
+		recs = append(recs, rec)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error processing rows: %w", err)
+	}
+
+	return recs, nil
+}
+
+// normaliseRecords normalises every record in place
+func normaliseRecords(recs []types.Record) {
+	for _, rec := range recs {
+		rec.Normalise()
+	}
+}
+
 // RawQueryOptions represents options for raw SQL queries
 type RawQueryOptions struct {
 	// Query is the SQL query string
@@ -126,6 +213,15 @@ type RawQueryOptions struct {
 	Args []any
 	// Pagination settings for the query
 	Pagination *Pagination
+	// Sort options applied on top of the query, required by cursor pagination
+	Sort []SortOption
+	// Cursor enables keyset pagination, takes precedence over Pagination
+	Cursor *Cursor
+	// CursorKey is a column unique to every row of the query, which the sort ends with so that
+	// every row has a unique position. It is required by cursor pagination.
+	CursorKey string
+	// SkipTotal skips the total count query
+	SkipTotal bool
 }
 
 // QueryBuilder creates a new query options builder
@@ -153,55 +249,153 @@ func (qo *RawQueryOptions) WithPagination(pagination Pagination) *RawQueryOption
 	return qo
 }
 
+// WithSort orders the results of the query.
+// The sort fields must be columns returned by the query.
+func (qo *RawQueryOptions) WithSort(sort ...SortOption) *RawQueryOptions {
+	qo.Sort = sort
+	return qo
+}
+
+// WithCursor adds keyset pagination to the query, driven by the sort options and
+// the key set with WithCursorKey.
+// The query is wrapped in a subquery, so it must not have its own LIMIT.
+func (qo *RawQueryOptions) WithCursor(after string, limit int) *RawQueryOptions {
+	qo.Cursor = &Cursor{
+		After: after,
+		Limit: limit,
+	}
+	return qo
+}
+
+// WithCursorKey sets the column unique to every row of the query used by cursor pagination,
+// e.g. the id of the rows of a table or a column of a unique index
+func (qo *RawQueryOptions) WithCursorKey(column string) *RawQueryOptions {
+	qo.CursorKey = column
+	return qo
+}
+
+// WithoutTotal skips the total count query
+func (qo *RawQueryOptions) WithoutTotal() *RawQueryOptions {
+	qo.SkipTotal = true
+	return qo
+}
+
 // Execute executes the query with the configured options
 func (qo *RawQueryOptions) Execute(ctx context.Context, s *Store) (*List, error) {
-	query := qo.Query
+	return qo.execute(ctx, s.reads())
+}
 
-	// Apply pagination if provided
-	if qo.Pagination != nil && qo.Pagination.Limit > 0 {
-		query = fmt.Sprintf("%s LIMIT %d OFFSET %d", query, qo.Pagination.Limit, qo.Pagination.Offset)
-	}
+// ExecuteTx executes the query with the configured options within a transaction
+func (qo *RawQueryOptions) ExecuteTx(ctx context.Context, tx *StoreTx) (*List, error) {
+	return qo.execute(ctx, tx.tx)
+}
 
-	rows, err := s.db.QueryxContext(ctx, query, qo.Args...)
-	if err != nil {
-		return nil, fmt.Errorf("query execution failed: %w", err)
-	}
-	defer rows.Close()
+func (qo *RawQueryOptions) execute(ctx context.Context, db dbInterface) (*List, error) {
+	meta := Metadata{}
 
-	var recs []types.Record
-	for rows.Next() {
-		rec := make(types.Record)
-		if err := rows.MapScan(rec); err != nil {
-			return nil, fmt.Errorf("failed to scan record into map: %v", err)
+	// If pagination is used, get the total count of the unpaginated query
+	paginated := qo.Cursor != nil || (qo.Pagination != nil && qo.Pagination.Limit > 0)
+	if paginated && !qo.SkipTotal {
+		countQuery := fmt.Sprintf("SELECT COUNT(*) FROM (%s) AS count_query", qo.Query)
+		if err := db.GetContext(ctx, &meta.Total, countQuery, qo.Args...); err != nil {
+			return nil, fmt.Errorf("failed to get total count: %w", err)
 		}
-		rec.Normalise()
+	}
 
-		recs = append(recs, rec)
+	if qo.Cursor != nil {
+		return qo.executeWithCursor(ctx, db, meta)
 	}
-	if err = rows.Err(); err != nil {
-		return nil, fmt.Errorf("error processing rows: %v", err)
+
+	query := qo.Query
+
+	if len(qo.Sort) > 0 {
+		orderBy, err := buildOrderBy(qo.Sort)
+		if err != nil {
+			return nil, err
+		}
+		query = fmt.Sprintf("SELECT * FROM (%s) AS raw_query%s", query, orderBy)
 	}
 
-	// Create metadata if pagination was used
-	meta := Metadata{}
+	// Apply pagination if provided
 	if qo.Pagination != nil {
 		meta.Limit = qo.Pagination.Limit
 		meta.Offset = qo.Pagination.Offset
 
-		// If pagination is used, try to get the total count
-		// This assumes the query doesn't already have COUNT or GROUP BY
-		// For complex queries, the user would need to handle counting separately
 		if qo.Pagination.Limit > 0 {
-			countQuery := fmt.Sprintf("SELECT COUNT(*) FROM (%s) as count_query",
-				strings.Replace(qo.Query, "SELECT * FROM", "SELECT 1 FROM", 1))
-			var total int
-			err := s.QueryValue(ctx, countQuery, &total, qo.Args...)
-			if err == nil {
-				meta.Total = total
+			query = fmt.Sprintf("%s LIMIT %d OFFSET %d", query, qo.Pagination.Limit, qo.Pagination.Offset)
+			if !qo.SkipTotal {
+				meta.TotalPages = int(math.Ceil(float64(meta.Total) / float64(qo.Pagination.Limit)))
 			}
 		}
 	}
 
+	rows, err := db.QueryxContext(ctx, query, qo.Args...)
+	if err != nil {
+		return nil, fmt.Errorf("query execution failed: %w", err)
+	}
+	defer rows.Close()
+
+	recs, err := scanRecords(rows)
+	if err != nil {
+		return nil, err
+	}
+	normaliseRecords(recs)
+
+	return &List{
+		Records: recs,
+		Meta:    meta,
+	}, nil
+}
+
+// executeWithCursor executes the query using keyset pagination
+func (qo *RawQueryOptions) executeWithCursor(ctx context.Context, db dbInterface, meta Metadata) (*List, error) {
+	if qo.Cursor.Limit <= 0 {
+		return nil, fmt.Errorf("cursor limit must be greater than zero")
+	}
+
+	// The rows of a query have no known unique column, unlike the id of a collection
+	if qo.CursorKey == "" {
+		return nil, fmt.Errorf("cursor pagination of a raw query requires a unique cursor key, see WithCursorKey")
+	}
+	sort, err := cursorSort(qo.Sort, qo.CursorKey)
+	if err != nil {
+		return nil, err
+	}
+
+	token, err := decodeCursor(qo.Cursor.After, sort)
+	if err != nil {
+		return nil, err
+	}
+
+	where := &whereBuilder{args: append([]any{}, qo.Args...)}
+	backward := false
+	if token != nil {
+		backward = token.Backward
+		clause, args := buildKeysetCondition(sort, token.Values, backward, where.nextIdx())
+		where.add(clause, args...)
+	}
+
+	// Fetch one extra row to find out whether there is another page
+	query := fmt.Sprintf("SELECT * FROM (%s) AS cursor_query", qo.Query) + where.String() +
+		buildCursorOrderBy(sort, backward) +
+		fmt.Sprintf(" LIMIT %d", qo.Cursor.Limit+1)
+
+	rows, err := db.QueryxContext(ctx, query, where.args...)
+	if err != nil {
+		return nil, fmt.Errorf("query execution failed: %w", err)
+	}
+	defer rows.Close()
+
+	recs, err := scanRecords(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	recs, err = cursorPage(recs, sort, qo.Cursor.Limit, token, &meta)
+	if err != nil {
+		return nil, err
+	}
+
 	return &List{
 		Records: recs,
 		Meta:    meta,
@@ -0,0 +1,157 @@
+package store
+
+import (
+	"fmt"
+	"sync"
+)
+
+// tableRegistry holds the options of the tables, which the stores apply to their collections
+type tableRegistry struct {
+	mu               sync.RWMutex
+	softDeleteTables map[string]bool
+	schemas          map[string]*Schema
+	autoFields       map[string]*AutoFields
+	relations        map[string]map[string]Relation
+	searchIndexes    map[string]SearchIndex
+}
+
+// EnableSoftDelete turns on soft delete for the tables.
+// Deletes then set the deleted_at column instead of removing the rows,
+// and reads exclude deleted rows unless WithDeleted or OnlyDeleted is passed.
+func (r *tableRegistry) EnableSoftDelete(tables ...string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.softDeleteTables == nil {
+		r.softDeleteTables = map[string]bool{}
+	}
+	for _, table := range tables {
+		if !ValidTableName(table) {
+			panic(fmt.Sprintf("invalid table name: %s", table))
+		}
+		r.softDeleteTables[table] = true
+	}
+}
+
+// SoftDeleteEnabled reports whether soft delete is enabled for the table
+func (r *tableRegistry) SoftDeleteEnabled(table string) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return r.softDeleteTables[table]
+}
+
+// RegisterSchema validates the creates and updates of the schema table against it.
+// Values are converted to the field types and invalid records are rejected with a ValidationErr.
+// It panics when the schema is invalid.
+func (r *tableRegistry) RegisterSchema(schema *Schema) {
+	if err := schema.Validate(); err != nil {
+		panic(fmt.Sprintf("invalid schema: %s", err))
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.schemas == nil {
+		r.schemas = map[string]*Schema{}
+	}
+	r.schemas[schema.Table] = schema
+}
+
+// Schema returns the schema registered for the table, or nil
+func (r *tableRegistry) Schema(table string) *Schema {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return r.schemas[table]
+}
+
+// EnableAutoFields makes the collections of the tables set the auto fields on writes,
+// e.g. EnableAutoFields(StandardAutoFields, "invoice") generates the ids, sets created_at
+// on insert and bumps updated_at on every update of the invoice table.
+func (r *tableRegistry) EnableAutoFields(fields AutoFields, tables ...string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.autoFields == nil {
+		r.autoFields = map[string]*AutoFields{}
+	}
+	for _, table := range tables {
+		if !ValidTableName(table) {
+			panic(fmt.Sprintf("invalid table name: %s", table))
+		}
+		r.autoFields[table] = &fields
+	}
+}
+
+// AutoFields returns the auto fields enabled for the table, nil when none are
+func (r *tableRegistry) AutoFields(table string) *AutoFields {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return r.autoFields[table]
+}
+
+// RegisterRelations declares the relations of the table, so that WithExpand can load them.
+// Collections scoped to an organisation only expand the related records of the organisation,
+// see Relation.TenantKey and Relation.Unscoped. It panics when a relation is invalid.
+func (r *tableRegistry) RegisterRelations(table string, relations ...Relation) {
+	if !ValidTableName(table) {
+		panic(fmt.Sprintf("invalid table name: %s", table))
+	}
+	for _, relation := range relations {
+		if err := relation.validate(); err != nil {
+			panic(fmt.Sprintf("invalid relation of %s: %s", table, err))
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.relations == nil {
+		r.relations = map[string]map[string]Relation{}
+	}
+	if r.relations[table] == nil {
+		r.relations[table] = map[string]Relation{}
+	}
+	for _, relation := range relations {
+		r.relations[table][relation.Name] = relation
+	}
+}
+
+// Relation returns the relation of the table with the given name
+func (r *tableRegistry) Relation(table, name string) (Relation, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	relation, ok := r.relations[table][name]
+	return relation, ok
+}
+
+// RegisterSearch declares the search index of the table, so that WithSearch can query it.
+// Create the GIN index of the columns with SearchIndexSQL. It panics when the index is invalid.
+func (r *tableRegistry) RegisterSearch(table string, index SearchIndex) {
+	if !ValidTableName(table) {
+		panic(fmt.Sprintf("invalid table name: %s", table))
+	}
+	if err := index.Validate(); err != nil {
+		panic(fmt.Sprintf("invalid search index of %s: %s", table, err))
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.searchIndexes == nil {
+		r.searchIndexes = map[string]SearchIndex{}
+	}
+	r.searchIndexes[table] = index
+}
+
+// SearchIndex returns the search index registered for the table
+func (r *tableRegistry) SearchIndex(table string) (SearchIndex, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	index, ok := r.searchIndexes[table]
+	return index, ok
+}
@@ -0,0 +1,289 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/tuongaz/go-saas/store/types"
+)
+
+const (
+	// maxExpandDepth bounds the nesting of expanded relations
+	maxExpandDepth = 4
+
+	// expandKeyColumn is the extra column returned by relation queries to match the related records
+	expandKeyColumn = "_expand_key"
+)
+
+// RelationKind is the kind of a relation between the records of two tables
+type RelationKind int
+
+const (
+	// BelongsTo relations hold the id of the related record in their ForeignKey column,
+	// e.g. organisation_account_role.account_id references an account
+	BelongsTo RelationKind = iota
+
+	// HasMany relations are referenced by the ForeignKey column of the related records,
+	// e.g. the organisation_account_role.organisation_id of the members of an organisation
+	HasMany
+
+	// ManyToMany relations are linked through the Through join table, whose ForeignKey column
+	// references the record and OtherKey column references the related record
+	ManyToMany
+)
+
+// Relation describes how the records of a table relate to the records of another table.
+// Related records are matched on their id column.
+type Relation struct {
+	// Name is the field the related records are nested under
+	Name string
+	Kind RelationKind
+
+	// Table is the related table
+	Table string
+
+	ForeignKey string
+	Through    string
+	OtherKey   string
+
+	// TenantKey is the column holding the organisation of the related records, or of the rows
+	// of the join table for many to many relations. The collections scoped to an organisation
+	// only expand the related records of their organisation, so they can only expand the
+	// relations with a tenant key, or the Unscoped ones.
+	TenantKey string
+
+	// Unscoped marks the related table as not owned by an organisation, e.g. account, so that
+	// the collections scoped to an organisation can expand the relation without a tenant key
+	Unscoped bool
+}
+
+func (r Relation) validate() error {
+	if !ValidIdentifierName(r.Name) || strings.Contains(r.Name, ".") {
+		return fmt.Errorf("invalid relation name: %s", r.Name)
+	}
+	if !ValidTableName(r.Table) {
+		return fmt.Errorf("relation %s: invalid table name: %s", r.Name, r.Table)
+	}
+	if !ValidIdentifierName(r.ForeignKey) {
+		return fmt.Errorf("relation %s: invalid foreign key: %s", r.Name, r.ForeignKey)
+	}
+
+	if r.TenantKey != "" && !ValidIdentifierName(r.TenantKey) {
+		return fmt.Errorf("relation %s: invalid tenant key: %s", r.Name, r.TenantKey)
+	}
+	if r.TenantKey != "" && r.Unscoped {
+		return fmt.Errorf("relation %s: an unscoped relation cannot have a tenant key", r.Name)
+	}
+
+	switch r.Kind {
+	case BelongsTo, HasMany:
+	case ManyToMany:
+		if !ValidTableName(r.Through) {
+			return fmt.Errorf("relation %s: invalid join table: %s", r.Name, r.Through)
+		}
+		if !ValidIdentifierName(r.OtherKey) {
+			return fmt.Errorf("relation %s: invalid other key: %s", r.Name, r.OtherKey)
+		}
+	default:
+		return fmt.Errorf("relation %s: unknown kind %d", r.Name, r.Kind)
+	}
+
+	return nil
+}
+
+// RelationResolver looks up the relations used to expand records
+type RelationResolver interface {
+	Relation(table, name string) (Relation, bool)
+	SoftDeleteEnabled(table string) bool
+}
+
+// WithRelations makes the relations of the resolver available to WithExpand
+func WithRelations(resolver RelationResolver) CollectionOption {
+	return func(c *collection) {
+		c.relations = resolver
+	}
+}
+
+// expandTree is the tree of the relations to expand, e.g. members.account gives {members: {account: {}}}
+type expandTree map[string]expandTree
+
+func parseExpandPaths(paths []string) expandTree {
+	tree := expandTree{}
+	for _, path := range paths {
+		node := tree
+		for _, name := range strings.Split(path, ".") {
+			name = strings.TrimSpace(name)
+			if node[name] == nil {
+				node[name] = expandTree{}
+			}
+			node = node[name]
+		}
+	}
+	return tree
+}
+
+// expand loads the related records of the paths and nests them in the records
+func (c *collection) expand(ctx context.Context, records []types.Record, paths []string) error {
+	if len(paths) == 0 || len(records) == 0 {
+		return nil
+	}
+	if c.relations == nil {
+		return fmt.Errorf("expand %s: no relations are registered", c.table)
+	}
+
+	return c.expandRelations(ctx, c.table, records, parseExpandPaths(paths), 1)
+}
+
+// expandRelations expands the relations of the records
+func (c *collection) expandRelations(ctx context.Context, table string, records []types.Record, tree expandTree, depth int) error {
+	if depth > maxExpandDepth {
+		return fmt.Errorf("expand %s: at most %d levels of relations can be expanded", table, maxExpandDepth)
+	}
+
+	for _, name := range slices.Sorted(maps.Keys(tree)) {
+		relation, ok := c.relations.Relation(table, name)
+		if !ok {
+			return fmt.Errorf("expand %s: unknown relation %s", table, name)
+		}
+		// A foreign key can be written by clients, so even the record a belongs to relation
+		// references is only expanded from the organisation of the collection
+		if c.organisationID != "" && relation.TenantKey == "" && !relation.Unscoped {
+			return NewTenantErr(fmt.Errorf("expand %s of %s: the relation has no tenant key to scope it to the organisation", name, table))
+		}
+
+		related, err := c.loadRelation(ctx, relation, records)
+		if err != nil {
+			return fmt.Errorf("expand %s of %s: %w", name, table, err)
+		}
+
+		if len(tree[name]) > 0 && len(related) > 0 {
+			if err := c.expandRelations(ctx, relation.Table, related, tree[name], depth+1); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// loadRelation loads the related records of all the records with a single query,
+// nests them in the records and returns them
+func (c *collection) loadRelation(ctx context.Context, relation Relation, records []types.Record) ([]types.Record, error) {
+	keyColumn := relationKeyColumn(relation)
+	keys := make([]any, 0, len(records))
+	seen := map[string]bool{}
+	for _, record := range records {
+		key, ok := record[keyColumn]
+		if !ok {
+			return nil, fmt.Errorf("the %s field is required to expand %s", keyColumn, relation.Name)
+		}
+		if key == nil || seen[fmt.Sprint(key)] {
+			continue
+		}
+		seen[fmt.Sprint(key)] = true
+		keys = append(keys, key)
+	}
+
+	var related []types.Record
+	if len(keys) > 0 {
+		load := c.queryRelated
+		if c.loadRelated != nil {
+			load = c.loadRelated
+		}
+
+		var err error
+		if related, err = load(ctx, relation, keys); err != nil {
+			return nil, err
+		}
+	}
+
+	nestRelated(relation, records, related)
+	return related, nil
+}
+
+// queryRelated queries the records related to the keys, with the key they relate to in the expand key column.
+// The related records of a tenant scoped collection are those of its organisation.
+func (c *collection) queryRelated(ctx context.Context, relation Relation, keys []any) ([]types.Record, error) {
+	dialect := c.sqlDialect()
+	query := relationQuery(dialect, relation, c.relations.SoftDeleteEnabled(relation.Table), c.scopesRelation(relation))
+	args := []any{dialect.Array(keys)}
+	if c.scopesRelation(relation) {
+		args = append(args, c.organisationID)
+	}
+	return c.readRecords(ctx, query, args...)
+}
+
+// scopesRelation tells whether the related records are filtered on the organisation of the collection
+func (c *collection) scopesRelation(relation Relation) bool {
+	return c.organisationID != "" && relation.TenantKey != ""
+}
+
+// relationKeyColumn is the column of the records matched against the related records
+func relationKeyColumn(relation Relation) string {
+	if relation.Kind == BelongsTo {
+		return relation.ForeignKey
+	}
+	return idColumn
+}
+
+// relationQuery selects the related records of the keys given in $1 as an array of the dialect,
+// with the key they relate to in the expand key column. When tenantScoped is set, the related
+// records are those of the organisation given in $2.
+func relationQuery(dialect Dialect, relation Relation, softDelete, tenantScoped bool) string {
+	var query string
+	switch relation.Kind {
+	case BelongsTo:
+		query = fmt.Sprintf("SELECT r.*, r.%s AS %s FROM %s r WHERE %s",
+			idColumn, expandKeyColumn, relation.Table, dialect.InArray("r."+idColumn, 1))
+	case HasMany:
+		query = fmt.Sprintf("SELECT r.*, r.%s AS %s FROM %s r WHERE %s",
+			relation.ForeignKey, expandKeyColumn, relation.Table, dialect.InArray("r."+relation.ForeignKey, 1))
+	case ManyToMany:
+		query = fmt.Sprintf("SELECT r.*, j.%s AS %s FROM %s r JOIN %s j ON j.%s = r.%s WHERE %s",
+			relation.ForeignKey, expandKeyColumn, relation.Table, relation.Through, relation.OtherKey, idColumn, dialect.InArray("j."+relation.ForeignKey, 1))
+	}
+
+	if softDelete {
+		query += " AND r." + deletedAtColumn + " IS NULL"
+	}
+	if tenantScoped {
+		alias := "r"
+		if relation.Kind == ManyToMany {
+			alias = "j"
+		}
+		query += fmt.Sprintf(" AND %s.%s = $2", alias, relation.TenantKey)
+	}
+	return query + " ORDER BY r." + idColumn
+}
+
+// nestRelated nests the related records in the records they relate to.
+// Belongs to relations are nested as a record or nil, the others as a list of records.
+func nestRelated(relation Relation, records []types.Record, related []types.Record) {
+	byKey := map[string][]types.Record{}
+	for _, record := range related {
+		key := fmt.Sprint(record[expandKeyColumn])
+		delete(record, expandKeyColumn)
+		byKey[key] = append(byKey[key], record)
+	}
+
+	keyColumn := relationKeyColumn(relation)
+	for _, record := range records {
+		var matches []types.Record
+		if key := record[keyColumn]; key != nil {
+			matches = byKey[fmt.Sprint(key)]
+		}
+
+		if relation.Kind == BelongsTo {
+			if len(matches) > 0 {
+				record[relation.Name] = matches[0]
+			} else {
+				record[relation.Name] = nil
+			}
+			continue
+		}
+		record[relation.Name] = append([]types.Record{}, matches...)
+	}
+}
@@ -0,0 +1,166 @@
+package store
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/tuongaz/go-saas/store/types"
+)
+
+var (
+	membersRelation = Relation{Name: "members", Kind: HasMany, Table: "organisation_account_role", ForeignKey: "organisation_id"}
+	accountRelation = Relation{Name: "account", Kind: BelongsTo, Table: "account", ForeignKey: "account_id"}
+	tagsRelation    = Relation{Name: "tags", Kind: ManyToMany, Table: "tag", Through: "project_tag", ForeignKey: "project_id", OtherKey: "tag_id"}
+)
+
+func TestParseExpandPaths(t *testing.T) {
+	assert.Equal(t, expandTree{
+		"members": {"account": {}, "organisation": {}},
+		"owner":   {},
+	}, parseExpandPaths([]string{"members.account", "owner", "members.organisation"}))
+}
+
+func TestRelationQuery(t *testing.T) {
+	assert.Equal(t,
+		"SELECT r.*, r.id AS _expand_key FROM account r WHERE r.id = ANY($1) ORDER BY r.id",
+		relationQuery(Postgres, accountRelation, false, false))
+	assert.Equal(t,
+		"SELECT r.*, r.organisation_id AS _expand_key FROM organisation_account_role r WHERE r.organisation_id = ANY($1) AND r.deleted_at IS NULL ORDER BY r.id",
+		relationQuery(Postgres, membersRelation, true, false))
+	assert.Equal(t,
+		"SELECT r.*, j.project_id AS _expand_key FROM tag r JOIN project_tag j ON j.tag_id = r.id WHERE j.project_id = ANY($1) ORDER BY r.id",
+		relationQuery(Postgres, tagsRelation, false, false))
+
+	scoped := membersRelation
+	scoped.TenantKey = "organisation_id"
+	assert.Equal(t,
+		"SELECT r.*, r.organisation_id AS _expand_key FROM organisation_account_role r WHERE r.organisation_id = ANY($1) AND r.organisation_id = $2 ORDER BY r.id",
+		relationQuery(Postgres, scoped, false, true))
+	scoped = tagsRelation
+	scoped.TenantKey = "organisation_id"
+	assert.Equal(t,
+		"SELECT r.*, j.project_id AS _expand_key FROM tag r JOIN project_tag j ON j.tag_id = r.id WHERE j.project_id = ANY($1) AND j.organisation_id = $2 ORDER BY r.id",
+		relationQuery(Postgres, scoped, false, true))
+}
+
+func TestNestRelated(t *testing.T) {
+	t.Run("belongs to", func(t *testing.T) {
+		members := []types.Record{
+			{"id": "m1", "account_id": "a1"},
+			{"id": "m2", "account_id": "a2"},
+			{"id": "m3", "account_id": nil},
+		}
+		nestRelated(accountRelation, members, []types.Record{
+			{"id": "a1", "name": "Ann", expandKeyColumn: "a1"},
+		})
+
+		assert.Equal(t, types.Record{"id": "a1", "name": "Ann"}, members[0]["account"])
+		assert.Nil(t, members[1]["account"])
+		assert.Nil(t, members[2]["account"])
+	})
+
+	t.Run("has many", func(t *testing.T) {
+		organisations := []types.Record{{"id": "o1"}, {"id": "o2"}}
+		nestRelated(membersRelation, organisations, []types.Record{
+			{"id": "m1", "organisation_id": "o1", expandKeyColumn: "o1"},
+			{"id": "m2", "organisation_id": "o1", expandKeyColumn: "o1"},
+		})
+
+		assert.Equal(t, []types.Record{
+			{"id": "m1", "organisation_id": "o1"},
+			{"id": "m2", "organisation_id": "o1"},
+		}, organisations[0]["members"])
+		assert.Equal(t, []types.Record{}, organisations[1]["members"])
+	})
+
+	t.Run("many to many", func(t *testing.T) {
+		projects := []types.Record{{"id": int64(1)}, {"id": int64(2)}}
+		nestRelated(tagsRelation, projects, []types.Record{
+			{"id": "t1", expandKeyColumn: int64(1)},
+			{"id": "t1", expandKeyColumn: int64(2)},
+		})
+
+		assert.Equal(t, []types.Record{{"id": "t1"}}, projects[0]["tags"])
+		assert.Equal(t, []types.Record{{"id": "t1"}}, projects[1]["tags"])
+	})
+}
+
+func TestNestRelated_ScanStruct(t *testing.T) {
+	type account struct {
+		Name string `db:"name"`
+	}
+	type member struct {
+		ID      string   `db:"id"`
+		Account *account `db:"-" relation:"account"`
+	}
+
+	records := []types.Record{{"id": "m1", "account_id": "a1"}}
+	nestRelated(accountRelation, records, []types.Record{{"id": "a1", "name": "Ann", expandKeyColumn: "a1"}})
+
+	var m member
+	assert.NoError(t, records[0].ScanStruct(&m))
+	assert.Equal(t, member{ID: "m1", Account: &account{Name: "Ann"}}, m)
+
+	// The relation is never written
+	record, err := types.RecordFromStruct(m)
+	assert.NoError(t, err)
+	assert.Equal(t, types.Record{"id": "m1"}, record)
+}
+
+func TestStore_RegisterRelations(t *testing.T) {
+	s := &Store{}
+	s.RegisterRelations("organisation", membersRelation)
+
+	relation, ok := s.Relation("organisation", "members")
+	assert.True(t, ok)
+	assert.Equal(t, membersRelation, relation)
+
+	_, ok = s.Relation("organisation", "owner")
+	assert.False(t, ok)
+
+	assert.Panics(t, func() {
+		s.RegisterRelations("project", Relation{Name: "tags", Kind: ManyToMany, Table: "tag", ForeignKey: "project_id"})
+	})
+	assert.Panics(t, func() {
+		s.RegisterRelations("project", Relation{Name: "owner.account", Table: "account", ForeignKey: "owner_id"})
+	})
+	assert.Panics(t, func() {
+		s.RegisterRelations("project", Relation{Name: "owner", Table: "account", ForeignKey: "owner_id", TenantKey: "organisation_id", Unscoped: true})
+	})
+}
+
+func TestCollection_ExpandErrors(t *testing.T) {
+	ctx := context.Background()
+	s := &Store{}
+	s.RegisterRelations("organisation_account_role", accountRelation)
+	c := &collection{table: "organisation_account_role", relations: s}
+
+	err := c.expand(ctx, []types.Record{{"id": "m1"}}, []string{"organisation"})
+	assert.EqualError(t, err, "expand organisation_account_role: unknown relation organisation")
+
+	err = c.expand(ctx, []types.Record{{"id": "m1"}}, []string{"account"})
+	assert.EqualError(t, err, "expand account of organisation_account_role: the account_id field is required to expand account")
+
+	// Nothing to load
+	records := []types.Record{{"id": "m1", "account_id": nil}}
+	assert.NoError(t, c.expand(ctx, records, []string{"account"}))
+	assert.Nil(t, records[0]["account"])
+
+	err = (&collection{table: "organisation"}).expand(ctx, records, []string{"members"})
+	assert.EqualError(t, err, "expand organisation: no relations are registered")
+
+	t.Run("tenant scoped", func(t *testing.T) {
+		// The account_id of a member could reference an account of another organisation
+		scoped := &collection{table: "organisation_account_role", relations: s, organisationID: "org1"}
+		err := scoped.expand(ctx, []types.Record{{"id": "m1", "account_id": "a1"}}, []string{"account"})
+		assert.True(t, IsTenantError(err))
+
+		unscoped := accountRelation
+		unscoped.Unscoped = true
+		s.RegisterRelations("organisation_account_role", unscoped)
+		records := []types.Record{{"id": "m1", "account_id": nil}}
+		assert.NoError(t, scoped.expand(ctx, records, []string{"account"}))
+	})
+}
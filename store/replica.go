@@ -0,0 +1,299 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+
+	"github.com/tuongaz/go-saas/pkg/log"
+)
+
+const (
+	defaultHealthCheckInterval = 5 * time.Second
+	healthCheckTimeout         = 2 * time.Second
+)
+
+type primaryCtxKey struct{}
+
+// WithPrimary pins the reads made with the context to the primary, e.g. to read a record
+// right after writing it, before the replicas catch up
+func WithPrimary(ctx context.Context) context.Context {
+	return context.WithValue(ctx, primaryCtxKey{}, true)
+}
+
+func usePrimary(ctx context.Context) bool {
+	pinned, _ := ctx.Value(primaryCtxKey{}).(bool)
+	return pinned
+}
+
+// WithReplicas routes the reads made outside transactions to the read replicas of the data sources.
+// Replicas are picked in turn among the healthy ones, and reads fail over to the primary
+// when no replica is healthy or a replica connection fails.
+func WithReplicas(datasources ...string) Option {
+	return func(o *options) {
+		o.replicas = append(o.replicas, datasources...)
+	}
+}
+
+// WithHealthCheckInterval sets how often the replicas are pinged, it defaults to 5 seconds
+func WithHealthCheckInterval(interval time.Duration) Option {
+	return func(o *options) {
+		o.healthCheckInterval = interval
+	}
+}
+
+// ReadStats counts the reads routed by the store
+type ReadStats struct {
+	// Primary is the number of reads served by the primary, including the failovers
+	Primary uint64
+	// Failovers is the number of reads that were meant for a replica but went to the primary
+	Failovers uint64
+	Replicas  []ReplicaStats
+}
+
+// ReplicaStats is the state of a read replica
+type ReplicaStats struct {
+	Name    string
+	Healthy bool
+	Reads   uint64
+}
+
+type replica struct {
+	name    string
+	db      *sqlx.DB
+	healthy atomic.Bool
+	reads   atomic.Uint64
+}
+
+// readRouter serves the read queries from the replicas, see WithReplicas.
+// Exec always goes to the primary.
+type readRouter struct {
+	primary  *sqlx.DB
+	replicas []*replica
+
+	next         atomic.Uint64
+	primaryReads atomic.Uint64
+	failovers    atomic.Uint64
+
+	stop chan struct{}
+	wg   sync.WaitGroup
+}
+
+var _ dbInterface = (*readRouter)(nil)
+
+// newReadRouter opens the replicas and checks their health. Replicas that cannot be reached
+// start unhealthy, so that the store starts while a replica is down.
+func newReadRouter(primary *sqlx.DB, datasources []string, o *options) (*readRouter, error) {
+	r := &readRouter{primary: primary, stop: make(chan struct{})}
+	for i, datasource := range datasources {
+		db, _, err := o.open(datasource)
+		if err != nil {
+			r.close()
+			return nil, fmt.Errorf("unable to open replica %d: %w", i+1, err)
+		}
+		r.replicas = append(r.replicas, &replica{name: fmt.Sprintf("replica-%d", i+1), db: db})
+	}
+
+	r.checkHealth(context.Background())
+	return r, nil
+}
+
+// start checks the health of the replicas every interval until the router is closed
+func (r *readRouter) start(interval time.Duration) {
+	r.wg.Add(1)
+	go func() {
+		defer r.wg.Done()
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-r.stop:
+				return
+			case <-ticker.C:
+				r.checkHealth(context.Background())
+			}
+		}
+	}()
+}
+
+func (r *readRouter) checkHealth(ctx context.Context) {
+	for _, rep := range r.replicas {
+		pingCtx, cancel := context.WithTimeout(ctx, healthCheckTimeout)
+		err := rep.db.PingContext(pingCtx)
+		cancel()
+
+		if err != nil {
+			r.markUnhealthy(rep, err)
+			continue
+		}
+		if !rep.healthy.Swap(true) {
+			log.Info("read replica is healthy", "replica", rep.name)
+		}
+	}
+}
+
+func (r *readRouter) markUnhealthy(rep *replica, err error) {
+	if rep.healthy.Swap(false) {
+		log.Default().Warn("read replica is unhealthy, reads fail over to the primary", "replica", rep.name, log.ErrorAttr(err))
+	}
+}
+
+func (r *readRouter) close() {
+	select {
+	case <-r.stop:
+	default:
+		close(r.stop)
+	}
+	r.wg.Wait()
+
+	for _, rep := range r.replicas {
+		_ = rep.db.Close()
+	}
+}
+
+// pick returns the next healthy replica, or nil to read from the primary
+func (r *readRouter) pick(ctx context.Context) *replica {
+	if usePrimary(ctx) {
+		log.DebugContext(ctx, "store read routed", "target", "primary", "reason", "pinned")
+		return nil
+	}
+
+	n := uint64(len(r.replicas))
+	start := r.next.Add(1) - 1
+	for i := uint64(0); i < n; i++ {
+		rep := r.replicas[(start+i)%n]
+		if rep.healthy.Load() {
+			log.DebugContext(ctx, "store read routed", "target", rep.name)
+			return rep
+		}
+	}
+
+	r.failovers.Add(1)
+	log.DebugContext(ctx, "store read routed", "target", "primary", "reason", "no healthy replica")
+	return nil
+}
+
+// route runs the read on a replica, retrying on the primary when the replica connection fails
+func (r *readRouter) route(ctx context.Context, read func(db *sqlx.DB) error) error {
+	rep := r.pick(ctx)
+	if rep == nil {
+		r.primaryReads.Add(1)
+		return read(r.primary)
+	}
+
+	err := read(rep.db)
+	if err != nil && isConnectionError(err) && ctx.Err() == nil {
+		r.markUnhealthy(rep, err)
+		r.failovers.Add(1)
+		r.primaryReads.Add(1)
+		log.Default().WarnContext(ctx, "read replica query failed, retrying on the primary", "replica", rep.name, log.ErrorAttr(err))
+		return read(r.primary)
+	}
+
+	rep.reads.Add(1)
+	return err
+}
+
+func (r *readRouter) GetContext(ctx context.Context, dest any, query string, args ...any) error {
+	return r.route(ctx, func(db *sqlx.DB) error {
+		return db.GetContext(ctx, dest, query, args...)
+	})
+}
+
+func (r *readRouter) SelectContext(ctx context.Context, dest any, query string, args ...any) error {
+	return r.route(ctx, func(db *sqlx.DB) error {
+		return db.SelectContext(ctx, dest, query, args...)
+	})
+}
+
+func (r *readRouter) QueryxContext(ctx context.Context, query string, args ...any) (*sqlx.Rows, error) {
+	var rows *sqlx.Rows
+	err := r.route(ctx, func(db *sqlx.DB) error {
+		var err error
+		rows, err = db.QueryxContext(ctx, query, args...)
+		return err
+	})
+	return rows, err
+}
+
+func (r *readRouter) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	return r.primary.ExecContext(ctx, query, args...)
+}
+
+func (r *readRouter) stats() ReadStats {
+	stats := ReadStats{
+		Primary:   r.primaryReads.Load(),
+		Failovers: r.failovers.Load(),
+		Replicas:  make([]ReplicaStats, len(r.replicas)),
+	}
+	for i, rep := range r.replicas {
+		stats.Replicas[i] = ReplicaStats{Name: rep.name, Healthy: rep.healthy.Load(), Reads: rep.reads.Load()}
+	}
+	return stats
+}
+
+// isConnectionError reports whether the error comes from the connection rather than the query
+func isConnectionError(err error) bool {
+	if errors.Is(err, driver.ErrBadConn) || errors.Is(err, sql.ErrConnDone) {
+		return true
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+
+	// Connection exceptions and the server shutting down or starting up
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		switch pqErr.Code {
+		case "57P01", "57P02", "57P03":
+			return true
+		}
+		return pqErr.Code.Class() == "08"
+	}
+
+	return false
+}
+
+// reads returns the connection of the read queries, the read replicas when configured
+func (s *Store) reads() dbInterface {
+	if s.reader != nil {
+		return s.reader
+	}
+	return s.db
+}
+
+// ReadStats returns the number of reads served by the primary and each read replica
+func (s *Store) ReadStats() ReadStats {
+	if s.reader == nil {
+		return ReadStats{}
+	}
+	return s.reader.stats()
+}
+
+// withReader makes the collection run its read queries on the reader
+func withReader(reader dbInterface) CollectionOption {
+	return func(c *collection) {
+		c.reader = reader
+	}
+}
+
+// reads returns the connection of the read queries
+func (c *collection) reads() dbInterface {
+	if c.reader != nil {
+		return c.reader
+	}
+	return c.db
+}
@@ -0,0 +1,112 @@
+package store
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/tuongaz/go-saas/store/types"
+)
+
+// newTestRouter returns a router whose connections are opened but never used
+func newTestRouter(t *testing.T, healthy ...bool) *readRouter {
+	primary, err := sqlx.Open("postgres", "postgres://localhost/primary")
+	assert.NoError(t, err)
+
+	r := &readRouter{primary: primary, stop: make(chan struct{})}
+	for i, h := range healthy {
+		db, err := sqlx.Open("postgres", "postgres://localhost/replica")
+		assert.NoError(t, err)
+		rep := &replica{name: fmt.Sprintf("replica-%d", i+1), db: db}
+		rep.healthy.Store(h)
+		r.replicas = append(r.replicas, rep)
+	}
+	t.Cleanup(func() {
+		r.close()
+		_ = primary.Close()
+	})
+	return r
+}
+
+func TestReadRouter_Pick(t *testing.T) {
+	ctx := context.Background()
+	r := newTestRouter(t, true, false, true)
+
+	var picked []string
+	for i := 0; i < 4; i++ {
+		picked = append(picked, r.pick(ctx).name)
+	}
+	assert.Equal(t, []string{"replica-1", "replica-3", "replica-3", "replica-1"}, picked)
+
+	assert.Nil(t, r.pick(WithPrimary(ctx)))
+	assert.Zero(t, r.failovers.Load())
+
+	t.Run("fails over without healthy replica", func(t *testing.T) {
+		r := newTestRouter(t, false)
+		assert.Nil(t, r.pick(ctx))
+		assert.Equal(t, uint64(1), r.failovers.Load())
+	})
+}
+
+func TestReadRouter_Route(t *testing.T) {
+	ctx := context.Background()
+	r := newTestRouter(t, true)
+
+	var used []*sqlx.DB
+	err := r.route(ctx, func(db *sqlx.DB) error {
+		used = append(used, db)
+		if db != r.primary {
+			return driver.ErrBadConn
+		}
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []*sqlx.DB{r.replicas[0].db, r.primary}, used)
+	assert.Equal(t, ReadStats{
+		Primary:   1,
+		Failovers: 1,
+		Replicas:  []ReplicaStats{{Name: "replica-1", Healthy: false, Reads: 0}},
+	}, r.stats())
+
+	t.Run("query errors are not retried", func(t *testing.T) {
+		r := newTestRouter(t, true)
+		queryErr := &pq.Error{Code: "42703", Message: "column does not exist"}
+
+		calls := 0
+		err := r.route(ctx, func(db *sqlx.DB) error {
+			calls++
+			return queryErr
+		})
+		assert.ErrorIs(t, err, queryErr)
+		assert.Equal(t, 1, calls)
+		assert.Equal(t, ReadStats{Replicas: []ReplicaStats{{Name: "replica-1", Healthy: true, Reads: 1}}}, r.stats())
+	})
+}
+
+func TestIsConnectionError(t *testing.T) {
+	assert.True(t, isConnectionError(driver.ErrBadConn))
+	assert.True(t, isConnectionError(&pq.Error{Code: "08006"}))
+	assert.True(t, isConnectionError(&pq.Error{Code: "57P01"}))
+	assert.False(t, isConnectionError(&pq.Error{Code: "57014"}))
+	assert.False(t, isConnectionError(errors.New("record not found")))
+}
+
+func TestCollection_ReadsUseReader(t *testing.T) {
+	ctx := context.Background()
+	primary, reader := &execRecorder{}, &queryRecorder{}
+	c := NewCollection("invoice", primary, nil, withReader(reader))
+
+	_, err := c.Count(ctx, Filter{"status": "paid"})
+	assert.NoError(t, err)
+	assert.Equal(t, "SELECT COUNT(*) FROM invoice WHERE status = $1", reader.query)
+
+	_, err = c.Update(ctx, types.Record{"status": "void"}, "id", "inv_1")
+	assert.NoError(t, err)
+	assert.Equal(t, "UPDATE invoice SET status = $1 WHERE id = $2", primary.query)
+}
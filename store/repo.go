@@ -0,0 +1,153 @@
+package store
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/tuongaz/go-saas/store/types"
+)
+
+// Repo is a typed repository on top of a collection.
+// T is a struct whose fields are mapped to columns with db tags, e.g. `db:"name"`.
+// Use `db:"name,omitempty"` to leave zero values out of inserts and updates so that
+// the database defaults are used, and `db:"-"` to ignore a field. A field tagged with
+// `db:"-" relation:"name"` is read from the relation expanded with WithExpand and never written.
+// All methods go through the collection, so events, soft delete and options apply as usual.
+type Repo[T any] struct {
+	collection CollectionInterface
+}
+
+// NewRepo creates a typed repository for the collection
+func NewRepo[T any](collection CollectionInterface) *Repo[T] {
+	return &Repo[T]{collection: collection}
+}
+
+// Collection returns the underlying collection
+func (r *Repo[T]) Collection() CollectionInterface {
+	return r.collection
+}
+
+// Create inserts item and returns the created row
+func (r *Repo[T]) Create(ctx context.Context, item T) (*T, error) {
+	record, err := types.RecordFromStruct(item)
+	if err != nil {
+		return nil, fmt.Errorf("convert %s item to record: %w", r.collection.Table(), err)
+	}
+
+	created, err := r.collection.CreateRecord(ctx, record)
+	if err != nil {
+		return nil, err
+	}
+
+	return r.scan(created)
+}
+
+// CreateMany inserts items using multi-row inserts and returns the created rows
+func (r *Repo[T]) CreateMany(ctx context.Context, items []T) ([]T, error) {
+	records := make([]types.Record, 0, len(items))
+	for _, item := range items {
+		record, err := types.RecordFromStruct(item)
+		if err != nil {
+			return nil, fmt.Errorf("convert %s item to record: %w", r.collection.Table(), err)
+		}
+		records = append(records, record)
+	}
+
+	created, err := r.collection.CreateRecords(ctx, records)
+	if err != nil {
+		return nil, err
+	}
+
+	return r.scanAll(created)
+}
+
+// Get returns the row with the given id
+func (r *Repo[T]) Get(ctx context.Context, id any, opts ...FindOption) (*T, error) {
+	record, err := r.collection.GetRecord(ctx, id, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	return r.scan(record)
+}
+
+// FindOne returns the first row matching the filter
+func (r *Repo[T]) FindOne(ctx context.Context, filter Filter, opts ...FindOption) (*T, error) {
+	record, err := r.collection.FindOne(ctx, filter, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	return r.scan(record)
+}
+
+// Find returns the rows matching the options along with the pagination metadata
+func (r *Repo[T]) Find(ctx context.Context, opts ...FindOption) ([]T, Metadata, error) {
+	list, err := r.collection.Find(ctx, opts...)
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+
+	items, err := r.scanAll(list.Records)
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+
+	return items, list.Meta, nil
+}
+
+// Update writes all the mapped fields of item, except id, to the row with the given id
+func (r *Repo[T]) Update(ctx context.Context, id any, item T, opts ...UpdateOption) (*T, error) {
+	record, err := types.RecordFromStruct(item)
+	if err != nil {
+		return nil, fmt.Errorf("convert %s item to record: %w", r.collection.Table(), err)
+	}
+	delete(record, "id")
+
+	return r.Patch(ctx, id, record, opts...)
+}
+
+// Patch updates only the given columns of the row with the given id
+func (r *Repo[T]) Patch(ctx context.Context, id any, record types.Record, opts ...UpdateOption) (*T, error) {
+	updated, err := r.collection.UpdateRecord(ctx, id, record, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	return r.scan(updated)
+}
+
+// Delete deletes the row with the given id
+func (r *Repo[T]) Delete(ctx context.Context, id any) error {
+	return r.collection.DeleteRecord(ctx, id)
+}
+
+// Count returns the number of rows matching the filter
+func (r *Repo[T]) Count(ctx context.Context, filter Filter, opts ...FindOption) (int, error) {
+	return r.collection.Count(ctx, filter, opts...)
+}
+
+// Exists checks if any rows match the filter
+func (r *Repo[T]) Exists(ctx context.Context, filter Filter, opts ...FindOption) (bool, error) {
+	return r.collection.Exists(ctx, filter, opts...)
+}
+
+func (r *Repo[T]) scan(record *types.Record) (*T, error) {
+	item := new(T)
+	if err := record.ScanStruct(item); err != nil {
+		return nil, fmt.Errorf("scan %s record: %w", r.collection.Table(), err)
+	}
+
+	return item, nil
+}
+
+func (r *Repo[T]) scanAll(records []types.Record) ([]T, error) {
+	items := make([]T, len(records))
+	for i, record := range records {
+		if err := record.ScanStruct(&items[i]); err != nil {
+			return nil, fmt.Errorf("scan %s record: %w", r.collection.Table(), err)
+		}
+	}
+
+	return items, nil
+}
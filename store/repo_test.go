@@ -0,0 +1,111 @@
+package store
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tuongaz/go-saas/store/types"
+)
+
+type repoItem struct {
+	ID        string    `db:"id"`
+	Name      string    `db:"name"`
+	Version   int64     `db:"version,omitempty"`
+	CreatedAt time.Time `db:"created_at"`
+}
+
+// fakeCollection echoes the written records back as rows
+type fakeCollection struct {
+	CollectionInterface
+	created []types.Record
+	updated types.Record
+}
+
+func (f *fakeCollection) Table() string {
+	return "items"
+}
+
+func (f *fakeCollection) CreateRecord(_ context.Context, record types.Record) (*types.Record, error) {
+	f.created = append(f.created, record)
+	row := types.Record{"version": int64(1)}
+	for k, v := range record {
+		row[k] = v
+	}
+	row.Normalise()
+	return &row, nil
+}
+
+func (f *fakeCollection) UpdateRecord(_ context.Context, id any, record types.Record, _ ...UpdateOption) (*types.Record, error) {
+	f.updated = record
+	row := types.Record{"id": id, "version": int64(2)}
+	for k, v := range record {
+		row[k] = v
+	}
+	return &row, nil
+}
+
+func (f *fakeCollection) Find(_ context.Context, _ ...FindOption) (*List, error) {
+	return &List{
+		Records: []types.Record{{"id": "1", "name": "a"}, {"id": "2", "name": "b"}},
+		Meta:    Metadata{Total: 2},
+	}, nil
+}
+
+func TestRepo(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2025, 1, 1, 10, 0, 0, 0, time.UTC)
+	coll := &fakeCollection{}
+	repo := NewRepo[repoItem](coll)
+
+	created, err := repo.Create(ctx, repoItem{ID: "1", Name: "a", CreatedAt: now})
+	assert.NoError(t, err)
+	assert.Equal(t, types.Record{"id": "1", "name": "a", "created_at": now}, coll.created[0])
+	assert.Equal(t, int64(1), created.Version)
+	assert.True(t, now.Equal(created.CreatedAt))
+
+	updated, err := repo.Update(ctx, "1", repoItem{ID: "1", Name: "b", CreatedAt: now})
+	assert.NoError(t, err)
+	assert.NotContains(t, coll.updated, "id")
+	assert.Equal(t, "b", updated.Name)
+	assert.Equal(t, int64(2), updated.Version)
+
+	items, meta, err := repo.Find(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, []repoItem{{ID: "1", Name: "a"}, {ID: "2", Name: "b"}}, items)
+	assert.Equal(t, 2, meta.Total)
+}
+
+type stampedItem struct {
+	ID        string    `db:"id"`
+	Name      string    `db:"name"`
+	CreatedAt time.Time `db:"created_at"`
+	UpdatedAt time.Time `db:"updated_at"`
+}
+
+func TestRepo_UpdateBumpsUpdatedAt(t *testing.T) {
+	ctx := context.Background()
+	createdAt := time.Date(2025, 1, 1, 10, 0, 0, 0, time.UTC)
+	freezeTime(t, createdAt)
+
+	st := NewMemory()
+	st.EnableAutoFields(StandardAutoFields, "items")
+	repo := NewRepo[stampedItem](st.Collection("items"))
+
+	created, err := repo.Create(ctx, stampedItem{Name: "a"})
+	assert.NoError(t, err)
+	loaded, err := repo.Get(ctx, created.ID)
+	assert.NoError(t, err)
+
+	// The stale timestamps of the loaded item are not written back
+	updatedAt := createdAt.Add(time.Hour)
+	freezeTime(t, updatedAt)
+	loaded.Name = "b"
+	loaded.CreatedAt = createdAt.Add(time.Minute)
+	updated, err := repo.Update(ctx, loaded.ID, *loaded)
+	assert.NoError(t, err)
+	assert.Equal(t, "b", updated.Name)
+	assert.True(t, updatedAt.Equal(updated.UpdatedAt))
+	assert.True(t, createdAt.Equal(updated.CreatedAt))
+}
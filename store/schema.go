@@ -0,0 +1,570 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/lib/pq"
+
+	"github.com/tuongaz/go-saas/store/types"
+)
+
+// FieldType is the type of a schema field
+type FieldType string
+
+const (
+	FieldText      FieldType = "text"
+	FieldInt       FieldType = "int"
+	FieldFloat     FieldType = "float"
+	FieldBool      FieldType = "bool"
+	FieldJSON      FieldType = "json"
+	FieldTimestamp FieldType = "timestamp"
+
+	// FieldRelation holds the id of a record of the Relation table
+	FieldRelation FieldType = "relation"
+
+	// FieldEnum holds one of the Values of the field
+	FieldEnum FieldType = "enum"
+)
+
+// idColumn is the primary key added to the tables of schemas that do not declare it
+const idColumn = "id"
+
+// Validator checks a field value. It is only called with non nil values, converted to the field type:
+// string for text, enum and relation fields, int64, float64, bool and time.Time.
+type Validator func(value any) error
+
+// Field describes a column of a collection schema
+type Field struct {
+	Name string
+	Type FieldType
+
+	// Required fields must be set on create and cannot be set to null, the column is NOT NULL
+	Required bool
+
+	// Unique fields get a unique constraint, violations are reported as field errors
+	Unique bool
+
+	// Default is set on create when the record does not have the field, and is the column default
+	Default any
+
+	// Values are the allowed values of an enum field
+	Values []string
+
+	// Relation is the table referenced by a relation field, through its id column
+	Relation string
+
+	// Validators run after the type checks, in order, until one fails
+	Validators []Validator
+}
+
+// Schema declares the fields of a collection. Once registered with Store.RegisterSchema,
+// creates and updates of the collection are validated against it and the values are converted
+// to the field types. Records must only contain declared fields, the id field is implicit.
+type Schema struct {
+	Table  string
+	Fields []Field
+}
+
+// Field returns the field of the given name
+func (s *Schema) Field(name string) (Field, bool) {
+	for _, f := range s.Fields {
+		if f.Name == name {
+			return f, true
+		}
+	}
+	return Field{}, false
+}
+
+// Validate checks the schema definition
+func (s *Schema) Validate() error {
+	if !ValidTableName(s.Table) {
+		return fmt.Errorf("invalid table name: %s", s.Table)
+	}
+	if len(s.Fields) == 0 {
+		return fmt.Errorf("schema %s has no fields", s.Table)
+	}
+
+	seen := map[string]bool{}
+	for _, f := range s.Fields {
+		if !ValidIdentifierName(f.Name) {
+			return fmt.Errorf("invalid field name: %s", f.Name)
+		}
+		if seen[f.Name] {
+			return fmt.Errorf("duplicate field: %s", f.Name)
+		}
+		seen[f.Name] = true
+
+		switch f.Type {
+		case FieldText, FieldInt, FieldFloat, FieldBool, FieldJSON, FieldTimestamp:
+		case FieldRelation:
+			if !ValidTableName(f.Relation) {
+				return fmt.Errorf("field %s: invalid relation table: %s", f.Name, f.Relation)
+			}
+		case FieldEnum:
+			if len(f.Values) == 0 {
+				return fmt.Errorf("field %s: enum has no values", f.Name)
+			}
+		default:
+			return fmt.Errorf("field %s: unknown type %q", f.Name, f.Type)
+		}
+
+		if f.Default != nil {
+			if _, problem := f.convert(f.Default); problem != "" {
+				return fmt.Errorf("field %s: default %s", f.Name, problem)
+			}
+		}
+	}
+
+	return nil
+}
+
+// ValidateCreate validates a record to insert. Missing fields are set to their default,
+// and the values are converted to the field types in place.
+func (s *Schema) ValidateCreate(record types.Record) error {
+	for _, f := range s.Fields {
+		if _, ok := record[f.Name]; !ok && f.Default != nil {
+			record[f.Name], _ = f.convert(f.Default)
+		}
+	}
+
+	return s.validate(record, false)
+}
+
+// ValidateUpdate validates the fields of a record to update, the values are converted
+// to the field types in place
+func (s *Schema) ValidateUpdate(record types.Record) error {
+	return s.validate(record, true)
+}
+
+func (s *Schema) validate(record types.Record, partial bool) error {
+	problems := map[string]string{}
+
+	for name := range record {
+		if _, ok := s.Field(name); !ok && name != idColumn {
+			problems[name] = "is not a field of " + s.Table
+		}
+	}
+
+	for _, f := range s.Fields {
+		value, ok := record[f.Name]
+		if !ok && partial {
+			continue
+		}
+		if rv := reflect.ValueOf(value); rv.Kind() == reflect.Pointer {
+			value = nil
+			if !rv.IsNil() {
+				value = rv.Elem().Interface()
+			}
+		}
+		if value == nil {
+			if f.Required {
+				problems[f.Name] = "is required"
+			}
+			continue
+		}
+
+		converted, problem := f.convert(value)
+		if problem == "" {
+			problem = f.runValidators(converted)
+		}
+		if problem != "" {
+			problems[f.Name] = problem
+			continue
+		}
+		record[f.Name] = converted
+	}
+
+	if len(problems) > 0 {
+		return NewValidationErr(s.Table, problems)
+	}
+	return nil
+}
+
+func (f Field) runValidators(value any) string {
+	for _, validate := range f.Validators {
+		if err := validate(value); err != nil {
+			return err.Error()
+		}
+	}
+	return ""
+}
+
+// convert converts a non nil value to the field type, or returns the problem found
+func (f Field) convert(value any) (any, string) {
+	switch f.Type {
+	case FieldText:
+		if s, ok := value.(string); ok {
+			return s, ""
+		}
+		return nil, "must be text"
+	case FieldEnum:
+		if s, ok := value.(string); ok && slices.Contains(f.Values, s) {
+			return s, ""
+		}
+		return nil, "must be one of " + strings.Join(f.Values, ", ")
+	case FieldRelation:
+		switch v := value.(type) {
+		case string:
+			if v != "" {
+				return v, ""
+			}
+		case int, int32, int64:
+			return fmt.Sprint(v), ""
+		}
+		return nil, "must be an id of " + f.Relation
+	case FieldInt:
+		switch v := value.(type) {
+		case int:
+			return int64(v), ""
+		case int32:
+			return int64(v), ""
+		case int64:
+			return v, ""
+		case float64:
+			// JSON numbers are decoded as float64
+			if v == math.Trunc(v) && math.Abs(v) < 1<<53 {
+				return int64(v), ""
+			}
+		case json.Number:
+			if n, err := v.Int64(); err == nil {
+				return n, ""
+			}
+		}
+		return nil, "must be an integer"
+	case FieldFloat:
+		switch v := value.(type) {
+		case int:
+			return float64(v), ""
+		case int32:
+			return float64(v), ""
+		case int64:
+			return float64(v), ""
+		case float32:
+			return float64(v), ""
+		case float64:
+			return v, ""
+		case json.Number:
+			if n, err := v.Float64(); err == nil {
+				return n, ""
+			}
+		}
+		return nil, "must be a number"
+	case FieldBool:
+		if b, ok := value.(bool); ok {
+			return b, ""
+		}
+		return nil, "must be a boolean"
+	case FieldTimestamp:
+		switch v := value.(type) {
+		case time.Time:
+			return v, ""
+		case string:
+			for _, layout := range []string{time.RFC3339Nano, time.DateOnly} {
+				if t, err := time.Parse(layout, v); err == nil {
+					return t, ""
+				}
+			}
+		}
+		return nil, "must be a RFC 3339 time"
+	case FieldJSON:
+		if _, err := json.Marshal(value); err != nil {
+			return nil, "is not valid JSON"
+		}
+		return value, ""
+	}
+
+	return nil, "has an unknown type"
+}
+
+// uniqueViolation converts the violation of the unique constraint of a field into a ValidationErr
+func (s *Schema) uniqueViolation(err error) error {
+	if !IsDuplicateKeyError(err) {
+		return err
+	}
+
+	for _, f := range s.Fields {
+		if f.Unique && s.violatesUnique(err, f) {
+			return NewValidationErr(s.Table, map[string]string{f.Name: "must be unique"})
+		}
+	}
+	return err
+}
+
+// violatesUnique tells whether the duplicate key error is the violation of the unique constraint
+// of the field. Postgres names the constraint, SQLite and the memory store end the message with
+// the table and columns of the constraint, e.g. "UNIQUE constraint failed: projects.slug".
+func (s *Schema) violatesUnique(err error, f Field) bool {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		return pqErr.Constraint == s.uniqueConstraint(f)
+	}
+	return strings.HasSuffix(err.Error(), ": "+s.Table+"."+f.Name)
+}
+
+// uniqueConstraint is the name Postgres gives to the unique constraint of a column
+func (s *Schema) uniqueConstraint(f Field) string {
+	return s.Table + "_" + f.Name + "_key"
+}
+
+// WithSchema validates the writes of the collection against the schema
+func WithSchema(schema *Schema) CollectionOption {
+	return func(c *collection) {
+		c.schema = schema
+	}
+}
+
+// validateCreate validates a record to insert against the schema of the collection, if any
+func (c *collection) validateCreate(record types.Record) error {
+	if c.schema == nil {
+		return nil
+	}
+	return c.schema.ValidateCreate(record)
+}
+
+// validateUpdate validates a record to update against the schema of the collection, if any
+func (c *collection) validateUpdate(record types.Record) error {
+	if c.schema == nil {
+		return nil
+	}
+	return c.schema.ValidateUpdate(record)
+}
+
+// schemaError reports the unique violations of schema fields as field errors
+func (c *collection) schemaError(err error) error {
+	if c.schema == nil {
+		return err
+	}
+	return c.schema.uniqueViolation(err)
+}
+
+// MaxLength rejects text longer than n characters
+func MaxLength(n int) Validator {
+	return func(value any) error {
+		if s, ok := value.(string); ok && utf8.RuneCountInString(s) > n {
+			return fmt.Errorf("must be at most %d characters", n)
+		}
+		return nil
+	}
+}
+
+// Pattern rejects text that does not match the regular expression
+func Pattern(re *regexp.Regexp) Validator {
+	return func(value any) error {
+		if s, ok := value.(string); ok && !re.MatchString(s) {
+			return fmt.Errorf("must match %s", re)
+		}
+		return nil
+	}
+}
+
+// Range rejects numbers outside of [lower, upper]
+func Range(lower, upper float64) Validator {
+	return func(value any) error {
+		var n float64
+		switch v := value.(type) {
+		case int64:
+			n = float64(v)
+		case float64:
+			n = v
+		default:
+			return nil
+		}
+		if n < lower || n > upper {
+			return fmt.Errorf("must be between %s and %s", formatFloat(lower), formatFloat(upper))
+		}
+		return nil
+	}
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+// Column describes an existing column of a table
+type Column struct {
+	Name     string `db:"column_name"`
+	DataType string `db:"data_type"`
+	Nullable bool   `db:"nullable"`
+}
+
+// InspectColumns reads the columns of a table of the current schema, it returns no columns when the table does not exist
+func InspectColumns(ctx context.Context, db dbInterface, table string) ([]Column, error) {
+	var columns []Column
+	err := db.SelectContext(ctx, &columns, `
+		SELECT column_name, data_type, is_nullable = 'YES' AS nullable
+		FROM information_schema.columns
+		WHERE table_schema = current_schema() AND table_name = $1
+		ORDER BY ordinal_position`, table)
+	if err != nil {
+		return nil, fmt.Errorf("inspect columns of %s: %w", table, err)
+	}
+
+	return columns, nil
+}
+
+// CreateTableSQL returns the CREATE TABLE statement of the schema.
+// A TEXT primary key id column is added when the schema does not declare an id field.
+func (s *Schema) CreateTableSQL() (string, error) {
+	if err := s.Validate(); err != nil {
+		return "", err
+	}
+
+	var columns []string
+	if _, ok := s.Field(idColumn); !ok {
+		columns = append(columns, idColumn+" TEXT PRIMARY KEY")
+	}
+	for _, f := range s.Fields {
+		columns = append(columns, columnDefinition(f))
+	}
+
+	return fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (\n    %s\n)", s.Table, strings.Join(columns, ",\n    ")), nil
+}
+
+// Diff returns the statements migrating a table with the given columns to the schema, see InspectColumns.
+// The table is created when it has no columns. Otherwise missing columns are added, the type and
+// nullability of the others are changed, and columns the schema does not declare are dropped.
+// Defaults and constraints of existing columns are not compared. Review the statements before running them.
+func (s *Schema) Diff(columns []Column) ([]string, error) {
+	if len(columns) == 0 {
+		stmt, err := s.CreateTableSQL()
+		if err != nil {
+			return nil, err
+		}
+		return []string{stmt}, nil
+	}
+	if err := s.Validate(); err != nil {
+		return nil, err
+	}
+
+	existing := make(map[string]Column, len(columns))
+	for _, column := range columns {
+		existing[column.Name] = column
+	}
+
+	var stmts []string
+	alter := func(format string, args ...any) {
+		stmts = append(stmts, fmt.Sprintf("ALTER TABLE %s ", s.Table)+fmt.Sprintf(format, args...))
+	}
+
+	for _, f := range s.Fields {
+		column, ok := existing[f.Name]
+		if !ok {
+			alter("ADD COLUMN %s", columnDefinition(f))
+			continue
+		}
+
+		if !slices.Contains(dataTypes[f.Type], column.DataType) {
+			sqlType := columnType(f)
+			alter("ALTER COLUMN %s TYPE %s USING %s::%s", f.Name, sqlType, f.Name, sqlType)
+		}
+		if f.Required && column.Nullable {
+			alter("ALTER COLUMN %s SET NOT NULL", f.Name)
+		}
+		if !f.Required && !column.Nullable && f.Name != idColumn {
+			alter("ALTER COLUMN %s DROP NOT NULL", f.Name)
+		}
+	}
+
+	for _, column := range columns {
+		if _, ok := s.Field(column.Name); !ok && column.Name != idColumn {
+			alter("DROP COLUMN %s", column.Name)
+		}
+	}
+
+	return stmts, nil
+}
+
+// dataTypes are the information_schema data types accepted for the field types
+var dataTypes = map[FieldType][]string{
+	FieldText:      {"text", "character varying"},
+	FieldInt:       {"bigint", "integer", "smallint"},
+	FieldFloat:     {"double precision", "real", "numeric"},
+	FieldBool:      {"boolean"},
+	FieldJSON:      {"jsonb", "json"},
+	FieldTimestamp: {"timestamp with time zone", "timestamp without time zone"},
+	FieldRelation:  {"text", "character varying"},
+	FieldEnum:      {"text", "character varying"},
+}
+
+func columnType(f Field) string {
+	switch f.Type {
+	case FieldInt:
+		return "BIGINT"
+	case FieldFloat:
+		return "DOUBLE PRECISION"
+	case FieldBool:
+		return "BOOLEAN"
+	case FieldJSON:
+		return "JSONB"
+	case FieldTimestamp:
+		return "TIMESTAMP WITH TIME ZONE"
+	}
+	return "TEXT"
+}
+
+func columnDefinition(f Field) string {
+	def := f.Name + " " + columnType(f)
+	if f.Name == idColumn {
+		def += " PRIMARY KEY"
+	} else if f.Required {
+		def += " NOT NULL"
+	}
+	if f.Unique && f.Name != idColumn {
+		def += " UNIQUE"
+	}
+	if f.Default != nil {
+		value, _ := f.convert(f.Default)
+		if f.Type == FieldJSON {
+			def += " DEFAULT " + jsonLiteral(value)
+		} else {
+			def += " DEFAULT " + literal(value)
+		}
+	}
+	switch f.Type {
+	case FieldEnum:
+		values := make([]string, len(f.Values))
+		for i, v := range f.Values {
+			values[i] = literal(v)
+		}
+		def += fmt.Sprintf(" CHECK (%s IN (%s))", f.Name, strings.Join(values, ", "))
+	case FieldRelation:
+		def += fmt.Sprintf(" REFERENCES %s (%s)", f.Relation, idColumn)
+	}
+	return def
+}
+
+// literal formats a converted field value as a SQL literal
+func literal(value any) string {
+	switch v := value.(type) {
+	case string:
+		return pq.QuoteLiteral(v)
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case float64:
+		return formatFloat(v)
+	case bool:
+		if v {
+			return "TRUE"
+		}
+		return "FALSE"
+	case time.Time:
+		return pq.QuoteLiteral(v.UTC().Format(time.RFC3339Nano))
+	}
+	return jsonLiteral(value)
+}
+
+func jsonLiteral(value any) string {
+	data, _ := json.Marshal(value)
+	return pq.QuoteLiteral(string(data)) + "::jsonb"
+}
@@ -0,0 +1,241 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/tuongaz/go-saas/store/types"
+)
+
+func testSchema() *Schema {
+	return &Schema{
+		Table: "projects",
+		Fields: []Field{
+			{Name: "name", Type: FieldText, Required: true, Validators: []Validator{MaxLength(10)}},
+			{Name: "slug", Type: FieldText, Required: true, Unique: true, Validators: []Validator{Pattern(regexp.MustCompile(`^[a-z-]+$`))}},
+			{Name: "status", Type: FieldEnum, Required: true, Values: []string{"active", "archived"}, Default: "active"},
+			{Name: "seats", Type: FieldInt, Validators: []Validator{Range(1, 100)}},
+			{Name: "public", Type: FieldBool, Default: false},
+			{Name: "settings", Type: FieldJSON, Default: map[string]any{}},
+			{Name: "owner_id", Type: FieldRelation, Relation: "account"},
+			{Name: "due_at", Type: FieldTimestamp},
+		},
+	}
+}
+
+func TestSchema_Validate(t *testing.T) {
+	assert.NoError(t, testSchema().Validate())
+
+	tests := []struct {
+		schema  Schema
+		message string
+	}{
+		{Schema{Table: "bad table", Fields: []Field{{Name: "name", Type: FieldText}}}, "invalid table name: bad table"},
+		{Schema{Table: "projects"}, "schema projects has no fields"},
+		{Schema{Table: "projects", Fields: []Field{{Name: "name", Type: FieldText}, {Name: "name", Type: FieldInt}}}, "duplicate field: name"},
+		{Schema{Table: "projects", Fields: []Field{{Name: "name", Type: "string"}}}, `field name: unknown type "string"`},
+		{Schema{Table: "projects", Fields: []Field{{Name: "status", Type: FieldEnum}}}, "field status: enum has no values"},
+		{Schema{Table: "projects", Fields: []Field{{Name: "owner_id", Type: FieldRelation}}}, "field owner_id: invalid relation table: "},
+		{Schema{Table: "projects", Fields: []Field{{Name: "seats", Type: FieldInt, Default: "ten"}}}, "field seats: default must be an integer"},
+	}
+	for _, tt := range tests {
+		assert.EqualError(t, tt.schema.Validate(), tt.message)
+	}
+}
+
+func TestSchema_ValidateCreate(t *testing.T) {
+	schema := testSchema()
+
+	t.Run("applies defaults and converts values", func(t *testing.T) {
+		record := types.Record{"id": "p1", "name": "Website", "slug": "website", "seats": float64(5), "due_at": "2025-01-02T03:04:05Z"}
+
+		assert.NoError(t, schema.ValidateCreate(record))
+		assert.Equal(t, types.Record{
+			"id":       "p1",
+			"name":     "Website",
+			"slug":     "website",
+			"status":   "active",
+			"seats":    int64(5),
+			"public":   false,
+			"settings": map[string]any{},
+			"due_at":   time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC),
+		}, record)
+	})
+
+	t.Run("reports field errors", func(t *testing.T) {
+		err := schema.ValidateCreate(types.Record{
+			"name":     "A very long name",
+			"slug":     "Not A Slug",
+			"status":   "deleted",
+			"seats":    1.5,
+			"public":   "yes",
+			"owner_id": "",
+			"due_at":   "tomorrow",
+			"secret":   "x",
+		})
+
+		var validationErr *ValidationErr
+		if assert.ErrorAs(t, err, &validationErr) {
+			assert.Equal(t, map[string]string{
+				"name":     "must be at most 10 characters",
+				"slug":     "must match ^[a-z-]+$",
+				"status":   "must be one of active, archived",
+				"seats":    "must be an integer",
+				"public":   "must be a boolean",
+				"owner_id": "must be an id of account",
+				"due_at":   "must be a RFC 3339 time",
+				"secret":   "is not a field of projects",
+			}, validationErr.Fields)
+		}
+	})
+
+	t.Run("requires fields", func(t *testing.T) {
+		name := "Website"
+		err := schema.ValidateCreate(types.Record{"name": &name, "seats": 500})
+		assert.EqualError(t, err, "invalid projects record: seats must be between 1 and 100, slug is required")
+		assert.True(t, IsValidationError(err))
+	})
+}
+
+func TestSchema_ValidateUpdate(t *testing.T) {
+	schema := testSchema()
+
+	record := types.Record{"seats": 3}
+	assert.NoError(t, schema.ValidateUpdate(record))
+	assert.Equal(t, types.Record{"seats": int64(3)}, record)
+
+	assert.NoError(t, schema.ValidateUpdate(types.Record{"seats": nil}))
+	assert.EqualError(t, schema.ValidateUpdate(types.Record{"name": nil}), "invalid projects record: name is required")
+}
+
+func TestSchema_UniqueViolation(t *testing.T) {
+	schema := testSchema()
+
+	err := handleDBError(&pq.Error{Code: "23505", Constraint: "projects_slug_key"})
+	var validationErr *ValidationErr
+	if assert.ErrorAs(t, schema.uniqueViolation(fmt.Errorf("insert: %w", err)), &validationErr) {
+		assert.Equal(t, map[string]string{"slug": "must be unique"}, validationErr.Fields)
+	}
+
+	other := &pq.Error{Code: "23505", Constraint: "projects_pkey"}
+	assert.Equal(t, error(other), schema.uniqueViolation(other))
+
+	plain := errors.New("connection refused")
+	assert.Equal(t, plain, schema.uniqueViolation(plain))
+
+	// SQLite and the memory store name the columns of the constraint
+	sqliteErr := NewDuplicateKeyErr(errors.New("UNIQUE constraint failed: projects.slug"))
+	if assert.ErrorAs(t, schema.uniqueViolation(sqliteErr), &validationErr) {
+		assert.Equal(t, map[string]string{"slug": "must be unique"}, validationErr.Fields)
+	}
+	memoryErr := NewDuplicateKeyErr(fmt.Errorf("duplicate key value violates unique constraint: %s", qualifiedColumns("projects", []string{"slug"})))
+	assert.True(t, IsValidationError(schema.uniqueViolation(memoryErr)))
+
+	composite := NewDuplicateKeyErr(errors.New("UNIQUE constraint failed: projects.name, projects.slug"))
+	assert.Equal(t, composite, schema.uniqueViolation(composite))
+}
+
+func TestCollection_UpsertValidation(t *testing.T) {
+	ctx := context.Background()
+	st := NewMemory()
+	st.RegisterSchema(testSchema())
+	projects := st.Collection("projects")
+
+	_, err := projects.CreateRecord(ctx, types.Record{"name": "Website", "slug": "website"})
+	assert.NoError(t, err)
+
+	// The conflicting row keeps its required name
+	project, err := projects.Upsert(ctx, types.Record{"slug": "website", "seats": 5}, []string{"slug"}, []string{"seats"})
+	assert.NoError(t, err)
+	assert.Equal(t, "Website", project.Get("name"))
+	assert.EqualValues(t, 5, project.Get("seats"))
+
+	// The values of the update are still validated
+	_, err = projects.Upsert(ctx, types.Record{"slug": "website", "seats": 500}, []string{"slug"}, []string{"seats"})
+	assert.True(t, IsValidationError(err))
+
+	// A new row is validated in full
+	_, err = projects.Upsert(ctx, types.Record{"slug": "shop", "seats": 5}, []string{"slug"}, []string{"seats"})
+	assert.EqualError(t, err, "invalid projects record: name is required")
+}
+
+type execRecorder struct {
+	queryRecorder
+}
+
+func (r *execRecorder) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	r.query, r.args = query, args
+	return driver.RowsAffected(1), nil
+}
+
+func TestCollection_ValidatesSchema(t *testing.T) {
+	ctx := context.Background()
+	db := &execRecorder{}
+	c := NewCollection("projects", db, nil, WithSchema(testSchema()))
+
+	_, err := c.Update(ctx, types.Record{"status": "deleted"}, "slug", "website")
+	assert.True(t, IsValidationError(err))
+	assert.Empty(t, db.query)
+
+	_, err = c.Update(ctx, types.Record{"seats": float64(10)}, "slug", "website")
+	assert.NoError(t, err)
+	assert.Equal(t, "UPDATE projects SET seats = $1 WHERE slug = $2", db.query)
+	assert.Equal(t, []any{int64(10), "website"}, db.args)
+}
+
+func TestSchema_CreateTableSQL(t *testing.T) {
+	stmt, err := testSchema().CreateTableSQL()
+	assert.NoError(t, err)
+	assert.Equal(t, `CREATE TABLE IF NOT EXISTS projects (
+    id TEXT PRIMARY KEY,
+    name TEXT NOT NULL,
+    slug TEXT NOT NULL UNIQUE,
+    status TEXT NOT NULL DEFAULT 'active' CHECK (status IN ('active', 'archived')),
+    seats BIGINT,
+    public BOOLEAN DEFAULT FALSE,
+    settings JSONB DEFAULT '{}'::jsonb,
+    owner_id TEXT REFERENCES account (id),
+    due_at TIMESTAMP WITH TIME ZONE
+)`, stmt)
+
+	_, err = (&Schema{Table: "projects"}).CreateTableSQL()
+	assert.Error(t, err)
+}
+
+func TestSchema_Diff(t *testing.T) {
+	schema := testSchema()
+
+	stmts, err := schema.Diff(nil)
+	assert.NoError(t, err)
+	assert.Len(t, stmts, 1)
+	assert.Contains(t, stmts[0], "CREATE TABLE IF NOT EXISTS projects")
+
+	stmts, err = schema.Diff([]Column{
+		{Name: "id", DataType: "text"},
+		{Name: "name", DataType: "character varying", Nullable: true},
+		{Name: "slug", DataType: "text"},
+		{Name: "status", DataType: "text"},
+		{Name: "seats", DataType: "text", Nullable: true},
+		{Name: "public", DataType: "boolean"},
+		{Name: "settings", DataType: "jsonb", Nullable: true},
+		{Name: "owner_id", DataType: "text", Nullable: true},
+		{Name: "legacy", DataType: "text", Nullable: true},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		"ALTER TABLE projects ALTER COLUMN name SET NOT NULL",
+		"ALTER TABLE projects ALTER COLUMN seats TYPE BIGINT USING seats::BIGINT",
+		"ALTER TABLE projects ALTER COLUMN public DROP NOT NULL",
+		"ALTER TABLE projects ADD COLUMN due_at TIMESTAMP WITH TIME ZONE",
+		"ALTER TABLE projects DROP COLUMN legacy",
+	}, stmts)
+}
@@ -0,0 +1,205 @@
+package store
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/tuongaz/go-saas/store/types"
+)
+
+const (
+	// SearchRankField is the field holding the rank of the records found with WithSearch
+	SearchRankField = "search_rank"
+
+	// SearchHighlightsField is the field holding the highlighted snippets of the records found with WithSearch
+	SearchHighlightsField = "search_highlights"
+
+	// highlightColumnPrefix prefixes the snippet columns returned by search queries
+	highlightColumnPrefix = "_highlight_"
+
+	defaultSearchLanguage = "english"
+)
+
+// SearchWeight ranks the matches of a column, A matches rank the highest and D the lowest
+type SearchWeight string
+
+const (
+	SearchWeightA SearchWeight = "A"
+	SearchWeightB SearchWeight = "B"
+	SearchWeightC SearchWeight = "C"
+	SearchWeightD SearchWeight = "D"
+)
+
+// SearchColumn is a text column of a search index
+type SearchColumn struct {
+	Name string
+
+	// Weight defaults to D
+	Weight SearchWeight
+}
+
+// SearchIndex declares the columns of a table searched with WithSearch.
+// The index is an expression over the columns, create it with SearchIndexSQL
+// so that the search queries use it.
+type SearchIndex struct {
+	// Language is the text search configuration, it defaults to english
+	Language string
+	Columns  []SearchColumn
+}
+
+// Validate checks the language and the columns of the index
+func (i SearchIndex) Validate() error {
+	if i.Language != "" && !ValidIdentifierName(i.Language) {
+		return fmt.Errorf("invalid search language: %s", i.Language)
+	}
+	if len(i.Columns) == 0 {
+		return fmt.Errorf("search index has no columns")
+	}
+	for _, column := range i.Columns {
+		if !ValidIdentifierName(column.Name) {
+			return fmt.Errorf("invalid search column: %s", column.Name)
+		}
+		switch column.Weight {
+		case "", SearchWeightA, SearchWeightB, SearchWeightC, SearchWeightD:
+		default:
+			return fmt.Errorf("invalid weight of search column %s: %s", column.Name, column.Weight)
+		}
+	}
+	return nil
+}
+
+func (i SearchIndex) language() string {
+	if i.Language == "" {
+		return defaultSearchLanguage
+	}
+	return i.Language
+}
+
+// vector returns the tsvector expression of the index, e.g.
+//
+//	setweight(to_tsvector('english', coalesce(name, '')), 'A') || setweight(to_tsvector('english', coalesce(description, '')), 'B')
+func (i SearchIndex) vector() string {
+	parts := make([]string, len(i.Columns))
+	for n, column := range i.Columns {
+		weight := column.Weight
+		if weight == "" {
+			weight = SearchWeightD
+		}
+		parts[n] = fmt.Sprintf("setweight(to_tsvector('%s', coalesce(%s, '')), '%s')", i.language(), column.Name, weight)
+	}
+	return strings.Join(parts, " || ")
+}
+
+// tsquery returns the query of the search text in the given param
+func (i SearchIndex) tsquery(param int) string {
+	return fmt.Sprintf("websearch_to_tsquery('%s', $%d)", i.language(), param)
+}
+
+// SearchIndexSQL returns the statement creating the GIN index of the search index of the table,
+// to run in a migration.
+func SearchIndexSQL(table string, index SearchIndex) (string, error) {
+	if !ValidTableName(table) {
+		return "", fmt.Errorf("invalid table name: %s", table)
+	}
+	if err := index.Validate(); err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("CREATE INDEX IF NOT EXISTS %s ON %s USING GIN ((%s))", searchIndexName(table), table, index.vector()), nil
+}
+
+// DropSearchIndexSQL returns the statement dropping the GIN index created by SearchIndexSQL
+func DropSearchIndexSQL(table string) string {
+	return "DROP INDEX IF EXISTS " + searchIndexName(table)
+}
+
+func searchIndexName(table string) string {
+	return strings.ReplaceAll(table, ".", "_") + "_search_idx"
+}
+
+// WithSearchIndex makes the collection searchable with WithSearch
+func WithSearchIndex(index SearchIndex) CollectionOption {
+	return func(c *collection) {
+		c.search = &index
+	}
+}
+
+// SearchOptions holds the full-text search of a find
+type SearchOptions struct {
+	// Query is the search text, in the web search syntax, e.g. "acme -inc" or "\"acme corp\""
+	Query string
+
+	// Highlight are the columns returned as highlighted snippets
+	Highlight []string
+}
+
+// SearchOption configures a full-text search
+type SearchOption func(*SearchOptions)
+
+// Highlight returns snippets of the columns with the matches wrapped in <mark> tags,
+// under the search_highlights field of the records
+func Highlight(columns ...string) SearchOption {
+	return func(o *SearchOptions) {
+		o.Highlight = append(o.Highlight, columns...)
+	}
+}
+
+// WithSearch finds the records matching the search text, ranked by relevance unless
+// a sort is given. The rank is returned in the search_rank field of the records.
+// The collection must have a search index, and the search is not supported with cursors.
+//
+// Example: WithSearch("acme", Highlight("name", "description"))
+func WithSearch(query string, opts ...SearchOption) FindOption {
+	return func(o *FindOptions) {
+		o.Search = &SearchOptions{Query: query}
+		for _, opt := range opts {
+			opt(o.Search)
+		}
+	}
+}
+
+// addSearch adds the search condition of the find to the where clause and returns
+// the rank and snippet columns to select. Empty searches are ignored.
+func (c *collection) addSearch(where *whereBuilder, options *FindOptions) (string, error) {
+	search := options.Search
+	if search == nil || strings.TrimSpace(search.Query) == "" {
+		return "", nil
+	}
+	if c.search == nil {
+		return "", fmt.Errorf("search is not enabled for table %s", c.table)
+	}
+	if options.Cursor != nil {
+		return "", fmt.Errorf("search does not support cursor pagination")
+	}
+
+	param := where.nextIdx()
+	tsquery := c.search.tsquery(param)
+	where.add(fmt.Sprintf("(%s) @@ %s", c.search.vector(), tsquery), search.Query)
+
+	columns := fmt.Sprintf(", ts_rank(%s, %s) AS %s", c.search.vector(), tsquery, SearchRankField)
+	for _, column := range search.Highlight {
+		if !ValidIdentifierName(column) {
+			return "", fmt.Errorf("invalid highlight column: %s", column)
+		}
+		columns += fmt.Sprintf(", ts_headline('%s', coalesce(%s, ''), %s, 'StartSel=<mark>, StopSel=</mark>') AS %s%s",
+			c.search.language(), column, tsquery, highlightColumnPrefix, column)
+	}
+
+	return columns, nil
+}
+
+// nestHighlights moves the snippet columns of the records under the search_highlights field
+func nestHighlights(records []types.Record, columns []string) {
+	if len(columns) == 0 {
+		return
+	}
+
+	for _, record := range records {
+		highlights := make(map[string]any, len(columns))
+		for _, column := range columns {
+			highlights[column] = record[highlightColumnPrefix+column]
+			delete(record, highlightColumnPrefix+column)
+		}
+		record[SearchHighlightsField] = highlights
+	}
+}
@@ -0,0 +1,90 @@
+package store
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/tuongaz/go-saas/store/types"
+)
+
+var organisationSearch = SearchIndex{Columns: []SearchColumn{
+	{Name: "name", Weight: SearchWeightA},
+	{Name: "description"},
+}}
+
+func TestSearchIndexSQL(t *testing.T) {
+	query, err := SearchIndexSQL("organisation", organisationSearch)
+	assert.NoError(t, err)
+	assert.Equal(t, "CREATE INDEX IF NOT EXISTS organisation_search_idx ON organisation USING GIN (("+
+		"setweight(to_tsvector('english', coalesce(name, '')), 'A') || "+
+		"setweight(to_tsvector('english', coalesce(description, '')), 'D')))", query)
+	assert.Equal(t, "DROP INDEX IF EXISTS organisation_search_idx", DropSearchIndexSQL("organisation"))
+
+	_, err = SearchIndexSQL("organisation", SearchIndex{Language: "english'", Columns: organisationSearch.Columns})
+	assert.EqualError(t, err, "invalid search language: english'")
+	_, err = SearchIndexSQL("organisation", SearchIndex{})
+	assert.EqualError(t, err, "search index has no columns")
+	_, err = SearchIndexSQL("organisation", SearchIndex{Columns: []SearchColumn{{Name: "name", Weight: "E"}}})
+	assert.EqualError(t, err, "invalid weight of search column name: E")
+}
+
+func TestCollection_AddSearch(t *testing.T) {
+	c := &collection{table: "organisation", search: &SearchIndex{Language: "simple", Columns: []SearchColumn{{Name: "name"}}}}
+
+	where := &whereBuilder{}
+	where.add("owner_id = $1", "acc_1")
+	columns, err := c.addSearch(where, findOptions([]FindOption{WithSearch("acme", Highlight("name"))}))
+	assert.NoError(t, err)
+
+	vector := "setweight(to_tsvector('simple', coalesce(name, '')), 'D')"
+	assert.Equal(t, " WHERE owner_id = $1 AND ("+vector+") @@ websearch_to_tsquery('simple', $2)", where.String())
+	assert.Equal(t, []any{"acc_1", "acme"}, where.args)
+	assert.Equal(t, ", ts_rank("+vector+", websearch_to_tsquery('simple', $2)) AS search_rank"+
+		", ts_headline('simple', coalesce(name, ''), websearch_to_tsquery('simple', $2), 'StartSel=<mark>, StopSel=</mark>') AS _highlight_name", columns)
+
+	t.Run("empty search", func(t *testing.T) {
+		where := &whereBuilder{}
+		columns, err := c.addSearch(where, findOptions([]FindOption{WithSearch("  ")}))
+		assert.NoError(t, err)
+		assert.Empty(t, columns)
+		assert.Empty(t, where.String())
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		_, err := (&collection{table: "invoice"}).addSearch(&whereBuilder{}, findOptions([]FindOption{WithSearch("acme")}))
+		assert.EqualError(t, err, "search is not enabled for table invoice")
+
+		_, err = c.addSearch(&whereBuilder{}, findOptions([]FindOption{WithSearch("acme"), WithCursor("", 10)}))
+		assert.EqualError(t, err, "search does not support cursor pagination")
+
+		_, err = c.addSearch(&whereBuilder{}, findOptions([]FindOption{WithSearch("acme", Highlight("name, now()"))}))
+		assert.EqualError(t, err, "invalid highlight column: name, now()")
+	})
+}
+
+func TestNestHighlights(t *testing.T) {
+	records := []types.Record{{"id": "org_1", "_highlight_name": "<mark>Acme</mark> Inc", "_highlight_description": nil}}
+	nestHighlights(records, []string{"name", "description"})
+
+	assert.Equal(t, []types.Record{{
+		"id":                  "org_1",
+		SearchHighlightsField: map[string]any{"name": "<mark>Acme</mark> Inc", "description": nil},
+	}}, records)
+}
+
+func TestStore_RegisterSearch(t *testing.T) {
+	s := &Store{}
+	s.RegisterSearch("organisation", organisationSearch)
+
+	index, ok := s.SearchIndex("organisation")
+	assert.True(t, ok)
+	assert.Equal(t, organisationSearch, index)
+
+	_, ok = s.SearchIndex("account")
+	assert.False(t, ok)
+
+	assert.Panics(t, func() {
+		s.RegisterSearch("account", SearchIndex{Columns: []SearchColumn{{Name: "first name"}}})
+	})
+}
@@ -0,0 +1,121 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/tuongaz/go-saas/store/types"
+)
+
+// deletedAtColumn is the column set when a record of a soft delete collection is deleted
+const deletedAtColumn = "deleted_at"
+
+// findOptions applies the options to empty FindOptions
+func findOptions(opts []FindOption) *FindOptions {
+	options := &FindOptions{}
+	for _, opt := range opts {
+		opt(options)
+	}
+	return options
+}
+
+// addDeletedScope restricts the query to the records in scope, on soft delete collections only
+func (c *collection) addDeletedScope(where *whereBuilder, scope DeletedScope) {
+	if !c.softDelete {
+		return
+	}
+
+	switch scope {
+	case DeletedScopeExclude:
+		where.add(deletedAtColumn + " IS NULL")
+	case DeletedScopeOnly:
+		where.add(deletedAtColumn + " IS NOT NULL")
+	}
+}
+
+func (c *collection) requireSoftDelete() error {
+	if !c.softDelete {
+		return fmt.Errorf("soft delete is not enabled for table %s", c.table)
+	}
+	return nil
+}
+
+// Restore clears the deleted_at column of a soft deleted record and returns the restored record
+func (c *collection) Restore(ctx context.Context, id any) (*types.Record, error) {
+	if err := c.requireSoftDelete(); err != nil {
+		return nil, err
+	}
+
+	record, err := c.GetRecord(WithPrimary(ctx), id, OnlyDeleted())
+	if err != nil {
+		return nil, fmt.Errorf("get deleted record: %w", err)
+	}
+
+	if err := c.store.OnBeforeRecordRestored(ctx, c.table, *record); err != nil {
+		return nil, fmt.Errorf("before restore event handler error: %w", err)
+	}
+
+	where := &whereBuilder{}
+	where.add("id = $1", id)
+	where.add(deletedAtColumn + " IS NOT NULL")
+	c.addTenantScope(where)
+
+	query := fmt.Sprintf("UPDATE %s SET %s = NULL", c.table, deletedAtColumn) + where.String() + " RETURNING *"
+	restored, err := c.queryRecord(ctx, query, where.args...)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := c.store.OnAfterRecordRestored(ctx, c.table, *restored); err != nil {
+		return nil, fmt.Errorf("after restore event handler error: %w", err)
+	}
+
+	return restored, nil
+}
+
+// PurgeDeletedBefore permanently deletes the records soft deleted before t
+// and returns the number of purged records.
+func (c *collection) PurgeDeletedBefore(ctx context.Context, t time.Time) (int64, error) {
+	if err := c.requireSoftDelete(); err != nil {
+		return 0, err
+	}
+
+	where := &whereBuilder{}
+	where.add(deletedAtColumn + " IS NOT NULL")
+	where.add(fmt.Sprintf("%s < $1", deletedAtColumn), t)
+	c.addTenantScope(where)
+
+	records, err := c.queryRecords(ctx, "SELECT * FROM "+c.table+where.String(), where.args...)
+	if err != nil {
+		return 0, fmt.Errorf("find deleted records: %w", err)
+	}
+
+	if len(records) == 0 {
+		return 0, nil
+	}
+
+	ids := make([]any, len(records))
+	for i, record := range records {
+		if err := c.store.OnBeforeRecordPurged(ctx, c.table, record); err != nil {
+			return 0, fmt.Errorf("before purge event handler error: %w", err)
+		}
+		ids[i] = record["id"]
+	}
+
+	// Only purge the records that were announced to the handlers and are still deleted
+	dialect := c.sqlDialect()
+	query := fmt.Sprintf("DELETE FROM %s WHERE %s AND %s IS NOT NULL AND %s < $2 RETURNING *", c.table, dialect.InArray("id", 1), deletedAtColumn, deletedAtColumn)
+	purged, err := c.queryRecords(ctx, query, dialect.Array(ids), t)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, record := range purged {
+		if err := c.store.OnAfterRecordPurged(ctx, c.table, record); err != nil {
+			return 0, fmt.Errorf("after purge event handler error: %w", err)
+		}
+	}
+
+	return int64(len(purged)), nil
+}
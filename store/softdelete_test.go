@@ -0,0 +1,102 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tuongaz/go-saas/pkg/timer"
+)
+
+type queryRecorder struct {
+	dbInterface
+	query string
+	args  []any
+}
+
+func (r *queryRecorder) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	r.query, r.args = query, args
+	return nil, nil
+}
+
+func (r *queryRecorder) GetContext(ctx context.Context, dest any, query string, args ...any) error {
+	r.query, r.args = query, args
+	return nil
+}
+
+func TestCollection_AddDeletedScope(t *testing.T) {
+	c := &collection{table: "users", softDelete: true}
+
+	where := &whereBuilder{}
+	c.addDeletedScope(where, DeletedScopeExclude)
+	assert.Equal(t, " WHERE deleted_at IS NULL", where.String())
+
+	where = &whereBuilder{}
+	c.addDeletedScope(where, DeletedScopeOnly)
+	assert.Equal(t, " WHERE deleted_at IS NOT NULL", where.String())
+
+	where = &whereBuilder{}
+	c.addDeletedScope(where, DeletedScopeInclude)
+	assert.Equal(t, "", where.String())
+
+	t.Run("ignored without soft delete", func(t *testing.T) {
+		where := &whereBuilder{}
+		(&collection{table: "users"}).addDeletedScope(where, DeletedScopeOnly)
+		assert.Equal(t, "", where.String())
+	})
+}
+
+func TestCollection_SoftDelete(t *testing.T) {
+	ctx := context.Background()
+	db := &queryRecorder{}
+	c := NewCollection("users", db, nil, SoftDelete())
+
+	t.Run("delete records sets deleted_at", func(t *testing.T) {
+		assert.NoError(t, c.DeleteRecords(ctx, Filter{"org_id": "org1"}))
+		assert.Equal(t, "UPDATE users SET deleted_at = $2 WHERE deleted_at IS NULL AND org_id = $1", db.query)
+		assert.Equal(t, "org1", db.args[0])
+		assert.Len(t, db.args, 2)
+	})
+
+	t.Run("count excludes deleted", func(t *testing.T) {
+		_, err := c.Count(ctx, Filter{"org_id": "org1"})
+		assert.NoError(t, err)
+		assert.Equal(t, "SELECT COUNT(*) FROM users WHERE org_id = $1 AND deleted_at IS NULL", db.query)
+
+		_, err = c.Count(ctx, Filter{"org_id": "org1"}, OnlyDeleted())
+		assert.NoError(t, err)
+		assert.Equal(t, "SELECT COUNT(*) FROM users WHERE org_id = $1 AND deleted_at IS NOT NULL", db.query)
+
+		_, err = c.Count(ctx, Filter{}, WithDeleted())
+		assert.NoError(t, err)
+		assert.Equal(t, "SELECT COUNT(*) FROM users", db.query)
+	})
+
+	t.Run("restore and purge require soft delete", func(t *testing.T) {
+		plain := NewCollection("users", db, nil)
+
+		_, err := plain.Restore(ctx, "id1")
+		assert.Error(t, err)
+
+		_, err = plain.PurgeDeletedBefore(ctx, timer.Now())
+		assert.Error(t, err)
+
+		assert.NoError(t, plain.DeleteRecords(ctx, Filter{"org_id": "org1"}))
+		assert.Equal(t, "DELETE FROM users WHERE org_id = $1", db.query)
+	})
+}
+
+func TestStore_EnableSoftDelete(t *testing.T) {
+	st := &Store{}
+	assert.False(t, st.SoftDeleteEnabled("users"))
+
+	st.EnableSoftDelete("users")
+	assert.True(t, st.SoftDeleteEnabled("users"))
+	assert.True(t, st.Collection("users").(*collection).softDelete)
+	assert.False(t, st.Collection("accounts").(*collection).softDelete)
+
+	assert.Panics(t, func() {
+		st.EnableSoftDelete("users; DROP TABLE users")
+	})
+}
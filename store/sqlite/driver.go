@@ -0,0 +1,307 @@
+//go:build cgo
+
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/mattn/go-sqlite3"
+
+	"github.com/tuongaz/go-saas/store"
+)
+
+// timestampFormat is the format the timestamps are stored in, in UTC so that they sort as text
+const timestampFormat = "2006-01-02 15:04:05.999999999-07:00"
+
+// memoryDatabases numbers the in-memory databases, so that each datasource opens a new one
+var memoryDatabases atomic.Int64
+
+func init() {
+	sql.Register(driverName, &sqliteDriver{
+		driver: &sqlite3.SQLiteDriver{ConnectHook: registerFunctions},
+	})
+}
+
+// open opens the database with foreign keys enforced. Database files use WAL and immediate
+// transactions, so that writers wait for each other instead of failing.
+// An in-memory database lives in a single connection, which the pool keeps open: the
+// statements run one at a time, like the transactions of a shared cache would.
+func open(datasource string) (*sqlx.DB, error) {
+	name, query, _ := strings.Cut(datasource, "?")
+	params, err := url.ParseQuery(query)
+	if err != nil {
+		return nil, fmt.Errorf("invalid sqlite datasource parameters: %w", err)
+	}
+
+	if name == "" || name == ":memory:" {
+		name = fmt.Sprintf("file:gosaas-%d", memoryDatabases.Add(1))
+		params.Set("mode", "memory")
+	}
+	setDefault(params, "_foreign_keys", "1")
+	setDefault(params, "_busy_timeout", "5000")
+	if params.Get("mode") != "memory" {
+		setDefault(params, "_journal_mode", "WAL")
+		setDefault(params, "_txlock", "immediate")
+	}
+
+	db, err := sqlx.Open(driverName, name+"?"+params.Encode())
+	if err != nil {
+		return nil, err
+	}
+	if params.Get("mode") == "memory" {
+		db.SetMaxOpenConns(1)
+	}
+	return db, nil
+}
+
+func setDefault(params url.Values, key, value string) {
+	if !params.Has(key) {
+		params.Set(key, value)
+	}
+}
+
+// registerFunctions adds the Postgres functions used by the store to the connection
+func registerFunctions(conn *sqlite3.SQLiteConn) error {
+	if err := conn.RegisterFunc("date_trunc", dateTrunc, true); err != nil {
+		return err
+	}
+	// Settings are only used by Postgres row level security policies
+	return conn.RegisterFunc("set_config", func(_, value string, _ bool) string {
+		return value
+	}, true)
+}
+
+// dateTrunc truncates the timestamp to the date part, like the Postgres function
+func dateTrunc(part string, value any) (any, error) {
+	var t time.Time
+	switch v := value.(type) {
+	case nil:
+		return nil, nil
+	case string:
+		parsed, ok := parseTimestamp(v)
+		if !ok {
+			return nil, fmt.Errorf("date_trunc: invalid timestamp %q", v)
+		}
+		t = parsed
+	case int64:
+		t = time.Unix(v, 0).UTC()
+	default:
+		return nil, fmt.Errorf("date_trunc: invalid timestamp %v", v)
+	}
+
+	switch strings.ToLower(part) {
+	case "second":
+		t = t.Truncate(time.Second)
+	case "minute":
+		t = t.Truncate(time.Minute)
+	case "hour":
+		t = t.Truncate(time.Hour)
+	case "day":
+		t = time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	case "week":
+		t = time.Date(t.Year(), t.Month(), t.Day()-(int(t.Weekday())+6)%7, 0, 0, 0, 0, time.UTC)
+	case "month":
+		t = time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+	case "quarter":
+		t = time.Date(t.Year(), t.Month()-(t.Month()-1)%3, 1, 0, 0, 0, 0, time.UTC)
+	case "year":
+		t = time.Date(t.Year(), time.January, 1, 0, 0, 0, 0, time.UTC)
+	default:
+		return nil, fmt.Errorf("date_trunc: unsupported date part %q", part)
+	}
+
+	return t.Format(timestampFormat), nil
+}
+
+func parseTimestamp(s string) (time.Time, bool) {
+	s = strings.TrimSuffix(s, "Z")
+	for _, format := range sqlite3.SQLiteTimestampFormats {
+		if t, err := time.ParseInLocation(format, s, time.UTC); err == nil {
+			return t.UTC(), true
+		}
+	}
+	return time.Time{}, false
+}
+
+func classifyError(err error) error {
+	var sqliteErr sqlite3.Error
+	if !errors.As(err, &sqliteErr) {
+		return nil
+	}
+
+	switch sqliteErr.ExtendedCode {
+	case sqlite3.ErrConstraintUnique, sqlite3.ErrConstraintPrimaryKey:
+		return store.NewDuplicateKeyErr(err)
+	case sqlite3.ErrConstraintForeignKey:
+		return store.NewForeignKeyErr(err)
+	case sqlite3.ErrConstraintNotNull:
+		return store.NewNotNullErr(err)
+	}
+	return nil
+}
+
+// sqliteDriver rewrites the statements with the dialect and converts the values:
+// times are bound in UTC, and the text of timestamp and JSON columns is returned
+// as time.Time and []byte like the Postgres driver does.
+type sqliteDriver struct {
+	driver *sqlite3.SQLiteDriver
+}
+
+func (d *sqliteDriver) Open(name string) (driver.Conn, error) {
+	conn, err := d.driver.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	return &sqliteConn{conn: conn.(*sqlite3.SQLiteConn)}, nil
+}
+
+type sqliteConn struct {
+	conn *sqlite3.SQLiteConn
+}
+
+var (
+	_ driver.ConnPrepareContext = (*sqliteConn)(nil)
+	_ driver.ConnBeginTx        = (*sqliteConn)(nil)
+	_ driver.QueryerContext     = (*sqliteConn)(nil)
+	_ driver.ExecerContext      = (*sqliteConn)(nil)
+	_ driver.Pinger             = (*sqliteConn)(nil)
+)
+
+func (c *sqliteConn) Prepare(query string) (driver.Stmt, error) {
+	return c.PrepareContext(context.Background(), query)
+}
+
+func (c *sqliteConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	stmt, err := c.conn.PrepareContext(ctx, rebind(query))
+	if err != nil {
+		return nil, err
+	}
+	return &sqliteStmt{stmt: stmt.(*sqlite3.SQLiteStmt)}, nil
+}
+
+func (c *sqliteConn) Close() error {
+	return c.conn.Close()
+}
+
+func (c *sqliteConn) Begin() (driver.Tx, error) {
+	return c.conn.Begin()
+}
+
+func (c *sqliteConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	return c.conn.BeginTx(ctx, opts)
+}
+
+func (c *sqliteConn) Ping(ctx context.Context) error {
+	return c.conn.Ping(ctx)
+}
+
+func (c *sqliteConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	rows, err := c.conn.QueryContext(ctx, rebind(query), bindValues(args))
+	if err != nil {
+		return nil, err
+	}
+	return newRows(rows), nil
+}
+
+func (c *sqliteConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	return c.conn.ExecContext(ctx, rebind(query), bindValues(args))
+}
+
+type sqliteStmt struct {
+	stmt *sqlite3.SQLiteStmt
+}
+
+var (
+	_ driver.StmtQueryContext = (*sqliteStmt)(nil)
+	_ driver.StmtExecContext  = (*sqliteStmt)(nil)
+)
+
+func (s *sqliteStmt) Close() error {
+	return s.stmt.Close()
+}
+
+func (s *sqliteStmt) NumInput() int {
+	return s.stmt.NumInput()
+}
+
+func (s *sqliteStmt) Exec(args []driver.Value) (driver.Result, error) {
+	return s.ExecContext(context.Background(), namedValues(args))
+}
+
+func (s *sqliteStmt) Query(args []driver.Value) (driver.Rows, error) {
+	return s.QueryContext(context.Background(), namedValues(args))
+}
+
+func (s *sqliteStmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	return s.stmt.ExecContext(ctx, bindValues(args))
+}
+
+func (s *sqliteStmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	rows, err := s.stmt.QueryContext(ctx, bindValues(args))
+	if err != nil {
+		return nil, err
+	}
+	return newRows(rows), nil
+}
+
+func namedValues(args []driver.Value) []driver.NamedValue {
+	named := make([]driver.NamedValue, len(args))
+	for i, arg := range args {
+		named[i] = driver.NamedValue{Ordinal: i + 1, Value: arg}
+	}
+	return named
+}
+
+// bindValues converts the times to UTC, so that the stored timestamps compare as text
+func bindValues(args []driver.NamedValue) []driver.NamedValue {
+	values := make([]driver.NamedValue, len(args))
+	for i, arg := range args {
+		if t, ok := arg.Value.(time.Time); ok {
+			arg.Value = t.UTC()
+		}
+		values[i] = arg
+	}
+	return values
+}
+
+type sqliteRows struct {
+	*sqlite3.SQLiteRows
+	declTypes []string
+}
+
+// newRows wraps the rows of the driver, the declared types are lower case
+func newRows(rows driver.Rows) driver.Rows {
+	r := rows.(*sqlite3.SQLiteRows)
+	return &sqliteRows{SQLiteRows: r, declTypes: r.DeclTypes()}
+}
+
+func (r *sqliteRows) Next(dest []driver.Value) error {
+	if err := r.SQLiteRows.Next(dest); err != nil {
+		return err
+	}
+
+	for i, value := range dest {
+		text, ok := value.(string)
+		if !ok || i >= len(r.declTypes) {
+			continue
+		}
+		switch declType := r.declTypes[i]; {
+		case strings.Contains(declType, "timestamp") || strings.Contains(declType, "datetime"):
+			if t, ok := parseTimestamp(text); ok {
+				dest[i] = t
+			}
+		case strings.Contains(declType, "json"):
+			dest[i] = []byte(text)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,17 @@
+//go:build !cgo
+
+package sqlite
+
+import (
+	"errors"
+
+	"github.com/jmoiron/sqlx"
+)
+
+func open(string) (*sqlx.DB, error) {
+	return nil, errors.New("the sqlite driver requires cgo, build with CGO_ENABLED=1")
+}
+
+func classifyError(error) error {
+	return nil
+}
@@ -0,0 +1,252 @@
+// Package sqlite is the SQLite dialect of the store, for local development, tests and small
+// self-hosted installs. Import it for its side effects to open sqlite: datasources:
+//
+//	import _ "github.com/tuongaz/go-saas/store/sqlite"
+//
+//	store.New("sqlite:app.db")   // a database file
+//	store.New("sqlite::memory:") // a new in-memory database, on a single connection
+//
+// The queries of the store, written for Postgres, are rewritten for SQLite by the driver
+// (placeholders, ILIKE, NOW(), casts and serial columns), so that raw queries and migrations
+// work too. Migrations that cannot be rewritten go in the sqlite subdirectory of the
+// migrations, see migrate.Source. Full-text search, vector search, the change feed and
+// read replicas are only supported on Postgres. The driver requires cgo.
+package sqlite
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/jmoiron/sqlx"
+
+	"github.com/tuongaz/go-saas/store"
+)
+
+const (
+	driverName = "gosaas_sqlite"
+
+	// maxParams is the default maximum number of parameters of a SQLite statement
+	maxParams = 32766
+)
+
+// Dialect is the SQLite dialect, it is registered with the store on import
+var Dialect store.Dialect = dialect{}
+
+func init() {
+	store.RegisterDialect(Dialect)
+	sqlx.BindDriver(driverName, sqlx.DOLLAR)
+}
+
+type dialect struct{}
+
+func (dialect) Name() string {
+	return "sqlite"
+}
+
+func (dialect) DriverName() string {
+	return driverName
+}
+
+func (dialect) Open(datasource string) (*sqlx.DB, error) {
+	return open(strings.TrimPrefix(datasource, "sqlite:"))
+}
+
+func (dialect) Rebind(query string) string {
+	return rebind(query)
+}
+
+// InArray matches the expression against the values of the JSON array bound by Array
+func (dialect) InArray(expr string, param int) string {
+	return fmt.Sprintf("%s IN (SELECT value FROM json_each($%d))", expr, param)
+}
+
+func (dialect) Array(values []any) any {
+	data, _ := json.Marshal(values)
+	return string(data)
+}
+
+// UpsertInserted is empty, SQLite does not tell inserted rows from updated ones
+func (dialect) UpsertInserted() string {
+	return ""
+}
+
+// InsertDefault is empty, SQLite has no DEFAULT value in multi-row inserts
+func (dialect) InsertDefault() string {
+	return ""
+}
+
+func (dialect) MaxParams() int {
+	return maxParams
+}
+
+func (dialect) ClassifyError(err error) error {
+	return classifyError(err)
+}
+
+// Lock acquires a lock local to the process, SQLite databases are not shared between replicas
+func (dialect) Lock(ctx context.Context, db *sqlx.DB, id int64) (store.Lock, error) {
+	l := localLockOf(db, id)
+	select {
+	case l.held <- struct{}{}:
+		return &localLockHandle{lock: l}, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (dialect) TryLock(_ context.Context, db *sqlx.DB, id int64) (store.Lock, error) {
+	l := localLockOf(db, id)
+	select {
+	case l.held <- struct{}{}:
+		return &localLockHandle{lock: l}, nil
+	default:
+		return nil, nil
+	}
+}
+
+type localLockKey struct {
+	db *sqlx.DB
+	id int64
+}
+
+type localLock struct {
+	held chan struct{}
+}
+
+var (
+	localLocksMu sync.Mutex
+	localLocks   = map[localLockKey]*localLock{}
+)
+
+func localLockOf(db *sqlx.DB, id int64) *localLock {
+	localLocksMu.Lock()
+	defer localLocksMu.Unlock()
+
+	key := localLockKey{db: db, id: id}
+	l, ok := localLocks[key]
+	if !ok {
+		l = &localLock{held: make(chan struct{}, 1)}
+		localLocks[key] = l
+	}
+	return l
+}
+
+type localLockHandle struct {
+	lock    *localLock
+	release sync.Once
+}
+
+func (h *localLockHandle) Check(context.Context) error {
+	return nil
+}
+
+func (h *localLockHandle) Release(context.Context) error {
+	h.release.Do(func() {
+		<-h.lock.held
+	})
+	return nil
+}
+
+// rebind rewrites a query written for Postgres for SQLite, outside of the quoted strings,
+// identifiers and comments:
+//
+//	$1                  ?1, the numbered parameters keep their numbers
+//	ILIKE               LIKE, which is case insensitive for ASCII characters in SQLite
+//	NOW()               CURRENT_TIMESTAMP
+//	value::type         value, SQLite values are dynamically typed
+//	SERIAL, BIGSERIAL   INTEGER, which makes INTEGER PRIMARY KEY columns auto increment
+func rebind(query string) string {
+	var b strings.Builder
+	b.Grow(len(query))
+
+	for i := 0; i < len(query); {
+		c := query[i]
+		switch {
+		case c == '\'' || c == '"':
+			end := quotedEnd(query, i)
+			b.WriteString(query[i:end])
+			i = end
+		case strings.HasPrefix(query[i:], "--"):
+			end := strings.IndexByte(query[i:], '\n')
+			if end < 0 {
+				end = len(query) - i
+			}
+			b.WriteString(query[i : i+end])
+			i += end
+		case strings.HasPrefix(query[i:], "/*"):
+			end := strings.Index(query[i+2:], "*/")
+			if end < 0 {
+				end = len(query) - i
+			} else {
+				end += 4
+			}
+			b.WriteString(query[i : i+end])
+			i += end
+		case c == '$' && i+1 < len(query) && isDigit(query[i+1]):
+			b.WriteByte('?')
+			i++
+		case strings.HasPrefix(query[i:], "::"):
+			i = identEnd(query, i+2)
+			for strings.HasPrefix(query[i:], "[]") {
+				i += 2
+			}
+		case isIdentStart(c):
+			end := identEnd(query, i)
+			word := query[i:end]
+			switch strings.ToUpper(word) {
+			case "ILIKE":
+				word = "LIKE"
+			case "SERIAL", "BIGSERIAL":
+				word = "INTEGER"
+			case "NOW":
+				rest := strings.TrimLeft(query[end:], " ")
+				if strings.HasPrefix(rest, "()") {
+					word = "CURRENT_TIMESTAMP"
+					end = len(query) - len(rest) + 2
+				}
+			}
+			b.WriteString(word)
+			i = end
+		default:
+			b.WriteByte(c)
+			i++
+		}
+	}
+
+	return b.String()
+}
+
+// quotedEnd returns the end of the string or identifier quoted at start, quotes are escaped by doubling them
+func quotedEnd(query string, start int) int {
+	quote := query[start]
+	for i := start + 1; i < len(query); i++ {
+		if query[i] != quote {
+			continue
+		}
+		if i+1 < len(query) && query[i+1] == quote {
+			i++
+			continue
+		}
+		return i + 1
+	}
+	return len(query)
+}
+
+func identEnd(query string, start int) int {
+	i := start
+	for i < len(query) && (isIdentStart(query[i]) || isDigit(query[i])) {
+		i++
+	}
+	return i
+}
+
+func isIdentStart(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
@@ -0,0 +1,77 @@
+package sqlite
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRebind(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+		want  string
+	}{
+		{
+			name:  "placeholders",
+			query: "SELECT * FROM users WHERE id = $1 AND org_id = $12",
+			want:  "SELECT * FROM users WHERE id = ?1 AND org_id = ?12",
+		},
+		{
+			name:  "ilike",
+			query: "SELECT * FROM users WHERE name ilike $1",
+			want:  "SELECT * FROM users WHERE name LIKE ?1",
+		},
+		{
+			name:  "now",
+			query: "UPDATE users SET updated_at = NOW () WHERE id = $1",
+			want:  "UPDATE users SET updated_at = CURRENT_TIMESTAMP WHERE id = ?1",
+		},
+		{
+			name:  "now column",
+			query: "SELECT now FROM clock",
+			want:  "SELECT now FROM clock",
+		},
+		{
+			name:  "casts",
+			query: "SELECT $1::text[], count(*)::int FROM users",
+			want:  "SELECT ?1, count(*) FROM users",
+		},
+		{
+			name:  "serial",
+			query: "CREATE TABLE a (id BIGSERIAL PRIMARY KEY, n serial)",
+			want:  "CREATE TABLE a (id INTEGER PRIMARY KEY, n INTEGER)",
+		},
+		{
+			name:  "quoted strings and identifiers",
+			query: `SELECT 'it''s $1 ILIKE now()', "$2::text" FROM users WHERE id = $1`,
+			want:  `SELECT 'it''s $1 ILIKE now()', "$2::text" FROM users WHERE id = ?1`,
+		},
+		{
+			name:  "comments",
+			query: "-- $1 ILIKE\nSELECT /* $2::int */ $1",
+			want:  "-- $1 ILIKE\nSELECT /* $2::int */ ?1",
+		},
+		{
+			name:  "unterminated comment",
+			query: "SELECT 1 /* $1",
+			want:  "SELECT 1 /* $1",
+		},
+		{
+			name:  "dollar without number",
+			query: "SELECT '$' || $a",
+			want:  "SELECT '$' || $a",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, rebind(tt.query))
+		})
+	}
+}
+
+func TestDialect_InArray(t *testing.T) {
+	assert.Equal(t, "id IN (SELECT value FROM json_each($2))", Dialect.InArray("id", 2))
+	assert.Equal(t, `["a",1]`, Dialect.Array([]any{"a", 1}))
+}
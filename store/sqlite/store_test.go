@@ -0,0 +1,439 @@
+//go:build cgo
+
+package sqlite_test
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	authstore "github.com/tuongaz/go-saas/core/auth/store"
+	paymentmodel "github.com/tuongaz/go-saas/service/payment/model"
+	paymentstore "github.com/tuongaz/go-saas/service/payment/store"
+	"github.com/tuongaz/go-saas/store"
+	"github.com/tuongaz/go-saas/store/migrate"
+	"github.com/tuongaz/go-saas/store/sqlite"
+	"github.com/tuongaz/go-saas/store/types"
+)
+
+func newStore(t *testing.T) *store.Store {
+	t.Helper()
+
+	st, err := store.New("sqlite::memory:")
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	t.Cleanup(func() { _ = st.Close() })
+
+	_, err = migrate.New(st.DB(), migrate.Sources()...).Up(context.Background())
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	return st
+}
+
+func TestStore_MemoryDatabase(t *testing.T) {
+	ctx := context.Background()
+
+	st, err := store.New("sqlite::memory:", store.WithPool(store.PoolConfig{MaxOpenConns: 5, ConnMaxIdleTime: time.Millisecond}))
+	assert.NoError(t, err)
+	defer st.Close()
+	assert.Equal(t, 1, st.Stats().MaxOpenConnections)
+
+	assert.NoError(t, st.Exec(ctx, "CREATE TABLE notes (id TEXT PRIMARY KEY)"))
+	time.Sleep(10 * time.Millisecond)
+	_, err = st.Collection("notes").CreateRecord(ctx, types.Record{"id": "n1"})
+	assert.NoError(t, err)
+
+	// Every datasource opens a new database
+	other := newStore(t)
+	assert.Error(t, other.Exec(ctx, "SELECT * FROM notes"))
+}
+
+func TestStore_Migrations(t *testing.T) {
+	ctx := context.Background()
+	st := newStore(t)
+	assert.Equal(t, sqlite.Dialect, store.DialectOf(st.DB()))
+
+	m := migrate.New(st.DB(), migrate.Sources()...)
+	statuses, err := m.Status(ctx)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, statuses)
+	for _, status := range statuses {
+		assert.True(t, status.Applied, status.Migration.String())
+	}
+
+	rolledBack, err := m.Down(ctx, len(statuses))
+	assert.NoError(t, err)
+	assert.Len(t, rolledBack, len(statuses))
+
+	applied, err := m.Up(ctx)
+	assert.NoError(t, err)
+	assert.Len(t, applied, len(statuses))
+}
+
+func TestStore_Collection(t *testing.T) {
+	ctx := context.Background()
+	st := newStore(t)
+
+	assert.NoError(t, st.Exec(ctx, `
+		CREATE TABLE notes (
+			id         TEXT PRIMARY KEY,
+			title      TEXT NOT NULL UNIQUE,
+			body       TEXT DEFAULT 'empty',
+			data       JSONB,
+			version    BIGINT NOT NULL DEFAULT 1,
+			created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+			updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+		)`))
+	notes := st.Collection("notes")
+
+	created, err := notes.CreateRecord(ctx, types.Record{"id": "n1", "title": "Hello", "data": json.RawMessage(`{"a":1}`)})
+	assert.NoError(t, err)
+	assert.Equal(t, "empty", created.Get("body"))
+	assert.Equal(t, map[string]any{"a": float64(1)}, created.Get("data"))
+	_, err = time.Parse(time.RFC3339, created.Get("created_at").(string))
+	assert.NoError(t, err)
+
+	t.Run("duplicate key", func(t *testing.T) {
+		_, err := notes.CreateRecord(ctx, types.Record{"id": "n2", "title": "Hello"})
+		assert.True(t, store.IsDuplicateKeyError(err))
+
+		_, err = notes.CreateRecord(ctx, types.Record{"id": "n2"})
+		assert.True(t, store.IsNotNullError(err))
+	})
+
+	t.Run("create records with defaults", func(t *testing.T) {
+		_, err := notes.CreateRecords(ctx, []types.Record{
+			{"id": "n2", "title": "Second", "body": "text"},
+			{"id": "n3", "title": "Third"},
+		})
+		assert.NoError(t, err)
+
+		rec, err := notes.GetRecord(ctx, "n3")
+		assert.NoError(t, err)
+		assert.Equal(t, "empty", rec.Get("body"))
+	})
+
+	t.Run("find", func(t *testing.T) {
+		recs, err := notes.Find(ctx,
+			store.WithAdvancedFilter(store.NewCondition("title", store.FilterOpILike, "%ECON%")),
+		)
+		assert.NoError(t, err)
+		if assert.Len(t, recs.Records, 1) {
+			assert.Equal(t, "n2", recs.Records[0].Get("id"))
+		}
+
+		count, err := notes.Count(ctx, store.Filter{"body": "empty"})
+		assert.NoError(t, err)
+		assert.Equal(t, 2, count)
+	})
+
+	t.Run("upsert", func(t *testing.T) {
+		rec, err := notes.Upsert(ctx, types.Record{"id": "n4", "title": "Fourth", "body": "new"}, []string{"id"}, []string{"body"})
+		assert.NoError(t, err)
+		assert.Equal(t, "new", rec.Get("body"))
+
+		rec, err = notes.Upsert(ctx, types.Record{"id": "n4", "title": "Fourth", "body": "updated"}, []string{"id"}, []string{"body"})
+		assert.NoError(t, err)
+		assert.Equal(t, "updated", rec.Get("body"))
+	})
+
+	t.Run("unmodified since", func(t *testing.T) {
+		rec, err := notes.GetRecord(ctx, "n1")
+		assert.NoError(t, err)
+		updatedAt, err := time.Parse(time.RFC3339, rec.Get("updated_at").(string))
+		assert.NoError(t, err)
+
+		_, err = notes.UpdateRecord(ctx, "n1", types.Record{"body": "changed"}, store.WithUnmodifiedSince(updatedAt))
+		assert.NoError(t, err)
+
+		_, err = notes.UpdateRecord(ctx, "n1", types.Record{"body": "stale"}, store.WithUnmodifiedSince(updatedAt.Add(-time.Hour)))
+		assert.True(t, store.IsConflictError(err))
+	})
+
+	t.Run("aggregate", func(t *testing.T) {
+		rows, err := notes.Aggregate(ctx,
+			[]store.GroupBy{store.GroupByField("body")},
+			[]store.Aggregation{store.CountAll(), store.Avg("version")},
+			store.WithFilter(store.Filter{"body": "empty"}),
+		)
+		assert.NoError(t, err)
+		if assert.Len(t, rows, 1) {
+			assert.Equal(t, int64(1), rows[0].Get("count"))
+			assert.Equal(t, float64(1), rows[0].Get("avg_version"))
+		}
+	})
+}
+
+func TestStore_SchemaUnique(t *testing.T) {
+	ctx := context.Background()
+	st := newStore(t)
+
+	schema := &store.Schema{
+		Table: "projects",
+		Fields: []store.Field{
+			{Name: "name", Type: store.FieldText, Required: true},
+			{Name: "slug", Type: store.FieldText, Required: true, Unique: true},
+		},
+	}
+	st.RegisterSchema(schema)
+	assert.NoError(t, st.Exec(ctx, `CREATE TABLE projects (id TEXT PRIMARY KEY, name TEXT NOT NULL, slug TEXT NOT NULL UNIQUE)`))
+	projects := st.Collection("projects")
+
+	_, err := projects.CreateRecord(ctx, types.Record{"id": "p1", "name": "Website", "slug": "website"})
+	assert.NoError(t, err)
+
+	_, err = projects.CreateRecord(ctx, types.Record{"id": "p2", "name": "Shop", "slug": "website"})
+	var validationErr *store.ValidationErr
+	if assert.ErrorAs(t, err, &validationErr) {
+		assert.Equal(t, map[string]string{"slug": "must be unique"}, validationErr.Fields)
+	}
+}
+
+func TestStore_CursorPagination(t *testing.T) {
+	ctx := context.Background()
+	st := newStore(t)
+
+	assert.NoError(t, st.Exec(ctx, "CREATE TABLE tasks (id TEXT PRIMARY KEY, priority INTEGER)"))
+	_, err := st.Collection("tasks").CreateRecords(ctx, []types.Record{
+		{"id": "t1", "priority": 2},
+		{"id": "t2", "priority": nil},
+		{"id": "t3", "priority": 1},
+		{"id": "t4", "priority": nil},
+		{"id": "t5", "priority": 2},
+	})
+	assert.NoError(t, err)
+
+	ids := func(list *store.List) []string {
+		var out []string
+		for _, record := range list.Records {
+			out = append(out, record.String("id"))
+		}
+		return out
+	}
+
+	t.Run("collection walks past NULL values", func(t *testing.T) {
+		tasks := st.Collection("tasks")
+		sort := store.WithSort(store.SortOption{Field: "priority", Direction: store.SortAsc})
+
+		var walked []string
+		cursor := ""
+		for {
+			page, err := tasks.Find(ctx, sort, store.WithCursor(cursor, 2))
+			if !assert.NoError(t, err) {
+				return
+			}
+			walked = append(walked, ids(page)...)
+			if page.Meta.NextCursor == "" {
+				break
+			}
+			cursor = page.Meta.NextCursor
+		}
+		assert.Equal(t, []string{"t3", "t1", "t5", "t2", "t4"}, walked)
+	})
+
+	t.Run("raw query", func(t *testing.T) {
+		query := func() *store.RawQueryOptions {
+			return st.QueryBuilder().
+				WithQuery("SELECT id AS task_id, priority FROM tasks").
+				WithSort(store.SortOption{Field: "priority", Direction: store.SortDesc})
+		}
+
+		_, err := query().WithCursor("", 2).Execute(ctx, st)
+		assert.ErrorContains(t, err, "unique cursor key")
+
+		first, err := query().WithCursor("", 3).WithCursorKey("task_id").Execute(ctx, st)
+		assert.NoError(t, err)
+		assert.Equal(t, 5, first.Meta.Total)
+		var firstIDs []string
+		for _, record := range first.Records {
+			firstIDs = append(firstIDs, record.String("task_id"))
+		}
+		assert.Equal(t, []string{"t2", "t4", "t1"}, firstIDs)
+
+		next, err := query().WithCursor(first.Meta.NextCursor, 3).WithCursorKey("task_id").Execute(ctx, st)
+		assert.NoError(t, err)
+		var nextIDs []string
+		for _, record := range next.Records {
+			nextIDs = append(nextIDs, record.String("task_id"))
+		}
+		assert.Equal(t, []string{"t5", "t3"}, nextIDs)
+	})
+
+	t.Run("raw query count error", func(t *testing.T) {
+		_, err := st.QueryBuilder().
+			WithQuery("SELECT * FROM tasks; SELECT 1").
+			WithPagination(store.Pagination{Limit: 2}).
+			Execute(ctx, st)
+		assert.ErrorContains(t, err, "total count")
+	})
+}
+
+func TestStore_SoftDeleteAndRelations(t *testing.T) {
+	ctx := context.Background()
+	st := newStore(t)
+
+	assert.NoError(t, st.Exec(ctx, `
+		CREATE TABLE authors (id TEXT PRIMARY KEY, name TEXT NOT NULL);
+		CREATE TABLE books (
+			id         TEXT PRIMARY KEY,
+			author_id  TEXT NOT NULL REFERENCES authors (id),
+			title      TEXT NOT NULL,
+			deleted_at TIMESTAMP WITH TIME ZONE
+		)`))
+	st.EnableSoftDelete("books")
+	st.RegisterRelations("books", store.Relation{Name: "author", Kind: store.BelongsTo, Table: "authors", ForeignKey: "author_id"})
+	st.RegisterRelations("authors", store.Relation{Name: "books", Kind: store.HasMany, Table: "books", ForeignKey: "author_id"})
+
+	_, err := st.Collection("authors").CreateRecord(ctx, types.Record{"id": "a1", "name": "Ann"})
+	assert.NoError(t, err)
+	books := st.Collection("books")
+	_, err = books.CreateRecords(ctx, []types.Record{
+		{"id": "b1", "author_id": "a1", "title": "One"},
+		{"id": "b2", "author_id": "a1", "title": "Two"},
+	})
+	assert.NoError(t, err)
+
+	_, err = books.CreateRecord(ctx, types.Record{"id": "b3", "author_id": "missing", "title": "Three"})
+	assert.True(t, store.IsForeignKeyError(err))
+
+	book, err := books.GetRecord(ctx, "b1", store.WithExpand("author"))
+	assert.NoError(t, err)
+	assert.Equal(t, "Ann", book.Get("author").(types.Record).Get("name"))
+
+	assert.NoError(t, books.DeleteRecord(ctx, "b2"))
+	author, err := st.Collection("authors").GetRecord(ctx, "a1", store.WithExpand("books"))
+	assert.NoError(t, err)
+	assert.Len(t, author.Get("books"), 1)
+
+	purged, err := books.PurgeDeletedBefore(ctx, time.Now().Add(time.Minute))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), purged)
+
+	count, err := books.Count(ctx, nil, store.WithDeleted())
+	assert.NoError(t, err)
+	assert.Equal(t, 1, count)
+}
+
+func TestStore_WithTx(t *testing.T) {
+	ctx := context.Background()
+	st := newStore(t)
+
+	assert.NoError(t, st.Exec(ctx, `CREATE TABLE tags (id TEXT PRIMARY KEY, name TEXT NOT NULL UNIQUE)`))
+	tags := st.Collection("tags")
+
+	err := st.WithTx(ctx, &store.TxOptions{Isolation: sql.LevelSerializable}, func(tx *store.StoreTx) error {
+		if _, err := tx.Collection("tags").CreateRecord(ctx, types.Record{"id": "t1", "name": "go"}); err != nil {
+			return err
+		}
+
+		// The duplicate is rolled back to the savepoint, the transaction carries on
+		err := tx.WithTx(ctx, nil, func(tx *store.StoreTx) error {
+			if _, err := tx.Collection("tags").CreateRecord(ctx, types.Record{"id": "t2", "name": "sql"}); err != nil {
+				return err
+			}
+			_, err := tx.Collection("tags").CreateRecord(ctx, types.Record{"id": "t3", "name": "go"})
+			return err
+		})
+		assert.True(t, store.IsDuplicateKeyError(err))
+
+		_, err = tx.Collection("tags").CreateRecord(ctx, types.Record{"id": "t4", "name": "sqlite"})
+		return err
+	})
+	assert.NoError(t, err)
+
+	count, err := tags.Count(ctx, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, count)
+	_, err = tags.GetRecord(ctx, "t2")
+	assert.ErrorIs(t, err, sql.ErrNoRows)
+}
+
+func TestStore_AuthAndPayment(t *testing.T) {
+	ctx := context.Background()
+	st := newStore(t)
+
+	auth, err := authstore.New(st)
+	assert.NoError(t, err)
+
+	account, org, _, role, err := auth.CreateOwnerAccount(ctx, authstore.CreateOwnerAccountInput{
+		Name:           "Jane Doe",
+		FirstName:      "Jane",
+		LastName:       "Doe",
+		Provider:       "password",
+		ProviderUserID: "jane@example.com",
+		Email:          "jane@example.com",
+		Password:       "secret",
+	})
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, account.ID, role.AccountID)
+
+	orgs, err := auth.ListOrganisationsByAccountID(ctx, account.ID)
+	assert.NoError(t, err)
+	if assert.Len(t, orgs, 1) {
+		assert.Equal(t, org.ID, orgs[0].ID)
+		assert.False(t, orgs[0].CreatedAt.IsZero())
+	}
+
+	org, err = auth.GetOrganisation(ctx, org.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), org.Version)
+
+	name := "Renamed"
+	stale := org.Version - 1
+	_, err = auth.UpdateOrganisation(ctx, authstore.UpdateOrganisationInput{ID: org.ID, Name: &name, Version: &stale})
+	assert.True(t, store.IsConflictError(err))
+
+	updated, err := auth.UpdateOrganisation(ctx, authstore.UpdateOrganisationInput{ID: org.ID, Name: &name, Version: &org.Version})
+	assert.NoError(t, err)
+	assert.Equal(t, "Renamed", updated.Name)
+	assert.Equal(t, org.Version+1, updated.Version)
+
+	payments, err := paymentstore.New(st)
+	assert.NoError(t, err)
+
+	invoice, err := payments.CreateInvoice(ctx, paymentmodel.CreateInvoiceInput{
+		AccountID:     account.ID,
+		ReferenceID:   "in_1",
+		AmountInCents: 1000,
+		Currency:      "usd",
+		Status:        "paid",
+	})
+	assert.NoError(t, err)
+
+	got, err := payments.GetInvoice(ctx, invoice.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1000), got.AmountInCents)
+
+	assert.NoError(t, auth.DeleteOrganisation(ctx, org.ID))
+	_, err = auth.GetOrganisation(ctx, org.ID)
+	assert.ErrorIs(t, err, sql.ErrNoRows)
+}
+
+func TestDialect_Lock(t *testing.T) {
+	ctx := context.Background()
+	st := newStore(t)
+
+	lock, err := sqlite.Dialect.TryLock(ctx, st.DB(), 1)
+	assert.NoError(t, err)
+	assert.NotNil(t, lock)
+
+	other, err := sqlite.Dialect.TryLock(ctx, st.DB(), 1)
+	assert.NoError(t, err)
+	assert.Nil(t, other)
+
+	assert.NoError(t, lock.Release(ctx))
+	assert.NoError(t, lock.Release(ctx))
+
+	other, err = sqlite.Dialect.TryLock(ctx, st.DB(), 1)
+	assert.NoError(t, err)
+	assert.NotNil(t, other)
+	assert.NoError(t, other.Release(ctx))
+}
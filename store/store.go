@@ -4,9 +4,9 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"time"
 
 	"github.com/jmoiron/sqlx"
-	_ "github.com/lib/pq"
 	"github.com/tuongaz/go-saas/store/events"
 	"github.com/tuongaz/go-saas/store/types"
 )
@@ -27,33 +27,114 @@ type Interface interface {
 	DB() *sqlx.DB
 	Close() error
 
+	// WithTx runs fn in a transaction, committed when fn returns nil and rolled back otherwise.
+	// Serialization failures and deadlocks are retried.
+	WithTx(ctx context.Context, opts *TxOptions, fn func(tx *StoreTx) error) error
+
+	// EnableSoftDelete turns on soft delete for the tables, which must have a nullable deleted_at column
+	EnableSoftDelete(tables ...string)
+	SoftDeleteEnabled(table string) bool
+
+	// RegisterSchema validates the writes of the schema table against it
+	RegisterSchema(schema *Schema)
+	Schema(table string) *Schema
+
+	// EnableAutoFields makes the collections of the tables set the auto fields on writes
+	EnableAutoFields(fields AutoFields, tables ...string)
+	AutoFields(table string) *AutoFields
+
+	// RegisterRelations declares the relations of the table, which WithExpand loads
+	RegisterRelations(table string, relations ...Relation)
+	Relation(table, name string) (Relation, bool)
+
+	// RegisterSearch declares the search index of the table, which WithSearch queries
+	RegisterSearch(table string, index SearchIndex)
+	SearchIndex(table string) (SearchIndex, bool)
+
+	// ForOrganisation returns a view of the store whose collections are scoped to the organisation
+	ForOrganisation(organisationID string) *TenantStore
+
 	// Event handlers
 	AddEventHandler(handler events.Handler)
 
 	// Database events
+	RecordEvents
+}
+
+// RecordEvents dispatches record events to the registered event handlers
+type RecordEvents interface {
 	OnBeforeRecordCreated(ctx context.Context, table string, record types.Record) error
 	OnAfterRecordCreated(ctx context.Context, table string, record types.Record) error
 	OnBeforeRecordUpdated(ctx context.Context, table string, record types.Record, oldRecord types.Record) error
 	OnAfterRecordUpdated(ctx context.Context, table string, record types.Record, oldRecord types.Record) error
 	OnBeforeRecordDeleted(ctx context.Context, table string, record types.Record) error
 	OnAfterRecordDeleted(ctx context.Context, table string, record types.Record) error
+	OnBeforeRecordRestored(ctx context.Context, table string, record types.Record) error
+	OnAfterRecordRestored(ctx context.Context, table string, record types.Record) error
+	OnBeforeRecordPurged(ctx context.Context, table string, record types.Record) error
+	OnAfterRecordPurged(ctx context.Context, table string, record types.Record) error
 }
 
 type Store struct {
-	db       *sqlx.DB
-	handlers []events.Handler
+	db      *sqlx.DB
+	dialect Dialect
+
+	tableRegistry
+	eventDispatcher
+
+	// reader routes the reads to the replicas, it is nil without replicas
+	reader *readRouter
+}
+
+// Option configures a store
+type Option func(*options)
+
+type options struct {
+	replicas            []string
+	healthCheckInterval time.Duration
+
+	pool              PoolConfig
+	statementTimeout  time.Duration
+	connectRetries    int
+	connectRetryDelay time.Duration
 }
 
-func New(datasource string) (*Store, error) {
-	db, err := sqlx.Connect("postgres", datasource)
+// New connects to the primary data source, see WithReplicas to route the reads to read replicas
+// and WithPool to configure the connection pools.
+// The data source is a Postgres connection string unless it starts with the name of a registered
+// dialect, e.g. sqlite:app.db once the store/sqlite package is imported.
+func New(datasource string, opts ...Option) (*Store, error) {
+	o := &options{
+		healthCheckInterval: defaultHealthCheckInterval,
+		connectRetryDelay:   defaultConnectRetryDelay,
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	db, dialect, err := o.open(datasource)
 	if err != nil {
+		return nil, fmt.Errorf("unable to open db: %w", err)
+	}
+	if err := connect(db.Ping, o.connectRetries, o.connectRetryDelay); err != nil {
+		_ = db.Close()
 		return nil, fmt.Errorf("unable to connect to db: %w", err)
 	}
 
-	return &Store{
-		db:       db,
-		handlers: make([]events.Handler, 0),
-	}, nil
+	s := &Store{
+		db:      db,
+		dialect: dialect,
+	}
+
+	if len(o.replicas) > 0 {
+		if s.reader, err = newReadRouter(db, o.replicas, o); err != nil {
+			_ = db.Close()
+			return nil, err
+		}
+		s.reader.start(o.healthCheckInterval)
+	}
+
+	return s, nil
 }
 
 func (s *Store) Collection(table string) CollectionInterface {
@@ -61,7 +142,29 @@ func (s *Store) Collection(table string) CollectionInterface {
 		panic(fmt.Sprintf("invalid table name: %s", table))
 	}
 
-	return NewCollection(table, s.db, s)
+	return withTxEvents(NewCollection(table, s.db, s, s.collectionOptions(table)...), s, s.WithTx)
+}
+
+// collectionOptions returns the options configured on the store for the table
+func (s *Store) collectionOptions(table string) []CollectionOption {
+	opts := []CollectionOption{WithRelations(s), withDialect(s.dialect)}
+	if s.reader != nil {
+		opts = append(opts, withReader(s.reader))
+	}
+	if s.SoftDeleteEnabled(table) {
+		opts = append(opts, SoftDelete())
+	}
+	if schema := s.Schema(table); schema != nil {
+		opts = append(opts, WithSchema(schema))
+	}
+	if fields := s.AutoFields(table); fields != nil {
+		opts = append(opts, WithAutoFields(*fields))
+	}
+	if index, ok := s.SearchIndex(table); ok {
+		opts = append(opts, WithSearchIndex(index))
+	}
+
+	return opts
 }
 
 func (s *Store) Exec(ctx context.Context, query string, args ...interface{}) error {
@@ -77,18 +180,28 @@ func (s *Store) DB() *sqlx.DB {
 }
 
 func (s *Store) Tx(ctx context.Context) (*StoreTx, error) {
-	tx, err := s.db.BeginTxx(ctx, nil)
+	return s.begin(ctx, nil)
+}
+
+// begin begins a transaction with the options, the defaults of the database when nil
+func (s *Store) begin(ctx context.Context, opts *sql.TxOptions) (*StoreTx, error) {
+	tx, err := s.db.BeginTxx(ctx, opts)
 	if err != nil {
 		return nil, fmt.Errorf("begin tx: %w", err)
 	}
 
 	return &StoreTx{
-		tx:    tx,
-		store: s,
+		tx:      tx,
+		store:   s,
+		dialect: s.dialect,
+		ctx:     ctx,
 	}, nil
 }
 
 func (s *Store) Close() error {
+	if s.reader != nil {
+		s.reader.close()
+	}
 	if s.db != nil {
 		return s.db.Close()
 	}
@@ -96,9 +209,10 @@ func (s *Store) Close() error {
 	return nil
 }
 
-// QueryValue executes a raw SQL query and scans the result into dest
+// QueryValue executes a raw SQL query and scans the result into dest.
+// Like Query and QueryOne, it is routed to the read replicas, use WithPrimary for queries that write.
 func (s *Store) QueryValue(ctx context.Context, query string, dest any, args ...any) error {
-	return s.db.GetContext(ctx, dest, query, args...)
+	return s.reads().GetContext(ctx, dest, query, args...)
 }
 
 // Query executes a raw SQL query and returns multiple records
@@ -120,7 +234,7 @@ func (s *Store) QueryWithPagination(ctx context.Context, query string, paginatio
 
 // QueryOne executes a raw SQL query and returns a single record
 func (s *Store) QueryOne(ctx context.Context, query string, args ...any) (*types.Record, error) {
-	rows, err := s.db.QueryxContext(ctx, query, args...)
+	rows, err := s.reads().QueryxContext(ctx, query, args...)
 	if err != nil {
 		return nil, fmt.Errorf("query execution failed: %v", err)
 	}
@@ -141,101 +255,3 @@ func (s *Store) QueryOne(ctx context.Context, query string, args ...any) (*types
 
 	return &rec, nil
 }
-
-// AddEventHandler adds an event handler to the store
-func (s *Store) AddEventHandler(handler events.Handler) {
-	s.handlers = append(s.handlers, handler)
-}
-
-// Database events
-func (s *Store) OnBeforeRecordCreated(ctx context.Context, table string, record types.Record) error {
-	event := &events.OnBeforeRecordCreatedEvent{
-		DatabaseEvent: events.DatabaseEvent{
-			Table:  table,
-			Record: record,
-		},
-	}
-	for _, handler := range s.handlers {
-		if err := handler.OnBeforeCreate(ctx, event); err != nil {
-			return err
-		}
-	}
-	return nil
-}
-
-func (s *Store) OnAfterRecordCreated(ctx context.Context, table string, record types.Record) error {
-	event := &events.OnAfterRecordCreatedEvent{
-		DatabaseEvent: events.DatabaseEvent{
-			Table:  table,
-			Record: record,
-		},
-	}
-	for _, handler := range s.handlers {
-		if err := handler.OnAfterCreate(ctx, event); err != nil {
-			return err
-		}
-	}
-	return nil
-}
-
-func (s *Store) OnBeforeRecordUpdated(ctx context.Context, table string, record types.Record, oldRecord types.Record) error {
-	event := &events.OnBeforeRecordUpdatedEvent{
-		DatabaseEvent: events.DatabaseEvent{
-			Table:  table,
-			Record: record,
-		},
-		OldRecord: oldRecord,
-	}
-	for _, handler := range s.handlers {
-		if err := handler.OnBeforeUpdate(ctx, event); err != nil {
-			return err
-		}
-	}
-	return nil
-}
-
-func (s *Store) OnAfterRecordUpdated(ctx context.Context, table string, record types.Record, oldRecord types.Record) error {
-	event := &events.OnAfterRecordUpdatedEvent{
-		DatabaseEvent: events.DatabaseEvent{
-			Table:  table,
-			Record: record,
-		},
-		OldRecord: oldRecord,
-	}
-	for _, handler := range s.handlers {
-		if err := handler.OnAfterUpdate(ctx, event); err != nil {
-			return err
-		}
-	}
-	return nil
-}
-
-func (s *Store) OnBeforeRecordDeleted(ctx context.Context, table string, record types.Record) error {
-	event := &events.OnBeforeRecordDeletedEvent{
-		DatabaseEvent: events.DatabaseEvent{
-			Table:  table,
-			Record: record,
-		},
-	}
-	for _, handler := range s.handlers {
-		if err := handler.OnBeforeDelete(ctx, event); err != nil {
-			return err
-		}
-	}
-	return nil
-}
-
-func (s *Store) OnAfterRecordDeleted(ctx context.Context, table string, record types.Record) error {
-	event := &events.OnAfterRecordDeletedEvent{
-		DatabaseEvent: events.DatabaseEvent{
-			Table:  table,
-			Record: record,
-		},
-	}
-	for _, handler := range s.handlers {
-		if err := handler.OnAfterDelete(ctx, event); err != nil {
-			return err
-		}
-	}
-	return nil
-}
@@ -3,8 +3,9 @@ package store
 import (
 	"context"
 	"fmt"
-	"strings"
+	"sync"
 
+	"github.com/tuongaz/go-saas/pkg/log"
 	"github.com/tuongaz/go-saas/store/types"
 )
 
@@ -14,21 +15,53 @@ type dbxInterface interface {
 	Rollback() error
 }
 
+// StoreTx is a database transaction.
+// Before events are dispatched immediately so that handlers can still abort the write,
+// after events are buffered and only dispatched once the transaction is committed.
+// Rolling back the transaction drops the buffered events.
 type StoreTx struct {
-	tx    dbxInterface
-	store Interface
+	tx      dbxInterface
+	store   Interface
+	dialect Dialect
+	ctx     context.Context
+
+	mu             sync.Mutex
+	organisationID string
+	afterEvents    []func(ctx context.Context) error
+	afterCommit    []func(ctx context.Context) error
+	savepoints     int
 }
 
-func (s *StoreTx) Collection(table string) *collection {
+var _ RecordEvents = (*StoreTx)(nil)
+
+func (s *StoreTx) Collection(table string) CollectionInterface {
 	if !ValidTableName(table) {
 		panic(fmt.Sprintf("invalid table name: %s", table))
 	}
 
-	return &collection{
-		table: table,
-		db:    s.tx,
-		store: s.store,
+	s.mu.Lock()
+	organisationID := s.organisationID
+	s.mu.Unlock()
+
+	if tx, ok := s.tx.(*memoryTx); ok {
+		return tx.store.collection(table, s, tx, organisationID)
+	}
+
+	c := &collection{
+		table:          table,
+		db:             s.tx,
+		store:          s,
+		softDelete:     s.store.SoftDeleteEnabled(table),
+		schema:         s.store.Schema(table),
+		autoFields:     s.store.AutoFields(table),
+		relations:      s.store,
+		dialect:        s.dialect,
+		organisationID: organisationID,
 	}
+	if index, ok := s.store.SearchIndex(table); ok {
+		c.search = &index
+	}
+	return c
 }
 
 func (s *StoreTx) Exec(ctx context.Context, query string, args ...interface{}) error {
@@ -39,39 +72,146 @@ func (s *StoreTx) Exec(ctx context.Context, query string, args ...interface{}) e
 	return nil
 }
 
+// Commit commits the transaction, then dispatches the buffered after events
+// followed by the OnAfterCommit hooks.
+// The data is already committed when they run, so their errors are logged rather than returned.
 func (s *StoreTx) Commit() error {
-	return s.tx.Commit()
+	s.mu.Lock()
+	afterEvents := s.afterEvents
+	afterCommit := s.afterCommit
+	s.afterEvents = nil
+	s.afterCommit = nil
+	s.mu.Unlock()
+
+	if err := s.tx.Commit(); err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	if s.ctx != nil {
+		ctx = context.WithoutCancel(s.ctx)
+	}
+
+	for _, dispatch := range afterEvents {
+		if err := dispatch(ctx); err != nil {
+			log.Default().ErrorContext(ctx, "after commit event handler error", log.ErrorAttr(err))
+		}
+	}
+
+	for _, fn := range afterCommit {
+		if err := fn(ctx); err != nil {
+			log.Default().ErrorContext(ctx, "after commit hook error", log.ErrorAttr(err))
+		}
+	}
+
+	return nil
 }
 
+// Rollback aborts the transaction and drops the buffered after events and hooks
 func (s *StoreTx) Rollback() error {
+	s.mu.Lock()
+	s.afterEvents = nil
+	s.afterCommit = nil
+	s.mu.Unlock()
+
 	return s.tx.Rollback()
 }
 
-// Query executes a raw SQL query within a transaction and returns multiple records
-func (s *StoreTx) Query(ctx context.Context, query string, args ...any) (*List, error) {
-	rows, err := s.tx.QueryxContext(ctx, query, args...)
-	if err != nil {
-		return nil, fmt.Errorf("query execution failed: %w", err)
+// OnAfterCommit registers a function that runs once the transaction is committed.
+// Use it for side effects such as sending emails or calling webhooks,
+// which must not happen when the transaction is rolled back.
+func (s *StoreTx) OnAfterCommit(fn func(ctx context.Context) error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.afterCommit = append(s.afterCommit, fn)
+}
+
+// deferAfterEvent buffers an after event until the transaction is committed
+func (s *StoreTx) deferAfterEvent(fn func(ctx context.Context) error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.afterEvents = append(s.afterEvents, fn)
+}
+
+// Database events
+func (s *StoreTx) OnBeforeRecordCreated(ctx context.Context, table string, record types.Record) error {
+	return s.store.OnBeforeRecordCreated(ctx, table, record)
+}
+
+func (s *StoreTx) OnAfterRecordCreated(ctx context.Context, table string, record types.Record) error {
+	if err := s.recordChanged(ctx, table, ActionCreate, record); err != nil {
+		return err
 	}
-	defer rows.Close()
+	s.deferAfterEvent(func(ctx context.Context) error {
+		return s.store.OnAfterRecordCreated(ctx, table, record)
+	})
+	return nil
+}
 
-	var recs []types.Record
-	for rows.Next() {
-		rec := make(types.Record)
-		if err := rows.MapScan(rec); err != nil {
-			return nil, fmt.Errorf("failed to scan record into map: %v", err)
-		}
-		rec.Normalise()
+func (s *StoreTx) OnBeforeRecordUpdated(ctx context.Context, table string, record types.Record, oldRecord types.Record) error {
+	return s.store.OnBeforeRecordUpdated(ctx, table, record, oldRecord)
+}
 
-		recs = append(recs, rec)
+func (s *StoreTx) OnAfterRecordUpdated(ctx context.Context, table string, record types.Record, oldRecord types.Record) error {
+	if err := s.recordChanged(ctx, table, ActionUpdate, record); err != nil {
+		return err
 	}
-	if err = rows.Err(); err != nil {
-		return nil, fmt.Errorf("error processing rows: %v", err)
+	s.deferAfterEvent(func(ctx context.Context) error {
+		return s.store.OnAfterRecordUpdated(ctx, table, record, oldRecord)
+	})
+	return nil
+}
+
+func (s *StoreTx) OnBeforeRecordDeleted(ctx context.Context, table string, record types.Record) error {
+	return s.store.OnBeforeRecordDeleted(ctx, table, record)
+}
+
+func (s *StoreTx) OnAfterRecordDeleted(ctx context.Context, table string, record types.Record) error {
+	if err := s.recordChanged(ctx, table, ActionDelete, record); err != nil {
+		return err
 	}
+	s.deferAfterEvent(func(ctx context.Context) error {
+		return s.store.OnAfterRecordDeleted(ctx, table, record)
+	})
+	return nil
+}
 
-	return &List{
-		Records: recs,
-	}, nil
+func (s *StoreTx) OnBeforeRecordRestored(ctx context.Context, table string, record types.Record) error {
+	return s.store.OnBeforeRecordRestored(ctx, table, record)
+}
+
+func (s *StoreTx) OnAfterRecordRestored(ctx context.Context, table string, record types.Record) error {
+	if err := s.recordChanged(ctx, table, ActionRestore, record); err != nil {
+		return err
+	}
+	s.deferAfterEvent(func(ctx context.Context) error {
+		return s.store.OnAfterRecordRestored(ctx, table, record)
+	})
+	return nil
+}
+
+func (s *StoreTx) OnBeforeRecordPurged(ctx context.Context, table string, record types.Record) error {
+	return s.store.OnBeforeRecordPurged(ctx, table, record)
+}
+
+func (s *StoreTx) OnAfterRecordPurged(ctx context.Context, table string, record types.Record) error {
+	if err := s.recordChanged(ctx, table, ActionPurge, record); err != nil {
+		return err
+	}
+	s.deferAfterEvent(func(ctx context.Context) error {
+		return s.store.OnAfterRecordPurged(ctx, table, record)
+	})
+	return nil
+}
+
+// Query executes a raw SQL query within a transaction and returns multiple records
+func (s *StoreTx) Query(ctx context.Context, query string, args ...any) (*List, error) {
+	return s.QueryBuilder().
+		WithQuery(query).
+		WithArgs(args...).
+		ExecuteTx(ctx, s)
 }
 
 // QueryBuilder creates a new query options builder for transactions
@@ -83,34 +223,11 @@ func (s *StoreTx) QueryBuilder() *RawQueryOptions {
 
 // QueryWithPagination executes a SQL query with pagination support within a transaction
 func (s *StoreTx) QueryWithPagination(ctx context.Context, query string, pagination Pagination, args ...any) (*List, error) {
-	// Apply pagination to the query
-	paginatedQuery := query
-	if pagination.Limit > 0 {
-		paginatedQuery = fmt.Sprintf("%s LIMIT %d OFFSET %d", query, pagination.Limit, pagination.Offset)
-	}
-
-	// Execute the query
-	list, err := s.Query(ctx, paginatedQuery, args...)
-	if err != nil {
-		return nil, err
-	}
-
-	// Add pagination metadata
-	list.Meta.Limit = pagination.Limit
-	list.Meta.Offset = pagination.Offset
-
-	// Try to get total count if pagination is used
-	if pagination.Limit > 0 {
-		countQuery := fmt.Sprintf("SELECT COUNT(*) FROM (%s) as count_query",
-			strings.Replace(query, "SELECT * FROM", "SELECT 1 FROM", 1))
-		var total int
-		err := s.tx.GetContext(ctx, &total, countQuery, args...)
-		if err == nil {
-			list.Meta.Total = total
-		}
-	}
-
-	return list, nil
+	return s.QueryBuilder().
+		WithQuery(query).
+		WithArgs(args...).
+		WithPagination(pagination).
+		ExecuteTx(ctx, s)
 }
 
 func (s *StoreTx) QueryValue(ctx context.Context, query string, dest any, args ...any) error {
@@ -0,0 +1,90 @@
+package store
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tuongaz/go-saas/store/events"
+	"github.com/tuongaz/go-saas/store/types"
+)
+
+type fakeTx struct {
+	dbInterface
+	committed  bool
+	rolledBack bool
+}
+
+func (f *fakeTx) Commit() error {
+	f.committed = true
+	return nil
+}
+
+func (f *fakeTx) Rollback() error {
+	f.rolledBack = true
+	return nil
+}
+
+type recordingHandler struct {
+	events.Handler
+	created []string
+}
+
+func (h *recordingHandler) OnBeforeCreate(ctx context.Context, event *events.OnBeforeRecordCreatedEvent) error {
+	h.created = append(h.created, "before:"+event.Table)
+	return nil
+}
+
+func (h *recordingHandler) OnAfterCreate(ctx context.Context, event *events.OnAfterRecordCreatedEvent) error {
+	h.created = append(h.created, "after:"+event.Table)
+	return nil
+}
+
+func newTestTx() (*StoreTx, *fakeTx, *recordingHandler) {
+	handler := &recordingHandler{}
+	st := &Store{}
+	st.AddEventHandler(handler)
+	tx := &fakeTx{}
+
+	return &StoreTx{tx: tx, store: st, ctx: context.Background()}, tx, handler
+}
+
+func TestStoreTx_Events(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("dispatches after events on commit", func(t *testing.T) {
+		storeTx, tx, handler := newTestTx()
+
+		var committed []string
+		storeTx.OnAfterCommit(func(ctx context.Context) error {
+			committed = append(committed, "hook")
+			return nil
+		})
+
+		assert.NoError(t, storeTx.OnBeforeRecordCreated(ctx, "account", types.Record{}))
+		assert.NoError(t, storeTx.OnAfterRecordCreated(ctx, "account", types.Record{}))
+		assert.Equal(t, []string{"before:account"}, handler.created)
+		assert.Empty(t, committed)
+
+		assert.NoError(t, storeTx.Commit())
+		assert.True(t, tx.committed)
+		assert.Equal(t, []string{"before:account", "after:account"}, handler.created)
+		assert.Equal(t, []string{"hook"}, committed)
+	})
+
+	t.Run("drops after events on rollback", func(t *testing.T) {
+		storeTx, tx, handler := newTestTx()
+
+		hookCalled := false
+		storeTx.OnAfterCommit(func(ctx context.Context) error {
+			hookCalled = true
+			return nil
+		})
+
+		assert.NoError(t, storeTx.OnAfterRecordCreated(ctx, "account", types.Record{}))
+		assert.NoError(t, storeTx.Rollback())
+		assert.True(t, tx.rolledBack)
+		assert.Empty(t, handler.created)
+		assert.False(t, hookCalled)
+	})
+}
@@ -0,0 +1,150 @@
+package store
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/tuongaz/go-saas/store/types"
+)
+
+const (
+	// TenantColumn is the column holding the organisation of tenant scoped records
+	TenantColumn = "organisation_id"
+
+	// TenantSetting is the Postgres setting holding the organisation of a tenant scoped transaction.
+	// RLS policies can read it with current_setting('app.organisation_id', true).
+	TenantSetting = "app.organisation_id"
+)
+
+// TenantScoped scopes the collection to an organisation.
+// Reads, updates and deletes only see the records of the organisation,
+// and inserts are stamped with it.
+func TenantScoped(organisationID string) CollectionOption {
+	if organisationID == "" {
+		panic("tenant scope requires an organisation id")
+	}
+
+	return func(c *collection) {
+		c.organisationID = organisationID
+	}
+}
+
+// addTenantScope restricts the query to the records of the organisation, on tenant scoped collections only
+func (c *collection) addTenantScope(where *whereBuilder) {
+	if c.organisationID == "" {
+		return
+	}
+
+	where.add(fmt.Sprintf("%s = $%d", TenantColumn, where.nextIdx()), c.organisationID)
+}
+
+// stampTenant sets the organisation of a record written to a tenant scoped collection.
+// A record that already belongs to another organisation is rejected.
+func (c *collection) stampTenant(record types.Record) error {
+	if c.organisationID == "" {
+		return nil
+	}
+
+	if err := c.checkTenant(record); err != nil {
+		return err
+	}
+	record[TenantColumn] = c.organisationID
+
+	return nil
+}
+
+// checkTenant rejects a record that would move to another organisation
+func (c *collection) checkTenant(record types.Record) error {
+	if c.organisationID == "" {
+		return nil
+	}
+
+	if value, ok := record[TenantColumn]; ok && fmt.Sprint(value) != c.organisationID {
+		return NewTenantErr(fmt.Errorf("%s record cannot be written to organisation %v", c.table, value))
+	}
+
+	return nil
+}
+
+// TenantStore is a view of the store whose collections are scoped to an organisation
+type TenantStore struct {
+	store          tenantBackend
+	organisationID string
+}
+
+// tenantBackend is a store whose collections can be scoped to an organisation
+type tenantBackend interface {
+	Tx(ctx context.Context) (*StoreTx, error)
+	WithTx(ctx context.Context, opts *TxOptions, fn func(tx *StoreTx) error) error
+	tenantCollection(table, organisationID string) CollectionInterface
+}
+
+// ForOrganisation returns a view of the store whose collections are scoped to the organisation.
+// The tables accessed through it must have an organisation_id column.
+func (s *Store) ForOrganisation(organisationID string) *TenantStore {
+	if organisationID == "" {
+		panic("tenant store requires an organisation id")
+	}
+
+	return &TenantStore{
+		store:          s,
+		organisationID: organisationID,
+	}
+}
+
+func (s *Store) tenantCollection(table, organisationID string) CollectionInterface {
+	opts := append(s.collectionOptions(table), TenantScoped(organisationID))
+	return withTxEvents(NewCollection(table, s.db, s, opts...), s, s.ForOrganisation(organisationID).WithTx)
+}
+
+// OrganisationID returns the organisation the store is scoped to
+func (t *TenantStore) OrganisationID() string {
+	return t.organisationID
+}
+
+// Collection returns a collection scoped to the organisation
+func (t *TenantStore) Collection(table string) CollectionInterface {
+	if !ValidTableName(table) {
+		panic(fmt.Sprintf("invalid table name: %s", table))
+	}
+
+	return t.store.tenantCollection(table, t.organisationID)
+}
+
+// Tx begins a transaction scoped to the organisation.
+// The organisation is also set in the app.organisation_id setting for the transaction,
+// so that RLS policies apply to every statement run in it.
+func (t *TenantStore) Tx(ctx context.Context) (*StoreTx, error) {
+	tx, err := t.store.Tx(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.SetOrganisation(ctx, t.organisationID); err != nil {
+		_ = tx.Rollback()
+		return nil, err
+	}
+
+	return tx, nil
+}
+
+// SetOrganisation scopes the collections of the transaction to the organisation
+// and sets the app.organisation_id setting until the transaction ends.
+func (s *StoreTx) SetOrganisation(ctx context.Context, organisationID string) error {
+	if organisationID == "" {
+		return fmt.Errorf("tenant transaction requires an organisation id")
+	}
+
+	// Memory transactions have no settings, their collections are scoped like the others
+	if _, ok := s.tx.(*memoryTx); !ok {
+		if _, err := s.tx.ExecContext(ctx, "SELECT set_config($1, $2, true)", TenantSetting, organisationID); err != nil {
+			return fmt.Errorf("set tenant setting: %w", err)
+		}
+	}
+
+	s.mu.Lock()
+	s.organisationID = organisationID
+	s.mu.Unlock()
+
+	return nil
+}
@@ -0,0 +1,79 @@
+package store
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tuongaz/go-saas/store/types"
+)
+
+type txRecorder struct {
+	queryRecorder
+}
+
+func (r *txRecorder) Commit() error   { return nil }
+func (r *txRecorder) Rollback() error { return nil }
+
+func TestCollection_TenantScope(t *testing.T) {
+	ctx := context.Background()
+	db := &queryRecorder{}
+	c := NewCollection("projects", db, nil, TenantScoped("org1"))
+
+	t.Run("count is scoped", func(t *testing.T) {
+		_, err := c.Count(ctx, Filter{"name": "a"})
+		assert.NoError(t, err)
+		assert.Equal(t, "SELECT COUNT(*) FROM projects WHERE name = $1 AND organisation_id = $2", db.query)
+		assert.Equal(t, []any{"a", "org1"}, db.args)
+	})
+
+	t.Run("delete records is scoped", func(t *testing.T) {
+		assert.NoError(t, c.DeleteRecords(ctx, Filter{}))
+		assert.Equal(t, "DELETE FROM projects WHERE organisation_id = $1", db.query)
+		assert.Equal(t, []any{"org1"}, db.args)
+	})
+
+	t.Run("soft delete records is scoped", func(t *testing.T) {
+		soft := NewCollection("projects", db, nil, SoftDelete(), TenantScoped("org1"))
+		assert.NoError(t, soft.DeleteRecords(ctx, Filter{}))
+		assert.Equal(t, "UPDATE projects SET deleted_at = $2 WHERE deleted_at IS NULL AND organisation_id = $1", db.query)
+	})
+
+	t.Run("cross tenant writes are rejected", func(t *testing.T) {
+		_, err := c.CreateRecord(ctx, types.Record{"name": "a", "organisation_id": "org2"})
+		assert.True(t, IsTenantError(err))
+
+		_, err = c.UpdateRecord(ctx, "id1", types.Record{"organisation_id": "org2"})
+		assert.True(t, IsTenantError(err))
+
+		_, err = c.Update(ctx, types.Record{"organisation_id": "org2"})
+		assert.True(t, IsTenantError(err))
+	})
+
+	t.Run("inserts are stamped", func(t *testing.T) {
+		record := types.Record{"name": "a"}
+		assert.NoError(t, c.(*collection).stampTenant(record))
+		assert.Equal(t, "org1", record[TenantColumn])
+	})
+
+	t.Run("unscoped collection is unchanged", func(t *testing.T) {
+		_, err := NewCollection("projects", db, nil).Count(ctx, Filter{})
+		assert.NoError(t, err)
+		assert.Equal(t, "SELECT COUNT(*) FROM projects", db.query)
+	})
+
+	assert.Panics(t, func() { TenantScoped("") })
+}
+
+func TestStoreTx_SetOrganisation(t *testing.T) {
+	ctx := context.Background()
+	db := &txRecorder{}
+	tx := &StoreTx{tx: db, store: &Store{}}
+
+	assert.Error(t, tx.SetOrganisation(ctx, ""))
+
+	assert.NoError(t, tx.SetOrganisation(ctx, "org1"))
+	assert.Equal(t, "SELECT set_config($1, $2, true)", db.query)
+	assert.Equal(t, []any{TenantSetting, "org1"}, db.args)
+	assert.Equal(t, "org1", tx.Collection("projects").(*collection).organisationID)
+}
@@ -0,0 +1,152 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/tuongaz/go-saas/store/types"
+)
+
+// Actions of the record changes seen by the transactional event handlers
+const (
+	ActionCreate  = "create"
+	ActionUpdate  = "update"
+	ActionDelete  = "delete"
+	ActionRestore = "restore"
+	ActionPurge   = "purge"
+)
+
+// RecordChange is a record written in a transaction
+type RecordChange struct {
+	Table  string
+	Action string
+	Record types.Record
+}
+
+// TxEventHandler is called after each record write, within the transaction of the write,
+// so that its own writes, e.g. a change log or an outbox message, commit or roll back with
+// the record. An error aborts the write.
+type TxEventHandler func(ctx context.Context, tx *StoreTx, change RecordChange) error
+
+// txEventRegistry holds the transactional event handlers of a store, by table
+type txEventRegistry struct {
+	txMu       sync.RWMutex
+	txHandlers map[string][]TxEventHandler
+}
+
+// AddTxEventHandler registers a transactional event handler for the writes of the tables.
+// The writes made to the tables outside a transaction run in one, so that the handler always
+// runs in the transaction of the write.
+func (h *txEventRegistry) AddTxEventHandler(handler TxEventHandler, tables ...string) {
+	h.txMu.Lock()
+	defer h.txMu.Unlock()
+
+	if h.txHandlers == nil {
+		h.txHandlers = map[string][]TxEventHandler{}
+	}
+	for _, table := range tables {
+		if !ValidTableName(table) {
+			panic(fmt.Sprintf("invalid table name: %s", table))
+		}
+		h.txHandlers[table] = append(h.txHandlers[table], handler)
+	}
+}
+
+func (h *txEventRegistry) txEventHandlers(table string) []TxEventHandler {
+	h.txMu.RLock()
+	defer h.txMu.RUnlock()
+
+	return h.txHandlers[table]
+}
+
+// txEventSource is a store with transactional event handlers
+type txEventSource interface {
+	txEventHandlers(table string) []TxEventHandler
+}
+
+// recordChanged calls the transactional event handlers of the table
+func (s *StoreTx) recordChanged(ctx context.Context, table, action string, record types.Record) error {
+	source, ok := s.store.(txEventSource)
+	if !ok {
+		return nil
+	}
+
+	for _, handler := range source.txEventHandlers(table) {
+		if err := handler(ctx, s, RecordChange{Table: table, Action: action, Record: record}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// txCollection runs the writes of a collection whose table has transactional event handlers
+// in a transaction. Update and DeleteRecords fire no record events, they go to the collection
+// like the reads.
+type txCollection struct {
+	CollectionInterface
+	withTx func(ctx context.Context, opts *TxOptions, fn func(tx *StoreTx) error) error
+}
+
+// withTxEvents wraps the collection in a txCollection when the table has transactional event handlers
+func withTxEvents(c CollectionInterface, source txEventSource, withTx func(ctx context.Context, opts *TxOptions, fn func(tx *StoreTx) error) error) CollectionInterface {
+	if len(source.txEventHandlers(c.Table())) == 0 {
+		return c
+	}
+	return &txCollection{CollectionInterface: c, withTx: withTx}
+}
+
+// inTx runs the write on the collection of the table in a transaction
+func inTx[T any](ctx context.Context, c *txCollection, write func(coll CollectionInterface) (T, error)) (T, error) {
+	var result T
+	err := c.withTx(ctx, nil, func(tx *StoreTx) error {
+		var err error
+		result, err = write(tx.Collection(c.Table()))
+		return err
+	})
+	return result, err
+}
+
+func (c *txCollection) CreateRecord(ctx context.Context, record types.Record) (*types.Record, error) {
+	return inTx(ctx, c, func(coll CollectionInterface) (*types.Record, error) {
+		return coll.CreateRecord(ctx, record)
+	})
+}
+
+func (c *txCollection) CreateRecords(ctx context.Context, records []types.Record) ([]types.Record, error) {
+	return inTx(ctx, c, func(coll CollectionInterface) ([]types.Record, error) {
+		return coll.CreateRecords(ctx, records)
+	})
+}
+
+func (c *txCollection) Upsert(ctx context.Context, record types.Record, conflictColumns []string, updateColumns []string) (*types.Record, error) {
+	return inTx(ctx, c, func(coll CollectionInterface) (*types.Record, error) {
+		return coll.Upsert(ctx, record, conflictColumns, updateColumns)
+	})
+}
+
+func (c *txCollection) UpdateRecord(ctx context.Context, id any, record types.Record, opts ...UpdateOption) (*types.Record, error) {
+	return inTx(ctx, c, func(coll CollectionInterface) (*types.Record, error) {
+		return coll.UpdateRecord(ctx, id, record, opts...)
+	})
+}
+
+func (c *txCollection) DeleteRecord(ctx context.Context, id any) error {
+	_, err := inTx(ctx, c, func(coll CollectionInterface) (struct{}, error) {
+		return struct{}{}, coll.DeleteRecord(ctx, id)
+	})
+	return err
+}
+
+func (c *txCollection) Restore(ctx context.Context, id any) (*types.Record, error) {
+	return inTx(ctx, c, func(coll CollectionInterface) (*types.Record, error) {
+		return coll.Restore(ctx, id)
+	})
+}
+
+func (c *txCollection) PurgeDeletedBefore(ctx context.Context, t time.Time) (int64, error) {
+	return inTx(ctx, c, func(coll CollectionInterface) (int64, error) {
+		return coll.PurgeDeletedBefore(ctx, t)
+	})
+}
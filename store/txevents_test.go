@@ -0,0 +1,81 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/tuongaz/go-saas/store/types"
+)
+
+func TestMemoryStore_TxEventHandler(t *testing.T) {
+	ctx := context.Background()
+	st := NewMemory()
+
+	var failOn string
+	st.AddTxEventHandler(func(ctx context.Context, tx *StoreTx, change RecordChange) error {
+		if change.Record.String("id") == failOn {
+			return errors.New("failed")
+		}
+		_, err := tx.Collection("change_log").CreateRecord(ctx, types.Record{
+			"table":     change.Table,
+			"action":    change.Action,
+			"record_id": change.Record.String("id"),
+		})
+		return err
+	}, "books")
+
+	logged := func() []string {
+		list, err := st.Collection("change_log").Find(ctx)
+		assert.NoError(t, err)
+		var out []string
+		for _, record := range list.Records {
+			out = append(out, record.String("action")+":"+record.String("record_id"))
+		}
+		return out
+	}
+
+	t.Run("writes outside a transaction run in one", func(t *testing.T) {
+		books := st.Collection("books")
+		_, err := books.CreateRecord(ctx, types.Record{"id": "b1", "title": "Go"})
+		assert.NoError(t, err)
+		_, err = books.UpdateRecord(ctx, "b1", types.Record{"title": "Go 2"})
+		assert.NoError(t, err)
+		assert.NoError(t, books.DeleteRecord(ctx, "b1"))
+
+		assert.Equal(t, []string{"create:b1", "update:b1", "delete:b1"}, logged())
+	})
+
+	t.Run("a handler error aborts the write", func(t *testing.T) {
+		failOn = "b2"
+		defer func() { failOn = "" }()
+
+		_, err := st.Collection("books").CreateRecord(ctx, types.Record{"id": "b2"})
+		assert.ErrorContains(t, err, "failed")
+
+		exists, err := st.Collection("books").Exists(ctx, Filter{"id": "b2"})
+		assert.NoError(t, err)
+		assert.False(t, exists)
+	})
+
+	t.Run("rolled back with the transaction", func(t *testing.T) {
+		err := st.WithTx(ctx, nil, func(tx *StoreTx) error {
+			if _, err := tx.Collection("books").CreateRecord(ctx, types.Record{"id": "b3"}); err != nil {
+				return err
+			}
+			return errors.New("failed")
+		})
+		assert.EqualError(t, err, "failed")
+
+		assert.Equal(t, []string{"create:b1", "update:b1", "delete:b1"}, logged())
+	})
+
+	t.Run("other tables are not wrapped", func(t *testing.T) {
+		_, ok := st.Collection("authors").(*txCollection)
+		assert.False(t, ok)
+		_, ok = st.ForOrganisation("org1").Collection("books").(*txCollection)
+		assert.True(t, ok)
+	})
+}
@@ -143,47 +143,66 @@ func (r Record) PrepareForDB() (keys []string, values []any, placeholders []stri
 	placeholders = make([]string, 0, len(r))
 
 	for k, v := range r {
-		keys = append(keys, k)
-
-		switch value := v.(type) {
-		case string, int, int64, float64, bool:
-			values = append(values, v)
-		case time.Time:
-			// Convert time to UTC before storing
-			values = append(values, value.UTC())
-		case nil:
-			// Explicitly handle nil as NULL
-			values = append(values, nil)
-		case *string, *int, *int64, *float64, *bool:
-			rv := reflect.ValueOf(value)
-			if rv.IsNil() {
-				values = append(values, nil)
-			} else {
-				values = append(values, rv.Elem().Interface())
-			}
-		case *time.Time:
-			rv := reflect.ValueOf(value)
-			if rv.IsNil() {
-				values = append(values, nil)
-			} else {
-				// Convert time pointer to UTC before storing
-				timeVal := rv.Elem().Interface().(time.Time)
-				values = append(values, timeVal.UTC())
-			}
-		default:
-			jsonBytes, marshalErr := json.Marshal(value)
-			if marshalErr != nil {
-				return nil, nil, nil, fmt.Errorf("failed to marshal value to JSON for key %s: %w", k, marshalErr)
-			}
-			values = append(values, string(jsonBytes))
+		value, err := prepareValue(k, v)
+		if err != nil {
+			return nil, nil, nil, err
 		}
 
+		keys = append(keys, k)
+		values = append(values, value)
 		placeholders = append(placeholders, fmt.Sprintf("$%d", len(placeholders)+1))
 	}
 
 	return keys, values, placeholders, nil
 }
 
+// PrepareValuesForDB converts the values of the given keys into database values, in the order of keys.
+// Missing keys are returned as nil.
+func (r Record) PrepareValuesForDB(keys []string) ([]any, error) {
+	values := make([]any, 0, len(keys))
+	for _, k := range keys {
+		value, err := prepareValue(k, r[k])
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+	}
+
+	return values, nil
+}
+
+// prepareValue converts a record value into a value accepted by the database driver
+func prepareValue(key string, v any) (any, error) {
+	switch value := v.(type) {
+	case string, int, int64, float64, bool:
+		return v, nil
+	case time.Time:
+		// Convert time to UTC before storing
+		return value.UTC(), nil
+	case nil:
+		// Explicitly handle nil as NULL
+		return nil, nil
+	case *string, *int, *int64, *float64, *bool:
+		rv := reflect.ValueOf(value)
+		if rv.IsNil() {
+			return nil, nil
+		}
+		return rv.Elem().Interface(), nil
+	case *time.Time:
+		if value == nil {
+			return nil, nil
+		}
+		// Convert time pointer to UTC before storing
+		return value.UTC(), nil
+	default:
+		jsonBytes, err := json.Marshal(value)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal value to JSON for key %s: %w", key, err)
+		}
+		return string(jsonBytes), nil
+	}
+}
+
 func (r Record) Get(key string) any {
 	return r[key]
 }
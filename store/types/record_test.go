@@ -176,3 +176,18 @@ func TestRecord_Decode(t *testing.T) {
 	assert.Equal(t, "test", user.Name)
 	assert.Equal(t, 30, user.Age)
 }
+
+func TestRecord_PrepareValuesForDB(t *testing.T) {
+	now := time.Date(2025, 1, 1, 10, 0, 0, 0, time.FixedZone("AEST", 10*60*60))
+	name := "test"
+
+	record := Record{
+		"name":       &name,
+		"created_at": now,
+		"tags":       []string{"a", "b"},
+	}
+
+	values, err := record.PrepareValuesForDB([]string{"tags", "name", "created_at", "missing"})
+	assert.NoError(t, err)
+	assert.Equal(t, []any{`["a","b"]`, "test", now.UTC(), nil}, values)
+}
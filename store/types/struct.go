@@ -0,0 +1,314 @@
+package types
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	tagName = "db"
+
+	// relationTagName maps a field to an expanded relation, e.g. `db:"-" relation:"account"`
+	relationTagName = "relation"
+)
+
+var (
+	timeType    = reflect.TypeOf(time.Time{})
+	scannerType = reflect.TypeOf((*sql.Scanner)(nil)).Elem()
+	valuerType  = reflect.TypeOf((*driver.Valuer)(nil)).Elem()
+
+	structFieldsCache sync.Map // map[reflect.Type][]structField
+)
+
+// structField is a struct field mapped to a column with a db tag, or to a relation
+type structField struct {
+	column    string
+	index     []int
+	omitEmpty bool
+
+	// relation fields are only scanned, from the relations nested by WithExpand
+	relation bool
+}
+
+// structFields returns the fields of t that have a db or relation tag, including the fields of
+// embedded structs. Fields tagged with db:"-" are skipped unless they have a relation tag,
+// db:"name,omitempty" skips zero values when writing.
+func structFields(t reflect.Type) []structField {
+	if cached, ok := structFieldsCache.Load(t); ok {
+		return cached.([]structField)
+	}
+
+	var fields []structField
+	var walk func(t reflect.Type, index []int)
+	walk = func(t reflect.Type, index []int) {
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			fieldIndex := append(append([]int{}, index...), i)
+			if relation := f.Tag.Get(relationTagName); relation != "" && f.IsExported() {
+				fields = append(fields, structField{column: relation, index: fieldIndex, relation: true})
+				continue
+			}
+
+			tag, hasTag := f.Tag.Lookup(tagName)
+			if tag == "-" {
+				continue
+			}
+
+			if f.Anonymous && !hasTag && f.Type.Kind() == reflect.Struct {
+				walk(f.Type, fieldIndex)
+				continue
+			}
+			if !hasTag || !f.IsExported() {
+				continue
+			}
+
+			name, opts, _ := strings.Cut(tag, ",")
+			if name == "" {
+				continue
+			}
+			fields = append(fields, structField{
+				column:    name,
+				index:     fieldIndex,
+				omitEmpty: opts == "omitempty",
+			})
+		}
+	}
+	walk(t, nil)
+
+	structFieldsCache.Store(t, fields)
+	return fields
+}
+
+func structValue(v any) (reflect.Value, error) {
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Pointer {
+		if rv.IsNil() {
+			return reflect.Value{}, fmt.Errorf("nil %s", rv.Type())
+		}
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return reflect.Value{}, fmt.Errorf("expected a struct, got %s", rv.Type())
+	}
+	return rv, nil
+}
+
+// Columns returns the column names mapped by the db tags of a struct, in field order
+func Columns(v any) ([]string, error) {
+	rv, err := structValue(v)
+	if err != nil {
+		return nil, err
+	}
+
+	fields := structFields(rv.Type())
+	columns := make([]string, 0, len(fields))
+	for _, f := range fields {
+		if !f.relation {
+			columns = append(columns, f.column)
+		}
+	}
+	return columns, nil
+}
+
+// RecordFromStruct converts a struct into a Record using its db tags, the relation fields are left out.
+// Named basic types are converted to their underlying type and nil pointers to nil,
+// so that the values can be written to the database as they are.
+func RecordFromStruct(v any) (Record, error) {
+	rv, err := structValue(v)
+	if err != nil {
+		return nil, err
+	}
+
+	fields := structFields(rv.Type())
+	record := make(Record, len(fields))
+	for _, f := range fields {
+		if f.relation {
+			continue
+		}
+
+		fv := rv.FieldByIndex(f.index)
+		if f.omitEmpty && fv.IsZero() {
+			continue
+		}
+
+		value, err := columnValue(fv)
+		if err != nil {
+			return nil, fmt.Errorf("column %s: %w", f.column, err)
+		}
+		record[f.column] = value
+	}
+
+	return record, nil
+}
+
+func columnValue(v reflect.Value) (any, error) {
+	if v.Type().Implements(valuerType) {
+		if v.Kind() == reflect.Pointer && v.IsNil() {
+			return nil, nil
+		}
+		return v.Interface().(driver.Valuer).Value()
+	}
+
+	switch v.Kind() {
+	case reflect.Pointer:
+		if v.IsNil() {
+			return nil, nil
+		}
+		return columnValue(v.Elem())
+	case reflect.String:
+		return v.String(), nil
+	case reflect.Bool:
+		return v.Bool(), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int(), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return int64(v.Uint()), nil
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), nil
+	case reflect.Slice:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			if v.IsNil() {
+				return nil, nil
+			}
+			// json.RawMessage and []byte are stored as JSON text
+			return string(v.Bytes()), nil
+		}
+	}
+
+	return v.Interface(), nil
+}
+
+// ScanStruct copies the record values into the db tagged fields of the struct pointed to by dst,
+// and the expanded relations into its relation tagged fields.
+// Unlike Decode it assigns the values directly, only JSON values are decoded through encoding/json.
+// Columns without a matching field are ignored.
+func (r Record) ScanStruct(dst any) error {
+	rv := reflect.ValueOf(dst)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() {
+		return fmt.Errorf("scan struct: expected a non nil pointer, got %T", dst)
+	}
+	rv = rv.Elem()
+	if rv.Kind() != reflect.Struct {
+		return fmt.Errorf("scan struct: expected a pointer to a struct, got %T", dst)
+	}
+
+	for _, f := range structFields(rv.Type()) {
+		value, ok := r[f.column]
+		if !ok {
+			continue
+		}
+
+		if err := assignValue(fieldByIndexAlloc(rv, f.index), value); err != nil {
+			return fmt.Errorf("scan column %s: %w", f.column, err)
+		}
+	}
+
+	return nil
+}
+
+// fieldByIndexAlloc is like FieldByIndex but allocates nil embedded struct pointers
+func fieldByIndexAlloc(v reflect.Value, index []int) reflect.Value {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Pointer {
+			if v.IsNil() {
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v
+}
+
+// assignValue assigns a record value to dst, converting it to the type of dst
+func assignValue(dst reflect.Value, value any) error {
+	if value == nil {
+		dst.Set(reflect.Zero(dst.Type()))
+		return nil
+	}
+
+	if reflect.PointerTo(dst.Type()).Implements(scannerType) {
+		return dst.Addr().Interface().(sql.Scanner).Scan(value)
+	}
+
+	if dst.Kind() == reflect.Pointer {
+		elem := reflect.New(dst.Type().Elem())
+		if err := assignValue(elem.Elem(), value); err != nil {
+			return err
+		}
+		dst.Set(elem)
+		return nil
+	}
+
+	src := reflect.ValueOf(value)
+	if src.Type().AssignableTo(dst.Type()) {
+		dst.Set(src)
+		return nil
+	}
+
+	switch {
+	case dst.Type() == timeType:
+		s, ok := value.(string)
+		if !ok {
+			break
+		}
+		t, err := time.Parse(time.RFC3339Nano, s)
+		if err != nil {
+			return err
+		}
+		dst.Set(reflect.ValueOf(t))
+		return nil
+	case dst.Kind() == reflect.Slice && dst.Type().Elem().Kind() == reflect.Uint8:
+		var data []byte
+		switch v := value.(type) {
+		case string:
+			data = []byte(v)
+		case []byte:
+			data = v
+		default:
+			// JSON columns are decoded by Normalise, encode them back for json.RawMessage
+			encoded, err := json.Marshal(v)
+			if err != nil {
+				return err
+			}
+			data = encoded
+		}
+		dst.SetBytes(append([]byte{}, data...))
+		return nil
+	case dst.Kind() == reflect.String && src.Kind() == reflect.Slice && src.Type().Elem().Kind() == reflect.Uint8:
+		dst.SetString(string(src.Bytes()))
+		return nil
+	case isBasicKind(dst.Kind()) && isBasicKind(src.Kind()) && src.Type().ConvertibleTo(dst.Type()):
+		if (dst.Kind() == reflect.String) != (src.Kind() == reflect.String) {
+			break
+		}
+		dst.Set(src.Convert(dst.Type()))
+		return nil
+	case dst.Kind() == reflect.Struct, dst.Kind() == reflect.Map, dst.Kind() == reflect.Slice:
+		// JSON columns decoded into a struct, map or slice field
+		encoded, err := json.Marshal(value)
+		if err != nil {
+			return err
+		}
+		return json.Unmarshal(encoded, dst.Addr().Interface())
+	}
+
+	return fmt.Errorf("cannot assign %T to %s", value, dst.Type())
+}
+
+func isBasicKind(k reflect.Kind) bool {
+	switch k {
+	case reflect.Bool, reflect.String,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
+}
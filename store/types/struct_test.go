@@ -0,0 +1,101 @@
+package types
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type role string
+
+type base struct {
+	ID string `db:"id"`
+}
+
+type structModel struct {
+	base
+	Name      string           `db:"name"`
+	Role      role             `db:"role"`
+	Count     int              `db:"count"`
+	Note      *string          `db:"note"`
+	Metadata  *json.RawMessage `db:"metadata"`
+	Tags      []string         `db:"tags"`
+	Optional  string           `db:"optional,omitempty"`
+	CreatedAt time.Time        `db:"created_at"`
+	Ignored   string           `db:"-"`
+	Untagged  string
+}
+
+func TestRecordFromStruct(t *testing.T) {
+	now := time.Date(2025, 1, 1, 10, 0, 0, 0, time.UTC)
+	metadata := json.RawMessage(`{"a":1}`)
+
+	record, err := RecordFromStruct(&structModel{
+		base:      base{ID: "1"},
+		Name:      "test",
+		Role:      "admin",
+		Count:     2,
+		Metadata:  &metadata,
+		CreatedAt: now,
+		Ignored:   "ignored",
+		Untagged:  "untagged",
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, Record{
+		"id":         "1",
+		"name":       "test",
+		"role":       "admin",
+		"count":      int64(2),
+		"note":       nil,
+		"metadata":   `{"a":1}`,
+		"tags":       []string(nil),
+		"created_at": now,
+	}, record)
+
+	_, err = RecordFromStruct("not a struct")
+	assert.Error(t, err)
+
+	columns, err := Columns(structModel{})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"id", "name", "role", "count", "note", "metadata", "tags", "optional", "created_at"}, columns)
+}
+
+func TestRecord_ScanStruct(t *testing.T) {
+	now := time.Date(2025, 1, 1, 10, 0, 0, 0, time.UTC)
+	record := Record{
+		"id":         "1",
+		"name":       "test",
+		"role":       "admin",
+		"count":      float64(3),
+		"note":       "a note",
+		"metadata":   map[string]any{"a": float64(1)},
+		"tags":       []any{"x", "y"},
+		"created_at": now,
+		"unknown":    "ignored",
+	}
+	record.Normalise()
+
+	var m structModel
+	assert.NoError(t, record.ScanStruct(&m))
+	assert.Equal(t, "1", m.ID)
+	assert.Equal(t, "test", m.Name)
+	assert.Equal(t, role("admin"), m.Role)
+	assert.Equal(t, 3, m.Count)
+	assert.Equal(t, "a note", *m.Note)
+	assert.JSONEq(t, `{"a":1}`, string(*m.Metadata))
+	assert.Equal(t, []string{"x", "y"}, m.Tags)
+	assert.True(t, now.Equal(m.CreatedAt))
+
+	t.Run("nil clears the field", func(t *testing.T) {
+		assert.NoError(t, Record{"note": nil}.ScanStruct(&m))
+		assert.Nil(t, m.Note)
+	})
+
+	t.Run("invalid values", func(t *testing.T) {
+		assert.Error(t, Record{"count": "three"}.ScanStruct(&m))
+		assert.Error(t, Record{"created_at": "yesterday"}.ScanStruct(&m))
+		assert.Error(t, Record{}.ScanStruct(m))
+	})
+}
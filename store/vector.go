@@ -0,0 +1,103 @@
+package store
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// DistanceField is the field holding the distance of the records found with WithNearest
+const DistanceField = "distance"
+
+// DistanceMetric is the pgvector distance used to find the nearest records
+type DistanceMetric string
+
+const (
+	// DistanceCosine is the cosine distance, the <=> operator
+	DistanceCosine DistanceMetric = "cosine"
+	// DistanceL2 is the euclidean distance, the <-> operator
+	DistanceL2 DistanceMetric = "l2"
+	// DistanceInnerProduct is the negative inner product, the <#> operator
+	DistanceInnerProduct DistanceMetric = "inner_product"
+)
+
+func (m DistanceMetric) operator() (string, error) {
+	switch m {
+	case DistanceCosine:
+		return "<=>", nil
+	case DistanceL2:
+		return "<->", nil
+	case DistanceInnerProduct:
+		return "<#>", nil
+	}
+	return "", fmt.Errorf("unknown distance metric: %s", m)
+}
+
+// NearestOptions holds the vector similarity search of a find
+type NearestOptions struct {
+	// Field is the vector column
+	Field  string
+	Vector []float32
+	Metric DistanceMetric
+
+	// K is the number of records to return
+	K int
+}
+
+// WithNearest finds the k records whose vector field is the nearest to the vector, closest first.
+// The distance is returned in the distance field of the records. It combines with the filters,
+// which restrict the records searched, and with WithSearch for hybrid search. The vector is bound
+// as a parameter. Sort, pagination and cursor options are not supported, the records are ordered
+// by distance so that pgvector indexes can be used.
+//
+// Example: WithNearest("embedding", queryEmbedding, DistanceCosine, 10)
+func WithNearest(field string, vector []float32, metric DistanceMetric, k int) FindOption {
+	return func(o *FindOptions) {
+		o.Nearest = &NearestOptions{Field: field, Vector: vector, Metric: metric, K: k}
+	}
+}
+
+// nearestColumn returns the distance column to select and the vector bound to the param
+func nearestColumn(options *FindOptions, param int) (string, any, error) {
+	nearest := options.Nearest
+	if nearest == nil {
+		return "", nil, nil
+	}
+	if options.Cursor != nil || options.Pagination != nil || len(options.Sort) > 0 {
+		return "", nil, fmt.Errorf("nearest does not support sort, pagination or cursor options")
+	}
+	if !ValidIdentifierName(nearest.Field) {
+		return "", nil, fmt.Errorf("invalid vector field: %s", nearest.Field)
+	}
+	if nearest.K <= 0 {
+		return "", nil, fmt.Errorf("nearest k must be greater than zero")
+	}
+
+	operator, err := nearest.Metric.operator()
+	if err != nil {
+		return "", nil, err
+	}
+	vector, err := vectorLiteral(nearest.Vector)
+	if err != nil {
+		return "", nil, err
+	}
+
+	return fmt.Sprintf(", %s %s $%d::vector AS %s", nearest.Field, operator, param, DistanceField), vector, nil
+}
+
+// vectorLiteral formats the vector in the pgvector text format, e.g. [1,0.5,-2]
+func vectorLiteral(vector []float32) (string, error) {
+	if len(vector) == 0 {
+		return "", fmt.Errorf("nearest vector is empty")
+	}
+
+	values := make([]string, len(vector))
+	for i, v := range vector {
+		if math.IsNaN(float64(v)) || math.IsInf(float64(v), 0) {
+			return "", fmt.Errorf("nearest vector has a non finite value at %d", i)
+		}
+		values[i] = strconv.FormatFloat(float64(v), 'f', -1, 32)
+	}
+	return "[" + strings.Join(values, ",") + "]", nil
+}
@@ -0,0 +1,49 @@
+package store
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNearestColumn(t *testing.T) {
+	column, vector, err := nearestColumn(findOptions([]FindOption{WithNearest("embedding", []float32{1, 0.25, -2}, DistanceCosine, 5)}), 3)
+	assert.NoError(t, err)
+	assert.Equal(t, ", embedding <=> $3::vector AS distance", column)
+	assert.Equal(t, "[1,0.25,-2]", vector)
+
+	column, _, err = nearestColumn(findOptions([]FindOption{WithNearest("embedding", []float32{1}, DistanceL2, 5)}), 1)
+	assert.NoError(t, err)
+	assert.Equal(t, ", embedding <-> $1::vector AS distance", column)
+
+	column, _, err = nearestColumn(findOptions([]FindOption{WithNearest("embedding", []float32{1}, DistanceInnerProduct, 5)}), 1)
+	assert.NoError(t, err)
+	assert.Equal(t, ", embedding <#> $1::vector AS distance", column)
+
+	column, vector, err = nearestColumn(findOptions(nil), 1)
+	assert.NoError(t, err)
+	assert.Empty(t, column)
+	assert.Nil(t, vector)
+}
+
+func TestNearestColumnErrors(t *testing.T) {
+	tests := map[string]struct {
+		opts []FindOption
+		err  string
+	}{
+		"field":      {opts: []FindOption{WithNearest("embedding <-> embedding", []float32{1}, DistanceL2, 5)}, err: "invalid vector field: embedding <-> embedding"},
+		"k":          {opts: []FindOption{WithNearest("embedding", []float32{1}, DistanceL2, 0)}, err: "nearest k must be greater than zero"},
+		"metric":     {opts: []FindOption{WithNearest("embedding", []float32{1}, "hamming", 5)}, err: "unknown distance metric: hamming"},
+		"empty":      {opts: []FindOption{WithNearest("embedding", nil, DistanceL2, 5)}, err: "nearest vector is empty"},
+		"not finite": {opts: []FindOption{WithNearest("embedding", []float32{1, float32(math.NaN())}, DistanceL2, 5)}, err: "nearest vector has a non finite value at 1"},
+		"pagination": {opts: []FindOption{WithNearest("embedding", []float32{1}, DistanceL2, 5), WithPagination(10, 0)}, err: "nearest does not support sort, pagination or cursor options"},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, _, err := nearestColumn(findOptions(tt.opts), 1)
+			assert.EqualError(t, err, tt.err)
+		})
+	}
+}
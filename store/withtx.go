@@ -0,0 +1,249 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"maps"
+	"math/rand/v2"
+	"time"
+
+	"github.com/lib/pq"
+
+	"github.com/tuongaz/go-saas/pkg/log"
+)
+
+const (
+	defaultTxRetries = 3
+	txRetryBaseDelay = 10 * time.Millisecond
+	txRetryMaxDelay  = time.Second
+)
+
+// TxOptions configures the transactions run by WithTx
+type TxOptions struct {
+	// Isolation is the isolation level of the transaction, the default of the database when zero
+	Isolation sql.IsolationLevel
+	ReadOnly  bool
+
+	// MaxRetries is how many times the transaction is run again after a serialization failure
+	// or a deadlock, 3 when zero. A negative value disables the retries.
+	MaxRetries int
+}
+
+// sqlOptions returns the options of the transaction for database/sql, nil for the defaults
+func (o *TxOptions) sqlOptions() *sql.TxOptions {
+	if o == nil || (o.Isolation == sql.LevelDefault && !o.ReadOnly) {
+		return nil
+	}
+	return &sql.TxOptions{Isolation: o.Isolation, ReadOnly: o.ReadOnly}
+}
+
+func (o *TxOptions) maxRetries() int {
+	switch {
+	case o == nil || o.MaxRetries == 0:
+		return defaultTxRetries
+	case o.MaxRetries < 0:
+		return 0
+	}
+	return o.MaxRetries
+}
+
+// IsRetryableTxError reports whether the transaction failed on a Postgres serialization
+// failure or deadlock, and succeeds when it is run again
+func IsRetryableTxError(err error) bool {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		return pqErr.Code == "40001" || pqErr.Code == "40P01"
+	}
+	return false
+}
+
+// WithTx runs fn in a transaction, committed when fn returns nil and rolled back when it returns
+// an error or panics. fn must not commit or roll back the transaction itself.
+// A transaction failing on a serialization failure or a deadlock is rolled back and run again
+// from the start after a jittered backoff, so fn must leave the side effects outside the
+// database to OnAfterCommit. See StoreTx.WithTx to nest transactions.
+func (s *Store) WithTx(ctx context.Context, opts *TxOptions, fn func(tx *StoreTx) error) error {
+	return runTx(ctx, opts, s.begin, fn)
+}
+
+// WithTx runs fn in a transaction of the memory store. The isolation level and read-only
+// mode are ignored; the commit fails with a duplicate key error when a concurrent transaction
+// committed the same key or unique values first.
+func (s *MemoryStore) WithTx(ctx context.Context, opts *TxOptions, fn func(tx *StoreTx) error) error {
+	return runTx(ctx, opts, func(ctx context.Context, _ *sql.TxOptions) (*StoreTx, error) {
+		return s.Tx(ctx)
+	}, fn)
+}
+
+// WithTx runs fn in a transaction scoped to the organisation, see Store.WithTx
+func (t *TenantStore) WithTx(ctx context.Context, opts *TxOptions, fn func(tx *StoreTx) error) error {
+	return t.store.WithTx(ctx, opts, func(tx *StoreTx) error {
+		if err := tx.SetOrganisation(ctx, t.organisationID); err != nil {
+			return err
+		}
+		return fn(tx)
+	})
+}
+
+// runTx runs fn in the transactions begun by begin until it succeeds, fails with an error
+// that is not retryable, or runs out of retries
+func runTx(
+	ctx context.Context,
+	opts *TxOptions,
+	begin func(ctx context.Context, opts *sql.TxOptions) (*StoreTx, error),
+	fn func(tx *StoreTx) error,
+) error {
+	retries := opts.maxRetries()
+	for attempt := 0; ; attempt++ {
+		err := runTxOnce(ctx, opts.sqlOptions(), begin, fn)
+		if err == nil || attempt >= retries || !IsRetryableTxError(err) {
+			return err
+		}
+
+		delay := txRetryDelay(attempt)
+		log.Default().WarnContext(ctx, "transaction conflict, retrying", "attempt", attempt+1, "delay", delay, log.ErrorAttr(err))
+
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(delay):
+		}
+	}
+}
+
+func runTxOnce(
+	ctx context.Context,
+	opts *sql.TxOptions,
+	begin func(ctx context.Context, opts *sql.TxOptions) (*StoreTx, error),
+	fn func(tx *StoreTx) error,
+) error {
+	tx, err := begin(ctx, opts)
+	if err != nil {
+		return err
+	}
+
+	committed := false
+	defer func() {
+		if !committed {
+			_ = tx.Rollback()
+		}
+	}()
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+
+	committed = true
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit tx: %w", err)
+	}
+	return nil
+}
+
+// txRetryDelay returns a random delay up to the exponential backoff of the attempt,
+// so that the conflicting transactions do not run again at the same time
+func txRetryDelay(attempt int) time.Duration {
+	backoff := min(txRetryBaseDelay<<attempt, txRetryMaxDelay)
+	return rand.N(backoff) + 1
+}
+
+// WithTx runs fn in a savepoint of the transaction. The savepoint is rolled back when fn returns
+// an error or panics, so that the transaction carries on without the writes of fn, and released
+// otherwise. The options are those of the outer transaction, which retries the conflicts.
+func (s *StoreTx) WithTx(ctx context.Context, _ *TxOptions, fn func(tx *StoreTx) error) error {
+	sp, err := s.savepoint(ctx)
+	if err != nil {
+		return err
+	}
+
+	released := false
+	defer func() {
+		if !released {
+			_ = sp.rollback(ctx)
+		}
+	}()
+
+	if err := fn(s); err != nil {
+		released = true
+		if rbErr := sp.rollback(ctx); rbErr != nil {
+			return errors.Join(err, rbErr)
+		}
+		return err
+	}
+
+	released = true
+	return sp.release(ctx)
+}
+
+// savepoint is a point of a transaction that it can be rolled back to
+type savepoint struct {
+	tx   *StoreTx
+	name string
+
+	// the state of the transaction when the savepoint was created
+	organisationID string
+	afterEvents    int
+	afterCommit    int
+	memoryWrites   memoryTables
+}
+
+func (s *StoreTx) savepoint(ctx context.Context) (*savepoint, error) {
+	s.mu.Lock()
+	s.savepoints++
+	sp := &savepoint{
+		tx:             s,
+		name:           fmt.Sprintf("gosaas_sp_%d", s.savepoints),
+		organisationID: s.organisationID,
+		afterEvents:    len(s.afterEvents),
+		afterCommit:    len(s.afterCommit),
+	}
+	s.mu.Unlock()
+
+	if tx, ok := s.tx.(*memoryTx); ok {
+		tx.store.mu.Lock()
+		defer tx.store.mu.Unlock()
+
+		sp.memoryWrites = make(memoryTables, len(tx.writes))
+		for table, rows := range tx.writes {
+			sp.memoryWrites[table] = maps.Clone(rows)
+		}
+		return sp, nil
+	}
+
+	if _, err := s.tx.ExecContext(ctx, "SAVEPOINT "+sp.name); err != nil {
+		return nil, fmt.Errorf("create savepoint: %w", err)
+	}
+	return sp, nil
+}
+
+// rollback undoes the writes made since the savepoint and drops the events they buffered
+func (sp *savepoint) rollback(ctx context.Context) error {
+	s := sp.tx
+	if tx, ok := s.tx.(*memoryTx); ok {
+		tx.store.mu.Lock()
+		tx.writes = sp.memoryWrites
+		tx.store.mu.Unlock()
+	} else if _, err := s.tx.ExecContext(ctx, "ROLLBACK TO SAVEPOINT "+sp.name); err != nil {
+		return fmt.Errorf("rollback to savepoint: %w", err)
+	}
+
+	s.mu.Lock()
+	s.organisationID = sp.organisationID
+	s.afterEvents = s.afterEvents[:min(sp.afterEvents, len(s.afterEvents))]
+	s.afterCommit = s.afterCommit[:min(sp.afterCommit, len(s.afterCommit))]
+	s.mu.Unlock()
+
+	return nil
+}
+
+func (sp *savepoint) release(ctx context.Context) error {
+	if _, ok := sp.tx.tx.(*memoryTx); ok {
+		return nil
+	}
+	if _, err := sp.tx.tx.ExecContext(ctx, "RELEASE SAVEPOINT "+sp.name); err != nil {
+		return fmt.Errorf("release savepoint: %w", err)
+	}
+	return nil
+}
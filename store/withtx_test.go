@@ -0,0 +1,182 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/tuongaz/go-saas/store/types"
+)
+
+func TestTxOptions(t *testing.T) {
+	var opts *TxOptions
+	assert.Nil(t, opts.sqlOptions())
+	assert.Equal(t, defaultTxRetries, opts.maxRetries())
+
+	opts = &TxOptions{Isolation: sql.LevelSerializable, ReadOnly: true, MaxRetries: -1}
+	assert.Equal(t, &sql.TxOptions{Isolation: sql.LevelSerializable, ReadOnly: true}, opts.sqlOptions())
+	assert.Zero(t, opts.maxRetries())
+}
+
+func TestIsRetryableTxError(t *testing.T) {
+	assert.True(t, IsRetryableTxError(fmt.Errorf("update: %w", &pq.Error{Code: "40001"})))
+	assert.True(t, IsRetryableTxError(&pq.Error{Code: "40P01"}))
+	assert.False(t, IsRetryableTxError(&pq.Error{Code: "23505"}))
+	assert.False(t, IsRetryableTxError(errors.New("serialization failure")))
+}
+
+func TestRunTx(t *testing.T) {
+	ctx := context.Background()
+	conflict := &pq.Error{Code: "40001", Message: "could not serialize access"}
+
+	// begin returns fake transactions, recording the options and the transactions
+	newBegin := func() (func(ctx context.Context, opts *sql.TxOptions) (*StoreTx, error), *[]*fakeTx, *[]*sql.TxOptions) {
+		var txs []*fakeTx
+		var options []*sql.TxOptions
+		return func(ctx context.Context, opts *sql.TxOptions) (*StoreTx, error) {
+			tx := &fakeTx{}
+			txs = append(txs, tx)
+			options = append(options, opts)
+			return &StoreTx{tx: tx, store: &Store{}, ctx: ctx}, nil
+		}, &txs, &options
+	}
+
+	t.Run("commits", func(t *testing.T) {
+		begin, txs, options := newBegin()
+		opts := &TxOptions{Isolation: sql.LevelSerializable}
+		assert.NoError(t, runTx(ctx, opts, begin, func(tx *StoreTx) error { return nil }))
+		if assert.Len(t, *txs, 1) {
+			assert.True(t, (*txs)[0].committed)
+			assert.False(t, (*txs)[0].rolledBack)
+		}
+		assert.Equal(t, []*sql.TxOptions{{Isolation: sql.LevelSerializable}}, *options)
+	})
+
+	t.Run("rolls back on error", func(t *testing.T) {
+		begin, txs, _ := newBegin()
+		fnErr := errors.New("failed")
+		assert.ErrorIs(t, runTx(ctx, nil, begin, func(tx *StoreTx) error { return fnErr }), fnErr)
+		if assert.Len(t, *txs, 1) {
+			assert.False(t, (*txs)[0].committed)
+			assert.True(t, (*txs)[0].rolledBack)
+		}
+	})
+
+	t.Run("rolls back on panic", func(t *testing.T) {
+		begin, txs, _ := newBegin()
+		assert.Panics(t, func() {
+			_ = runTx(ctx, nil, begin, func(tx *StoreTx) error { panic("boom") })
+		})
+		if assert.Len(t, *txs, 1) {
+			assert.True(t, (*txs)[0].rolledBack)
+		}
+	})
+
+	t.Run("retries conflicts", func(t *testing.T) {
+		begin, txs, _ := newBegin()
+		calls := 0
+		err := runTx(ctx, nil, begin, func(tx *StoreTx) error {
+			calls++
+			if calls < 3 {
+				return fmt.Errorf("update: %w", conflict)
+			}
+			return nil
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, 3, calls)
+		if assert.Len(t, *txs, 3) {
+			assert.True(t, (*txs)[0].rolledBack)
+			assert.True(t, (*txs)[1].rolledBack)
+			assert.True(t, (*txs)[2].committed)
+		}
+	})
+
+	t.Run("gives up after the retries", func(t *testing.T) {
+		begin, txs, _ := newBegin()
+		err := runTx(ctx, &TxOptions{MaxRetries: 1}, begin, func(tx *StoreTx) error { return conflict })
+		assert.ErrorIs(t, err, conflict)
+		assert.Len(t, *txs, 2)
+	})
+
+	t.Run("does not retry other errors", func(t *testing.T) {
+		begin, txs, _ := newBegin()
+		err := runTx(ctx, nil, begin, func(tx *StoreTx) error { return &pq.Error{Code: "23505"} })
+		assert.Error(t, err)
+		assert.Len(t, *txs, 1)
+	})
+}
+
+func TestMemoryStore_WithTx(t *testing.T) {
+	ctx := context.Background()
+	st := NewMemory()
+	handler := &recordingHandler{}
+	st.AddEventHandler(handler)
+	books := st.Collection("books")
+
+	t.Run("nested transactions roll back to their savepoint", func(t *testing.T) {
+		err := st.WithTx(ctx, nil, func(tx *StoreTx) error {
+			if _, err := tx.Collection("books").CreateRecord(ctx, types.Record{"id": "b1"}); err != nil {
+				return err
+			}
+
+			nestedErr := tx.WithTx(ctx, nil, func(tx *StoreTx) error {
+				if _, err := tx.Collection("books").CreateRecord(ctx, types.Record{"id": "b2"}); err != nil {
+					return err
+				}
+				return errors.New("failed")
+			})
+			assert.EqualError(t, nestedErr, "failed")
+
+			return tx.WithTx(ctx, nil, func(tx *StoreTx) error {
+				_, err := tx.Collection("books").CreateRecord(ctx, types.Record{"id": "b3"})
+				return err
+			})
+		})
+		assert.NoError(t, err)
+
+		list, err := books.Find(ctx, WithSort(SortOption{Field: "id", Direction: SortAsc}))
+		assert.NoError(t, err)
+		var ids []any
+		for _, record := range list.Records {
+			ids = append(ids, record["id"])
+		}
+		assert.Equal(t, []any{"b1", "b3"}, ids)
+
+		// The after event of the rolled back savepoint is dropped
+		assert.Equal(t, []string{
+			"before:books", "before:books", "before:books",
+			"after:books", "after:books",
+		}, handler.created)
+	})
+
+	t.Run("rolls back on error", func(t *testing.T) {
+		err := st.WithTx(ctx, nil, func(tx *StoreTx) error {
+			if _, err := tx.Collection("books").CreateRecord(ctx, types.Record{"id": "b4"}); err != nil {
+				return err
+			}
+			return errors.New("failed")
+		})
+		assert.EqualError(t, err, "failed")
+
+		count, err := books.Count(ctx, nil)
+		assert.NoError(t, err)
+		assert.Equal(t, 2, count)
+	})
+
+	t.Run("tenant transactions", func(t *testing.T) {
+		err := st.ForOrganisation("org1").WithTx(ctx, nil, func(tx *StoreTx) error {
+			_, err := tx.Collection("books").CreateRecord(ctx, types.Record{"id": "b5"})
+			return err
+		})
+		assert.NoError(t, err)
+
+		record, err := books.GetRecord(ctx, "b5")
+		assert.NoError(t, err)
+		assert.Equal(t, "org1", record.Get(TenantColumn))
+	})
+}
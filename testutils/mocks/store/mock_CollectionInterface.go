@@ -8,6 +8,8 @@ import (
 	mock "github.com/stretchr/testify/mock"
 	store "github.com/tuongaz/go-saas/store"
 
+	time "time"
+
 	types "github.com/tuongaz/go-saas/store/types"
 )
 
@@ -24,9 +26,91 @@ func (_m *MockCollectionInterface) EXPECT() *MockCollectionInterface_Expecter {
 	return &MockCollectionInterface_Expecter{mock: &_m.Mock}
 }
 
-// Count provides a mock function with given fields: ctx, filter
-func (_m *MockCollectionInterface) Count(ctx context.Context, filter store.Filter) (int, error) {
-	ret := _m.Called(ctx, filter)
+// Aggregate provides a mock function with given fields: ctx, groups, aggregations, opts
+func (_m *MockCollectionInterface) Aggregate(ctx context.Context, groups []store.GroupBy, aggregations []store.Aggregation, opts ...store.FindOption) ([]types.Record, error) {
+	_va := make([]interface{}, len(opts))
+	for _i := range opts {
+		_va[_i] = opts[_i]
+	}
+	var _ca []interface{}
+	_ca = append(_ca, ctx, groups, aggregations)
+	_ca = append(_ca, _va...)
+	ret := _m.Called(_ca...)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Aggregate")
+	}
+
+	var r0 []types.Record
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, []store.GroupBy, []store.Aggregation, ...store.FindOption) ([]types.Record, error)); ok {
+		return rf(ctx, groups, aggregations, opts...)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, []store.GroupBy, []store.Aggregation, ...store.FindOption) []types.Record); ok {
+		r0 = rf(ctx, groups, aggregations, opts...)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]types.Record)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, []store.GroupBy, []store.Aggregation, ...store.FindOption) error); ok {
+		r1 = rf(ctx, groups, aggregations, opts...)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// MockCollectionInterface_Aggregate_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'Aggregate'
+type MockCollectionInterface_Aggregate_Call struct {
+	*mock.Call
+}
+
+// Aggregate is a helper method to define mock.On call
+//   - ctx context.Context
+//   - groups []store.GroupBy
+//   - aggregations []store.Aggregation
+//   - opts ...store.FindOption
+func (_e *MockCollectionInterface_Expecter) Aggregate(ctx interface{}, groups interface{}, aggregations interface{}, opts ...interface{}) *MockCollectionInterface_Aggregate_Call {
+	return &MockCollectionInterface_Aggregate_Call{Call: _e.mock.On("Aggregate",
+		append([]interface{}{ctx, groups, aggregations}, opts...)...)}
+}
+
+func (_c *MockCollectionInterface_Aggregate_Call) Run(run func(ctx context.Context, groups []store.GroupBy, aggregations []store.Aggregation, opts ...store.FindOption)) *MockCollectionInterface_Aggregate_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		variadicArgs := make([]store.FindOption, len(args)-3)
+		for i, a := range args[3:] {
+			if a != nil {
+				variadicArgs[i] = a.(store.FindOption)
+			}
+		}
+		run(args[0].(context.Context), args[1].([]store.GroupBy), args[2].([]store.Aggregation), variadicArgs...)
+	})
+	return _c
+}
+
+func (_c *MockCollectionInterface_Aggregate_Call) Return(_a0 []types.Record, _a1 error) *MockCollectionInterface_Aggregate_Call {
+	_c.Call.Return(_a0, _a1)
+	return _c
+}
+
+func (_c *MockCollectionInterface_Aggregate_Call) RunAndReturn(run func(context.Context, []store.GroupBy, []store.Aggregation, ...store.FindOption) ([]types.Record, error)) *MockCollectionInterface_Aggregate_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// Count provides a mock function with given fields: ctx, filter, opts
+func (_m *MockCollectionInterface) Count(ctx context.Context, filter store.Filter, opts ...store.FindOption) (int, error) {
+	_va := make([]interface{}, len(opts))
+	for _i := range opts {
+		_va[_i] = opts[_i]
+	}
+	var _ca []interface{}
+	_ca = append(_ca, ctx, filter)
+	_ca = append(_ca, _va...)
+	ret := _m.Called(_ca...)
 
 	if len(ret) == 0 {
 		panic("no return value specified for Count")
@@ -34,17 +118,17 @@ func (_m *MockCollectionInterface) Count(ctx context.Context, filter store.Filte
 
 	var r0 int
 	var r1 error
-	if rf, ok := ret.Get(0).(func(context.Context, store.Filter) (int, error)); ok {
-		return rf(ctx, filter)
+	if rf, ok := ret.Get(0).(func(context.Context, store.Filter, ...store.FindOption) (int, error)); ok {
+		return rf(ctx, filter, opts...)
 	}
-	if rf, ok := ret.Get(0).(func(context.Context, store.Filter) int); ok {
-		r0 = rf(ctx, filter)
+	if rf, ok := ret.Get(0).(func(context.Context, store.Filter, ...store.FindOption) int); ok {
+		r0 = rf(ctx, filter, opts...)
 	} else {
 		r0 = ret.Get(0).(int)
 	}
 
-	if rf, ok := ret.Get(1).(func(context.Context, store.Filter) error); ok {
-		r1 = rf(ctx, filter)
+	if rf, ok := ret.Get(1).(func(context.Context, store.Filter, ...store.FindOption) error); ok {
+		r1 = rf(ctx, filter, opts...)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -60,13 +144,21 @@ type MockCollectionInterface_Count_Call struct {
 // Count is a helper method to define mock.On call
 //   - ctx context.Context
 //   - filter store.Filter
-func (_e *MockCollectionInterface_Expecter) Count(ctx interface{}, filter interface{}) *MockCollectionInterface_Count_Call {
-	return &MockCollectionInterface_Count_Call{Call: _e.mock.On("Count", ctx, filter)}
+//   - opts ...store.FindOption
+func (_e *MockCollectionInterface_Expecter) Count(ctx interface{}, filter interface{}, opts ...interface{}) *MockCollectionInterface_Count_Call {
+	return &MockCollectionInterface_Count_Call{Call: _e.mock.On("Count",
+		append([]interface{}{ctx, filter}, opts...)...)}
 }
 
-func (_c *MockCollectionInterface_Count_Call) Run(run func(ctx context.Context, filter store.Filter)) *MockCollectionInterface_Count_Call {
+func (_c *MockCollectionInterface_Count_Call) Run(run func(ctx context.Context, filter store.Filter, opts ...store.FindOption)) *MockCollectionInterface_Count_Call {
 	_c.Call.Run(func(args mock.Arguments) {
-		run(args[0].(context.Context), args[1].(store.Filter))
+		variadicArgs := make([]store.FindOption, len(args)-2)
+		for i, a := range args[2:] {
+			if a != nil {
+				variadicArgs[i] = a.(store.FindOption)
+			}
+		}
+		run(args[0].(context.Context), args[1].(store.Filter), variadicArgs...)
 	})
 	return _c
 }
@@ -76,7 +168,7 @@ func (_c *MockCollectionInterface_Count_Call) Return(_a0 int, _a1 error) *MockCo
 	return _c
 }
 
-func (_c *MockCollectionInterface_Count_Call) RunAndReturn(run func(context.Context, store.Filter) (int, error)) *MockCollectionInterface_Count_Call {
+func (_c *MockCollectionInterface_Count_Call) RunAndReturn(run func(context.Context, store.Filter, ...store.FindOption) (int, error)) *MockCollectionInterface_Count_Call {
 	_c.Call.Return(run)
 	return _c
 }
@@ -140,6 +232,65 @@ func (_c *MockCollectionInterface_CreateRecord_Call) RunAndReturn(run func(conte
 	return _c
 }
 
+// CreateRecords provides a mock function with given fields: ctx, records
+func (_m *MockCollectionInterface) CreateRecords(ctx context.Context, records []types.Record) ([]types.Record, error) {
+	ret := _m.Called(ctx, records)
+
+	if len(ret) == 0 {
+		panic("no return value specified for CreateRecords")
+	}
+
+	var r0 []types.Record
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, []types.Record) ([]types.Record, error)); ok {
+		return rf(ctx, records)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, []types.Record) []types.Record); ok {
+		r0 = rf(ctx, records)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]types.Record)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, []types.Record) error); ok {
+		r1 = rf(ctx, records)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// MockCollectionInterface_CreateRecords_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'CreateRecords'
+type MockCollectionInterface_CreateRecords_Call struct {
+	*mock.Call
+}
+
+// CreateRecords is a helper method to define mock.On call
+//   - ctx context.Context
+//   - records []types.Record
+func (_e *MockCollectionInterface_Expecter) CreateRecords(ctx interface{}, records interface{}) *MockCollectionInterface_CreateRecords_Call {
+	return &MockCollectionInterface_CreateRecords_Call{Call: _e.mock.On("CreateRecords", ctx, records)}
+}
+
+func (_c *MockCollectionInterface_CreateRecords_Call) Run(run func(ctx context.Context, records []types.Record)) *MockCollectionInterface_CreateRecords_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].([]types.Record))
+	})
+	return _c
+}
+
+func (_c *MockCollectionInterface_CreateRecords_Call) Return(_a0 []types.Record, _a1 error) *MockCollectionInterface_CreateRecords_Call {
+	_c.Call.Return(_a0, _a1)
+	return _c
+}
+
+func (_c *MockCollectionInterface_CreateRecords_Call) RunAndReturn(run func(context.Context, []types.Record) ([]types.Record, error)) *MockCollectionInterface_CreateRecords_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
 // DeleteRecord provides a mock function with given fields: ctx, id
 func (_m *MockCollectionInterface) DeleteRecord(ctx context.Context, id interface{}) error {
 	ret := _m.Called(ctx, id)
@@ -234,9 +385,16 @@ func (_c *MockCollectionInterface_DeleteRecords_Call) RunAndReturn(run func(cont
 	return _c
 }
 
-// Exists provides a mock function with given fields: ctx, filter
-func (_m *MockCollectionInterface) Exists(ctx context.Context, filter store.Filter) (bool, error) {
-	ret := _m.Called(ctx, filter)
+// Exists provides a mock function with given fields: ctx, filter, opts
+func (_m *MockCollectionInterface) Exists(ctx context.Context, filter store.Filter, opts ...store.FindOption) (bool, error) {
+	_va := make([]interface{}, len(opts))
+	for _i := range opts {
+		_va[_i] = opts[_i]
+	}
+	var _ca []interface{}
+	_ca = append(_ca, ctx, filter)
+	_ca = append(_ca, _va...)
+	ret := _m.Called(_ca...)
 
 	if len(ret) == 0 {
 		panic("no return value specified for Exists")
@@ -244,17 +402,17 @@ func (_m *MockCollectionInterface) Exists(ctx context.Context, filter store.Filt
 
 	var r0 bool
 	var r1 error
-	if rf, ok := ret.Get(0).(func(context.Context, store.Filter) (bool, error)); ok {
-		return rf(ctx, filter)
+	if rf, ok := ret.Get(0).(func(context.Context, store.Filter, ...store.FindOption) (bool, error)); ok {
+		return rf(ctx, filter, opts...)
 	}
-	if rf, ok := ret.Get(0).(func(context.Context, store.Filter) bool); ok {
-		r0 = rf(ctx, filter)
+	if rf, ok := ret.Get(0).(func(context.Context, store.Filter, ...store.FindOption) bool); ok {
+		r0 = rf(ctx, filter, opts...)
 	} else {
 		r0 = ret.Get(0).(bool)
 	}
 
-	if rf, ok := ret.Get(1).(func(context.Context, store.Filter) error); ok {
-		r1 = rf(ctx, filter)
+	if rf, ok := ret.Get(1).(func(context.Context, store.Filter, ...store.FindOption) error); ok {
+		r1 = rf(ctx, filter, opts...)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -270,13 +428,21 @@ type MockCollectionInterface_Exists_Call struct {
 // Exists is a helper method to define mock.On call
 //   - ctx context.Context
 //   - filter store.Filter
-func (_e *MockCollectionInterface_Expecter) Exists(ctx interface{}, filter interface{}) *MockCollectionInterface_Exists_Call {
-	return &MockCollectionInterface_Exists_Call{Call: _e.mock.On("Exists", ctx, filter)}
+//   - opts ...store.FindOption
+func (_e *MockCollectionInterface_Expecter) Exists(ctx interface{}, filter interface{}, opts ...interface{}) *MockCollectionInterface_Exists_Call {
+	return &MockCollectionInterface_Exists_Call{Call: _e.mock.On("Exists",
+		append([]interface{}{ctx, filter}, opts...)...)}
 }
 
-func (_c *MockCollectionInterface_Exists_Call) Run(run func(ctx context.Context, filter store.Filter)) *MockCollectionInterface_Exists_Call {
+func (_c *MockCollectionInterface_Exists_Call) Run(run func(ctx context.Context, filter store.Filter, opts ...store.FindOption)) *MockCollectionInterface_Exists_Call {
 	_c.Call.Run(func(args mock.Arguments) {
-		run(args[0].(context.Context), args[1].(store.Filter))
+		variadicArgs := make([]store.FindOption, len(args)-2)
+		for i, a := range args[2:] {
+			if a != nil {
+				variadicArgs[i] = a.(store.FindOption)
+			}
+		}
+		run(args[0].(context.Context), args[1].(store.Filter), variadicArgs...)
 	})
 	return _c
 }
@@ -286,7 +452,7 @@ func (_c *MockCollectionInterface_Exists_Call) Return(_a0 bool, _a1 error) *Mock
 	return _c
 }
 
-func (_c *MockCollectionInterface_Exists_Call) RunAndReturn(run func(context.Context, store.Filter) (bool, error)) *MockCollectionInterface_Exists_Call {
+func (_c *MockCollectionInterface_Exists_Call) RunAndReturn(run func(context.Context, store.Filter, ...store.FindOption) (bool, error)) *MockCollectionInterface_Exists_Call {
 	_c.Call.Return(run)
 	return _c
 }
@@ -364,9 +530,16 @@ func (_c *MockCollectionInterface_Find_Call) RunAndReturn(run func(context.Conte
 	return _c
 }
 
-// FindOne provides a mock function with given fields: ctx, filter
-func (_m *MockCollectionInterface) FindOne(ctx context.Context, filter store.Filter) (*types.Record, error) {
-	ret := _m.Called(ctx, filter)
+// FindOne provides a mock function with given fields: ctx, filter, opts
+func (_m *MockCollectionInterface) FindOne(ctx context.Context, filter store.Filter, opts ...store.FindOption) (*types.Record, error) {
+	_va := make([]interface{}, len(opts))
+	for _i := range opts {
+		_va[_i] = opts[_i]
+	}
+	var _ca []interface{}
+	_ca = append(_ca, ctx, filter)
+	_ca = append(_ca, _va...)
+	ret := _m.Called(_ca...)
 
 	if len(ret) == 0 {
 		panic("no return value specified for FindOne")
@@ -374,19 +547,19 @@ func (_m *MockCollectionInterface) FindOne(ctx context.Context, filter store.Fil
 
 	var r0 *types.Record
 	var r1 error
-	if rf, ok := ret.Get(0).(func(context.Context, store.Filter) (*types.Record, error)); ok {
-		return rf(ctx, filter)
+	if rf, ok := ret.Get(0).(func(context.Context, store.Filter, ...store.FindOption) (*types.Record, error)); ok {
+		return rf(ctx, filter, opts...)
 	}
-	if rf, ok := ret.Get(0).(func(context.Context, store.Filter) *types.Record); ok {
-		r0 = rf(ctx, filter)
+	if rf, ok := ret.Get(0).(func(context.Context, store.Filter, ...store.FindOption) *types.Record); ok {
+		r0 = rf(ctx, filter, opts...)
 	} else {
 		if ret.Get(0) != nil {
 			r0 = ret.Get(0).(*types.Record)
 		}
 	}
 
-	if rf, ok := ret.Get(1).(func(context.Context, store.Filter) error); ok {
-		r1 = rf(ctx, filter)
+	if rf, ok := ret.Get(1).(func(context.Context, store.Filter, ...store.FindOption) error); ok {
+		r1 = rf(ctx, filter, opts...)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -402,13 +575,21 @@ type MockCollectionInterface_FindOne_Call struct {
 // FindOne is a helper method to define mock.On call
 //   - ctx context.Context
 //   - filter store.Filter
-func (_e *MockCollectionInterface_Expecter) FindOne(ctx interface{}, filter interface{}) *MockCollectionInterface_FindOne_Call {
-	return &MockCollectionInterface_FindOne_Call{Call: _e.mock.On("FindOne", ctx, filter)}
+//   - opts ...store.FindOption
+func (_e *MockCollectionInterface_Expecter) FindOne(ctx interface{}, filter interface{}, opts ...interface{}) *MockCollectionInterface_FindOne_Call {
+	return &MockCollectionInterface_FindOne_Call{Call: _e.mock.On("FindOne",
+		append([]interface{}{ctx, filter}, opts...)...)}
 }
 
-func (_c *MockCollectionInterface_FindOne_Call) Run(run func(ctx context.Context, filter store.Filter)) *MockCollectionInterface_FindOne_Call {
+func (_c *MockCollectionInterface_FindOne_Call) Run(run func(ctx context.Context, filter store.Filter, opts ...store.FindOption)) *MockCollectionInterface_FindOne_Call {
 	_c.Call.Run(func(args mock.Arguments) {
-		run(args[0].(context.Context), args[1].(store.Filter))
+		variadicArgs := make([]store.FindOption, len(args)-2)
+		for i, a := range args[2:] {
+			if a != nil {
+				variadicArgs[i] = a.(store.FindOption)
+			}
+		}
+		run(args[0].(context.Context), args[1].(store.Filter), variadicArgs...)
 	})
 	return _c
 }
@@ -418,19 +599,150 @@ func (_c *MockCollectionInterface_FindOne_Call) Return(_a0 *types.Record, _a1 er
 	return _c
 }
 
-func (_c *MockCollectionInterface_FindOne_Call) RunAndReturn(run func(context.Context, store.Filter) (*types.Record, error)) *MockCollectionInterface_FindOne_Call {
+func (_c *MockCollectionInterface_FindOne_Call) RunAndReturn(run func(context.Context, store.Filter, ...store.FindOption) (*types.Record, error)) *MockCollectionInterface_FindOne_Call {
 	_c.Call.Return(run)
 	return _c
 }
 
-// GetRecord provides a mock function with given fields: ctx, id
-func (_m *MockCollectionInterface) GetRecord(ctx context.Context, id interface{}) (*types.Record, error) {
-	ret := _m.Called(ctx, id)
+// GetRecord provides a mock function with given fields: ctx, id, opts
+func (_m *MockCollectionInterface) GetRecord(ctx context.Context, id interface{}, opts ...store.FindOption) (*types.Record, error) {
+	_va := make([]interface{}, len(opts))
+	for _i := range opts {
+		_va[_i] = opts[_i]
+	}
+	var _ca []interface{}
+	_ca = append(_ca, ctx, id)
+	_ca = append(_ca, _va...)
+	ret := _m.Called(_ca...)
 
 	if len(ret) == 0 {
 		panic("no return value specified for GetRecord")
 	}
 
+	var r0 *types.Record
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, interface{}, ...store.FindOption) (*types.Record, error)); ok {
+		return rf(ctx, id, opts...)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, interface{}, ...store.FindOption) *types.Record); ok {
+		r0 = rf(ctx, id, opts...)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*types.Record)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, interface{}, ...store.FindOption) error); ok {
+		r1 = rf(ctx, id, opts...)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// MockCollectionInterface_GetRecord_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'GetRecord'
+type MockCollectionInterface_GetRecord_Call struct {
+	*mock.Call
+}
+
+// GetRecord is a helper method to define mock.On call
+//   - ctx context.Context
+//   - id interface{}
+//   - opts ...store.FindOption
+func (_e *MockCollectionInterface_Expecter) GetRecord(ctx interface{}, id interface{}, opts ...interface{}) *MockCollectionInterface_GetRecord_Call {
+	return &MockCollectionInterface_GetRecord_Call{Call: _e.mock.On("GetRecord",
+		append([]interface{}{ctx, id}, opts...)...)}
+}
+
+func (_c *MockCollectionInterface_GetRecord_Call) Run(run func(ctx context.Context, id interface{}, opts ...store.FindOption)) *MockCollectionInterface_GetRecord_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		variadicArgs := make([]store.FindOption, len(args)-2)
+		for i, a := range args[2:] {
+			if a != nil {
+				variadicArgs[i] = a.(store.FindOption)
+			}
+		}
+		run(args[0].(context.Context), args[1].(interface{}), variadicArgs...)
+	})
+	return _c
+}
+
+func (_c *MockCollectionInterface_GetRecord_Call) Return(_a0 *types.Record, _a1 error) *MockCollectionInterface_GetRecord_Call {
+	_c.Call.Return(_a0, _a1)
+	return _c
+}
+
+func (_c *MockCollectionInterface_GetRecord_Call) RunAndReturn(run func(context.Context, interface{}, ...store.FindOption) (*types.Record, error)) *MockCollectionInterface_GetRecord_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// PurgeDeletedBefore provides a mock function with given fields: ctx, t
+func (_m *MockCollectionInterface) PurgeDeletedBefore(ctx context.Context, t time.Time) (int64, error) {
+	ret := _m.Called(ctx, t)
+
+	if len(ret) == 0 {
+		panic("no return value specified for PurgeDeletedBefore")
+	}
+
+	var r0 int64
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, time.Time) (int64, error)); ok {
+		return rf(ctx, t)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, time.Time) int64); ok {
+		r0 = rf(ctx, t)
+	} else {
+		r0 = ret.Get(0).(int64)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, time.Time) error); ok {
+		r1 = rf(ctx, t)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// MockCollectionInterface_PurgeDeletedBefore_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'PurgeDeletedBefore'
+type MockCollectionInterface_PurgeDeletedBefore_Call struct {
+	*mock.Call
+}
+
+// PurgeDeletedBefore is a helper method to define mock.On call
+//   - ctx context.Context
+//   - t time.Time
+func (_e *MockCollectionInterface_Expecter) PurgeDeletedBefore(ctx interface{}, t interface{}) *MockCollectionInterface_PurgeDeletedBefore_Call {
+	return &MockCollectionInterface_PurgeDeletedBefore_Call{Call: _e.mock.On("PurgeDeletedBefore", ctx, t)}
+}
+
+func (_c *MockCollectionInterface_PurgeDeletedBefore_Call) Run(run func(ctx context.Context, t time.Time)) *MockCollectionInterface_PurgeDeletedBefore_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(time.Time))
+	})
+	return _c
+}
+
+func (_c *MockCollectionInterface_PurgeDeletedBefore_Call) Return(_a0 int64, _a1 error) *MockCollectionInterface_PurgeDeletedBefore_Call {
+	_c.Call.Return(_a0, _a1)
+	return _c
+}
+
+func (_c *MockCollectionInterface_PurgeDeletedBefore_Call) RunAndReturn(run func(context.Context, time.Time) (int64, error)) *MockCollectionInterface_PurgeDeletedBefore_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// Restore provides a mock function with given fields: ctx, id
+func (_m *MockCollectionInterface) Restore(ctx context.Context, id interface{}) (*types.Record, error) {
+	ret := _m.Called(ctx, id)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Restore")
+	}
+
 	var r0 *types.Record
 	var r1 error
 	if rf, ok := ret.Get(0).(func(context.Context, interface{}) (*types.Record, error)); ok {
@@ -453,31 +765,31 @@ func (_m *MockCollectionInterface) GetRecord(ctx context.Context, id interface{}
 	return r0, r1
 }
 
-// MockCollectionInterface_GetRecord_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'GetRecord'
-type MockCollectionInterface_GetRecord_Call struct {
+// MockCollectionInterface_Restore_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'Restore'
+type MockCollectionInterface_Restore_Call struct {
 	*mock.Call
 }
 
-// GetRecord is a helper method to define mock.On call
+// Restore is a helper method to define mock.On call
 //   - ctx context.Context
 //   - id interface{}
-func (_e *MockCollectionInterface_Expecter) GetRecord(ctx interface{}, id interface{}) *MockCollectionInterface_GetRecord_Call {
-	return &MockCollectionInterface_GetRecord_Call{Call: _e.mock.On("GetRecord", ctx, id)}
+func (_e *MockCollectionInterface_Expecter) Restore(ctx interface{}, id interface{}) *MockCollectionInterface_Restore_Call {
+	return &MockCollectionInterface_Restore_Call{Call: _e.mock.On("Restore", ctx, id)}
 }
 
-func (_c *MockCollectionInterface_GetRecord_Call) Run(run func(ctx context.Context, id interface{})) *MockCollectionInterface_GetRecord_Call {
+func (_c *MockCollectionInterface_Restore_Call) Run(run func(ctx context.Context, id interface{})) *MockCollectionInterface_Restore_Call {
 	_c.Call.Run(func(args mock.Arguments) {
 		run(args[0].(context.Context), args[1].(interface{}))
 	})
 	return _c
 }
 
-func (_c *MockCollectionInterface_GetRecord_Call) Return(_a0 *types.Record, _a1 error) *MockCollectionInterface_GetRecord_Call {
+func (_c *MockCollectionInterface_Restore_Call) Return(_a0 *types.Record, _a1 error) *MockCollectionInterface_Restore_Call {
 	_c.Call.Return(_a0, _a1)
 	return _c
 }
 
-func (_c *MockCollectionInterface_GetRecord_Call) RunAndReturn(run func(context.Context, interface{}) (*types.Record, error)) *MockCollectionInterface_GetRecord_Call {
+func (_c *MockCollectionInterface_Restore_Call) RunAndReturn(run func(context.Context, interface{}) (*types.Record, error)) *MockCollectionInterface_Restore_Call {
 	_c.Call.Return(run)
 	return _c
 }
@@ -595,9 +907,16 @@ func (_c *MockCollectionInterface_Update_Call) RunAndReturn(run func(context.Con
 	return _c
 }
 
-// UpdateRecord provides a mock function with given fields: ctx, id, record
-func (_m *MockCollectionInterface) UpdateRecord(ctx context.Context, id interface{}, record types.Record) (*types.Record, error) {
-	ret := _m.Called(ctx, id, record)
+// UpdateRecord provides a mock function with given fields: ctx, id, record, opts
+func (_m *MockCollectionInterface) UpdateRecord(ctx context.Context, id interface{}, record types.Record, opts ...store.UpdateOption) (*types.Record, error) {
+	_va := make([]interface{}, len(opts))
+	for _i := range opts {
+		_va[_i] = opts[_i]
+	}
+	var _ca []interface{}
+	_ca = append(_ca, ctx, id, record)
+	_ca = append(_ca, _va...)
+	ret := _m.Called(_ca...)
 
 	if len(ret) == 0 {
 		panic("no return value specified for UpdateRecord")
@@ -605,19 +924,19 @@ func (_m *MockCollectionInterface) UpdateRecord(ctx context.Context, id interfac
 
 	var r0 *types.Record
 	var r1 error
-	if rf, ok := ret.Get(0).(func(context.Context, interface{}, types.Record) (*types.Record, error)); ok {
-		return rf(ctx, id, record)
+	if rf, ok := ret.Get(0).(func(context.Context, interface{}, types.Record, ...store.UpdateOption) (*types.Record, error)); ok {
+		return rf(ctx, id, record, opts...)
 	}
-	if rf, ok := ret.Get(0).(func(context.Context, interface{}, types.Record) *types.Record); ok {
-		r0 = rf(ctx, id, record)
+	if rf, ok := ret.Get(0).(func(context.Context, interface{}, types.Record, ...store.UpdateOption) *types.Record); ok {
+		r0 = rf(ctx, id, record, opts...)
 	} else {
 		if ret.Get(0) != nil {
 			r0 = ret.Get(0).(*types.Record)
 		}
 	}
 
-	if rf, ok := ret.Get(1).(func(context.Context, interface{}, types.Record) error); ok {
-		r1 = rf(ctx, id, record)
+	if rf, ok := ret.Get(1).(func(context.Context, interface{}, types.Record, ...store.UpdateOption) error); ok {
+		r1 = rf(ctx, id, record, opts...)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -634,13 +953,21 @@ type MockCollectionInterface_UpdateRecord_Call struct {
 //   - ctx context.Context
 //   - id interface{}
 //   - record types.Record
-func (_e *MockCollectionInterface_Expecter) UpdateRecord(ctx interface{}, id interface{}, record interface{}) *MockCollectionInterface_UpdateRecord_Call {
-	return &MockCollectionInterface_UpdateRecord_Call{Call: _e.mock.On("UpdateRecord", ctx, id, record)}
+//   - opts ...store.UpdateOption
+func (_e *MockCollectionInterface_Expecter) UpdateRecord(ctx interface{}, id interface{}, record interface{}, opts ...interface{}) *MockCollectionInterface_UpdateRecord_Call {
+	return &MockCollectionInterface_UpdateRecord_Call{Call: _e.mock.On("UpdateRecord",
+		append([]interface{}{ctx, id, record}, opts...)...)}
 }
 
-func (_c *MockCollectionInterface_UpdateRecord_Call) Run(run func(ctx context.Context, id interface{}, record types.Record)) *MockCollectionInterface_UpdateRecord_Call {
+func (_c *MockCollectionInterface_UpdateRecord_Call) Run(run func(ctx context.Context, id interface{}, record types.Record, opts ...store.UpdateOption)) *MockCollectionInterface_UpdateRecord_Call {
 	_c.Call.Run(func(args mock.Arguments) {
-		run(args[0].(context.Context), args[1].(interface{}), args[2].(types.Record))
+		variadicArgs := make([]store.UpdateOption, len(args)-3)
+		for i, a := range args[3:] {
+			if a != nil {
+				variadicArgs[i] = a.(store.UpdateOption)
+			}
+		}
+		run(args[0].(context.Context), args[1].(interface{}), args[2].(types.Record), variadicArgs...)
 	})
 	return _c
 }
@@ -650,7 +977,68 @@ func (_c *MockCollectionInterface_UpdateRecord_Call) Return(_a0 *types.Record, _
 	return _c
 }
 
-func (_c *MockCollectionInterface_UpdateRecord_Call) RunAndReturn(run func(context.Context, interface{}, types.Record) (*types.Record, error)) *MockCollectionInterface_UpdateRecord_Call {
+func (_c *MockCollectionInterface_UpdateRecord_Call) RunAndReturn(run func(context.Context, interface{}, types.Record, ...store.UpdateOption) (*types.Record, error)) *MockCollectionInterface_UpdateRecord_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// Upsert provides a mock function with given fields: ctx, record, conflictColumns, updateColumns
+func (_m *MockCollectionInterface) Upsert(ctx context.Context, record types.Record, conflictColumns []string, updateColumns []string) (*types.Record, error) {
+	ret := _m.Called(ctx, record, conflictColumns, updateColumns)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Upsert")
+	}
+
+	var r0 *types.Record
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, types.Record, []string, []string) (*types.Record, error)); ok {
+		return rf(ctx, record, conflictColumns, updateColumns)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, types.Record, []string, []string) *types.Record); ok {
+		r0 = rf(ctx, record, conflictColumns, updateColumns)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*types.Record)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, types.Record, []string, []string) error); ok {
+		r1 = rf(ctx, record, conflictColumns, updateColumns)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// MockCollectionInterface_Upsert_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'Upsert'
+type MockCollectionInterface_Upsert_Call struct {
+	*mock.Call
+}
+
+// Upsert is a helper method to define mock.On call
+//   - ctx context.Context
+//   - record types.Record
+//   - conflictColumns []string
+//   - updateColumns []string
+func (_e *MockCollectionInterface_Expecter) Upsert(ctx interface{}, record interface{}, conflictColumns interface{}, updateColumns interface{}) *MockCollectionInterface_Upsert_Call {
+	return &MockCollectionInterface_Upsert_Call{Call: _e.mock.On("Upsert", ctx, record, conflictColumns, updateColumns)}
+}
+
+func (_c *MockCollectionInterface_Upsert_Call) Run(run func(ctx context.Context, record types.Record, conflictColumns []string, updateColumns []string)) *MockCollectionInterface_Upsert_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(types.Record), args[2].([]string), args[3].([]string))
+	})
+	return _c
+}
+
+func (_c *MockCollectionInterface_Upsert_Call) Return(_a0 *types.Record, _a1 error) *MockCollectionInterface_Upsert_Call {
+	_c.Call.Return(_a0, _a1)
+	return _c
+}
+
+func (_c *MockCollectionInterface_Upsert_Call) RunAndReturn(run func(context.Context, types.Record, []string, []string) (*types.Record, error)) *MockCollectionInterface_Upsert_Call {
 	_c.Call.Return(run)
 	return _c
 }
@@ -61,6 +61,54 @@ func (_c *MockInterface_AddEventHandler_Call) RunAndReturn(run func(events.Handl
 	return _c
 }
 
+// AutoFields provides a mock function with given fields: table
+func (_m *MockInterface) AutoFields(table string) *store.AutoFields {
+	ret := _m.Called(table)
+
+	if len(ret) == 0 {
+		panic("no return value specified for AutoFields")
+	}
+
+	var r0 *store.AutoFields
+	if rf, ok := ret.Get(0).(func(string) *store.AutoFields); ok {
+		r0 = rf(table)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*store.AutoFields)
+		}
+	}
+
+	return r0
+}
+
+// MockInterface_AutoFields_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'AutoFields'
+type MockInterface_AutoFields_Call struct {
+	*mock.Call
+}
+
+// AutoFields is a helper method to define mock.On call
+//   - table string
+func (_e *MockInterface_Expecter) AutoFields(table interface{}) *MockInterface_AutoFields_Call {
+	return &MockInterface_AutoFields_Call{Call: _e.mock.On("AutoFields", table)}
+}
+
+func (_c *MockInterface_AutoFields_Call) Run(run func(table string)) *MockInterface_AutoFields_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(string))
+	})
+	return _c
+}
+
+func (_c *MockInterface_AutoFields_Call) Return(_a0 *store.AutoFields) *MockInterface_AutoFields_Call {
+	_c.Call.Return(_a0)
+	return _c
+}
+
+func (_c *MockInterface_AutoFields_Call) RunAndReturn(run func(string) *store.AutoFields) *MockInterface_AutoFields_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
 // Close provides a mock function with no fields
 func (_m *MockInterface) Close() error {
 	ret := _m.Called()
@@ -201,6 +249,100 @@ func (_c *MockInterface_DB_Call) RunAndReturn(run func() *sqlx.DB) *MockInterfac
 	return _c
 }
 
+// EnableAutoFields provides a mock function with given fields: fields, tables
+func (_m *MockInterface) EnableAutoFields(fields store.AutoFields, tables ...string) {
+	_va := make([]interface{}, len(tables))
+	for _i := range tables {
+		_va[_i] = tables[_i]
+	}
+	var _ca []interface{}
+	_ca = append(_ca, fields)
+	_ca = append(_ca, _va...)
+	_m.Called(_ca...)
+}
+
+// MockInterface_EnableAutoFields_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'EnableAutoFields'
+type MockInterface_EnableAutoFields_Call struct {
+	*mock.Call
+}
+
+// EnableAutoFields is a helper method to define mock.On call
+//   - fields store.AutoFields
+//   - tables ...string
+func (_e *MockInterface_Expecter) EnableAutoFields(fields interface{}, tables ...interface{}) *MockInterface_EnableAutoFields_Call {
+	return &MockInterface_EnableAutoFields_Call{Call: _e.mock.On("EnableAutoFields",
+		append([]interface{}{fields}, tables...)...)}
+}
+
+func (_c *MockInterface_EnableAutoFields_Call) Run(run func(fields store.AutoFields, tables ...string)) *MockInterface_EnableAutoFields_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		variadicArgs := make([]string, len(args)-1)
+		for i, a := range args[1:] {
+			if a != nil {
+				variadicArgs[i] = a.(string)
+			}
+		}
+		run(args[0].(store.AutoFields), variadicArgs...)
+	})
+	return _c
+}
+
+func (_c *MockInterface_EnableAutoFields_Call) Return() *MockInterface_EnableAutoFields_Call {
+	_c.Call.Return()
+	return _c
+}
+
+func (_c *MockInterface_EnableAutoFields_Call) RunAndReturn(run func(store.AutoFields, ...string)) *MockInterface_EnableAutoFields_Call {
+	_c.Run(run)
+	return _c
+}
+
+// EnableSoftDelete provides a mock function with given fields: tables
+func (_m *MockInterface) EnableSoftDelete(tables ...string) {
+	_va := make([]interface{}, len(tables))
+	for _i := range tables {
+		_va[_i] = tables[_i]
+	}
+	var _ca []interface{}
+	_ca = append(_ca, _va...)
+	_m.Called(_ca...)
+}
+
+// MockInterface_EnableSoftDelete_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'EnableSoftDelete'
+type MockInterface_EnableSoftDelete_Call struct {
+	*mock.Call
+}
+
+// EnableSoftDelete is a helper method to define mock.On call
+//   - tables ...string
+func (_e *MockInterface_Expecter) EnableSoftDelete(tables ...interface{}) *MockInterface_EnableSoftDelete_Call {
+	return &MockInterface_EnableSoftDelete_Call{Call: _e.mock.On("EnableSoftDelete",
+		append([]interface{}{}, tables...)...)}
+}
+
+func (_c *MockInterface_EnableSoftDelete_Call) Run(run func(tables ...string)) *MockInterface_EnableSoftDelete_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		variadicArgs := make([]string, len(args)-0)
+		for i, a := range args[0:] {
+			if a != nil {
+				variadicArgs[i] = a.(string)
+			}
+		}
+		run(variadicArgs...)
+	})
+	return _c
+}
+
+func (_c *MockInterface_EnableSoftDelete_Call) Return() *MockInterface_EnableSoftDelete_Call {
+	_c.Call.Return()
+	return _c
+}
+
+func (_c *MockInterface_EnableSoftDelete_Call) RunAndReturn(run func(...string)) *MockInterface_EnableSoftDelete_Call {
+	_c.Run(run)
+	return _c
+}
+
 // Exec provides a mock function with given fields: ctx, query, args
 func (_m *MockInterface) Exec(ctx context.Context, query string, args ...interface{}) error {
 	var _ca []interface{}
@@ -259,6 +401,54 @@ func (_c *MockInterface_Exec_Call) RunAndReturn(run func(context.Context, string
 	return _c
 }
 
+// ForOrganisation provides a mock function with given fields: organisationID
+func (_m *MockInterface) ForOrganisation(organisationID string) *store.TenantStore {
+	ret := _m.Called(organisationID)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ForOrganisation")
+	}
+
+	var r0 *store.TenantStore
+	if rf, ok := ret.Get(0).(func(string) *store.TenantStore); ok {
+		r0 = rf(organisationID)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*store.TenantStore)
+		}
+	}
+
+	return r0
+}
+
+// MockInterface_ForOrganisation_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'ForOrganisation'
+type MockInterface_ForOrganisation_Call struct {
+	*mock.Call
+}
+
+// ForOrganisation is a helper method to define mock.On call
+//   - organisationID string
+func (_e *MockInterface_Expecter) ForOrganisation(organisationID interface{}) *MockInterface_ForOrganisation_Call {
+	return &MockInterface_ForOrganisation_Call{Call: _e.mock.On("ForOrganisation", organisationID)}
+}
+
+func (_c *MockInterface_ForOrganisation_Call) Run(run func(organisationID string)) *MockInterface_ForOrganisation_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(string))
+	})
+	return _c
+}
+
+func (_c *MockInterface_ForOrganisation_Call) Return(_a0 *store.TenantStore) *MockInterface_ForOrganisation_Call {
+	_c.Call.Return(_a0)
+	return _c
+}
+
+func (_c *MockInterface_ForOrganisation_Call) RunAndReturn(run func(string) *store.TenantStore) *MockInterface_ForOrganisation_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
 // OnAfterRecordCreated provides a mock function with given fields: ctx, table, record
 func (_m *MockInterface) OnAfterRecordCreated(ctx context.Context, table string, record types.Record) error {
 	ret := _m.Called(ctx, table, record)
@@ -355,6 +545,102 @@ func (_c *MockInterface_OnAfterRecordDeleted_Call) RunAndReturn(run func(context
 	return _c
 }
 
+// OnAfterRecordPurged provides a mock function with given fields: ctx, table, record
+func (_m *MockInterface) OnAfterRecordPurged(ctx context.Context, table string, record types.Record) error {
+	ret := _m.Called(ctx, table, record)
+
+	if len(ret) == 0 {
+		panic("no return value specified for OnAfterRecordPurged")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, types.Record) error); ok {
+		r0 = rf(ctx, table, record)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// MockInterface_OnAfterRecordPurged_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'OnAfterRecordPurged'
+type MockInterface_OnAfterRecordPurged_Call struct {
+	*mock.Call
+}
+
+// OnAfterRecordPurged is a helper method to define mock.On call
+//   - ctx context.Context
+//   - table string
+//   - record types.Record
+func (_e *MockInterface_Expecter) OnAfterRecordPurged(ctx interface{}, table interface{}, record interface{}) *MockInterface_OnAfterRecordPurged_Call {
+	return &MockInterface_OnAfterRecordPurged_Call{Call: _e.mock.On("OnAfterRecordPurged", ctx, table, record)}
+}
+
+func (_c *MockInterface_OnAfterRecordPurged_Call) Run(run func(ctx context.Context, table string, record types.Record)) *MockInterface_OnAfterRecordPurged_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(string), args[2].(types.Record))
+	})
+	return _c
+}
+
+func (_c *MockInterface_OnAfterRecordPurged_Call) Return(_a0 error) *MockInterface_OnAfterRecordPurged_Call {
+	_c.Call.Return(_a0)
+	return _c
+}
+
+func (_c *MockInterface_OnAfterRecordPurged_Call) RunAndReturn(run func(context.Context, string, types.Record) error) *MockInterface_OnAfterRecordPurged_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// OnAfterRecordRestored provides a mock function with given fields: ctx, table, record
+func (_m *MockInterface) OnAfterRecordRestored(ctx context.Context, table string, record types.Record) error {
+	ret := _m.Called(ctx, table, record)
+
+	if len(ret) == 0 {
+		panic("no return value specified for OnAfterRecordRestored")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, types.Record) error); ok {
+		r0 = rf(ctx, table, record)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// MockInterface_OnAfterRecordRestored_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'OnAfterRecordRestored'
+type MockInterface_OnAfterRecordRestored_Call struct {
+	*mock.Call
+}
+
+// OnAfterRecordRestored is a helper method to define mock.On call
+//   - ctx context.Context
+//   - table string
+//   - record types.Record
+func (_e *MockInterface_Expecter) OnAfterRecordRestored(ctx interface{}, table interface{}, record interface{}) *MockInterface_OnAfterRecordRestored_Call {
+	return &MockInterface_OnAfterRecordRestored_Call{Call: _e.mock.On("OnAfterRecordRestored", ctx, table, record)}
+}
+
+func (_c *MockInterface_OnAfterRecordRestored_Call) Run(run func(ctx context.Context, table string, record types.Record)) *MockInterface_OnAfterRecordRestored_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(string), args[2].(types.Record))
+	})
+	return _c
+}
+
+func (_c *MockInterface_OnAfterRecordRestored_Call) Return(_a0 error) *MockInterface_OnAfterRecordRestored_Call {
+	_c.Call.Return(_a0)
+	return _c
+}
+
+func (_c *MockInterface_OnAfterRecordRestored_Call) RunAndReturn(run func(context.Context, string, types.Record) error) *MockInterface_OnAfterRecordRestored_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
 // OnAfterRecordUpdated provides a mock function with given fields: ctx, table, record, oldRecord
 func (_m *MockInterface) OnAfterRecordUpdated(ctx context.Context, table string, record types.Record, oldRecord types.Record) error {
 	ret := _m.Called(ctx, table, record, oldRecord)
@@ -500,6 +786,102 @@ func (_c *MockInterface_OnBeforeRecordDeleted_Call) RunAndReturn(run func(contex
 	return _c
 }
 
+// OnBeforeRecordPurged provides a mock function with given fields: ctx, table, record
+func (_m *MockInterface) OnBeforeRecordPurged(ctx context.Context, table string, record types.Record) error {
+	ret := _m.Called(ctx, table, record)
+
+	if len(ret) == 0 {
+		panic("no return value specified for OnBeforeRecordPurged")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, types.Record) error); ok {
+		r0 = rf(ctx, table, record)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// MockInterface_OnBeforeRecordPurged_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'OnBeforeRecordPurged'
+type MockInterface_OnBeforeRecordPurged_Call struct {
+	*mock.Call
+}
+
+// OnBeforeRecordPurged is a helper method to define mock.On call
+//   - ctx context.Context
+//   - table string
+//   - record types.Record
+func (_e *MockInterface_Expecter) OnBeforeRecordPurged(ctx interface{}, table interface{}, record interface{}) *MockInterface_OnBeforeRecordPurged_Call {
+	return &MockInterface_OnBeforeRecordPurged_Call{Call: _e.mock.On("OnBeforeRecordPurged", ctx, table, record)}
+}
+
+func (_c *MockInterface_OnBeforeRecordPurged_Call) Run(run func(ctx context.Context, table string, record types.Record)) *MockInterface_OnBeforeRecordPurged_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(string), args[2].(types.Record))
+	})
+	return _c
+}
+
+func (_c *MockInterface_OnBeforeRecordPurged_Call) Return(_a0 error) *MockInterface_OnBeforeRecordPurged_Call {
+	_c.Call.Return(_a0)
+	return _c
+}
+
+func (_c *MockInterface_OnBeforeRecordPurged_Call) RunAndReturn(run func(context.Context, string, types.Record) error) *MockInterface_OnBeforeRecordPurged_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// OnBeforeRecordRestored provides a mock function with given fields: ctx, table, record
+func (_m *MockInterface) OnBeforeRecordRestored(ctx context.Context, table string, record types.Record) error {
+	ret := _m.Called(ctx, table, record)
+
+	if len(ret) == 0 {
+		panic("no return value specified for OnBeforeRecordRestored")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, types.Record) error); ok {
+		r0 = rf(ctx, table, record)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// MockInterface_OnBeforeRecordRestored_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'OnBeforeRecordRestored'
+type MockInterface_OnBeforeRecordRestored_Call struct {
+	*mock.Call
+}
+
+// OnBeforeRecordRestored is a helper method to define mock.On call
+//   - ctx context.Context
+//   - table string
+//   - record types.Record
+func (_e *MockInterface_Expecter) OnBeforeRecordRestored(ctx interface{}, table interface{}, record interface{}) *MockInterface_OnBeforeRecordRestored_Call {
+	return &MockInterface_OnBeforeRecordRestored_Call{Call: _e.mock.On("OnBeforeRecordRestored", ctx, table, record)}
+}
+
+func (_c *MockInterface_OnBeforeRecordRestored_Call) Run(run func(ctx context.Context, table string, record types.Record)) *MockInterface_OnBeforeRecordRestored_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(string), args[2].(types.Record))
+	})
+	return _c
+}
+
+func (_c *MockInterface_OnBeforeRecordRestored_Call) Return(_a0 error) *MockInterface_OnBeforeRecordRestored_Call {
+	_c.Call.Return(_a0)
+	return _c
+}
+
+func (_c *MockInterface_OnBeforeRecordRestored_Call) RunAndReturn(run func(context.Context, string, types.Record) error) *MockInterface_OnBeforeRecordRestored_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
 // OnBeforeRecordUpdated provides a mock function with given fields: ctx, table, record, oldRecord
 func (_m *MockInterface) OnBeforeRecordUpdated(ctx context.Context, table string, record types.Record, oldRecord types.Record) error {
 	ret := _m.Called(ctx, table, record, oldRecord)
@@ -549,6 +931,328 @@ func (_c *MockInterface_OnBeforeRecordUpdated_Call) RunAndReturn(run func(contex
 	return _c
 }
 
+// RegisterRelations provides a mock function with given fields: table, relations
+func (_m *MockInterface) RegisterRelations(table string, relations ...store.Relation) {
+	_va := make([]interface{}, len(relations))
+	for _i := range relations {
+		_va[_i] = relations[_i]
+	}
+	var _ca []interface{}
+	_ca = append(_ca, table)
+	_ca = append(_ca, _va...)
+	_m.Called(_ca...)
+}
+
+// MockInterface_RegisterRelations_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'RegisterRelations'
+type MockInterface_RegisterRelations_Call struct {
+	*mock.Call
+}
+
+// RegisterRelations is a helper method to define mock.On call
+//   - table string
+//   - relations ...store.Relation
+func (_e *MockInterface_Expecter) RegisterRelations(table interface{}, relations ...interface{}) *MockInterface_RegisterRelations_Call {
+	return &MockInterface_RegisterRelations_Call{Call: _e.mock.On("RegisterRelations",
+		append([]interface{}{table}, relations...)...)}
+}
+
+func (_c *MockInterface_RegisterRelations_Call) Run(run func(table string, relations ...store.Relation)) *MockInterface_RegisterRelations_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		variadicArgs := make([]store.Relation, len(args)-1)
+		for i, a := range args[1:] {
+			if a != nil {
+				variadicArgs[i] = a.(store.Relation)
+			}
+		}
+		run(args[0].(string), variadicArgs...)
+	})
+	return _c
+}
+
+func (_c *MockInterface_RegisterRelations_Call) Return() *MockInterface_RegisterRelations_Call {
+	_c.Call.Return()
+	return _c
+}
+
+func (_c *MockInterface_RegisterRelations_Call) RunAndReturn(run func(string, ...store.Relation)) *MockInterface_RegisterRelations_Call {
+	_c.Run(run)
+	return _c
+}
+
+// RegisterSchema provides a mock function with given fields: schema
+func (_m *MockInterface) RegisterSchema(schema *store.Schema) {
+	_m.Called(schema)
+}
+
+// MockInterface_RegisterSchema_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'RegisterSchema'
+type MockInterface_RegisterSchema_Call struct {
+	*mock.Call
+}
+
+// RegisterSchema is a helper method to define mock.On call
+//   - schema *store.Schema
+func (_e *MockInterface_Expecter) RegisterSchema(schema interface{}) *MockInterface_RegisterSchema_Call {
+	return &MockInterface_RegisterSchema_Call{Call: _e.mock.On("RegisterSchema", schema)}
+}
+
+func (_c *MockInterface_RegisterSchema_Call) Run(run func(schema *store.Schema)) *MockInterface_RegisterSchema_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(*store.Schema))
+	})
+	return _c
+}
+
+func (_c *MockInterface_RegisterSchema_Call) Return() *MockInterface_RegisterSchema_Call {
+	_c.Call.Return()
+	return _c
+}
+
+func (_c *MockInterface_RegisterSchema_Call) RunAndReturn(run func(*store.Schema)) *MockInterface_RegisterSchema_Call {
+	_c.Run(run)
+	return _c
+}
+
+// RegisterSearch provides a mock function with given fields: table, index
+func (_m *MockInterface) RegisterSearch(table string, index store.SearchIndex) {
+	_m.Called(table, index)
+}
+
+// MockInterface_RegisterSearch_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'RegisterSearch'
+type MockInterface_RegisterSearch_Call struct {
+	*mock.Call
+}
+
+// RegisterSearch is a helper method to define mock.On call
+//   - table string
+//   - index store.SearchIndex
+func (_e *MockInterface_Expecter) RegisterSearch(table interface{}, index interface{}) *MockInterface_RegisterSearch_Call {
+	return &MockInterface_RegisterSearch_Call{Call: _e.mock.On("RegisterSearch", table, index)}
+}
+
+func (_c *MockInterface_RegisterSearch_Call) Run(run func(table string, index store.SearchIndex)) *MockInterface_RegisterSearch_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(string), args[1].(store.SearchIndex))
+	})
+	return _c
+}
+
+func (_c *MockInterface_RegisterSearch_Call) Return() *MockInterface_RegisterSearch_Call {
+	_c.Call.Return()
+	return _c
+}
+
+func (_c *MockInterface_RegisterSearch_Call) RunAndReturn(run func(string, store.SearchIndex)) *MockInterface_RegisterSearch_Call {
+	_c.Run(run)
+	return _c
+}
+
+// Relation provides a mock function with given fields: table, name
+func (_m *MockInterface) Relation(table string, name string) (store.Relation, bool) {
+	ret := _m.Called(table, name)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Relation")
+	}
+
+	var r0 store.Relation
+	var r1 bool
+	if rf, ok := ret.Get(0).(func(string, string) (store.Relation, bool)); ok {
+		return rf(table, name)
+	}
+	if rf, ok := ret.Get(0).(func(string, string) store.Relation); ok {
+		r0 = rf(table, name)
+	} else {
+		r0 = ret.Get(0).(store.Relation)
+	}
+
+	if rf, ok := ret.Get(1).(func(string, string) bool); ok {
+		r1 = rf(table, name)
+	} else {
+		r1 = ret.Get(1).(bool)
+	}
+
+	return r0, r1
+}
+
+// MockInterface_Relation_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'Relation'
+type MockInterface_Relation_Call struct {
+	*mock.Call
+}
+
+// Relation is a helper method to define mock.On call
+//   - table string
+//   - name string
+func (_e *MockInterface_Expecter) Relation(table interface{}, name interface{}) *MockInterface_Relation_Call {
+	return &MockInterface_Relation_Call{Call: _e.mock.On("Relation", table, name)}
+}
+
+func (_c *MockInterface_Relation_Call) Run(run func(table string, name string)) *MockInterface_Relation_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(string), args[1].(string))
+	})
+	return _c
+}
+
+func (_c *MockInterface_Relation_Call) Return(_a0 store.Relation, _a1 bool) *MockInterface_Relation_Call {
+	_c.Call.Return(_a0, _a1)
+	return _c
+}
+
+func (_c *MockInterface_Relation_Call) RunAndReturn(run func(string, string) (store.Relation, bool)) *MockInterface_Relation_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// Schema provides a mock function with given fields: table
+func (_m *MockInterface) Schema(table string) *store.Schema {
+	ret := _m.Called(table)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Schema")
+	}
+
+	var r0 *store.Schema
+	if rf, ok := ret.Get(0).(func(string) *store.Schema); ok {
+		r0 = rf(table)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*store.Schema)
+		}
+	}
+
+	return r0
+}
+
+// MockInterface_Schema_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'Schema'
+type MockInterface_Schema_Call struct {
+	*mock.Call
+}
+
+// Schema is a helper method to define mock.On call
+//   - table string
+func (_e *MockInterface_Expecter) Schema(table interface{}) *MockInterface_Schema_Call {
+	return &MockInterface_Schema_Call{Call: _e.mock.On("Schema", table)}
+}
+
+func (_c *MockInterface_Schema_Call) Run(run func(table string)) *MockInterface_Schema_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(string))
+	})
+	return _c
+}
+
+func (_c *MockInterface_Schema_Call) Return(_a0 *store.Schema) *MockInterface_Schema_Call {
+	_c.Call.Return(_a0)
+	return _c
+}
+
+func (_c *MockInterface_Schema_Call) RunAndReturn(run func(string) *store.Schema) *MockInterface_Schema_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// SearchIndex provides a mock function with given fields: table
+func (_m *MockInterface) SearchIndex(table string) (store.SearchIndex, bool) {
+	ret := _m.Called(table)
+
+	if len(ret) == 0 {
+		panic("no return value specified for SearchIndex")
+	}
+
+	var r0 store.SearchIndex
+	var r1 bool
+	if rf, ok := ret.Get(0).(func(string) (store.SearchIndex, bool)); ok {
+		return rf(table)
+	}
+	if rf, ok := ret.Get(0).(func(string) store.SearchIndex); ok {
+		r0 = rf(table)
+	} else {
+		r0 = ret.Get(0).(store.SearchIndex)
+	}
+
+	if rf, ok := ret.Get(1).(func(string) bool); ok {
+		r1 = rf(table)
+	} else {
+		r1 = ret.Get(1).(bool)
+	}
+
+	return r0, r1
+}
+
+// MockInterface_SearchIndex_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'SearchIndex'
+type MockInterface_SearchIndex_Call struct {
+	*mock.Call
+}
+
+// SearchIndex is a helper method to define mock.On call
+//   - table string
+func (_e *MockInterface_Expecter) SearchIndex(table interface{}) *MockInterface_SearchIndex_Call {
+	return &MockInterface_SearchIndex_Call{Call: _e.mock.On("SearchIndex", table)}
+}
+
+func (_c *MockInterface_SearchIndex_Call) Run(run func(table string)) *MockInterface_SearchIndex_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(string))
+	})
+	return _c
+}
+
+func (_c *MockInterface_SearchIndex_Call) Return(_a0 store.SearchIndex, _a1 bool) *MockInterface_SearchIndex_Call {
+	_c.Call.Return(_a0, _a1)
+	return _c
+}
+
+func (_c *MockInterface_SearchIndex_Call) RunAndReturn(run func(string) (store.SearchIndex, bool)) *MockInterface_SearchIndex_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// SoftDeleteEnabled provides a mock function with given fields: table
+func (_m *MockInterface) SoftDeleteEnabled(table string) bool {
+	ret := _m.Called(table)
+
+	if len(ret) == 0 {
+		panic("no return value specified for SoftDeleteEnabled")
+	}
+
+	var r0 bool
+	if rf, ok := ret.Get(0).(func(string) bool); ok {
+		r0 = rf(table)
+	} else {
+		r0 = ret.Get(0).(bool)
+	}
+
+	return r0
+}
+
+// MockInterface_SoftDeleteEnabled_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'SoftDeleteEnabled'
+type MockInterface_SoftDeleteEnabled_Call struct {
+	*mock.Call
+}
+
+// SoftDeleteEnabled is a helper method to define mock.On call
+//   - table string
+func (_e *MockInterface_Expecter) SoftDeleteEnabled(table interface{}) *MockInterface_SoftDeleteEnabled_Call {
+	return &MockInterface_SoftDeleteEnabled_Call{Call: _e.mock.On("SoftDeleteEnabled", table)}
+}
+
+func (_c *MockInterface_SoftDeleteEnabled_Call) Run(run func(table string)) *MockInterface_SoftDeleteEnabled_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(string))
+	})
+	return _c
+}
+
+func (_c *MockInterface_SoftDeleteEnabled_Call) Return(_a0 bool) *MockInterface_SoftDeleteEnabled_Call {
+	_c.Call.Return(_a0)
+	return _c
+}
+
+func (_c *MockInterface_SoftDeleteEnabled_Call) RunAndReturn(run func(string) bool) *MockInterface_SoftDeleteEnabled_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
 // Tx provides a mock function with given fields: ctx
 func (_m *MockInterface) Tx(ctx context.Context) (*store.StoreTx, error) {
 	ret := _m.Called(ctx)
@@ -607,6 +1311,54 @@ func (_c *MockInterface_Tx_Call) RunAndReturn(run func(context.Context) (*store.
 	return _c
 }
 
+// WithTx provides a mock function with given fields: ctx, opts, fn
+func (_m *MockInterface) WithTx(ctx context.Context, opts *store.TxOptions, fn func(*store.StoreTx) error) error {
+	ret := _m.Called(ctx, opts, fn)
+
+	if len(ret) == 0 {
+		panic("no return value specified for WithTx")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, *store.TxOptions, func(*store.StoreTx) error) error); ok {
+		r0 = rf(ctx, opts, fn)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// MockInterface_WithTx_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'WithTx'
+type MockInterface_WithTx_Call struct {
+	*mock.Call
+}
+
+// WithTx is a helper method to define mock.On call
+//   - ctx context.Context
+//   - opts *store.TxOptions
+//   - fn func(*store.StoreTx) error
+func (_e *MockInterface_Expecter) WithTx(ctx interface{}, opts interface{}, fn interface{}) *MockInterface_WithTx_Call {
+	return &MockInterface_WithTx_Call{Call: _e.mock.On("WithTx", ctx, opts, fn)}
+}
+
+func (_c *MockInterface_WithTx_Call) Run(run func(ctx context.Context, opts *store.TxOptions, fn func(*store.StoreTx) error)) *MockInterface_WithTx_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(*store.TxOptions), args[2].(func(*store.StoreTx) error))
+	})
+	return _c
+}
+
+func (_c *MockInterface_WithTx_Call) Return(_a0 error) *MockInterface_WithTx_Call {
+	_c.Call.Return(_a0)
+	return _c
+}
+
+func (_c *MockInterface_WithTx_Call) RunAndReturn(run func(context.Context, *store.TxOptions, func(*store.StoreTx) error) error) *MockInterface_WithTx_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
 // NewMockInterface creates a new instance of MockInterface. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
 // The first argument is typically a *testing.T value.
 func NewMockInterface(t interface {